@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -22,19 +24,26 @@ import (
 	"github.com/yanqian/ai-helloworld/internal/domain/uploadask"
 	"github.com/yanqian/ai-helloworld/internal/domain/uvadvisor"
 	"github.com/yanqian/ai-helloworld/internal/infra/config"
+	"github.com/yanqian/ai-helloworld/internal/infra/embeddingcache"
 	"github.com/yanqian/ai-helloworld/internal/infra/faqrepo"
 	"github.com/yanqian/ai-helloworld/internal/infra/faqstore"
 	"github.com/yanqian/ai-helloworld/internal/infra/llm/chatgpt"
 	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
 	uploadchunker "github.com/yanqian/ai-helloworld/internal/infra/uploadask/chunker"
 	uploadembedder "github.com/yanqian/ai-helloworld/internal/infra/uploadask/embedder"
+	uploadextractor "github.com/yanqian/ai-helloworld/internal/infra/uploadask/extractor"
+	uploadfetcher "github.com/yanqian/ai-helloworld/internal/infra/uploadask/fetcher"
 	uploadllm "github.com/yanqian/ai-helloworld/internal/infra/uploadask/llm"
 	uploadmemory "github.com/yanqian/ai-helloworld/internal/infra/uploadask/memory"
 	uploadqueue "github.com/yanqian/ai-helloworld/internal/infra/uploadask/queue"
 	uploadrepo "github.com/yanqian/ai-helloworld/internal/infra/uploadask/repo"
+	uploadreranker "github.com/yanqian/ai-helloworld/internal/infra/uploadask/reranker"
 	uploadstorage "github.com/yanqian/ai-helloworld/internal/infra/uploadask/storage"
+	"github.com/yanqian/ai-helloworld/internal/infra/uploadask/vectorindex"
 	"github.com/yanqian/ai-helloworld/internal/infra/userrepo"
 	"github.com/yanqian/ai-helloworld/internal/infra/uv/datagov"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
 )
 
 func provideSummaryConfig(cfg *config.Config) summarizer.Config {
@@ -232,10 +241,26 @@ func provideUploadAskConfig(cfg *config.Config) uploadask.Config {
 		memCfg.PruneLimit = 200
 	}
 	return uploadask.Config{
-		VectorDim:       cfg.UploadAsk.VectorDim,
-		MaxFileBytes:    int64(cfg.UploadAsk.MaxFileMB) * 1024 * 1024,
-		MaxRetrieved:    8,
-		MaxPreviewChars: cfg.UploadAsk.MaxPreviewChars,
+		VectorDim:           cfg.UploadAsk.VectorDim,
+		MaxFileBytes:        int64(cfg.UploadAsk.MaxFileMB) * 1024 * 1024,
+		MaxRetrieved:        8,
+		MaxPreviewChars:     cfg.UploadAsk.MaxPreviewChars,
+		RetrievalMode:       uploadask.RetrievalMode(cfg.UploadAsk.RetrievalMode),
+		RerankCandidates:    cfg.UploadAsk.Rerank.Candidates,
+		EmbeddingModel:      uploadEmbeddingModel(cfg),
+		IndexVersion:        uploadIndexVersion(cfg),
+		EmbedBatchSize:      cfg.UploadAsk.EmbedBatchSize,
+		EmbedConcurrency:    cfg.UploadAsk.EmbedRetry.Concurrency,
+		EmbedMaxAttempts:    cfg.UploadAsk.EmbedRetry.MaxAttempts,
+		EmbedBaseBackoff:    cfg.UploadAsk.EmbedRetry.BaseBackoff,
+		RecoveryStaleAfter:  cfg.UploadAsk.Recovery.StaleAfter,
+		RecoveryMaxAttempts: cfg.UploadAsk.Recovery.MaxAttempts,
+		DirectUploadTTL:     cfg.UploadAsk.DirectUpload.URLTTL,
+		Quota: uploadask.QuotaConfig{
+			MaxBytes:     int64(cfg.UploadAsk.Quota.MaxTotalMB) * 1024 * 1024,
+			MaxDocuments: cfg.UploadAsk.Quota.MaxDocuments,
+			MaxChunks:    cfg.UploadAsk.Quota.MaxChunks,
+		},
 		Memory: uploadask.MemoryConfig{
 			Enabled:            memCfg.Enabled,
 			TopKMems:           memCfg.TopKMems,
@@ -247,37 +272,127 @@ func provideUploadAskConfig(cfg *config.Config) uploadask.Config {
 	}
 }
 
+// directUploadPath is where the HTTP router receives presigned local uploads.
+const directUploadPath = "/api/v1/upload-ask/direct-uploads"
+
+// directUploadSigningKey signs presigned local upload URLs. Without a
+// configured key one is derived from the JWT secret, so restarts and other
+// instances sharing it accept the same URLs.
+func directUploadSigningKey(cfg *config.Config) []byte {
+	if key := strings.TrimSpace(cfg.UploadAsk.DirectUpload.SigningKey); key != "" {
+		return []byte(key)
+	}
+	sum := sha256.Sum256([]byte("uploadask-direct-upload\n" + cfg.Auth.JWTSecret))
+	return sum[:]
+}
+
 func provideUploadStorage(cfg *config.Config, logger *slog.Logger) uploadask.ObjectStorage {
-	endpoint := strings.TrimSpace(cfg.UploadAsk.Storage.Endpoint)
-	accessKey := strings.TrimSpace(cfg.UploadAsk.Storage.AccessKey)
-	secretKey := strings.TrimSpace(cfg.UploadAsk.Storage.SecretKey)
-	bucket := strings.TrimSpace(cfg.UploadAsk.Storage.Bucket)
-	region := strings.TrimSpace(cfg.UploadAsk.Storage.Region)
-
-	if endpoint == "" || accessKey == "" || secretKey == "" || bucket == "" {
-		logger.Info("uploadask storage not fully configured, using memory storage")
-		return uploadstorage.NewMemoryStorage()
+	storageCfg := cfg.UploadAsk.Storage
+	endpoint := strings.TrimSpace(storageCfg.Endpoint)
+	accessKey := strings.TrimSpace(storageCfg.AccessKey)
+	secretKey := strings.TrimSpace(storageCfg.SecretKey)
+	bucket := strings.TrimSpace(storageCfg.Bucket)
+	region := strings.TrimSpace(storageCfg.Region)
+	r2Configured := endpoint != "" && accessKey != "" && secretKey != "" && bucket != ""
+
+	driver := storageCfg.Driver
+	if driver == "" {
+		driver = "local"
+		if r2Configured {
+			driver = "r2"
+		}
 	}
-	r2, err := uploadstorage.NewR2Storage(endpoint, accessKey, secretKey, bucket, region, logger)
-	if err != nil {
-		logger.Error("failed to initialize r2 storage, using memory storage", "error", err)
+	switch driver {
+	case "memory":
+		logger.Info("uploadask memory storage enabled; uploads are lost on restart")
 		return uploadstorage.NewMemoryStorage()
+	case "r2":
+		if !r2Configured {
+			logger.Info("uploadask storage not fully configured, using memory storage")
+			return uploadstorage.NewMemoryStorage()
+		}
+		r2, err := uploadstorage.NewR2Storage(endpoint, accessKey, secretKey, bucket, region, logger)
+		if err != nil {
+			logger.Error("failed to initialize r2 storage, using memory storage", "error", err)
+			return uploadstorage.NewMemoryStorage()
+		}
+		logger.Info("uploadask r2 storage enabled", "endpoint", endpoint, "bucket", bucket)
+		return r2
+	default:
+		uploadURL := strings.TrimRight(cfg.UploadAsk.DirectUpload.PublicBaseURL, "/") + directUploadPath
+		local, err := uploadstorage.NewLocalStorage(storageCfg.LocalPath, uploadURL, directUploadSigningKey(cfg))
+		if err != nil {
+			logger.Error("failed to initialize local storage, using memory storage", "path", storageCfg.LocalPath, "error", err)
+			return uploadstorage.NewMemoryStorage()
+		}
+		logger.Info("uploadask local storage enabled", "path", storageCfg.LocalPath)
+		return local
+	}
+}
+
+// uploadEmbeddingModel names the embedder provideUploadEmbedder builds.
+func uploadEmbeddingModel(cfg *config.Config) string {
+	if model := strings.TrimSpace(cfg.LLM.EmbeddingModel); model != "" {
+		return model
+	}
+	return "deterministic"
+}
+
+// uploadIndexVersion captures the settings that change chunk boundaries or
+// vector shape. Chunks stamped with another version are reported as stale.
+func uploadIndexVersion(cfg *config.Config) string {
+	chunkCfg := cfg.UploadAsk.Chunker
+	return fmt.Sprintf("dim=%d;chunker=%s/%d/%d", cfg.UploadAsk.VectorDim, chunkCfg.Strategy, chunkCfg.MaxTokens, chunkCfg.Overlap)
+}
+
+func provideEmbeddingCache(cfg *config.Config, logger *slog.Logger) *embeddingcache.Cache {
+	cacheCfg := cfg.LLM.EmbeddingCache
+	if !cacheCfg.Enabled {
+		logger.Info("embedding cache disabled")
+		return nil
 	}
-	logger.Info("uploadask r2 storage enabled", "endpoint", endpoint, "bucket", bucket)
-	return r2
+	var store embeddingcache.Store
+	if db := sqliteDB(cfg, logger); db != nil {
+		store = embeddingcache.NewSQLiteStore(db, cacheCfg.MaxEntries, cacheCfg.TTL)
+	}
+	logger.Info("embedding cache enabled", "persistent", store != nil, "lru_size", cacheCfg.LRUSize, "max_entries", cacheCfg.MaxEntries, "ttl", cacheCfg.TTL)
+	return embeddingcache.New(store, cacheCfg.LRUSize, logger)
 }
 
-func provideUploadEmbedder(client *chatgpt.Client, cfg *config.Config, logger *slog.Logger) uploadask.Embedder {
+func provideFAQChatClient(client *chatgpt.Client, cache *embeddingcache.Cache) faq.ChatClient {
+	if cache == nil {
+		return client
+	}
+	return embeddingcache.NewChatClient(client, cache)
+}
+
+func provideUploadEmbedder(client *chatgpt.Client, cfg *config.Config, cache *embeddingcache.Cache, logger *slog.Logger) uploadask.Embedder {
 	model := strings.TrimSpace(cfg.LLM.EmbeddingModel)
 	if client != nil && model != "" {
-		return uploadembedder.NewChatGPTEmbedder(client, model, logger)
+		embedder := uploadask.Embedder(uploadembedder.NewChatGPTEmbedder(client, model, logger))
+		if cache != nil {
+			embedder = embeddingcache.NewEmbedder(embedder, model, cfg.UploadAsk.VectorDim, cache)
+		}
+		return embedder
 	}
 	logger.Warn("embedding client unavailable, using deterministic embedder")
 	return uploadembedder.NewDeterministicEmbedder(cfg.UploadAsk.VectorDim)
 }
 
-func provideUploadChunker() uploadask.Chunker {
-	return uploadchunker.NewSimpleChunker(800, 80)
+func provideUploadChunker(cfg *config.Config) uploadask.Chunker {
+	chunkCfg := cfg.UploadAsk.Chunker
+	if chunkCfg.Strategy == "structured" {
+		return uploadchunker.NewStructuredChunker(chunkCfg.MaxTokens)
+	}
+	return uploadchunker.NewSimpleChunker(chunkCfg.MaxTokens, chunkCfg.Overlap)
+}
+
+func provideUploadExtractor(cfg *config.Config) uploadask.TextExtractor {
+	return uploadextractor.NewRegistry(int64(cfg.UploadAsk.MaxFileMB) * 1024 * 1024)
+}
+
+func provideUploadFetcher(cfg *config.Config) uploadask.URLFetcher {
+	return uploadfetcher.NewHTTPFetcher(cfg.UploadAsk.URLFetch.Timeout, cfg.UploadAsk.URLFetch.AllowPrivateNetworks)
 }
 
 func provideUploadDocumentRepository(cfg *config.Config, logger *slog.Logger) uploadask.DocumentRepository {
@@ -306,10 +421,75 @@ func provideUploadFileRepository(cfg *config.Config, logger *slog.Logger) upload
 	return uploadrepo.NewMemoryFileRepository()
 }
 
+func provideUploadIntentRepository(cfg *config.Config, logger *slog.Logger) uploadask.UploadIntentRepository {
+	if db := sqliteDB(cfg, logger); db != nil {
+		logger.Info("uploadask sqlite upload intent repository enabled", "path", cfg.SQLite.Path)
+		return uploadrepo.NewSQLiteUploadIntentRepository(db)
+	}
+	pool := uploadPostgresPool(cfg, logger)
+	if pool != nil {
+		return uploadrepo.NewPostgresUploadIntentRepository(pool)
+	}
+	logger.Warn("uploadask upload intent repository falling back to memory")
+	return uploadrepo.NewMemoryUploadIntentRepository()
+}
+
+func provideUploadCollectionRepository(cfg *config.Config, logger *slog.Logger) uploadask.CollectionRepository {
+	if db := sqliteDB(cfg, logger); db != nil {
+		logger.Info("uploadask sqlite collection repository enabled", "path", cfg.SQLite.Path)
+		return uploadrepo.NewSQLiteCollectionRepository(db)
+	}
+	pool := uploadPostgresPool(cfg, logger)
+	if pool != nil {
+		return uploadrepo.NewPostgresCollectionRepository(pool)
+	}
+	logger.Warn("uploadask collection repository falling back to memory")
+	return uploadrepo.NewMemoryCollectionRepository()
+}
+
+func provideUploadShareRepository(cfg *config.Config, logger *slog.Logger) uploadask.ShareRepository {
+	if db := sqliteDB(cfg, logger); db != nil {
+		logger.Info("uploadask sqlite share repository enabled", "path", cfg.SQLite.Path)
+		return uploadrepo.NewSQLiteShareRepository(db)
+	}
+	pool := uploadPostgresPool(cfg, logger)
+	if pool != nil {
+		return uploadrepo.NewPostgresShareRepository(pool)
+	}
+	logger.Warn("uploadask share repository falling back to memory")
+	return uploadrepo.NewMemoryShareRepository()
+}
+
+// uploadVectorIndex opens the HNSW index for SQLite chunk search. Worker
+// processes never search, so they leave it to the server.
+func uploadVectorIndex(cfg *config.Config, logger *slog.Logger) *vectorindex.Index {
+	indexCfg := cfg.UploadAsk.VectorIndex
+	if !indexCfg.Enabled || cfg.Mode == config.ModeWorker {
+		return nil
+	}
+	path := strings.TrimSpace(indexCfg.Path)
+	if path == "" && cfg.SQLite.Path != ":memory:" && !strings.HasPrefix(cfg.SQLite.Path, "file:") {
+		path = cfg.SQLite.Path + ".hnsw"
+	}
+	opts := vectorindex.Options{
+		M:              indexCfg.M,
+		EfConstruction: indexCfg.EfConstruction,
+		EfSearch:       indexCfg.EfSearch,
+		MinVectors:     indexCfg.MinChunks,
+	}
+	index, err := vectorindex.Open(path, opts, logger)
+	if err != nil {
+		logger.Warn("uploadask vector index unreadable, rebuilding", "path", path, "error", err)
+		index = vectorindex.New(path, opts, logger)
+	}
+	logger.Info("uploadask vector index enabled", "path", path)
+	return index
+}
+
 func provideUploadChunkRepository(cfg *config.Config, docRepo uploadask.DocumentRepository, logger *slog.Logger) uploadask.ChunkRepository {
 	if db := sqliteDB(cfg, logger); db != nil {
 		logger.Info("uploadask sqlite chunk repository enabled", "path", cfg.SQLite.Path)
-		return uploadrepo.NewSQLiteChunkRepository(db)
+		return uploadrepo.NewSQLiteChunkRepository(db, uploadVectorIndex(cfg, logger))
 	}
 	pool := uploadPostgresPool(cfg, logger)
 	if pool != nil {
@@ -390,6 +570,19 @@ func provideUploadQueue(cfg *config.Config, logger *slog.Logger) uploadqueue.Han
 		logger.Info("uploadask valkey queue enabled", "addr", cfg.UploadAsk.Redis.Addr)
 		return uploadqueue.NewValkeyQueue(client, "uploadask:jobs", logger)
 	}
+	if cfg.UploadAsk.Queue.Driver == "sqlite" {
+		if db := sqliteDB(cfg, logger); db != nil {
+			q := cfg.UploadAsk.Queue
+			logger.Info("uploadask sqlite queue enabled", "workers", q.Workers, "max_attempts", q.MaxAttempts)
+			return uploadqueue.NewSQLiteQueue(db, uploadqueue.SQLiteQueueConfig{
+				Workers:       q.Workers,
+				MaxAttempts:   q.MaxAttempts,
+				BaseBackoff:   q.BaseBackoff,
+				LeaseDuration: q.LeaseDuration,
+				PollInterval:  q.PollInterval,
+			}, logger)
+		}
+	}
 	return uploadqueue.NewImmediateQueue(nil)
 }
 
@@ -401,53 +594,73 @@ func provideUploadLLM(client *chatgpt.Client, cfg *config.Config, logger *slog.L
 	return uploadllm.NewChatGPTLLM(client, cfg.LLM.Model, cfg.LLM.Temperature)
 }
 
-func provideUploadService(appCfg uploadask.Config, docs uploadask.DocumentRepository, files uploadask.FileObjectRepository, chunks uploadask.ChunkRepository, sessions uploadask.QASessionRepository, logs uploadask.QueryLogRepository, messages uploadask.MessageLog, memories uploadask.MemoryStore, storage uploadask.ObjectStorage, embedder uploadask.Embedder, llm uploadask.LLM, chunker uploadask.Chunker, queue uploadqueue.HandlerQueue, logger *slog.Logger) *uploadask.Service {
-	svc := uploadask.NewService(appCfg, docs, files, chunks, sessions, logs, messages, memories, storage, embedder, llm, chunker, queue, logger)
-	queue.SetHandler(func(ctx context.Context, name string, payload map[string]any) {
+// provideUploadReranker returns nil when reranking is disabled so Ask keeps
+// the retrieval order.
+func provideUploadReranker(cfg *config.Config, llm uploadask.LLM) uploadask.Reranker {
+	switch cfg.UploadAsk.Rerank.Strategy {
+	case "deterministic":
+		return uploadreranker.DeterministicReranker{}
+	case "llm":
+		return uploadreranker.NewLLMReranker(llm)
+	}
+	return nil
+}
+
+func provideUploadService(appCfg uploadask.Config, docs uploadask.DocumentRepository, files uploadask.FileObjectRepository, intents uploadask.UploadIntentRepository, collections uploadask.CollectionRepository, shares uploadask.ShareRepository, chunks uploadask.ChunkRepository, sessions uploadask.QASessionRepository, logs uploadask.QueryLogRepository, messages uploadask.MessageLog, memories uploadask.MemoryStore, storage uploadask.ObjectStorage, embedder uploadask.Embedder, llm uploadask.LLM, reranker uploadask.Reranker, chunker uploadask.Chunker, extractor uploadask.TextExtractor, fetcher uploadask.URLFetcher, queue uploadqueue.HandlerQueue, logger *slog.Logger) *uploadask.Service {
+	return uploadask.NewService(appCfg, docs, files, intents, collections, shares, chunks, sessions, logs, messages, memories, storage, embedder, llm, reranker, chunker, extractor, fetcher, queue, logger)
+}
+
+// provideUploadJobHandler runs upload queue jobs against svc. bootstrap.App
+// attaches it to the queue when the process consumes jobs.
+func provideUploadJobHandler(svc *uploadask.Service, logger *slog.Logger) uploadqueue.Handler {
+	return func(ctx context.Context, name string, payload map[string]any) error {
 		switch name {
-		case "process_document":
-			rawDocID, ok := payload["document_id"]
-			if !ok {
-				return
-			}
-			rawUserID, ok := payload["user_id"]
-			if !ok {
-				return
-			}
-			docID, err := parseUUID(rawDocID)
+		case uploadask.ProcessJobName, uploadask.ReindexJobName:
+			docID, err := parseUUID(payload["document_id"])
 			if err != nil {
-				logger.Warn("invalid document id in queue payload", "error", err)
-				return
+				return uploadqueue.Permanent(fmt.Errorf("invalid document id in queue payload: %w", err))
 			}
-			userID := parseUserID(rawUserID)
+			userID := parseUserID(payload["user_id"])
 			if userID == 0 {
-				return
+				return uploadqueue.Permanent(errors.New("missing user id in queue payload"))
 			}
-			if err := svc.ProcessDocument(ctx, docID, userID); err != nil {
-				logger.Warn("process_document failed", "error", err)
+			process := svc.ProcessDocument
+			if name == uploadask.ReindexJobName {
+				process = svc.ReindexDocument
 			}
-		case "summarize_session":
-			rawSessionID, ok := payload["session_id"]
-			if !ok {
-				return
-			}
-			rawUserID, ok := payload["user_id"]
-			if !ok {
-				return
+			if err := process(ctx, docID, userID); err != nil {
+				logger.Warn(name+" failed", "document_id", docID, "error", err)
+				if isPermanentUploadError(err) {
+					return uploadqueue.Permanent(err)
+				}
+				return err
 			}
-			sessionID, err := parseUUID(rawSessionID)
+		case "summarize_session":
+			sessionID, err := parseUUID(payload["session_id"])
 			if err != nil {
-				logger.Warn("invalid session id in summary job", "error", err)
-				return
+				return uploadqueue.Permanent(fmt.Errorf("invalid session id in summary job: %w", err))
 			}
-			userID := parseUserID(rawUserID)
+			userID := parseUserID(payload["user_id"])
 			if userID == 0 {
-				return
+				return uploadqueue.Permanent(errors.New("missing user id in summary job"))
 			}
 			svc.SummarizeSession(ctx, userID, sessionID)
+		default:
+			return uploadqueue.Permanent(fmt.Errorf("unknown job %q", name))
 		}
-	})
-	return svc
+		return nil
+	}
+}
+
+// isPermanentUploadError reports processing failures that a retry would only
+// repeat.
+func isPermanentUploadError(err error) bool {
+	for _, code := range []string{"not_found", "invalid_input", "unsupported_file_type", "extraction_error"} {
+		if apperrors.IsCode(err, code) {
+			return true
+		}
+	}
+	return false
 }
 
 func buildValkeyOptions(addr string) (valkey.ClientOption, error) {
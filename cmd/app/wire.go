@@ -27,6 +27,8 @@ func initializeApp() (*bootstrap.App, error) {
 		provideFAQConfig,
 		provideAuthConfig,
 		provideChatGPTClient,
+		provideEmbeddingCache,
+		provideFAQChatClient,
 		provideUVClient,
 		provideFAQRepository,
 		provideFAQStore,
@@ -35,8 +37,14 @@ func initializeApp() (*bootstrap.App, error) {
 		provideUploadStorage,
 		provideUploadEmbedder,
 		provideUploadChunker,
+		provideUploadReranker,
+		provideUploadExtractor,
+		provideUploadFetcher,
 		provideUploadDocumentRepository,
 		provideUploadFileRepository,
+		provideUploadIntentRepository,
+		provideUploadCollectionRepository,
+		provideUploadShareRepository,
 		provideUploadChunkRepository,
 		provideUploadSessionRepository,
 		provideUploadQueryLogRepository,
@@ -45,6 +53,7 @@ func initializeApp() (*bootstrap.App, error) {
 		provideUploadQueue,
 		provideUploadLLM,
 		provideUploadService,
+		provideUploadJobHandler,
 		summarizer.NewService,
 		uvadvisor.NewService,
 		faq.NewService,
@@ -52,7 +61,6 @@ func initializeApp() (*bootstrap.App, error) {
 		wire.Bind(new(summarizer.ChatClient), new(*chatgpt.Client)),
 		wire.Bind(new(uvadvisor.ChatClient), new(*chatgpt.Client)),
 		wire.Bind(new(uvadvisor.UVClient), new(*datagov.Client)),
-		wire.Bind(new(faq.ChatClient), new(*chatgpt.Client)),
 		httpiface.NewHandler,
 		httpiface.NewRouter,
 		bootstrap.NewApp,
@@ -40,14 +40,21 @@ func initializeApp() (*bootstrap.App, error) {
 	faqConfig := provideFAQConfig(configConfig)
 	questionRepository := provideFAQRepository(configConfig, slogLogger)
 	store := provideFAQStore(configConfig, slogLogger)
-	faqService := faq.NewService(faqConfig, questionRepository, store, client, slogLogger)
+	cache := provideEmbeddingCache(configConfig, slogLogger)
+	chatClient := provideFAQChatClient(client, cache)
+	faqService := faq.NewService(faqConfig, questionRepository, store, chatClient, slogLogger)
 	// upload ask dependencies
 	uploadAskConfig := provideUploadAskConfig(configConfig)
 	objectStorage := provideUploadStorage(configConfig, slogLogger)
-	uploadEmbedder := provideUploadEmbedder(client, configConfig, slogLogger)
-	chunker := provideUploadChunker()
+	uploadEmbedder := provideUploadEmbedder(client, configConfig, cache, slogLogger)
+	chunker := provideUploadChunker(configConfig)
+	textExtractor := provideUploadExtractor(configConfig)
+	urlFetcher := provideUploadFetcher(configConfig)
 	uploadDocumentRepository := provideUploadDocumentRepository(configConfig, slogLogger)
 	uploadFileRepository := provideUploadFileRepository(configConfig, slogLogger)
+	uploadIntentRepository := provideUploadIntentRepository(configConfig, slogLogger)
+	collectionRepository := provideUploadCollectionRepository(configConfig, slogLogger)
+	shareRepository := provideUploadShareRepository(configConfig, slogLogger)
 	uploadChunkRepository := provideUploadChunkRepository(configConfig, uploadDocumentRepository, slogLogger)
 	uploadQASessionRepository := provideUploadSessionRepository(configConfig, slogLogger)
 	uploadQueryLogRepository := provideUploadQueryLogRepository(configConfig, slogLogger)
@@ -55,12 +62,14 @@ func initializeApp() (*bootstrap.App, error) {
 	uploadMemoryStore := provideUploadMemoryStore(configConfig, slogLogger)
 	uploadQueue := provideUploadQueue(configConfig, slogLogger)
 	uploadLLM := provideUploadLLM(client, configConfig, slogLogger)
-	uploadService := provideUploadService(uploadAskConfig, uploadDocumentRepository, uploadFileRepository, uploadChunkRepository, uploadQASessionRepository, uploadQueryLogRepository, uploadMessageLog, uploadMemoryStore, objectStorage, uploadEmbedder, uploadLLM, chunker, uploadQueue, slogLogger)
+	uploadReranker := provideUploadReranker(configConfig, uploadLLM)
+	uploadService := provideUploadService(uploadAskConfig, uploadDocumentRepository, uploadFileRepository, uploadIntentRepository, collectionRepository, shareRepository, uploadChunkRepository, uploadQASessionRepository, uploadQueryLogRepository, uploadMessageLog, uploadMemoryStore, objectStorage, uploadEmbedder, uploadLLM, uploadReranker, chunker, textExtractor, urlFetcher, uploadQueue, slogLogger)
+	handler := provideUploadJobHandler(uploadService, slogLogger)
 	authConfig := provideAuthConfig(configConfig)
 	repository := provideAuthRepository(configConfig, slogLogger)
 	authService := auth.NewService(authConfig, repository, slogLogger)
-	handler := http.NewHandler(service, uvadvisorService, faqService, authService, uploadService, slogLogger)
-	server := http.NewRouter(configConfig, handler)
-	app := bootstrap.NewApp(configConfig, slogLogger, server)
+	httpHandler := http.NewHandler(service, uvadvisorService, faqService, authService, uploadService, cache, slogLogger)
+	server := http.NewRouter(configConfig, httpHandler)
+	app := bootstrap.NewApp(configConfig, slogLogger, server, uploadService, uploadQueue, handler)
 	return app, nil
 }
@@ -13,52 +13,164 @@ CREATE TABLE IF NOT EXISTS upload_documents (
     user_id        BIGINT NOT NULL,
     title          TEXT NOT NULL,
     source         TEXT NOT NULL,
+    source_url     TEXT,
     status         TEXT NOT NULL,
     failure_reason TEXT,
+    progress       JSONB,
+    recovery_attempts INT NOT NULL DEFAULT 0,
     created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+ALTER TABLE upload_documents
+    ADD COLUMN IF NOT EXISTS source_url TEXT,
+    ADD COLUMN IF NOT EXISTS progress JSONB,
+    ADD COLUMN IF NOT EXISTS recovery_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS mime_type TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_upload_documents_tags
+    ON upload_documents USING GIN (tags);
+
+CREATE INDEX IF NOT EXISTS idx_upload_documents_metadata
+    ON upload_documents USING GIN (metadata jsonb_path_ops);
+
 CREATE INDEX IF NOT EXISTS idx_upload_documents_user_status
     ON upload_documents (user_id, status, created_at DESC);
 
 CREATE TABLE IF NOT EXISTS upload_file_objects (
     id          UUID PRIMARY KEY,
     document_id UUID NOT NULL REFERENCES upload_documents(id) ON DELETE CASCADE,
+    user_id     BIGINT NOT NULL DEFAULT 0,
     storage_key TEXT NOT NULL,
     size_bytes  BIGINT NOT NULL,
     mime_type   TEXT NOT NULL,
     etag        TEXT NOT NULL,
+    content_hash TEXT NOT NULL DEFAULT '',
     created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+ALTER TABLE upload_file_objects
+    ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE upload_file_objects
+    ADD COLUMN IF NOT EXISTS user_id BIGINT NOT NULL DEFAULT 0;
+
+UPDATE upload_file_objects f
+SET user_id = d.user_id
+FROM upload_documents d
+WHERE d.id = f.document_id AND f.user_id = 0;
+
 CREATE INDEX IF NOT EXISTS idx_upload_file_objects_doc
     ON upload_file_objects (document_id);
 
+CREATE INDEX IF NOT EXISTS idx_upload_file_objects_content_hash
+    ON upload_file_objects (content_hash);
+
+CREATE INDEX IF NOT EXISTS idx_upload_file_objects_user
+    ON upload_file_objects (user_id);
+
+UPDATE upload_documents d
+SET mime_type = f.mime_type
+FROM upload_file_objects f
+WHERE f.document_id = d.id AND d.mime_type = '';
+
+CREATE TABLE IF NOT EXISTS upload_intents (
+    id          UUID PRIMARY KEY,
+    user_id     BIGINT NOT NULL,
+    filename    TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    mime_type   TEXT NOT NULL,
+    size_bytes  BIGINT NOT NULL,
+    storage_key TEXT NOT NULL,
+    status      TEXT NOT NULL,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_intents_storage_key
+    ON upload_intents (storage_key);
+
 CREATE TABLE IF NOT EXISTS upload_document_chunks (
     id          UUID PRIMARY KEY,
     document_id UUID NOT NULL REFERENCES upload_documents(id) ON DELETE CASCADE,
     chunk_index INT NOT NULL,
+    page_number INT NOT NULL DEFAULT 0,
+    heading_path TEXT[],
     content     TEXT NOT NULL,
+    content_tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED,
     token_count INT NOT NULL,
     embedding   VECTOR(1536) NOT NULL,
+    embedding_model TEXT NOT NULL DEFAULT '',
+    index_version   TEXT NOT NULL DEFAULT '',
     created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+ALTER TABLE upload_document_chunks
+    ADD COLUMN IF NOT EXISTS page_number INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS heading_path TEXT[],
+    ADD COLUMN IF NOT EXISTS content_tsv TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED,
+    ADD COLUMN IF NOT EXISTS embedding_model TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS index_version TEXT NOT NULL DEFAULT '';
+
 CREATE INDEX IF NOT EXISTS idx_upload_document_chunks_doc
     ON upload_document_chunks (document_id, chunk_index);
 
+-- Keyword index for hybrid (BM25-style + vector) retrieval.
+CREATE INDEX IF NOT EXISTS idx_upload_document_chunks_tsv
+    ON upload_document_chunks USING GIN (content_tsv);
+
 -- Optional IVF_FLAT index for pgvector similarity (set lists > 0 for performance)
 -- Adjust lists based on data size: CREATE INDEX ... USING ivfflat (embedding vector_cosine_ops) WITH (lists = 100);
 -- CREATE INDEX IF NOT EXISTS idx_upload_document_chunks_embedding
 --     ON upload_document_chunks USING ivfflat (embedding vector_cosine_ops);
 
+CREATE TABLE IF NOT EXISTS upload_collections (
+    id          UUID PRIMARY KEY,
+    user_id     BIGINT NOT NULL,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_collections_user
+    ON upload_collections (user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS upload_collection_documents (
+    collection_id UUID NOT NULL REFERENCES upload_collections(id) ON DELETE CASCADE,
+    document_id   UUID NOT NULL REFERENCES upload_documents(id) ON DELETE CASCADE,
+    added_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (collection_id, document_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_collection_documents_document
+    ON upload_collection_documents (document_id);
+
+CREATE TABLE IF NOT EXISTS upload_shares (
+    resource_type TEXT NOT NULL CHECK (resource_type IN ('document', 'collection')),
+    resource_id   UUID NOT NULL,
+    owner_id      BIGINT NOT NULL,
+    user_id       BIGINT NOT NULL,
+    role          TEXT NOT NULL CHECK (role IN ('viewer', 'editor')),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (resource_type, resource_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_shares_user
+    ON upload_shares (user_id, created_at DESC);
+
 CREATE TABLE IF NOT EXISTS upload_qa_sessions (
     id         UUID PRIMARY KEY,
     user_id    BIGINT NOT NULL,
     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+ALTER TABLE upload_qa_sessions
+    ADD COLUMN IF NOT EXISTS collection_id UUID;
+
 CREATE INDEX IF NOT EXISTS idx_upload_qa_sessions_user
     ON upload_qa_sessions (user_id, created_at DESC);
 
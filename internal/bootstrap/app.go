@@ -7,23 +7,55 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/yanqian/ai-helloworld/internal/domain/uploadask"
 	"github.com/yanqian/ai-helloworld/internal/infra/config"
+	uploadqueue "github.com/yanqian/ai-helloworld/internal/infra/uploadask/queue"
 )
 
-// App encapsulates the HTTP server lifecycle.
+// App encapsulates the HTTP server and background worker lifecycle.
 type App struct {
-	cfg    *config.Config
-	logger *slog.Logger
-	server *http.Server
+	cfg         *config.Config
+	logger      *slog.Logger
+	server      *http.Server
+	uploadSvc   *uploadask.Service
+	uploadQueue uploadqueue.HandlerQueue
+	uploadJobs  uploadqueue.Handler
 }
 
 // NewApp is used by Wire to build the runnable app.
-func NewApp(cfg *config.Config, logger *slog.Logger, server *http.Server) *App {
-	return &App{cfg: cfg, logger: logger.With("component", "bootstrap"), server: server}
+func NewApp(cfg *config.Config, logger *slog.Logger, server *http.Server, uploadSvc *uploadask.Service, uploadQueue uploadqueue.HandlerQueue, uploadJobs uploadqueue.Handler) *App {
+	return &App{
+		cfg:         cfg,
+		logger:      logger.With("component", "bootstrap"),
+		server:      server,
+		uploadSvc:   uploadSvc,
+		uploadQueue: uploadQueue,
+		uploadJobs:  uploadJobs,
+	}
 }
 
-// Run starts the HTTP server and blocks until shutdown.
+// Run starts the configured mode and blocks until shutdown. Server mode
+// serves HTTP, runs the recovery sweep and, unless uploadAsk.worker.enabled
+// is off, consumes upload jobs; worker mode only consumes jobs. On shutdown
+// the HTTP server drains first, then running jobs are given
+// uploadAsk.worker.shutdownTimeout to finish.
 func (a *App) Run(ctx context.Context) error {
+	workerOnly := a.cfg.Mode == config.ModeWorker
+	if workerOnly || a.cfg.UploadAsk.Worker.Enabled {
+		a.startWorker()
+		defer a.stopWorker()
+	}
+	if !workerOnly {
+		a.startRecovery(ctx)
+	}
+
+	if workerOnly {
+		a.logger.Info("worker mode: http server disabled")
+		<-ctx.Done()
+		a.logger.Info("shutdown signal received")
+		return nil
+	}
+
 	errCh := make(chan error, 1)
 
 	go func() {
@@ -49,3 +81,87 @@ func (a *App) Run(ctx context.Context) error {
 		return err
 	}
 }
+
+// startWorker attaches the job handler to the upload queue.
+func (a *App) startWorker() {
+	if a.uploadQueue == nil || a.uploadJobs == nil {
+		return
+	}
+	a.logger.Info("upload worker starting")
+	a.uploadQueue.SetHandler(a.uploadJobs)
+}
+
+// startRecovery recovers uploads a previous run left unfinished, then keeps
+// sweeping every interval. It runs in server mode only, so worker replicas
+// never sweep alongside the API and enqueue the same document twice.
+func (a *App) startRecovery(ctx context.Context) {
+	if !a.cfg.UploadAsk.Recovery.Enabled || a.uploadSvc == nil {
+		return
+	}
+	a.recoverDocuments(ctx)
+	if interval := a.cfg.UploadAsk.Recovery.Interval; interval > 0 {
+		go a.recoverPeriodically(ctx, interval)
+	}
+}
+
+// stopWorker waits for running jobs up to the shutdown timeout. Jobs still
+// running after that are cancelled when the queue supports it, and abandoned
+// otherwise; durable queues hand them out again.
+func (a *App) stopWorker() {
+	if a.uploadQueue == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		a.uploadQueue.Close()
+		close(done)
+	}()
+	timeout := a.cfg.UploadAsk.Worker.ShutdownTimeout
+	if timeout <= 0 {
+		<-done
+		a.logger.Info("upload worker stopped")
+		return
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		a.logger.Info("upload worker stopped")
+	case <-timer.C:
+		aborter, ok := a.uploadQueue.(uploadqueue.Aborter)
+		if !ok {
+			a.logger.Warn("upload worker shutdown timed out, abandoning running jobs", "timeout", timeout)
+			return
+		}
+		a.logger.Warn("upload worker shutdown timed out, cancelling running jobs", "timeout", timeout)
+		aborter.Abort()
+		<-done
+		a.logger.Info("upload worker stopped")
+	}
+}
+
+// recoverDocuments requeues documents a previous run left pending or
+// processing. Failures are logged so they never block startup.
+func (a *App) recoverDocuments(ctx context.Context) {
+	result, err := a.uploadSvc.RecoverStuckDocuments(ctx)
+	if err != nil {
+		a.logger.Error("upload recovery sweep failed", "error", err, "requeued", result.Requeued, "failed", result.Failed)
+		return
+	}
+	if result.Requeued > 0 || result.Failed > 0 {
+		a.logger.Info("upload recovery sweep finished", "requeued", result.Requeued, "failed", result.Failed)
+	}
+}
+
+func (a *App) recoverPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.recoverDocuments(ctx)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package bootstrap
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yanqian/ai-helloworld/internal/infra/config"
+	uploadqueue "github.com/yanqian/ai-helloworld/internal/infra/uploadask/queue"
+)
+
+type fakeQueue struct {
+	mu      sync.Mutex
+	handler uploadqueue.Handler
+	closed  bool
+}
+
+func (q *fakeQueue) Enqueue(ctx context.Context, name string, payload any) error {
+	q.mu.Lock()
+	handler := q.handler
+	q.mu.Unlock()
+	typed, _ := payload.(map[string]any)
+	return handler(ctx, name, typed)
+}
+
+func (q *fakeQueue) SetHandler(handler uploadqueue.Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handler = handler
+}
+
+func (q *fakeQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+}
+
+func TestRunWorkerModeConsumesJobsWithoutHTTP(t *testing.T) {
+	cfg := &config.Config{Mode: config.ModeWorker}
+	queue := &fakeQueue{}
+	jobs := make(chan string, 1)
+	handler := func(ctx context.Context, name string, payload map[string]any) error {
+		jobs <- name
+		return nil
+	}
+	// A nil server makes any attempt to serve HTTP panic.
+	app := NewApp(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), nil, nil, queue, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		queue.mu.Lock()
+		defer queue.mu.Unlock()
+		return queue.handler != nil
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, queue.Enqueue(context.Background(), "process_document", nil))
+	require.Equal(t, "process_document", <-jobs)
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop")
+	}
+	require.True(t, queue.closed)
+}
@@ -0,0 +1,231 @@
+package uploadask
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// Limits on collection fields.
+const (
+	maxCollectionNameLen        = 100
+	maxCollectionDescriptionLen = 1000
+)
+
+// CollectionUpdate changes the fields that are set.
+type CollectionUpdate struct {
+	Name        *string
+	Description *string
+}
+
+// CreateCollection creates an empty collection for the user.
+func (s *Service) CreateCollection(ctx context.Context, userID int64, name, description string) (Collection, error) {
+	if userID == 0 {
+		return Collection{}, apperrors.Wrap("unauthorized", "missing user", nil)
+	}
+	now := time.Now().UTC()
+	collection := Collection{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Name:        strings.TrimSpace(name),
+		Description: strings.TrimSpace(description),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := validateCollection(collection); err != nil {
+		return Collection{}, err
+	}
+	if err := s.collections.Create(ctx, collection); err != nil {
+		return Collection{}, apperrors.Wrap("storage_error", "failed to create collection", err)
+	}
+	return collection, nil
+}
+
+// ListCollections returns the user's collections.
+func (s *Service) ListCollections(ctx context.Context, userID int64) ([]Collection, error) {
+	if userID == 0 {
+		return nil, apperrors.Wrap("unauthorized", "missing user", nil)
+	}
+	collections, err := s.collections.List(ctx, userID)
+	if err != nil {
+		return nil, apperrors.Wrap("storage_error", "failed to list collections", err)
+	}
+	return collections, nil
+}
+
+// GetCollection fetches a collection the user owns or that is shared with
+// them.
+func (s *Service) GetCollection(ctx context.Context, userID int64, id uuid.UUID) (Collection, error) {
+	return s.accessibleCollection(ctx, userID, id, accessViewer)
+}
+
+// UpdateCollection renames or redescribes a collection. Editors may do this
+// too.
+func (s *Service) UpdateCollection(ctx context.Context, userID int64, id uuid.UUID, update CollectionUpdate) (Collection, error) {
+	collection, err := s.accessibleCollection(ctx, userID, id, accessEditor)
+	if err != nil {
+		return Collection{}, err
+	}
+	if update.Name != nil {
+		collection.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Description != nil {
+		collection.Description = strings.TrimSpace(*update.Description)
+	}
+	if err := validateCollection(collection); err != nil {
+		return Collection{}, err
+	}
+	collection.UpdatedAt = time.Now().UTC()
+	found, err := s.collections.Update(ctx, collection)
+	if err != nil {
+		return Collection{}, apperrors.Wrap("storage_error", "failed to update collection", err)
+	}
+	if !found {
+		return Collection{}, apperrors.Wrap("not_found", "collection not found", nil)
+	}
+	return collection, nil
+}
+
+// DeleteCollection removes a collection and its shares. Its documents are
+// kept, and sessions bound to it no longer retrieve anything.
+func (s *Service) DeleteCollection(ctx context.Context, userID int64, id uuid.UUID) error {
+	if _, err := s.accessibleCollection(ctx, userID, id, accessOwner); err != nil {
+		return err
+	}
+	if err := s.shares.DeleteByResource(ctx, ShareResourceCollection, id); err != nil {
+		return apperrors.Wrap("storage_error", "failed to delete collection shares", err)
+	}
+	deleted, err := s.collections.Delete(ctx, id, userID)
+	if err != nil {
+		return apperrors.Wrap("storage_error", "failed to delete collection", err)
+	}
+	if !deleted {
+		return apperrors.Wrap("not_found", "collection not found", nil)
+	}
+	return nil
+}
+
+// AddCollectionDocuments adds the user's documents to a collection and
+// returns the updated collection. Editors may add their own documents;
+// documents shared with the user cannot be added, since that would pass them
+// on to everyone the collection is shared with.
+func (s *Service) AddCollectionDocuments(ctx context.Context, userID int64, id uuid.UUID, docIDs []uuid.UUID) (Collection, error) {
+	if _, err := s.accessibleCollection(ctx, userID, id, accessEditor); err != nil {
+		return Collection{}, err
+	}
+	if len(docIDs) == 0 {
+		return Collection{}, apperrors.Wrap("invalid_input", "documentIds cannot be empty", nil)
+	}
+	owned, err := s.docs.List(ctx, userID, DocumentFilter{DocumentIDs: docIDs})
+	if err != nil {
+		return Collection{}, apperrors.Wrap("storage_error", "failed to load documents", err)
+	}
+	found := make(map[uuid.UUID]bool, len(owned))
+	for _, doc := range owned {
+		found[doc.ID] = true
+	}
+	for _, docID := range docIDs {
+		if !found[docID] {
+			return Collection{}, apperrors.Wrap("not_found", fmt.Sprintf("document %s not found", docID), nil)
+		}
+	}
+	if err := s.collections.AddDocuments(ctx, id, docIDs); err != nil {
+		return Collection{}, apperrors.Wrap("storage_error", "failed to add documents to collection", err)
+	}
+	return s.GetCollection(ctx, userID, id)
+}
+
+// RemoveCollectionDocuments takes documents out of a collection without
+// deleting them.
+func (s *Service) RemoveCollectionDocuments(ctx context.Context, userID int64, id uuid.UUID, docIDs []uuid.UUID) error {
+	if _, err := s.accessibleCollection(ctx, userID, id, accessEditor); err != nil {
+		return err
+	}
+	if err := s.collections.RemoveDocuments(ctx, id, docIDs); err != nil {
+		return apperrors.Wrap("storage_error", "failed to remove documents from collection", err)
+	}
+	return nil
+}
+
+// ListCollectionDocuments lists the collection's documents that pass filter,
+// whoever owns them, newest first.
+func (s *Service) ListCollectionDocuments(ctx context.Context, userID int64, id uuid.UUID, filter DocumentFilter) ([]Document, error) {
+	if _, err := s.GetCollection(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	filter, err := normalizeScope(filter)
+	if err != nil {
+		return nil, err
+	}
+	scopes, err := s.retrievalScopes(ctx, QASession{UserID: userID, CollectionID: &id}, filter)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]Document, 0)
+	for _, scope := range scopes {
+		owned, err := s.docs.List(ctx, scope.ownerID, scope.filter)
+		if err != nil {
+			return nil, apperrors.Wrap("storage_error", "failed to list documents", err)
+		}
+		docs = append(docs, owned...)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].CreatedAt.After(docs[j].CreatedAt)
+	})
+	return docs, nil
+}
+
+// scopeToCollection narrows filter to the documents of the session's
+// collection. It reports false when nothing can match, including when the
+// collection is gone or no longer shared with the user, since an empty
+// DocumentIDs would not restrict at all.
+func (s *Service) scopeToCollection(ctx context.Context, session QASession, filter DocumentFilter) (DocumentFilter, bool, error) {
+	if session.CollectionID == nil {
+		return filter, true, nil
+	}
+	if _, err := s.accessibleCollection(ctx, session.UserID, *session.CollectionID, accessViewer); err != nil {
+		if apperrors.IsCode(err, "not_found") {
+			return DocumentFilter{}, false, nil
+		}
+		return DocumentFilter{}, false, err
+	}
+	members, err := s.collections.DocumentIDs(ctx, *session.CollectionID)
+	if err != nil {
+		return DocumentFilter{}, false, apperrors.Wrap("storage_error", "failed to load collection documents", err)
+	}
+	if len(filter.DocumentIDs) > 0 {
+		requested := make(map[uuid.UUID]bool, len(filter.DocumentIDs))
+		for _, id := range filter.DocumentIDs {
+			requested[id] = true
+		}
+		kept := members[:0]
+		for _, id := range members {
+			if requested[id] {
+				kept = append(kept, id)
+			}
+		}
+		members = kept
+	}
+	filter.DocumentIDs = members
+	return filter, len(members) > 0, nil
+}
+
+func validateCollection(collection Collection) error {
+	if collection.Name == "" {
+		return apperrors.Wrap("invalid_input", "collection name cannot be empty", nil)
+	}
+	if utf8.RuneCountInString(collection.Name) > maxCollectionNameLen {
+		return apperrors.Wrap("invalid_input", fmt.Sprintf("collection name is longer than %d characters", maxCollectionNameLen), nil)
+	}
+	if utf8.RuneCountInString(collection.Description) > maxCollectionDescriptionLen {
+		return apperrors.Wrap("invalid_input", fmt.Sprintf("collection description is longer than %d characters", maxCollectionDescriptionLen), nil)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package uploadask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// defaultDirectUploadTTL applies when Config.DirectUploadTTL is unset.
+const defaultDirectUploadTTL = 15 * time.Minute
+
+// UploadIntentRequest describes a file the client will upload directly to
+// storage.
+type UploadIntentRequest struct {
+	Filename  string `json:"filename"`
+	Title     string `json:"title"`
+	MimeType  string `json:"mimeType"`
+	SizeBytes int64  `json:"sizeBytes"`
+}
+
+// UploadIntentResponse tells the client how to upload the file.
+type UploadIntentResponse struct {
+	Intent UploadIntent     `json:"intent"`
+	Upload PresignedRequest `json:"upload"`
+}
+
+// CreateUploadIntent reserves a storage key for the file and presigns an
+// upload to it. The client uploads the bytes itself and then calls
+// ConfirmUploadIntent.
+func (s *Service) CreateUploadIntent(ctx context.Context, userID int64, req UploadIntentRequest) (UploadIntentResponse, error) {
+	if userID == 0 {
+		return UploadIntentResponse{}, apperrors.Wrap("unauthorized", "missing user", nil)
+	}
+	presigner, err := s.presigner()
+	if err != nil {
+		return UploadIntentResponse{}, err
+	}
+	if req.SizeBytes <= 0 {
+		return UploadIntentResponse{}, apperrors.Wrap("invalid_input", "sizeBytes must be positive", nil)
+	}
+	if s.cfg.MaxFileBytes > 0 && req.SizeBytes > s.cfg.MaxFileBytes {
+		return UploadIntentResponse{}, apperrors.Wrap("invalid_input", "file exceeds maximum allowed size", nil)
+	}
+	if _, err := s.checkQuota(ctx, userID, req.SizeBytes); err != nil {
+		return UploadIntentResponse{}, err
+	}
+	filename := strings.TrimSpace(req.Filename)
+	if filename == "" {
+		filename = "document.txt"
+	}
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = filename
+	}
+	ttl := s.cfg.DirectUploadTTL
+	if ttl <= 0 {
+		ttl = defaultDirectUploadTTL
+	}
+
+	now := time.Now()
+	intent := UploadIntent{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Filename:  filename,
+		Title:     title,
+		MimeType:  declaredMimeType(filename, req.MimeType),
+		SizeBytes: req.SizeBytes,
+		Status:    UploadIntentPending,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	intent.StorageKey = storageKey(userID, intent.ID, filename)
+	upload, err := presigner.PresignPut(ctx, intent.StorageKey, intent.MimeType, ttl)
+	if err != nil {
+		return UploadIntentResponse{}, apperrors.Wrap("storage_error", "failed to presign upload", err)
+	}
+	if err := s.intents.Create(ctx, intent); err != nil {
+		return UploadIntentResponse{}, apperrors.Wrap("storage_error", "failed to persist upload intent", err)
+	}
+	return UploadIntentResponse{Intent: intent, Upload: upload}, nil
+}
+
+// ConfirmUploadIntent checks that the uploaded object has the announced size
+// and the ETag storage returned to the client, then records the document and
+// enqueues its processing. Confirming twice returns the same document.
+// Directly uploaded files are not checked for duplicates, since this server
+// never sees their bytes.
+func (s *Service) ConfirmUploadIntent(ctx context.Context, userID int64, intentID uuid.UUID, etag string) (UploadResponse, error) {
+	presigner, err := s.presigner()
+	if err != nil {
+		return UploadResponse{}, err
+	}
+	intent, found, err := s.intents.Get(ctx, intentID, userID)
+	if err != nil {
+		return UploadResponse{}, apperrors.Wrap("storage_error", "failed to load upload intent", err)
+	}
+	if !found {
+		return UploadResponse{}, apperrors.Wrap("not_found", "upload intent not found", nil)
+	}
+	if intent.Status == UploadIntentConfirmed {
+		return s.confirmedDocument(ctx, intent)
+	}
+	if time.Now().After(intent.ExpiresAt) {
+		return UploadResponse{}, apperrors.Wrap("invalid_input", "upload intent has expired", nil)
+	}
+	if normalizeETag(etag) == "" {
+		return UploadResponse{}, apperrors.Wrap("invalid_input", "etag is required", nil)
+	}
+	obj, err := presigner.Stat(ctx, intent.StorageKey)
+	if err != nil {
+		return UploadResponse{}, apperrors.Wrap("invalid_input", "uploaded file not found", err)
+	}
+	if obj.Size != intent.SizeBytes {
+		return UploadResponse{}, apperrors.Wrap("invalid_input", fmt.Sprintf("uploaded file is %d bytes, expected %d", obj.Size, intent.SizeBytes), nil)
+	}
+	if normalizeETag(obj.ETag) != normalizeETag(etag) {
+		return UploadResponse{}, apperrors.Wrap("invalid_input", "uploaded file etag does not match", nil)
+	}
+	// Other uploads may have used up the quota since the intent was created.
+	if _, err := s.checkQuota(ctx, userID, obj.Size); err != nil {
+		if delErr := s.storage.Delete(ctx, intent.StorageKey); delErr != nil {
+			s.logger.Warn("delete direct upload over quota failed", "key", intent.StorageKey, "error", delErr)
+		}
+		return UploadResponse{}, err
+	}
+	claimed, err := s.intents.MarkConfirmed(ctx, intent.ID)
+	if err != nil {
+		return UploadResponse{}, apperrors.Wrap("storage_error", "failed to confirm upload intent", err)
+	}
+	if !claimed {
+		return s.confirmedDocument(ctx, intent)
+	}
+
+	now := time.Now()
+	doc := Document{
+		ID:        intent.ID,
+		UserID:    userID,
+		Title:     intent.Title,
+		Source:    DocumentSourceUpload,
+		Status:    DocumentStatusPending,
+		MimeType:  intent.MimeType,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	file := FileObject{
+		ID:         uuid.New(),
+		DocumentID: doc.ID,
+		UserID:     userID,
+		StorageKey: intent.StorageKey,
+		SizeBytes:  obj.Size,
+		MimeType:   intent.MimeType,
+		ETag:       obj.ETag,
+		CreatedAt:  now,
+	}
+	if err := s.register(ctx, doc, file); err != nil {
+		return UploadResponse{}, err
+	}
+	return UploadResponse{Document: doc}, nil
+}
+
+// ReceiveDirectUpload stores the body of a presigned PUT when the storage's
+// presigned URLs point back at this server. The key must belong to a pending,
+// unexpired intent, so a signed URL cannot be replayed once the upload is
+// confirmed, and the body may not exceed the intent's SizeBytes, which was
+// checked against MaxFileBytes and the quota when the intent was created.
+func (s *Service) ReceiveDirectUpload(ctx context.Context, key string, query url.Values, body io.Reader, mimeType string) (StoredObject, error) {
+	receiver, ok := s.storage.(SignedUploadReceiver)
+	if !ok || s.intents == nil {
+		return StoredObject{}, apperrors.Wrap("not_found", "direct uploads are not served here", nil)
+	}
+	intent, found, err := s.intents.GetByStorageKey(ctx, key)
+	if err != nil {
+		return StoredObject{}, apperrors.Wrap("storage_error", "failed to load upload intent", err)
+	}
+	if !found || intent.Status != UploadIntentPending || time.Now().After(intent.ExpiresAt) {
+		return StoredObject{}, apperrors.Wrap("forbidden", "upload is no longer accepted", nil)
+	}
+	limited := newUploadReader(body, intent.SizeBytes)
+	obj, err := receiver.ReceiveSignedPut(ctx, key, query, limited, mimeType)
+	switch {
+	case err == nil:
+		return obj, nil
+	case errors.Is(err, ErrInvalidSignature):
+		return StoredObject{}, apperrors.Wrap("forbidden", ErrInvalidSignature.Error(), err)
+	case limited.tooLarge || errors.Is(err, ErrFileTooLarge):
+		return StoredObject{}, apperrors.Wrap("invalid_input", "file exceeds maximum allowed size", ErrFileTooLarge)
+	default:
+		return StoredObject{}, apperrors.Wrap("storage_error", "failed to store file", err)
+	}
+}
+
+func (s *Service) presigner() (PresigningStorage, error) {
+	presigner, ok := s.storage.(PresigningStorage)
+	if !ok || s.intents == nil {
+		return nil, apperrors.Wrap("direct_upload_unavailable", "storage does not support direct uploads", nil)
+	}
+	return presigner, nil
+}
+
+func (s *Service) confirmedDocument(ctx context.Context, intent UploadIntent) (UploadResponse, error) {
+	doc, found, err := s.docs.Get(ctx, intent.ID, intent.UserID)
+	if err != nil {
+		return UploadResponse{}, apperrors.Wrap("storage_error", "failed to load document", err)
+	}
+	if !found {
+		return UploadResponse{}, apperrors.Wrap("conflict", "upload intent is already confirmed", nil)
+	}
+	return UploadResponse{Document: doc}, nil
+}
+
+// declaredMimeType is detectMimeType without content to sniff: generic types
+// are left for the extractor to sniff when the document is processed.
+func declaredMimeType(filename, declared string) string {
+	declared = strings.TrimSpace(declared)
+	if declared != "" && !strings.HasPrefix(declared, "application/octet-stream") {
+		return declared
+	}
+	if byExt, ok := extensionMimeTypes[strings.ToLower(filepath.Ext(filename))]; ok {
+		return byExt
+	}
+	return "application/octet-stream"
+}
+
+// normalizeETag drops the quotes and weak prefix HTTP puts around ETags.
+func normalizeETag(etag string) string {
+	etag = strings.TrimSpace(etag)
+	etag = strings.TrimPrefix(etag, "W/")
+	return strings.ToLower(strings.Trim(etag, `"`))
+}
@@ -0,0 +1,27 @@
+package uploadask
+
+import (
+	"context"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// findDuplicate returns the user's newest document whose file has the given
+// content hash. Failed documents are skipped so re-uploading one processes it
+// afresh.
+func (s *Service) findDuplicate(ctx context.Context, userID int64, hash string) (Document, bool, error) {
+	files, err := s.files.ListByContentHash(ctx, hash)
+	if err != nil {
+		return Document{}, false, apperrors.Wrap("storage_error", "failed to look up duplicate uploads", err)
+	}
+	for _, file := range files {
+		doc, found, err := s.docs.Get(ctx, file.DocumentID, userID)
+		if err != nil {
+			return Document{}, false, apperrors.Wrap("storage_error", "failed to load duplicate document", err)
+		}
+		if found && doc.Status != DocumentStatusFailed {
+			return doc, true, nil
+		}
+	}
+	return Document{}, false, nil
+}
@@ -0,0 +1,254 @@
+package uploadask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// Embedding defaults applied when the matching Config field is unset.
+const (
+	defaultEmbedBatchSize   = 32
+	defaultEmbedConcurrency = 1
+	defaultEmbedMaxAttempts = 3
+	defaultEmbedBaseBackoff = 500 * time.Millisecond
+)
+
+// embedChunks embeds the candidates whose index is not in done, in batches of
+// Config.EmbedBatchSize with up to Config.EmbedConcurrency batches in flight.
+// Each finished batch is handed to sink; the first failure cancels the
+// remaining batches. Progress is reported as base chunks plus those finished
+// here, out of total.
+func (s *Service) embedChunks(ctx context.Context, docID uuid.UUID, candidates []ChunkCandidate, done map[int]string, sink func(context.Context, []DocumentChunk) error, tracker *progressTracker, base, total int) error {
+	var pending []int
+	for i, c := range candidates {
+		if _, ok := done[c.Index]; !ok {
+			pending = append(pending, i)
+		}
+	}
+	completed := base + len(candidates) - len(pending)
+	tracker.chunks(ctx, completed, total)
+
+	size := s.cfg.EmbedBatchSize
+	if size <= 0 {
+		size = defaultEmbedBatchSize
+	}
+	concurrency := s.cfg.EmbedConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultEmbedConcurrency
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		throttle embedThrottle
+	)
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	sem := make(chan struct{}, concurrency)
+	now := time.Now()
+	for start := 0; start < len(pending); start += size {
+		batch := pending[start:min(start+size, len(pending))]
+		select {
+		case sem <- struct{}{}:
+		case <-runCtx.Done():
+		}
+		if runCtx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			texts := make([]string, len(batch))
+			for j, i := range batch {
+				texts[j] = candidates[i].Content
+			}
+			embeddings, err := s.embedWithRetry(runCtx, texts, &throttle)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fail(apperrors.Wrap("embedding_error", "failed to embed chunks", err))
+				return
+			}
+			if firstErr != nil {
+				return
+			}
+			built := make([]DocumentChunk, len(batch))
+			for j, i := range batch {
+				built[j] = s.newChunk(docID, candidates[i], embeddings[j], now)
+			}
+			if err := sink(runCtx, built); err != nil {
+				fail(apperrors.Wrap("storage_error", "failed to persist chunks", err))
+				return
+			}
+			completed += len(batch)
+			tracker.chunks(ctx, completed, total)
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return apperrors.Wrap("embedding_error", "failed to embed chunks", err)
+	}
+	return nil
+}
+
+// embedWithRetry embeds one batch, retrying failures with exponential backoff
+// up to Config.EmbedMaxAttempts attempts. A RateLimitError pauses every batch
+// sharing throttle for at least the provider's RetryAfter.
+func (s *Service) embedWithRetry(ctx context.Context, texts []string, throttle *embedThrottle) ([][]float32, error) {
+	attempts := s.cfg.EmbedMaxAttempts
+	if attempts <= 0 {
+		attempts = defaultEmbedMaxAttempts
+	}
+	backoff := s.cfg.EmbedBaseBackoff
+	if backoff <= 0 {
+		backoff = defaultEmbedBaseBackoff
+	}
+	for attempt := 1; ; attempt++ {
+		if err := throttle.wait(ctx); err != nil {
+			return nil, err
+		}
+		embeddings, err := s.embedder.Embed(ctx, texts)
+		if err == nil && len(embeddings) != len(texts) {
+			err = fmt.Errorf("embedder returned %d vectors for %d texts", len(embeddings), len(texts))
+		}
+		if err == nil {
+			return embeddings, nil
+		}
+		if attempt >= attempts || ctx.Err() != nil {
+			return nil, err
+		}
+		delay := backoff * time.Duration(1<<(attempt-1))
+		var limited *RateLimitError
+		if errors.As(err, &limited) {
+			delay = max(delay, limited.RetryAfter)
+			throttle.pause(delay)
+		}
+		s.logger.Warn("embedding batch failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// embedThrottle holds back concurrent batches while the provider is rate
+// limiting.
+type embedThrottle struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+func (t *embedThrottle) pause(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := time.Now().Add(d); until.After(t.until) {
+		t.until = until
+	}
+}
+
+func (t *embedThrottle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	delay := time.Until(t.until)
+	t.mu.Unlock()
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resumableChunks returns the content of the chunks a previous run of
+// ProcessDocument already embedded and stored, keyed by chunk index.
+// Leftovers built with other embedding settings are deleted instead, and the
+// document is embedded from scratch.
+func (s *Service) resumableChunks(ctx context.Context, docID uuid.UUID) (map[int]string, error) {
+	existing, err := s.chunks.ListByDocument(ctx, docID)
+	if err != nil {
+		return nil, apperrors.Wrap("storage_error", "failed to load stored chunks", err)
+	}
+	if len(existing) == 0 {
+		return nil, nil
+	}
+	done := make(map[int]string, len(existing))
+	for _, chunk := range existing {
+		_, duplicate := done[chunk.ChunkIndex]
+		if duplicate || chunk.ChunkIndex < 0 ||
+			chunk.EmbeddingModel != s.cfg.EmbeddingModel || chunk.IndexVersion != s.cfg.IndexVersion {
+			s.logger.Info("discarding stale partial chunks", "document_id", docID, "chunks", len(existing))
+			if err := s.chunks.DeleteByDocument(ctx, docID); err != nil {
+				return nil, apperrors.Wrap("storage_error", "failed to delete stale chunks", err)
+			}
+			return nil, nil
+		}
+		done[chunk.ChunkIndex] = chunk.Content
+	}
+	s.logger.Info("resuming document embedding", "document_id", docID, "chunks_done", len(done))
+	return done, nil
+}
+
+// errStaleChunks reports stored chunks that no longer line up with the
+// document's candidates.
+var errStaleChunks = errors.New("stored chunks do not match the document")
+
+// checkResumed verifies that the stored chunks for the candidates' indexes
+// hold the same text. On a mismatch every stored chunk is deleted and
+// errStaleChunks returned, so the caller embeds the document from scratch.
+func (s *Service) checkResumed(ctx context.Context, docID uuid.UUID, candidates []ChunkCandidate, done map[int]string) error {
+	for _, c := range candidates {
+		if content, ok := done[c.Index]; ok && content != c.Content {
+			return s.discardStaleChunks(ctx, docID)
+		}
+	}
+	return nil
+}
+
+func (s *Service) discardStaleChunks(ctx context.Context, docID uuid.UUID) error {
+	s.logger.Info("discarding stale partial chunks", "document_id", docID)
+	if err := s.chunks.DeleteByDocument(ctx, docID); err != nil {
+		return apperrors.Wrap("storage_error", "failed to delete stale chunks", err)
+	}
+	return apperrors.Wrap("storage_error", "stored chunks were stale and have been discarded", errStaleChunks)
+}
+
+func (s *Service) newChunk(docID uuid.UUID, c ChunkCandidate, embedding []float32, now time.Time) DocumentChunk {
+	return DocumentChunk{
+		ID:             uuid.New(),
+		DocumentID:     docID,
+		ChunkIndex:     c.Index,
+		PageNumber:     c.PageNumber,
+		HeadingPath:    c.HeadingPath,
+		Content:        c.Content,
+		TokenCount:     c.TokenCount,
+		Embedding:      append([]float32(nil), embedding...),
+		EmbeddingModel: s.cfg.EmbeddingModel,
+		IndexVersion:   s.cfg.IndexVersion,
+		CreatedAt:      now,
+	}
+}
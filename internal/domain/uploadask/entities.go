@@ -26,51 +26,136 @@ const (
 
 // Document represents a user scoped file submission.
 type Document struct {
-	ID            uuid.UUID      `json:"id"`
-	UserID        int64          `json:"userId"`
-	Title         string         `json:"title"`
-	Source        DocumentSource `json:"source"`
-	Status        DocumentStatus `json:"status"`
-	FailureReason *string        `json:"failureReason,omitempty"`
-	CreatedAt     time.Time      `json:"createdAt"`
-	UpdatedAt     time.Time      `json:"updatedAt"`
+	ID            uuid.UUID         `json:"id"`
+	UserID        int64             `json:"userId"`
+	Title         string            `json:"title"`
+	Source        DocumentSource    `json:"source"`
+	SourceURL     *string           `json:"sourceUrl,omitempty"`
+	Status        DocumentStatus    `json:"status"`
+	FailureReason *string           `json:"failureReason,omitempty"`
+	Progress      *DocumentProgress `json:"progress,omitempty"`
+	MimeType      string            `json:"mimeType,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	UpdatedAt     time.Time         `json:"updatedAt"`
 }
 
-// FileObject stores uploaded blob metadata.
+// FileObject stores uploaded blob metadata. ContentHash is the hex SHA-256 of
+// the uploaded bytes.
 type FileObject struct {
-	ID         uuid.UUID `json:"id"`
-	DocumentID uuid.UUID `json:"documentId"`
-	StorageKey string    `json:"storageKey"`
-	SizeBytes  int64     `json:"sizeBytes"`
-	MimeType   string    `json:"mimeType"`
-	ETag       string    `json:"etag"`
-	CreatedAt  time.Time `json:"createdAt"`
+	ID          uuid.UUID `json:"id"`
+	DocumentID  uuid.UUID `json:"documentId"`
+	UserID      int64     `json:"userId"`
+	StorageKey  string    `json:"storageKey"`
+	SizeBytes   int64     `json:"sizeBytes"`
+	MimeType    string    `json:"mimeType"`
+	ETag        string    `json:"etag"`
+	ContentHash string    `json:"contentHash"`
+	CreatedAt   time.Time `json:"createdAt"`
 }
 
-// DocumentChunk contains an embedded slice of a document.
+// UploadIntentStatus tracks a direct-to-storage upload.
+type UploadIntentStatus string
+
+const (
+	UploadIntentPending   UploadIntentStatus = "pending"
+	UploadIntentConfirmed UploadIntentStatus = "confirmed"
+)
+
+// UploadIntent reserves a storage key for a file the client uploads straight
+// to storage. Its ID becomes the document ID once the upload is confirmed.
+type UploadIntent struct {
+	ID         uuid.UUID          `json:"id"`
+	UserID     int64              `json:"userId"`
+	Filename   string             `json:"filename"`
+	Title      string             `json:"title"`
+	MimeType   string             `json:"mimeType"`
+	SizeBytes  int64              `json:"sizeBytes"`
+	StorageKey string             `json:"-"`
+	Status     UploadIntentStatus `json:"status"`
+	ExpiresAt  time.Time          `json:"expiresAt"`
+	CreatedAt  time.Time          `json:"createdAt"`
+}
+
+// DocumentChunk contains an embedded slice of a document. EmbeddingModel and
+// IndexVersion record what produced the chunk so stale chunks can be found
+// and reindexed after the embedder or chunker configuration changes.
 type DocumentChunk struct {
-	ID         uuid.UUID `json:"id"`
-	DocumentID uuid.UUID `json:"documentId"`
-	ChunkIndex int       `json:"chunkIndex"`
-	Content    string    `json:"content"`
-	TokenCount int       `json:"tokenCount"`
-	Embedding  []float32 `json:"embedding"`
-	CreatedAt  time.Time `json:"createdAt"`
+	ID             uuid.UUID `json:"id"`
+	DocumentID     uuid.UUID `json:"documentId"`
+	ChunkIndex     int       `json:"chunkIndex"`
+	PageNumber     int       `json:"pageNumber,omitempty"`
+	HeadingPath    []string  `json:"headingPath,omitempty"`
+	Content        string    `json:"content"`
+	TokenCount     int       `json:"tokenCount"`
+	Embedding      []float32 `json:"embedding"`
+	EmbeddingModel string    `json:"embeddingModel,omitempty"`
+	IndexVersion   string    `json:"indexVersion,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
 }
 
 // ChunkSource captures retrieval metadata returned to the client.
 type ChunkSource struct {
-	DocumentID uuid.UUID `json:"documentId"`
-	ChunkIndex int       `json:"chunkIndex"`
-	Score      float64   `json:"score"`
-	Preview    string    `json:"preview"`
+	DocumentID  uuid.UUID `json:"documentId"`
+	ChunkIndex  int       `json:"chunkIndex"`
+	PageNumber  int       `json:"pageNumber,omitempty"`
+	HeadingPath []string  `json:"headingPath,omitempty"`
+	Score       float64   `json:"score"`
+	RerankScore *float64  `json:"rerankScore,omitempty"`
+	Preview     string    `json:"preview"`
 }
 
-// QASession groups multiple questions from the same user.
+// QASession groups multiple questions from the same user. A session bound to
+// a collection only retrieves from that collection's documents.
 type QASession struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    int64     `json:"userId"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID           uuid.UUID  `json:"id"`
+	UserID       int64      `json:"userId"`
+	CollectionID *uuid.UUID `json:"collectionId,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// Collection groups a user's documents, for example by project. A document
+// may belong to several collections.
+type Collection struct {
+	ID            uuid.UUID `json:"id"`
+	UserID        int64     `json:"userId"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description,omitempty"`
+	DocumentCount int       `json:"documentCount"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// ShareRole is the access a share grants.
+type ShareRole string
+
+const (
+	// ShareRoleViewer may read the resource and ask questions about it.
+	ShareRoleViewer ShareRole = "viewer"
+	// ShareRoleEditor may also change it: a document's labels, or a
+	// collection's name, description and documents.
+	ShareRoleEditor ShareRole = "editor"
+)
+
+// ShareResourceType is the kind of resource a share grants access to.
+type ShareResourceType string
+
+const (
+	ShareResourceDocument   ShareResourceType = "document"
+	ShareResourceCollection ShareResourceType = "collection"
+)
+
+// Share grants another user access to a document or collection. Sharing a
+// collection lets the user read every document in it.
+type Share struct {
+	ResourceType ShareResourceType `json:"resourceType"`
+	ResourceID   uuid.UUID         `json:"resourceId"`
+	OwnerID      int64             `json:"ownerId"`
+	UserID       int64             `json:"userId"`
+	Role         ShareRole         `json:"role"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
 }
 
 // QueryLog records a single question/answer exchange.
@@ -0,0 +1,34 @@
+package uploadask
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUnsupportedFileType indicates no text extractor handles the file's MIME type.
+var ErrUnsupportedFileType = errors.New("unsupported file type")
+
+// ErrFileTooLarge indicates a document exceeds the configured size limit.
+var ErrFileTooLarge = errors.New("file exceeds maximum allowed size")
+
+// ErrInvalidSignature indicates a presigned upload URL was tampered with or
+// has expired.
+var ErrInvalidSignature = errors.New("invalid or expired upload signature")
+
+// ErrJobRunning indicates a job cannot be changed while a worker holds it.
+var ErrJobRunning = errors.New("job is running")
+
+// RateLimitError reports that a provider throttled the request. RetryAfter is
+// the wait the provider asked for, or zero when it gave none.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *RateLimitError) Error() string {
+	return "rate limited: " + e.Err.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
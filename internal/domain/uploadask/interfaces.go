@@ -3,6 +3,7 @@ package uploadask
 import (
 	"context"
 	"io"
+	"net/url"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,11 +11,40 @@ import (
 
 // ObjectStorage abstracts blob storage (R2/S3/Supabase/local).
 type ObjectStorage interface {
-	Put(ctx context.Context, key string, data []byte, mimeType string) (StoredObject, error)
+	// Put streams body into the object until EOF. When reading body fails the
+	// error is returned wrapped and no object is left behind.
+	Put(ctx context.Context, key string, body io.Reader, mimeType string) (StoredObject, error)
 	Get(ctx context.Context, key string) (io.ReadCloser, error)
 	Delete(ctx context.Context, key string) error
 }
 
+// PresigningStorage is ObjectStorage that can let clients upload an object
+// directly, bypassing this server.
+type PresigningStorage interface {
+	ObjectStorage
+	// PresignPut returns a request that uploads the object under key until
+	// ttl has elapsed.
+	PresignPut(ctx context.Context, key, mimeType string, ttl time.Duration) (PresignedRequest, error)
+	// Stat reports the size and ETag of a stored object.
+	Stat(ctx context.Context, key string) (StoredObject, error)
+}
+
+// SignedUploadReceiver is implemented by storage whose presigned URLs point
+// back at this server rather than at a bucket.
+type SignedUploadReceiver interface {
+	// ReceiveSignedPut checks the presigned query, failing with
+	// ErrInvalidSignature, and then stores body under key.
+	ReceiveSignedPut(ctx context.Context, key string, query url.Values, body io.Reader, mimeType string) (StoredObject, error)
+}
+
+// PresignedRequest is the request a client sends to upload straight to
+// storage.
+type PresignedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
 // StoredObject captures persisted blob metadata.
 type StoredObject struct {
 	Key      string
@@ -23,6 +53,21 @@ type StoredObject struct {
 	ETag     string
 }
 
+// URLFetcher downloads a remote document for ingestion. Implementations must
+// stop reading once maxBytes is exceeded and report ErrFileTooLarge.
+type URLFetcher interface {
+	Fetch(ctx context.Context, rawURL string, maxBytes int64) (FetchedDocument, error)
+}
+
+// FetchedDocument is the body and metadata of a fetched URL. The caller
+// must close Body; reading past the size limit fails with ErrFileTooLarge.
+type FetchedDocument struct {
+	URL      string
+	Filename string
+	MimeType string
+	Body     io.ReadCloser
+}
+
 // Embedder produces embeddings for free form text.
 type Embedder interface {
 	Embed(ctx context.Context, texts []string) ([][]float32, error)
@@ -31,6 +76,15 @@ type Embedder interface {
 // LLM generates answers for a question and context.
 type LLM interface {
 	Chat(ctx context.Context, messages []LLMMessage) (string, error)
+	// ChatStream streams the answer as deltas. The channel is closed when the
+	// answer is complete; a failure mid-stream is reported on the last chunk.
+	ChatStream(ctx context.Context, messages []LLMMessage) (<-chan LLMStreamChunk, error)
+}
+
+// LLMStreamChunk is one piece of a streamed answer.
+type LLMStreamChunk struct {
+	Delta string
+	Err   error
 }
 
 // LLMMessage mirrors a simplified chat payload.
@@ -39,6 +93,12 @@ type LLMMessage struct {
 	Content string
 }
 
+// Reranker rescores retrieved chunks against the question. It returns one
+// score per candidate, in candidate order; higher means more relevant.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, candidates []RetrievedChunk) ([]float64, error)
+}
+
 // Retriever performs similarity search across stored chunks.
 type Retriever interface {
 	Search(ctx context.Context, userID int64, embedding []float32, filter DocumentFilter) ([]RetrievedChunk, error)
@@ -48,20 +108,63 @@ type Retriever interface {
 type DocumentRepository interface {
 	Create(ctx context.Context, doc Document) error
 	UpdateStatus(ctx context.Context, docID uuid.UUID, status DocumentStatus, failureReason *string) error
+	UpdateProgress(ctx context.Context, docID uuid.UUID, progress DocumentProgress) error
 	Get(ctx context.Context, docID uuid.UUID, userID int64) (Document, bool, error)
 	List(ctx context.Context, userID int64, filter DocumentFilter) ([]Document, error)
+	// Delete removes the user's document and reports whether it existed.
+	Delete(ctx context.Context, docID uuid.UUID, userID int64) (bool, error)
+	// ListStale returns every user's documents in one of statuses that were
+	// last updated before updatedBefore, oldest first.
+	ListStale(ctx context.Context, statuses []DocumentStatus, updatedBefore time.Time) ([]Document, error)
+	// IncrementRecoveryAttempts bumps the document's recovery counter and
+	// updated_at, returning the new count.
+	IncrementRecoveryAttempts(ctx context.Context, docID uuid.UUID) (int, error)
+	// UpdateLabels replaces the user's document tags and metadata and reports
+	// whether the document exists.
+	UpdateLabels(ctx context.Context, docID uuid.UUID, userID int64, tags []string, metadata map[string]string) (bool, error)
+	// OwnerIDs returns the owner of each of docIDs that exists. It is not
+	// scoped to a user, so callers check access first.
+	OwnerIDs(ctx context.Context, docIDs []uuid.UUID) (map[uuid.UUID]int64, error)
 }
 
 // FileObjectRepository persists uploaded file metadata.
 type FileObjectRepository interface {
 	Create(ctx context.Context, file FileObject) error
 	FindByDocument(ctx context.Context, docID uuid.UUID) (FileObject, bool, error)
+	// ListByContentHash returns every user's files with the hash, newest
+	// first.
+	ListByContentHash(ctx context.Context, hash string) ([]FileObject, error)
+	// UsageByUser counts the user's files and their bytes; Chunks is left
+	// zero.
+	UsageByUser(ctx context.Context, userID int64) (StorageUsage, error)
+	DeleteByDocument(ctx context.Context, docID uuid.UUID) error
+}
+
+// UploadIntentRepository persists direct upload intents.
+type UploadIntentRepository interface {
+	Create(ctx context.Context, intent UploadIntent) error
+	Get(ctx context.Context, id uuid.UUID, userID int64) (UploadIntent, bool, error)
+	// GetByStorageKey finds the intent that reserved key, for any user.
+	GetByStorageKey(ctx context.Context, key string) (UploadIntent, bool, error)
+	// MarkConfirmed moves a pending intent to confirmed and reports whether
+	// this call made the change.
+	MarkConfirmed(ctx context.Context, id uuid.UUID) (bool, error)
 }
 
 // ChunkRepository stores embedded chunks.
 type ChunkRepository interface {
 	InsertBatch(ctx context.Context, chunks []DocumentChunk) error
 	SearchSimilar(ctx context.Context, userID int64, embedding []float32, filter DocumentFilter) ([]RetrievedChunk, error)
+	// SearchLexical ranks chunks by BM25 keyword relevance to query, best first.
+	SearchLexical(ctx context.Context, userID int64, query string, filter DocumentFilter) ([]RetrievedChunk, error)
+	// ListByDocument returns the document's chunks ordered by chunk index.
+	ListByDocument(ctx context.Context, docID uuid.UUID) ([]DocumentChunk, error)
+	DeleteByDocument(ctx context.Context, docID uuid.UUID) error
+	// ListStaleDocuments returns the user's documents that have chunks built
+	// with a different embedding model or index version.
+	ListStaleDocuments(ctx context.Context, userID int64, embeddingModel, indexVersion string) ([]uuid.UUID, error)
+	CountByUser(ctx context.Context, userID int64) (int, error)
+	CountByDocument(ctx context.Context, docID uuid.UUID) (int, error)
 }
 
 // QASessionRepository persists user sessions.
@@ -71,6 +174,43 @@ type QASessionRepository interface {
 	List(ctx context.Context, userID int64) ([]QASession, error)
 }
 
+// CollectionRepository persists collections and their document membership.
+// Get and List fill in DocumentCount.
+type CollectionRepository interface {
+	Create(ctx context.Context, collection Collection) error
+	Get(ctx context.Context, id uuid.UUID, userID int64) (Collection, bool, error)
+	List(ctx context.Context, userID int64) ([]Collection, error)
+	// Update stores the collection's name and description and reports whether
+	// it exists.
+	Update(ctx context.Context, collection Collection) (bool, error)
+	// Delete removes the collection and its memberships, not the documents.
+	Delete(ctx context.Context, id uuid.UUID, userID int64) (bool, error)
+	// AddDocuments adds documents to the collection; existing members are
+	// left as they are.
+	AddDocuments(ctx context.Context, collectionID uuid.UUID, docIDs []uuid.UUID) error
+	RemoveDocuments(ctx context.Context, collectionID uuid.UUID, docIDs []uuid.UUID) error
+	DocumentIDs(ctx context.Context, collectionID uuid.UUID) ([]uuid.UUID, error)
+	// DocumentIDsIn returns the members of all the collections, each once.
+	DocumentIDsIn(ctx context.Context, collectionIDs []uuid.UUID) ([]uuid.UUID, error)
+	// DeleteByDocument removes the document from every collection.
+	DeleteByDocument(ctx context.Context, docID uuid.UUID) error
+}
+
+// ShareRepository persists shares. It only stores them; the service decides
+// what a share allows.
+type ShareRepository interface {
+	// Upsert creates the share, or updates the role and UpdatedAt of the
+	// existing share of the resource with the same user.
+	Upsert(ctx context.Context, share Share) error
+	Get(ctx context.Context, resourceType ShareResourceType, resourceID uuid.UUID, userID int64) (Share, bool, error)
+	// ListByResource returns the resource's shares, oldest first.
+	ListByResource(ctx context.Context, resourceType ShareResourceType, resourceID uuid.UUID) ([]Share, error)
+	// ListByUser returns everything shared with the user, newest first.
+	ListByUser(ctx context.Context, userID int64) ([]Share, error)
+	Delete(ctx context.Context, resourceType ShareResourceType, resourceID uuid.UUID, userID int64) (bool, error)
+	DeleteByResource(ctx context.Context, resourceType ShareResourceType, resourceID uuid.UUID) error
+}
+
 // QueryLogRepository records question/answer pairs.
 type QueryLogRepository interface {
 	Append(ctx context.Context, log QueryLog) error
@@ -95,6 +235,26 @@ type JobQueue interface {
 	Enqueue(ctx context.Context, name string, payload any) error
 }
 
+// TextExtractor converts a stored blob into plain text before chunking.
+type TextExtractor interface {
+	Extract(ctx context.Context, mimeType string, data []byte) ([]ExtractedPage, error)
+}
+
+// StreamingExtractor is a TextExtractor that can also read the blob
+// incrementally, handing emit one section of text at a time so a large file
+// is never held in memory whole. Sections are chunked independently.
+type StreamingExtractor interface {
+	TextExtractor
+	ExtractStream(ctx context.Context, mimeType string, r io.Reader, emit func(ExtractedPage) error) error
+}
+
+// ExtractedPage is a unit of extracted text. Number is 1-based for paged
+// formats such as PDF and 0 when the source has no page structure.
+type ExtractedPage struct {
+	Number int
+	Text   string
+}
+
 // Chunker splits raw text into contextual pieces.
 type Chunker interface {
 	Chunk(text string) []ChunkCandidate
@@ -102,21 +262,33 @@ type Chunker interface {
 
 // ChunkCandidate is produced by the chunker before embedding.
 type ChunkCandidate struct {
-	Index      int
-	Content    string
-	TokenCount int
+	Index       int
+	Content     string
+	TokenCount  int
+	PageNumber  int
+	HeadingPath []string
 }
 
-// DocumentFilter restricts scope to a set of documents or statuses.
+// DocumentFilter restricts scope to a set of documents or statuses, and to
+// documents carrying every tag in Tags and every pair in Metadata, with one of
+// MimeTypes, created at or after CreatedAfter and before CreatedBefore. Empty
+// fields do not restrict.
 type DocumentFilter struct {
-	DocumentIDs []uuid.UUID
-	Statuses    []DocumentStatus
+	DocumentIDs   []uuid.UUID
+	Statuses      []DocumentStatus
+	Tags          []string
+	Metadata      map[string]string
+	MimeTypes     []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
 }
 
-// RetrievedChunk bundles the chunk and score.
+// RetrievedChunk bundles the chunk and score. RerankScore is set when a
+// Reranker reordered the candidates.
 type RetrievedChunk struct {
-	Chunk     DocumentChunk
-	Document  Document
-	Score     float64
-	CreatedAt time.Time
+	Chunk       DocumentChunk
+	Document    Document
+	Score       float64
+	RerankScore *float64
+	CreatedAt   time.Time
 }
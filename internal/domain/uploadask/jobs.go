@@ -0,0 +1,101 @@
+package uploadask
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// JobState is where a persisted job is in its lifecycle.
+type JobState string
+
+const (
+	JobStateQueued  JobState = "queued"
+	JobStateRunning JobState = "running"
+	// JobStateDead marks jobs that used up their attempts and were moved to
+	// the dead-letter table.
+	JobStateDead JobState = "dead"
+)
+
+// Job is a background job as stored by a persistent queue.
+type Job struct {
+	ID             uuid.UUID      `json:"id"`
+	Name           string         `json:"name"`
+	Payload        map[string]any `json:"payload"`
+	State          JobState       `json:"state"`
+	Attempts       int            `json:"attempts"`
+	MaxAttempts    int            `json:"maxAttempts"`
+	LastError      *string        `json:"lastError,omitempty"`
+	RunAt          time.Time      `json:"runAt"`
+	LeaseExpiresAt *time.Time     `json:"leaseExpiresAt,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+}
+
+// JobInspector is implemented by job queues that persist their jobs.
+type JobInspector interface {
+	// ListJobs returns up to limit jobs in state, oldest first; an empty state
+	// lists every job.
+	ListJobs(ctx context.Context, state JobState, limit int) ([]Job, error)
+	// RequeueJob makes a queued or dead job runnable now with a fresh attempt
+	// budget and reports whether it existed. Running jobs fail with
+	// ErrJobRunning.
+	RequeueJob(ctx context.Context, id uuid.UUID) (bool, error)
+	// LiveJobs returns every queued or running job named name, dead-lettered
+	// ones excluded.
+	LiveJobs(ctx context.Context, name string) ([]Job, error)
+}
+
+// maxListedJobs caps ListJobs responses.
+const maxListedJobs = 200
+
+// ListJobs returns the processing queue's jobs in state. It fails with
+// "unavailable" when the queue does not persist jobs.
+func (s *Service) ListJobs(ctx context.Context, state JobState) ([]Job, error) {
+	inspector, err := s.jobInspector()
+	if err != nil {
+		return nil, err
+	}
+	switch state {
+	case "", JobStateQueued, JobStateRunning, JobStateDead:
+	default:
+		return nil, apperrors.Wrap("invalid_input", "state must be queued, running or dead", nil)
+	}
+	jobs, err := inspector.ListJobs(ctx, state, maxListedJobs)
+	if err != nil {
+		return nil, apperrors.Wrap("storage_error", "failed to list jobs", err)
+	}
+	return jobs, nil
+}
+
+// RequeueJob schedules a queued or dead job to run again right away.
+func (s *Service) RequeueJob(ctx context.Context, id uuid.UUID) error {
+	inspector, err := s.jobInspector()
+	if err != nil {
+		return err
+	}
+	found, err := inspector.RequeueJob(ctx, id)
+	if errors.Is(err, ErrJobRunning) {
+		return apperrors.Wrap("conflict", "job is running", err)
+	}
+	if err != nil {
+		return apperrors.Wrap("storage_error", "failed to requeue job", err)
+	}
+	if !found {
+		return apperrors.Wrap("not_found", "job not found", nil)
+	}
+	s.logger.Info("job requeued", "job_id", id)
+	return nil
+}
+
+func (s *Service) jobInspector() (JobInspector, error) {
+	inspector, ok := s.queue.(JobInspector)
+	if !ok {
+		return nil, apperrors.Wrap("unavailable", "processing queue does not persist jobs", nil)
+	}
+	return inspector, nil
+}
@@ -0,0 +1,215 @@
+package uploadask
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// Limits on user-defined document labels.
+const (
+	maxDocumentTags     = 20
+	maxTagLength        = 64
+	maxMetadataKeys     = 20
+	maxMetadataKeyLen   = 64
+	maxMetadataValueLen = 512
+)
+
+// DocumentLabelsUpdate changes a document's labels. Tags, when set, replace
+// the current tags. Metadata is merged into the current metadata, and a nil
+// value removes its key.
+type DocumentLabelsUpdate struct {
+	Tags     *[]string
+	Metadata map[string]*string
+}
+
+// TagCount is a tag in use and how many of the user's documents carry it.
+type TagCount struct {
+	Tag       string `json:"tag"`
+	Documents int    `json:"documents"`
+}
+
+// HasScope reports whether the filter restricts by tags, metadata, MIME type
+// or creation time.
+func (f DocumentFilter) HasScope() bool {
+	return len(f.Tags) > 0 || len(f.Metadata) > 0 || len(f.MimeTypes) > 0 ||
+		f.CreatedAfter != nil || f.CreatedBefore != nil
+}
+
+// MatchesScope reports whether doc satisfies the filter's tag, metadata, MIME
+// type and creation time restrictions. Document IDs and statuses are left to
+// the caller.
+func (f DocumentFilter) MatchesScope(doc Document) bool {
+	for _, tag := range f.Tags {
+		if !slices.Contains(doc.Tags, tag) {
+			return false
+		}
+	}
+	for key, value := range f.Metadata {
+		if got, ok := doc.Metadata[key]; !ok || got != value {
+			return false
+		}
+	}
+	if len(f.MimeTypes) > 0 && !slices.Contains(f.MimeTypes, doc.MimeType) {
+		return false
+	}
+	if f.CreatedAfter != nil && doc.CreatedAt.Before(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && !doc.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// UpdateDocumentLabels applies update to a document the user owns or edits
+// and returns it.
+func (s *Service) UpdateDocumentLabels(ctx context.Context, userID int64, docID uuid.UUID, update DocumentLabelsUpdate) (Document, error) {
+	doc, err := s.accessibleDocument(ctx, userID, docID, accessEditor)
+	if err != nil {
+		return Document{}, err
+	}
+	tags := doc.Tags
+	if update.Tags != nil {
+		if tags, err = normalizeTags(*update.Tags); err != nil {
+			return Document{}, err
+		}
+	}
+	metadata := make(map[string]string, len(doc.Metadata)+len(update.Metadata))
+	for key, value := range doc.Metadata {
+		metadata[key] = value
+	}
+	for key, value := range update.Metadata {
+		if value == nil {
+			delete(metadata, strings.TrimSpace(key))
+			continue
+		}
+		metadata[key] = *value
+	}
+	if metadata, err = normalizeMetadata(metadata); err != nil {
+		return Document{}, err
+	}
+	found, err := s.docs.UpdateLabels(ctx, docID, doc.UserID, tags, metadata)
+	if err != nil {
+		return Document{}, apperrors.Wrap("storage_error", "failed to update document labels", err)
+	}
+	if !found {
+		return Document{}, apperrors.Wrap("not_found", "document not found", nil)
+	}
+	doc.Tags = tags
+	doc.Metadata = metadata
+	doc.UpdatedAt = time.Now().UTC()
+	return doc, nil
+}
+
+// ListTags returns the tags on the user's documents with their document
+// counts, most used first.
+func (s *Service) ListTags(ctx context.Context, userID int64) ([]TagCount, error) {
+	if userID == 0 {
+		return nil, apperrors.Wrap("unauthorized", "missing user", nil)
+	}
+	docs, err := s.docs.List(ctx, userID, DocumentFilter{})
+	if err != nil {
+		return nil, apperrors.Wrap("storage_error", "failed to list documents", err)
+	}
+	counts := make(map[string]int)
+	for _, doc := range docs {
+		for _, tag := range doc.Tags {
+			counts[tag]++
+		}
+	}
+	out := make([]TagCount, 0, len(counts))
+	for tag, n := range counts {
+		out = append(out, TagCount{Tag: tag, Documents: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Documents != out[j].Documents {
+			return out[i].Documents > out[j].Documents
+		}
+		return out[i].Tag < out[j].Tag
+	})
+	return out, nil
+}
+
+// normalizeScope validates the filter's labels and brings tags and MIME types
+// to the form they are stored in.
+func normalizeScope(filter DocumentFilter) (DocumentFilter, error) {
+	tags, err := normalizeTags(filter.Tags)
+	if err != nil {
+		return DocumentFilter{}, err
+	}
+	filter.Tags = tags
+	var mimeTypes []string
+	for _, mimeType := range filter.MimeTypes {
+		if mimeType = strings.ToLower(strings.TrimSpace(mimeType)); mimeType != "" {
+			mimeTypes = append(mimeTypes, mimeType)
+		}
+	}
+	filter.MimeTypes = mimeTypes
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && !filter.CreatedAfter.Before(*filter.CreatedBefore) {
+		return DocumentFilter{}, apperrors.Wrap("invalid_input", "createdAfter must be before createdBefore", nil)
+	}
+	return filter, nil
+}
+
+// normalizeTags lowercases, deduplicates and sorts tags. Tags may hold
+// letters, digits, '-', '_', '.' and ':'.
+func normalizeTags(tags []string) ([]string, error) {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, apperrors.Wrap("invalid_input", fmt.Sprintf("tag %q is longer than %d characters", tag, maxTagLength), nil)
+		}
+		if strings.IndexFunc(tag, func(r rune) bool { return !validTagRune(r) }) >= 0 {
+			return nil, apperrors.Wrap("invalid_input", fmt.Sprintf("tag %q may only contain letters, digits, '-', '_', '.' and ':'", tag), nil)
+		}
+		out = append(out, tag)
+	}
+	sort.Strings(out)
+	out = slices.Compact(out)
+	if len(out) > maxDocumentTags {
+		return nil, apperrors.Wrap("invalid_input", fmt.Sprintf("a document can have at most %d tags", maxDocumentTags), nil)
+	}
+	return out, nil
+}
+
+func validTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_.:", r)
+}
+
+// normalizeMetadata trims keys and checks the metadata limits.
+func normalizeMetadata(metadata map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, apperrors.Wrap("invalid_input", "metadata keys cannot be empty", nil)
+		}
+		if len(key) > maxMetadataKeyLen {
+			return nil, apperrors.Wrap("invalid_input", fmt.Sprintf("metadata key %q is longer than %d characters", key, maxMetadataKeyLen), nil)
+		}
+		if len(value) > maxMetadataValueLen {
+			return nil, apperrors.Wrap("invalid_input", fmt.Sprintf("metadata value for %q is longer than %d characters", key, maxMetadataValueLen), nil)
+		}
+		out[key] = value
+	}
+	if len(out) > maxMetadataKeys {
+		return nil, apperrors.Wrap("invalid_input", fmt.Sprintf("a document can have at most %d metadata keys", maxMetadataKeys), nil)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
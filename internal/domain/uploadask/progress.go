@@ -0,0 +1,141 @@
+package uploadask
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProcessingStage names a step of the document pipeline.
+type ProcessingStage string
+
+const (
+	ProcessingStageExtract ProcessingStage = "extract"
+	ProcessingStageChunk   ProcessingStage = "chunk"
+	ProcessingStageEmbed   ProcessingStage = "embed"
+	ProcessingStagePersist ProcessingStage = "persist"
+)
+
+// defaultProgressPollInterval is how often WatchDocument reloads the document
+// when Config.ProgressPollInterval is unset.
+const defaultProgressPollInterval = 500 * time.Millisecond
+
+// DocumentProgress reports how far processing has come. Stage is the current
+// (or, once finished, the last) stage; Stages holds the timing of every stage
+// reached so far, in pipeline order.
+type DocumentProgress struct {
+	Stage       ProcessingStage `json:"stage"`
+	ChunksDone  int             `json:"chunksDone"`
+	ChunksTotal int             `json:"chunksTotal"`
+	Stages      []StageTiming   `json:"stages"`
+}
+
+// StageTiming records when a stage started and, once it has, finished.
+type StageTiming struct {
+	Stage      ProcessingStage `json:"stage"`
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+// progressTracker accumulates progress for one processing run and saves it
+// after every change. Save failures are logged, never fatal.
+type progressTracker struct {
+	s        *Service
+	docID    uuid.UUID
+	progress DocumentProgress
+}
+
+func (s *Service) newProgressTracker(docID uuid.UUID) *progressTracker {
+	return &progressTracker{s: s, docID: docID}
+}
+
+// start closes the running stage and opens stage.
+func (t *progressTracker) start(ctx context.Context, stage ProcessingStage) {
+	t.closeStage()
+	t.progress.Stage = stage
+	t.progress.Stages = append(t.progress.Stages, StageTiming{Stage: stage, StartedAt: time.Now()})
+	t.save(ctx)
+}
+
+func (t *progressTracker) chunks(ctx context.Context, done, total int) {
+	t.progress.ChunksDone = done
+	t.progress.ChunksTotal = total
+	t.save(ctx)
+}
+
+// finish closes the running stage.
+func (t *progressTracker) finish(ctx context.Context) {
+	t.closeStage()
+	t.save(ctx)
+}
+
+func (t *progressTracker) closeStage() {
+	if n := len(t.progress.Stages); n > 0 && t.progress.Stages[n-1].FinishedAt == nil {
+		now := time.Now()
+		t.progress.Stages[n-1].FinishedAt = &now
+	}
+}
+
+func (t *progressTracker) save(ctx context.Context) {
+	snapshot := t.progress
+	snapshot.Stages = append([]StageTiming(nil), t.progress.Stages...)
+	if err := t.s.docs.UpdateProgress(ctx, t.docID, snapshot); err != nil {
+		t.s.logger.Warn("update document progress failed", "document_id", t.docID, "error", err)
+	}
+}
+
+// WatchDocument streams the document every time its status or progress
+// changes, starting with its current state. The channel closes once the
+// document is processed or failed, is deleted, or ctx ends.
+func (s *Service) WatchDocument(ctx context.Context, userID int64, docID uuid.UUID) (<-chan Document, error) {
+	doc, err := s.GetDocument(ctx, userID, docID)
+	if err != nil {
+		return nil, err
+	}
+	interval := s.cfg.ProgressPollInterval
+	if interval <= 0 {
+		interval = defaultProgressPollInterval
+	}
+	out := make(chan Document)
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last := doc
+		select {
+		case out <- doc:
+		case <-ctx.Done():
+			return
+		}
+		for !isTerminalStatus(last.Status) {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			current, found, err := s.docs.Get(ctx, docID, doc.UserID)
+			if err != nil {
+				s.logger.Warn("watch document reload failed", "document_id", docID, "error", err)
+				continue
+			}
+			if !found {
+				return
+			}
+			if current.Status == last.Status && current.UpdatedAt.Equal(last.UpdatedAt) {
+				continue
+			}
+			last = current
+			select {
+			case out <- current:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func isTerminalStatus(status DocumentStatus) bool {
+	return status == DocumentStatusProcessed || status == DocumentStatusFailed
+}
@@ -0,0 +1,130 @@
+package uploadask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// QuotaConfig caps what one user's documents may consume in total. A zero
+// limit is off. Limits are checked before work starts, so concurrent uploads
+// can overshoot them slightly.
+type QuotaConfig struct {
+	MaxBytes     int64
+	MaxDocuments int
+	MaxChunks    int
+}
+
+// StorageUsage is what a user's documents consume.
+type StorageUsage struct {
+	Documents int   `json:"documents"`
+	Bytes     int64 `json:"bytes"`
+	Chunks    int   `json:"chunks"`
+}
+
+// UsageReport compares a user's consumption with their quota; a zero limit
+// is unlimited.
+type UsageReport struct {
+	Used   StorageUsage `json:"used"`
+	Limits StorageUsage `json:"limits"`
+}
+
+// errChunkQuota stops chunking once a document outgrows the chunk quota.
+var errChunkQuota = errors.New("chunk quota exceeded")
+
+// Usage reports the user's current consumption against their quota.
+func (s *Service) Usage(ctx context.Context, userID int64) (UsageReport, error) {
+	if userID == 0 {
+		return UsageReport{}, apperrors.Wrap("unauthorized", "missing user", nil)
+	}
+	used, err := s.usage(ctx, userID)
+	if err != nil {
+		return UsageReport{}, err
+	}
+	return UsageReport{
+		Used: used,
+		Limits: StorageUsage{
+			Documents: s.cfg.Quota.MaxDocuments,
+			Bytes:     s.cfg.Quota.MaxBytes,
+			Chunks:    s.cfg.Quota.MaxChunks,
+		},
+	}, nil
+}
+
+func (s *Service) usage(ctx context.Context, userID int64) (StorageUsage, error) {
+	usage, err := s.files.UsageByUser(ctx, userID)
+	if err != nil {
+		return StorageUsage{}, apperrors.Wrap("storage_error", "failed to load storage usage", err)
+	}
+	if usage.Chunks, err = s.chunks.CountByUser(ctx, userID); err != nil {
+		return StorageUsage{}, apperrors.Wrap("storage_error", "failed to count chunks", err)
+	}
+	return usage, nil
+}
+
+// checkQuota loads the user's usage and rejects one more document of size
+// bytes; size 0 checks only that some room is left.
+func (s *Service) checkQuota(ctx context.Context, userID int64, size int64) (StorageUsage, error) {
+	quota := s.cfg.Quota
+	if quota == (QuotaConfig{}) {
+		return StorageUsage{}, nil
+	}
+	usage, err := s.usage(ctx, userID)
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	switch {
+	case quota.MaxDocuments > 0 && usage.Documents >= quota.MaxDocuments:
+		return usage, quotaError(fmt.Sprintf("document limit of %d reached", quota.MaxDocuments))
+	case quota.MaxChunks > 0 && usage.Chunks >= quota.MaxChunks:
+		return usage, quotaError(fmt.Sprintf("chunk limit of %d reached", quota.MaxChunks))
+	case quota.MaxBytes > 0 && usage.Bytes+max(size, 1) > quota.MaxBytes:
+		return usage, quotaError(fmt.Sprintf("storage limit of %d bytes reached", quota.MaxBytes))
+	}
+	return usage, nil
+}
+
+// uploadLimit is the most bytes the next upload may hold: MaxFileBytes or the
+// room left under the byte quota, whichever is smaller. byQuota reports that
+// the quota is the tighter bound.
+func (s *Service) uploadLimit(usage StorageUsage) (limit int64, byQuota bool) {
+	limit = s.cfg.MaxFileBytes
+	if s.cfg.Quota.MaxBytes <= 0 {
+		return limit, false
+	}
+	room := s.cfg.Quota.MaxBytes - usage.Bytes
+	if limit > 0 && limit <= room {
+		return limit, false
+	}
+	return room, true
+}
+
+// chunkAllowance is how many chunks the document may hold without taking the
+// user past the chunk quota, or 0 when chunks are unlimited. Its own existing
+// chunks do not count against it.
+func (s *Service) chunkAllowance(ctx context.Context, userID int64, docID uuid.UUID) (int, error) {
+	if s.cfg.Quota.MaxChunks <= 0 {
+		return 0, nil
+	}
+	total, err := s.chunks.CountByUser(ctx, userID)
+	if err != nil {
+		return 0, apperrors.Wrap("storage_error", "failed to count chunks", err)
+	}
+	own, err := s.chunks.CountByDocument(ctx, docID)
+	if err != nil {
+		return 0, apperrors.Wrap("storage_error", "failed to count chunks", err)
+	}
+	allowance := s.cfg.Quota.MaxChunks - (total - own)
+	if allowance <= 0 {
+		return 0, quotaError(fmt.Sprintf("chunk limit of %d reached", s.cfg.Quota.MaxChunks))
+	}
+	return allowance, nil
+}
+
+func quotaError(msg string) error {
+	return apperrors.Wrap("quota_exceeded", msg, nil)
+}
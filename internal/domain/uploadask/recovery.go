@@ -0,0 +1,97 @@
+package uploadask
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// Recovery defaults applied when the matching Config field is unset.
+const (
+	defaultRecoveryStaleAfter  = 10 * time.Minute
+	defaultRecoveryMaxAttempts = 3
+)
+
+// RecoveryResult counts what RecoverStuckDocuments did.
+type RecoveryResult struct {
+	Requeued int
+	Failed   int
+}
+
+// RecoverStuckDocuments re-enqueues pending or processing documents that have
+// not changed for Config.RecoveryStaleAfter, typically because the process
+// died mid-job. Every recovery counts as an attempt; documents recovered more
+// than Config.RecoveryMaxAttempts times are marked failed instead so a poison
+// document cannot loop forever. Documents whose job is still queued or
+// running in a persistent queue are left to that job and cost no attempt.
+func (s *Service) RecoverStuckDocuments(ctx context.Context) (RecoveryResult, error) {
+	if s.queue == nil {
+		return RecoveryResult{}, apperrors.Wrap("unavailable", "processing queue is not configured", nil)
+	}
+	staleAfter := s.cfg.RecoveryStaleAfter
+	if staleAfter <= 0 {
+		staleAfter = defaultRecoveryStaleAfter
+	}
+	maxAttempts := s.cfg.RecoveryMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultRecoveryMaxAttempts
+	}
+	stuck, err := s.docs.ListStale(ctx, []DocumentStatus{DocumentStatusPending, DocumentStatusProcessing}, time.Now().Add(-staleAfter))
+	if err != nil {
+		return RecoveryResult{}, apperrors.Wrap("storage_error", "failed to find stuck documents", err)
+	}
+	live, err := s.documentsWithLiveJobs(ctx)
+	if err != nil {
+		return RecoveryResult{}, err
+	}
+	var result RecoveryResult
+	for _, doc := range stuck {
+		if live[doc.ID] {
+			continue
+		}
+		attempts, err := s.docs.IncrementRecoveryAttempts(ctx, doc.ID)
+		if err != nil {
+			return result, apperrors.Wrap("storage_error", "failed to record recovery attempt", err)
+		}
+		if attempts > maxAttempts {
+			reason := fmt.Sprintf("processing did not finish after %d recovery attempts", maxAttempts)
+			if err := s.docs.UpdateStatus(ctx, doc.ID, DocumentStatusFailed, &reason); err != nil {
+				return result, apperrors.Wrap("storage_error", "failed to fail stuck document", err)
+			}
+			s.logger.Warn("stuck document failed", "document_id", doc.ID, "user_id", doc.UserID, "attempts", attempts-1)
+			result.Failed++
+			continue
+		}
+		if err := s.enqueueProcess(ctx, doc.UserID, doc.ID); err != nil {
+			return result, apperrors.Wrap("queue_error", "failed to requeue stuck document", err)
+		}
+		s.logger.Info("stuck document requeued", "document_id", doc.ID, "user_id", doc.UserID, "status", doc.Status, "attempt", attempts)
+		result.Requeued++
+	}
+	return result, nil
+}
+
+// documentsWithLiveJobs returns the documents that have a processing job
+// waiting or running. Queues that do not persist jobs report none.
+func (s *Service) documentsWithLiveJobs(ctx context.Context) (map[uuid.UUID]bool, error) {
+	inspector, ok := s.queue.(JobInspector)
+	if !ok {
+		return nil, nil
+	}
+	jobs, err := inspector.LiveJobs(ctx, ProcessJobName)
+	if err != nil {
+		return nil, apperrors.Wrap("queue_error", "failed to list queued jobs", err)
+	}
+	live := make(map[uuid.UUID]bool, len(jobs))
+	for _, job := range jobs {
+		raw, _ := job.Payload["document_id"].(string)
+		if id, err := uuid.Parse(raw); err == nil {
+			live[id] = true
+		}
+	}
+	return live, nil
+}
@@ -0,0 +1,114 @@
+package uploadask
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// ReindexJobName is the queue job that rebuilds a document's chunks.
+const ReindexJobName = "reindex_document"
+
+// QueueReindex schedules one document to be re-chunked and re-embedded with
+// the current configuration.
+func (s *Service) QueueReindex(ctx context.Context, userID int64, docID uuid.UUID) error {
+	if _, err := s.accessibleDocument(ctx, userID, docID, accessOwner); err != nil {
+		return err
+	}
+	return s.enqueueReindex(ctx, userID, docID)
+}
+
+// QueueReindexAll schedules the user's documents for reindexing and returns
+// how many were queued. With staleOnly set, only documents whose chunks were
+// built by a different embedding model or index version are queued.
+func (s *Service) QueueReindexAll(ctx context.Context, userID int64, staleOnly bool) (int, error) {
+	if userID == 0 {
+		return 0, apperrors.Wrap("unauthorized", "missing user", nil)
+	}
+	var ids []uuid.UUID
+	if staleOnly {
+		stale, err := s.chunks.ListStaleDocuments(ctx, userID, s.cfg.EmbeddingModel, s.cfg.IndexVersion)
+		if err != nil {
+			return 0, apperrors.Wrap("storage_error", "failed to find stale documents", err)
+		}
+		ids = stale
+	} else {
+		docs, err := s.docs.List(ctx, userID, DocumentFilter{})
+		if err != nil {
+			return 0, apperrors.Wrap("storage_error", "failed to list documents", err)
+		}
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+	}
+	for i, id := range ids {
+		if err := s.enqueueReindex(ctx, userID, id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+func (s *Service) enqueueReindex(ctx context.Context, userID int64, docID uuid.UUID) error {
+	if s.queue == nil {
+		return apperrors.Wrap("unavailable", "processing queue is not configured", nil)
+	}
+	payload := map[string]any{
+		"document_id": docID.String(),
+		"user_id":     userID,
+	}
+	if err := s.queue.Enqueue(ctx, ReindexJobName, payload); err != nil {
+		return apperrors.Wrap("queue_error", "failed to enqueue reindex", err)
+	}
+	return nil
+}
+
+// ReindexDocument rebuilds the chunks of a processed document. The old chunks
+// are only replaced once the new ones are embedded, so a failed reindex leaves
+// the document answerable. Documents that never finished processing go
+// through ProcessDocument instead.
+func (s *Service) ReindexDocument(ctx context.Context, docID uuid.UUID, userID int64) error {
+	doc, found, err := s.docs.Get(ctx, docID, userID)
+	if err != nil {
+		return apperrors.Wrap("storage_error", "failed to load document", err)
+	}
+	if !found {
+		return apperrors.Wrap("not_found", "document not found", nil)
+	}
+	if doc.Status != DocumentStatusProcessed {
+		return s.ProcessDocument(ctx, docID, userID)
+	}
+
+	maxChunks, err := s.chunkAllowance(ctx, userID, docID)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("reindex_document start", "document_id", docID, "user_id", userID)
+	tracker := s.newProgressTracker(docID)
+	// The old chunks keep serving answers until every new one is embedded, so
+	// the rebuilt set is held in memory.
+	var chunks []DocumentChunk
+	_, _, err = s.buildChunks(ctx, docID, false, maxChunks, tracker, func(_ context.Context, built []DocumentChunk) error {
+		chunks = append(chunks, built...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	tracker.start(ctx, ProcessingStagePersist)
+	if err := s.chunks.DeleteByDocument(ctx, docID); err != nil {
+		return apperrors.Wrap("storage_error", "failed to delete old chunks", err)
+	}
+	if err := s.chunks.InsertBatch(ctx, chunks); err != nil {
+		_ = s.docs.UpdateStatus(ctx, docID, DocumentStatusFailed, ptrString("persisting chunks failed"))
+		return apperrors.Wrap("storage_error", "failed to persist chunks", err)
+	}
+	tracker.finish(ctx)
+	if err := s.docs.UpdateStatus(ctx, docID, DocumentStatusProcessed, nil); err != nil {
+		return apperrors.Wrap("storage_error", "failed to finalize document", err)
+	}
+	s.logger.Info("reindex_document complete", "document_id", docID, "user_id", userID, "chunks", len(chunks))
+	return nil
+}
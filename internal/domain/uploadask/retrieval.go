@@ -0,0 +1,159 @@
+package uploadask
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// RetrievalMode selects how Ask finds candidate chunks.
+type RetrievalMode string
+
+const (
+	// RetrievalModeVector ranks chunks by embedding similarity only.
+	RetrievalModeVector RetrievalMode = "vector"
+	// RetrievalModeLexical ranks chunks by BM25 keyword relevance only.
+	RetrievalModeLexical RetrievalMode = "lexical"
+	// RetrievalModeHybrid fuses the vector and lexical rankings.
+	RetrievalModeHybrid RetrievalMode = "hybrid"
+)
+
+// rrfK dampens the advantage of top ranks in reciprocal rank fusion. 60 is
+// the constant from the original RRF paper and works well without tuning.
+const rrfK = 60
+
+func (s *Service) resolveRetrievalMode(requested RetrievalMode) (RetrievalMode, error) {
+	mode := RetrievalMode(strings.ToLower(strings.TrimSpace(string(requested))))
+	if mode == "" {
+		mode = s.cfg.RetrievalMode
+	}
+	switch mode {
+	case "":
+		return RetrievalModeHybrid, nil
+	case RetrievalModeVector, RetrievalModeLexical, RetrievalModeHybrid:
+		return mode, nil
+	}
+	return "", apperrors.Wrap("invalid_input", "retrievalMode must be vector, lexical or hybrid", nil)
+}
+
+// retrieve runs the searches required by mode in every scope and returns
+// chunks ordered by relevance. Vector scores are cosine similarities and
+// merge across scopes as they are; BM25 scores depend on each owner's corpus,
+// so lexical lists from several scopes are fused by rank. Hybrid results
+// carry the fused RRF score.
+func (s *Service) retrieve(ctx context.Context, query string, embedding []float32, scopes []searchScope, mode RetrievalMode) ([]RetrievedChunk, error) {
+	var vector, lexical [][]RetrievedChunk
+	for _, scope := range scopes {
+		if mode != RetrievalModeLexical {
+			found, err := s.chunks.SearchSimilar(ctx, scope.ownerID, embedding, scope.filter)
+			if err != nil {
+				return nil, apperrors.Wrap("storage_error", "search failed", err)
+			}
+			vector = append(vector, found)
+		}
+		if mode != RetrievalModeVector {
+			found, err := s.chunks.SearchLexical(ctx, scope.ownerID, query, scope.filter)
+			if err != nil {
+				return nil, apperrors.Wrap("storage_error", "keyword search failed", err)
+			}
+			lexical = append(lexical, found)
+		}
+	}
+	switch mode {
+	case RetrievalModeVector:
+		return mergeByScore(vector), nil
+	case RetrievalModeLexical:
+		return fuseScopes(lexical), nil
+	}
+	return fuseReciprocalRank(mergeByScore(vector), fuseScopes(lexical)), nil
+}
+
+// mergeByScore merges per-scope rankings whose scores are comparable, best
+// first.
+func mergeByScore(lists [][]RetrievedChunk) []RetrievedChunk {
+	if len(lists) == 1 {
+		return lists[0]
+	}
+	var merged []RetrievedChunk
+	for _, list := range lists {
+		merged = append(merged, list...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+	return merged
+}
+
+// fuseScopes merges per-scope lexical rankings by rank, keeping the raw
+// scores when there is only one.
+func fuseScopes(lists [][]RetrievedChunk) []RetrievedChunk {
+	if len(lists) == 1 {
+		return lists[0]
+	}
+	return fuseReciprocalRank(lists...)
+}
+
+type chunkKey struct {
+	documentID uuid.UUID
+	index      int
+}
+
+// fuseReciprocalRank merges ranked lists by summing 1/(rrfK+rank) for every
+// list a chunk appears in. Ties keep the order of first appearance.
+func fuseReciprocalRank(lists ...[]RetrievedChunk) []RetrievedChunk {
+	scores := make(map[chunkKey]float64)
+	var fused []RetrievedChunk
+	for _, list := range lists {
+		for rank, rc := range list {
+			key := chunkKey{documentID: rc.Chunk.DocumentID, index: rc.Chunk.ChunkIndex}
+			if _, seen := scores[key]; !seen {
+				fused = append(fused, rc)
+			}
+			scores[key] += 1 / float64(rrfK+rank+1)
+		}
+	}
+	for i := range fused {
+		fused[i].Score = scores[chunkKey{documentID: fused[i].Chunk.DocumentID, index: fused[i].Chunk.ChunkIndex}]
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	return fused
+}
+
+// rerank rescores the leading retrieval candidates with the configured
+// reranker and orders them by rerank score. Candidates beyond the rerank
+// window are dropped because they can no longer reach the top K. Reranker
+// failures are logged and the retrieval order is kept.
+func (s *Service) rerank(ctx context.Context, query string, results []RetrievedChunk, topK int) []RetrievedChunk {
+	if s.reranker == nil || len(results) == 0 {
+		return results
+	}
+	window := max(s.cfg.RerankCandidates, topK)
+	if len(results) > window {
+		results = results[:window]
+	}
+	scores, err := s.reranker.Rerank(ctx, query, results)
+	if err == nil && len(scores) != len(results) {
+		err = fmt.Errorf("reranker returned %d scores for %d candidates", len(scores), len(results))
+	}
+	if err != nil {
+		s.logger.Warn("rerank failed, keeping retrieval order", "error", err)
+		return results
+	}
+	reranked := make([]RetrievedChunk, len(results))
+	copy(reranked, results)
+	for i := range reranked {
+		score := scores[i]
+		reranked[i].RerankScore = &score
+	}
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return *reranked[i].RerankScore > *reranked[j].RerankScore
+	})
+	return reranked
+}
@@ -2,10 +2,13 @@ package uploadask
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -21,7 +24,34 @@ type Config struct {
 	MaxFileBytes    int64
 	MaxRetrieved    int
 	MaxPreviewChars int
-	Memory          MemoryConfig
+	RetrievalMode   RetrievalMode
+	// RerankCandidates is how many retrieved chunks are passed to the
+	// reranker before the top K are kept.
+	RerankCandidates int
+	// EmbeddingModel and IndexVersion are stamped on every chunk. IndexVersion
+	// should change whenever the vector dimension or chunker settings do.
+	EmbeddingModel string
+	IndexVersion   string
+	// EmbedBatchSize is how many chunks are embedded per call; progress is
+	// reported after every batch. Up to EmbedConcurrency batches run at once,
+	// and a failed batch is retried up to EmbedMaxAttempts times, waiting
+	// EmbedBaseBackoff and doubling after each failure.
+	EmbedBatchSize   int
+	EmbedConcurrency int
+	EmbedMaxAttempts int
+	EmbedBaseBackoff time.Duration
+	// ProgressPollInterval is how often WatchDocument checks for changes.
+	ProgressPollInterval time.Duration
+	Memory               MemoryConfig
+	// RecoveryStaleAfter is how long a pending or processing document must
+	// sit unchanged before RecoverStuckDocuments requeues it, at most
+	// RecoveryMaxAttempts times.
+	RecoveryStaleAfter  time.Duration
+	RecoveryMaxAttempts int
+	// DirectUploadTTL is how long a presigned direct upload URL, and the
+	// intent behind it, stays valid.
+	DirectUploadTTL time.Duration
+	Quota           QuotaConfig
 }
 
 // MemoryConfig controls conversational memory behavior.
@@ -36,53 +66,75 @@ type MemoryConfig struct {
 
 // Service orchestrates the Upload-and-Ask workflows.
 type Service struct {
-	cfg      Config
-	docs     DocumentRepository
-	files    FileObjectRepository
-	chunks   ChunkRepository
-	sessions QASessionRepository
-	logs     QueryLogRepository
-	storage  ObjectStorage
-	embedder Embedder
-	llm      LLM
-	chunker  Chunker
-	queue    JobQueue
-	messages MessageLog
-	memories MemoryStore
-	logger   *slog.Logger
+	cfg         Config
+	docs        DocumentRepository
+	files       FileObjectRepository
+	intents     UploadIntentRepository
+	collections CollectionRepository
+	shares      ShareRepository
+	chunks      ChunkRepository
+	sessions    QASessionRepository
+	logs        QueryLogRepository
+	storage     ObjectStorage
+	embedder    Embedder
+	llm         LLM
+	reranker    Reranker
+	chunker     Chunker
+	extractor   TextExtractor
+	fetcher     URLFetcher
+	queue       JobQueue
+	messages    MessageLog
+	memories    MemoryStore
+	logger      *slog.Logger
 }
 
 // NewService constructs a Service.
-func NewService(cfg Config, docs DocumentRepository, files FileObjectRepository, chunks ChunkRepository, sessions QASessionRepository, logs QueryLogRepository, messages MessageLog, memories MemoryStore, storage ObjectStorage, embedder Embedder, llm LLM, chunker Chunker, queue JobQueue, logger *slog.Logger) *Service {
+func NewService(cfg Config, docs DocumentRepository, files FileObjectRepository, intents UploadIntentRepository, collections CollectionRepository, shares ShareRepository, chunks ChunkRepository, sessions QASessionRepository, logs QueryLogRepository, messages MessageLog, memories MemoryStore, storage ObjectStorage, embedder Embedder, llm LLM, reranker Reranker, chunker Chunker, extractor TextExtractor, fetcher URLFetcher, queue JobQueue, logger *slog.Logger) *Service {
 	return &Service{
-		cfg:      cfg,
-		docs:     docs,
-		files:    files,
-		chunks:   chunks,
-		sessions: sessions,
-		logs:     logs,
-		messages: messages,
-		memories: memories,
-		storage:  storage,
-		embedder: embedder,
-		llm:      llm,
-		chunker:  chunker,
-		queue:    queue,
-		logger:   logger.With("component", "uploadask.service"),
-	}
-}
-
-// UploadRequest captures a multipart submission.
+		cfg:         cfg,
+		docs:        docs,
+		files:       files,
+		intents:     intents,
+		collections: collections,
+		shares:      shares,
+		chunks:      chunks,
+		sessions:    sessions,
+		logs:        logs,
+		messages:    messages,
+		memories:    memories,
+		storage:     storage,
+		embedder:    embedder,
+		llm:         llm,
+		reranker:    reranker,
+		chunker:     chunker,
+		extractor:   extractor,
+		fetcher:     fetcher,
+		queue:       queue,
+		logger:      logger.With("component", "uploadask.service"),
+	}
+}
+
+// UploadRequest captures a multipart submission. Content is read once and
+// streamed into storage.
 type UploadRequest struct {
 	Filename string
 	Title    string
 	MimeType string
-	Content  []byte
+	Content  io.Reader
 }
 
-// UploadResponse returns document metadata after enqueueing.
+// URLIngestRequest asks the service to fetch and ingest a remote document.
+type URLIngestRequest struct {
+	URL   string
+	Title string
+}
+
+// UploadResponse returns document metadata after enqueueing. Duplicate is set
+// when the user already had a document with the same content; that document
+// is returned and nothing is processed again.
 type UploadResponse struct {
-	Document Document `json:"document"`
+	Document  Document `json:"document"`
+	Duplicate bool     `json:"duplicate,omitempty"`
 }
 
 // AskRequest contains the question payload.
@@ -94,6 +146,17 @@ type AskRequest struct {
 	TopKMems         *int
 	MaxHistoryTokens *int
 	IncludeHistory   *bool
+	RetrievalMode    RetrievalMode
+	// Tags, Metadata, MimeTypes and the creation range scope retrieval like
+	// the DocumentFilter fields of the same names.
+	Tags          []string
+	Metadata      map[string]string
+	MimeTypes     []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// CollectionID binds a new session to a collection. For an existing
+	// session it must match the collection the session is bound to.
+	CollectionID *uuid.UUID
 }
 
 // AskResponse is returned to the HTTP handler.
@@ -111,12 +174,56 @@ func (s *Service) Upload(ctx context.Context, userID int64, req UploadRequest) (
 	if userID == 0 {
 		return UploadResponse{}, apperrors.Wrap("unauthorized", "missing user", nil)
 	}
-	if len(req.Content) == 0 {
+	if req.Content == nil {
 		return UploadResponse{}, apperrors.Wrap("invalid_input", "file content cannot be empty", nil)
 	}
-	if s.cfg.MaxFileBytes > 0 && int64(len(req.Content)) > s.cfg.MaxFileBytes {
-		return UploadResponse{}, apperrors.Wrap("invalid_input", "file exceeds maximum allowed size", nil)
+	return s.ingest(ctx, userID, DocumentSourceUpload, nil, req, "file")
+}
+
+// MaxFileBytes is the largest accepted upload; 0 means unlimited.
+func (s *Service) MaxFileBytes() int64 {
+	return s.cfg.MaxFileBytes
+}
+
+// IngestURL fetches a remote document and runs it through the upload pipeline.
+func (s *Service) IngestURL(ctx context.Context, userID int64, req URLIngestRequest) (UploadResponse, error) {
+	if userID == 0 {
+		return UploadResponse{}, apperrors.Wrap("unauthorized", "missing user", nil)
 	}
+	if s.fetcher == nil {
+		return UploadResponse{}, apperrors.Wrap("unavailable", "url ingestion is not configured", nil)
+	}
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return UploadResponse{}, apperrors.Wrap("invalid_input", "url must be an absolute http or https URL", err)
+	}
+	target.Fragment = ""
+	fetched, err := s.fetcher.Fetch(ctx, target.String(), s.cfg.MaxFileBytes)
+	if err != nil {
+		if errors.Is(err, ErrFileTooLarge) {
+			return UploadResponse{}, apperrors.Wrap("invalid_input", "remote document exceeds maximum allowed size", err)
+		}
+		return UploadResponse{}, apperrors.Wrap("url_fetch_failed", "failed to fetch url", err)
+	}
+	defer fetched.Body.Close()
+	sourceURL := target.String()
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		title = sourceURL
+	}
+	return s.ingest(ctx, userID, DocumentSourceURL, &sourceURL, UploadRequest{
+		Filename: fetched.Filename,
+		Title:    title,
+		MimeType: fetched.MimeType,
+		Content:  fetched.Body,
+	}, "remote document")
+}
+
+// ingest streams the content into storage while hashing and measuring it,
+// then records the document and enqueues processing. The blob is written
+// before the document exists, so a too large, empty or duplicate upload only
+// costs a blob that is deleted again. subject names the content in errors.
+func (s *Service) ingest(ctx context.Context, userID int64, source DocumentSource, sourceURL *string, req UploadRequest, subject string) (UploadResponse, error) {
 	filename := strings.TrimSpace(req.Filename)
 	if filename == "" {
 		filename = "document.txt"
@@ -125,54 +232,106 @@ func (s *Service) Upload(ctx context.Context, userID int64, req UploadRequest) (
 	if title == "" {
 		title = filename
 	}
+	usage, err := s.checkQuota(ctx, userID, 0)
+	if err != nil {
+		return UploadResponse{}, err
+	}
+	limit, byQuota := s.uploadLimit(usage)
+	docID := uuid.New()
+	body := newUploadReader(req.Content, limit)
+	mime := detectMimeType(filename, req.MimeType, body.peek())
+	obj, err := s.storage.Put(ctx, storageKey(userID, docID, filename), body, mime)
+	if err != nil {
+		if body.tooLarge || errors.Is(err, ErrFileTooLarge) {
+			if byQuota {
+				return UploadResponse{}, quotaError(fmt.Sprintf("%s exceeds the storage limit of %d bytes", subject, s.cfg.Quota.MaxBytes))
+			}
+			return UploadResponse{}, apperrors.Wrap("invalid_input", subject+" exceeds maximum allowed size", ErrFileTooLarge)
+		}
+		return UploadResponse{}, apperrors.Wrap("storage_error", "failed to store file", err)
+	}
+	discard := func() {
+		if err := s.storage.Delete(ctx, obj.Key); err != nil {
+			s.logger.Warn("delete unused upload blob failed", "key", obj.Key, "error", err)
+		}
+	}
+	if body.size == 0 {
+		discard()
+		return UploadResponse{}, apperrors.Wrap("invalid_input", subject+" is empty", nil)
+	}
+	hash := body.sum()
+	existing, found, err := s.findDuplicate(ctx, userID, hash)
+	if err != nil {
+		discard()
+		return UploadResponse{}, err
+	}
+	if found {
+		discard()
+		s.logger.Info("duplicate upload reuses document", "document_id", existing.ID, "user_id", userID)
+		return UploadResponse{Document: existing, Duplicate: true}, nil
+	}
+
 	now := time.Now()
 	doc := Document{
-		ID:        uuid.New(),
+		ID:        docID,
 		UserID:    userID,
 		Title:     title,
-		Source:    DocumentSourceUpload,
+		Source:    source,
+		SourceURL: sourceURL,
 		Status:    DocumentStatusPending,
+		MimeType:  obj.MimeType,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
-	if err := s.docs.Create(ctx, doc); err != nil {
-		return UploadResponse{}, apperrors.Wrap("storage_error", "failed to persist document", err)
-	}
-
-	mime := req.MimeType
-	if mime == "" {
-		mime = http.DetectContentType(req.Content)
-	}
-	storageKey := fmt.Sprintf("uploads/%d/%s/%s", userID, doc.ID.String(), sanitizeFilename(filename))
-	obj, err := s.storage.Put(ctx, storageKey, req.Content, mime)
-	if err != nil {
-		return UploadResponse{}, apperrors.Wrap("storage_error", "failed to store file", err)
+	file := FileObject{
+		ID:          uuid.New(),
+		DocumentID:  doc.ID,
+		UserID:      userID,
+		StorageKey:  obj.Key,
+		SizeBytes:   body.size,
+		MimeType:    obj.MimeType,
+		ETag:        obj.ETag,
+		ContentHash: hash,
+		CreatedAt:   now,
+	}
+	if err := s.register(ctx, doc, file); err != nil {
+		discard()
+		return UploadResponse{}, err
 	}
+	return UploadResponse{Document: doc}, nil
+}
 
-	file := FileObject{
-		ID:         uuid.New(),
-		DocumentID: doc.ID,
-		StorageKey: obj.Key,
-		SizeBytes:  obj.Size,
-		MimeType:   obj.MimeType,
-		ETag:       obj.ETag,
-		CreatedAt:  now,
+// register records a stored blob as a pending document and enqueues its
+// processing.
+func (s *Service) register(ctx context.Context, doc Document, file FileObject) error {
+	if err := s.docs.Create(ctx, doc); err != nil {
+		return apperrors.Wrap("storage_error", "failed to persist document", err)
 	}
 	if err := s.files.Create(ctx, file); err != nil {
-		return UploadResponse{}, apperrors.Wrap("storage_error", "failed to persist file metadata", err)
+		return apperrors.Wrap("storage_error", "failed to persist file metadata", err)
 	}
-
 	if s.queue != nil {
-		payload := map[string]any{
-			"document_id": doc.ID.String(),
-			"user_id":     userID,
-		}
-		if err := s.queue.Enqueue(ctx, "process_document", payload); err != nil {
+		if err := s.enqueueProcess(ctx, doc.UserID, doc.ID); err != nil {
 			s.logger.Warn("enqueue process_document failed", "error", err)
 		}
 	}
+	return nil
+}
 
-	return UploadResponse{Document: doc}, nil
+// storageKey is where a document's blob is stored.
+func storageKey(userID int64, docID uuid.UUID, filename string) string {
+	return fmt.Sprintf("uploads/%d/%s/%s", userID, docID.String(), sanitizeFilename(filename))
+}
+
+// ProcessJobName is the queue job that runs ProcessDocument.
+const ProcessJobName = "process_document"
+
+func (s *Service) enqueueProcess(ctx context.Context, userID int64, docID uuid.UUID) error {
+	payload := map[string]any{
+		"document_id": docID.String(),
+		"user_id":     userID,
+	}
+	return s.queue.Enqueue(ctx, ProcessJobName, payload)
 }
 
 // ProcessDocument extracts, chunks, embeds, and stores chunks.
@@ -193,76 +352,235 @@ func (s *Service) ProcessDocument(ctx context.Context, docID uuid.UUID, userID i
 		return apperrors.Wrap("storage_error", "failed to update status", err)
 	}
 
+	maxChunks, err := s.chunkAllowance(ctx, userID, docID)
+	if err != nil {
+		if apperrors.IsCode(err, "quota_exceeded") {
+			_ = s.docs.UpdateStatus(ctx, docID, DocumentStatusFailed, ptrString(err.Error()))
+		}
+		return err
+	}
+	tracker := s.newProgressTracker(docID)
+	count, reason, err := s.buildChunks(ctx, docID, true, maxChunks, tracker, s.chunks.InsertBatch)
+	if errors.Is(err, errStaleChunks) {
+		// The leftover chunks were discarded; embed the document from scratch.
+		tracker = s.newProgressTracker(docID)
+		count, reason, err = s.buildChunks(ctx, docID, false, maxChunks, tracker, s.chunks.InsertBatch)
+	}
+	if err != nil {
+		if apperrors.IsCode(err, "quota_exceeded") {
+			// The batches stored so far would otherwise count against the quota.
+			if delErr := s.chunks.DeleteByDocument(ctx, docID); delErr != nil {
+				s.logger.Warn("delete chunks over quota failed", "document_id", docID, "error", delErr)
+			}
+		}
+		if reason != "" {
+			_ = s.docs.UpdateStatus(ctx, docID, DocumentStatusFailed, &reason)
+		}
+		return err
+	}
+	// The chunks were stored batch by batch while embedding.
+	tracker.start(ctx, ProcessingStagePersist)
+	tracker.finish(ctx)
+	if err := s.docs.UpdateStatus(ctx, docID, DocumentStatusProcessed, nil); err != nil {
+		return apperrors.Wrap("storage_error", "failed to finalize document", err)
+	}
+	s.logger.Info("process_document complete", "document_id", docID, "user_id", userID, "chunks", count)
+	return nil
+}
+
+// chunkWindow is how many chunk candidates buildChunks collects before
+// embedding them, which bounds the memory a large document needs.
+const chunkWindow = 256
+
+// buildChunks streams the stored blob through extraction and chunking and
+// embeds the candidates a window at a time, stamped with the configured
+// embedding model and index version. Every embedded batch is handed to sink.
+// A document producing more than maxChunks chunks fails, unless maxChunks is 0.
+// With resume set, chunks stored by an earlier, interrupted run are reused
+// rather than embedded again. It returns the number of chunks; on failure it
+// also returns the reason to record on the document, or "" when the document
+// status should be left alone. Progress is reported through tracker; on long
+// documents extraction and chunking carry on during the embed stage.
+func (s *Service) buildChunks(ctx context.Context, docID uuid.UUID, resume bool, maxChunks int, tracker *progressTracker, sink func(context.Context, []DocumentChunk) error) (int, string, error) {
+	tracker.start(ctx, ProcessingStageExtract)
 	file, found, err := s.files.FindByDocument(ctx, docID)
 	if err != nil {
-		return apperrors.Wrap("storage_error", "failed to load file metadata", err)
+		return 0, "", apperrors.Wrap("storage_error", "failed to load file metadata", err)
 	}
 	if !found {
-		return apperrors.Wrap("not_found", "file not found for document", nil)
+		return 0, "", apperrors.Wrap("not_found", "file not found for document", nil)
 	}
 
+	var done map[int]string
+	if resume {
+		if done, err = s.resumableChunks(ctx, docID); err != nil {
+			return 0, "", err
+		}
+	}
 	reader, err := s.storage.Get(ctx, file.StorageKey)
 	if err != nil {
-		_ = s.docs.UpdateStatus(ctx, docID, DocumentStatusFailed, ptrString("failed to read storage"))
-		return apperrors.Wrap("storage_error", "failed to fetch stored file", err)
+		return 0, "failed to read storage", apperrors.Wrap("storage_error", "failed to fetch stored file", err)
 	}
 	defer reader.Close()
-	raw, err := io.ReadAll(reader)
-	if err != nil {
-		_ = s.docs.UpdateStatus(ctx, docID, DocumentStatusFailed, ptrString("failed to read storage"))
-		return apperrors.Wrap("storage_error", "failed to read stored file", err)
-	}
+	blob := &blobReader{r: reader}
 
-	candidates := s.chunker.Chunk(string(raw))
-	if len(candidates) == 0 {
-		reason := "no content to process"
-		_ = s.docs.UpdateStatus(ctx, docID, DocumentStatusFailed, &reason)
-		return apperrors.Wrap("invalid_input", reason, nil)
+	var (
+		window   []ChunkCandidate
+		total    int
+		embedded int
+		embedErr error
+	)
+	flush := func() error {
+		if len(window) == 0 {
+			return nil
+		}
+		if tracker.progress.Stage != ProcessingStageEmbed {
+			tracker.start(ctx, ProcessingStageEmbed)
+		}
+		if err := s.checkResumed(ctx, docID, window, done); err != nil {
+			return err
+		}
+		if err := s.embedChunks(ctx, docID, window, done, sink, tracker, embedded, total); err != nil {
+			return err
+		}
+		embedded += len(window)
+		window = window[:0]
+		return nil
 	}
-
-	texts := make([]string, 0, len(candidates))
-	for _, c := range candidates {
-		texts = append(texts, c.Content)
+	err = s.extractSections(ctx, file.MimeType, blob, func(page ExtractedPage) error {
+		if tracker.progress.Stage == ProcessingStageExtract {
+			tracker.start(ctx, ProcessingStageChunk)
+		}
+		for _, c := range s.chunker.Chunk(page.Text) {
+			if maxChunks > 0 && total >= maxChunks {
+				return errChunkQuota
+			}
+			c.Index = total
+			c.PageNumber = page.Number
+			total++
+			window = append(window, c)
+			if len(window) >= chunkWindow {
+				if embedErr = flush(); embedErr != nil {
+					return embedErr
+				}
+			}
+		}
+		return nil
+	})
+	switch {
+	case embedErr != nil:
+		return 0, embedFailureReason(embedErr), embedErr
+	case blob.err != nil:
+		return 0, "failed to read storage", apperrors.Wrap("storage_error", "failed to read stored file", blob.err)
+	case errors.Is(err, errChunkQuota):
+		reason := fmt.Sprintf("document exceeds the chunk limit of %d", s.cfg.Quota.MaxChunks)
+		return 0, reason, quotaError(reason)
+	case err != nil:
+		reason := "text extraction failed: " + err.Error()
+		code := "extraction_error"
+		if errors.Is(err, ErrUnsupportedFileType) {
+			reason = err.Error()
+			code = "unsupported_file_type"
+		}
+		return 0, reason, apperrors.Wrap(code, "text extraction failed", err)
 	}
-	embeddings, err := s.embedder.Embed(ctx, texts)
-	if err != nil {
-		_ = s.docs.UpdateStatus(ctx, docID, DocumentStatusFailed, ptrString("embedding failed"))
-		return apperrors.Wrap("embedding_error", "failed to embed chunks", err)
+	if tracker.progress.Stage == ProcessingStageExtract {
+		tracker.start(ctx, ProcessingStageChunk)
 	}
-	now := time.Now()
-	chunks := make([]DocumentChunk, 0, len(candidates))
-	for i, c := range candidates {
-		embedding := make([]float32, len(embeddings[i]))
-		copy(embedding, embeddings[i])
-		chunks = append(chunks, DocumentChunk{
-			ID:         uuid.New(),
-			DocumentID: docID,
-			ChunkIndex: c.Index,
-			Content:    c.Content,
-			TokenCount: c.TokenCount,
-			Embedding:  embedding,
-			CreatedAt:  now,
-		})
+	if total == 0 {
+		reason := "no content to process"
+		return 0, reason, apperrors.Wrap("invalid_input", reason, nil)
 	}
-	if err := s.chunks.InsertBatch(ctx, chunks); err != nil {
-		_ = s.docs.UpdateStatus(ctx, docID, DocumentStatusFailed, ptrString("persisting chunks failed"))
-		return apperrors.Wrap("storage_error", "failed to persist chunks", err)
+	if err := flush(); err != nil {
+		return 0, embedFailureReason(err), err
 	}
-	if err := s.docs.UpdateStatus(ctx, docID, DocumentStatusProcessed, nil); err != nil {
-		return apperrors.Wrap("storage_error", "failed to finalize document", err)
+	for index := range done {
+		if index >= total {
+			return 0, "", s.discardStaleChunks(ctx, docID)
+		}
 	}
-	s.logger.Info("process_document complete", "document_id", docID, "user_id", userID, "chunks", len(chunks))
-	return nil
+	return total, "", nil
+}
+
+// embedFailureReason is the document failure reason for an embedding error.
+// Stale resumed chunks leave the status alone, since ProcessDocument starts
+// over right away.
+func embedFailureReason(err error) string {
+	switch {
+	case errors.Is(err, errStaleChunks):
+		return ""
+	case apperrors.IsCode(err, "storage_error"):
+		return "persisting chunks failed"
+	default:
+		return "embedding failed"
+	}
+}
+
+// extractText converts the raw blob into page-aware text. Without a configured
+// extractor the blob is treated as a single page of plain text.
+func (s *Service) extractText(ctx context.Context, mimeType string, raw []byte) ([]ExtractedPage, error) {
+	if s.extractor == nil {
+		return []ExtractedPage{{Text: string(raw)}}, nil
+	}
+	return s.extractor.Extract(ctx, mimeType, raw)
 }
 
 // Ask performs similarity search then calls the LLM to answer.
 func (s *Service) Ask(ctx context.Context, userID int64, req AskRequest) (AskResponse, error) {
+	turn, err := s.prepareAsk(ctx, userID, req)
+	if err != nil {
+		return AskResponse{}, err
+	}
+	start := time.Now()
+	answer := s.answerWithPrompt(ctx, turn.query, turn.results, turn.memories, turn.messages)
+	latency := time.Since(start).Milliseconds()
+
+	s.recordTurn(ctx, turn, answer, latency)
+	return AskResponse{
+		SessionID:         turn.sessionID,
+		Answer:            answer,
+		Sources:           turn.sources,
+		Memories:          turn.memories,
+		UsedHistoryTokens: turn.usedHistoryTokens,
+		LatencyMs:         latency,
+	}, nil
+}
+
+// askTurn is the retrieval outcome and prompt for one question, shared by
+// Ask and AskStream.
+type askTurn struct {
+	userID            int64
+	sessionID         uuid.UUID
+	query             string
+	history           []ConversationMessage
+	usedHistoryTokens int
+	results           []RetrievedChunk
+	sources           []ChunkSource
+	memories          []RetrievedMemory
+	messages          []LLMMessage
+}
+
+// prepareAsk validates the request, resolves the session and runs retrieval,
+// reranking and memory search to build the prompt.
+func (s *Service) prepareAsk(ctx context.Context, userID int64, req AskRequest) (askTurn, error) {
 	if userID == 0 {
-		return AskResponse{}, apperrors.Wrap("unauthorized", "missing user", nil)
+		return askTurn{}, apperrors.Wrap("unauthorized", "missing user", nil)
 	}
 	query := strings.TrimSpace(req.Query)
 	if query == "" {
-		return AskResponse{}, apperrors.Wrap("invalid_input", "query cannot be empty", nil)
+		return askTurn{}, apperrors.Wrap("invalid_input", "query cannot be empty", nil)
+	}
+	scope, err := normalizeScope(DocumentFilter{
+		DocumentIDs:   req.DocumentIDs,
+		Statuses:      []DocumentStatus{DocumentStatusProcessed},
+		Tags:          req.Tags,
+		Metadata:      req.Metadata,
+		MimeTypes:     req.MimeTypes,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+	})
+	if err != nil {
+		return askTurn{}, err
 	}
 	topKDocs := req.TopK
 	if topKDocs <= 0 {
@@ -271,63 +589,70 @@ func (s *Service) Ask(ctx context.Context, userID int64, req AskRequest) (AskRes
 			topKDocs = 8
 		}
 	}
+	mode, err := s.resolveRetrievalMode(req.RetrievalMode)
+	if err != nil {
+		return askTurn{}, err
+	}
 	topKMems := s.resolveTopKMems(req.TopKMems)
 	maxHistoryTokens := s.resolveMaxHistoryTokens(req.MaxHistoryTokens)
 	includeHistory := s.shouldIncludeHistory(req.IncludeHistory)
 
-	sessionID, err := s.ensureSession(ctx, userID, req.SessionID)
+	session, err := s.ensureSession(ctx, userID, req.SessionID, req.CollectionID)
 	if err != nil {
-		return AskResponse{}, err
+		return askTurn{}, err
+	}
+	sessionID := session.ID
+	scopes, err := s.retrievalScopes(ctx, session, scope)
+	if err != nil {
+		return askTurn{}, err
 	}
 
 	history, usedHistoryTokens := s.loadHistory(ctx, userID, sessionID, maxHistoryTokens, includeHistory)
 	semanticQuery := s.buildSemanticQuery(query, history)
 	embedding, err := s.embedText(ctx, semanticQuery)
 	if err != nil {
-		return AskResponse{}, err
-	}
-	filter := DocumentFilter{
-		DocumentIDs: req.DocumentIDs,
-		Statuses:    []DocumentStatus{DocumentStatusProcessed},
+		return askTurn{}, err
 	}
-	results, err := s.chunks.SearchSimilar(ctx, userID, embedding, filter)
+	results, err := s.retrieve(ctx, query, embedding, scopes, mode)
 	if err != nil {
-		return AskResponse{}, apperrors.Wrap("storage_error", "search failed", err)
+		return askTurn{}, err
 	}
+	results = s.rerank(ctx, query, results, topKDocs)
 	if len(results) > topKDocs {
 		results = results[:topKDocs]
 	}
 	memories := s.searchMemories(ctx, userID, sessionID, embedding, topKMems)
 
-	messages := s.buildPrompt(query, results, memories, history, includeHistory)
-	start := time.Now()
-	answer := s.answerWithPrompt(ctx, query, results, memories, messages)
-	latency := time.Since(start).Milliseconds()
+	return askTurn{
+		userID:            userID,
+		sessionID:         sessionID,
+		query:             query,
+		history:           history,
+		usedHistoryTokens: usedHistoryTokens,
+		results:           results,
+		sources:           s.buildChunkSources(results),
+		memories:          memories,
+		messages:          s.buildPrompt(query, results, memories, history, includeHistory),
+	}, nil
+}
 
-	sources := s.buildChunkSources(results)
+// recordTurn writes the query log, conversation messages and turn memory
+// once an answer is complete.
+func (s *Service) recordTurn(ctx context.Context, turn askTurn, answer string, latency int64) {
 	log := QueryLog{
 		ID:           uuid.New(),
-		SessionID:    sessionID,
-		QueryText:    query,
+		SessionID:    turn.sessionID,
+		QueryText:    turn.query,
 		ResponseText: answer,
 		LatencyMs:    latency,
-		Sources:      sources,
+		Sources:      turn.sources,
 		CreatedAt:    time.Now(),
 	}
 	_ = s.logs.Append(ctx, log)
 
-	s.appendMessages(ctx, userID, sessionID, query, answer)
-	s.persistTurnMemory(ctx, userID, sessionID, query, answer)
-	s.maybeTriggerSummary(ctx, userID, sessionID, len(history)+2)
-
-	return AskResponse{
-		SessionID:         sessionID,
-		Answer:            answer,
-		Sources:           sources,
-		Memories:          memories,
-		UsedHistoryTokens: usedHistoryTokens,
-		LatencyMs:         latency,
-	}, nil
+	s.appendMessages(ctx, turn.userID, turn.sessionID, turn.query, answer)
+	s.persistTurnMemory(ctx, turn.userID, turn.sessionID, turn.query, answer)
+	s.maybeTriggerSummary(ctx, turn.userID, turn.sessionID, len(turn.history)+2)
 }
 
 func (s *Service) resolveTopKMems(val *int) int {
@@ -351,25 +676,33 @@ func (s *Service) shouldIncludeHistory(flag *bool) bool {
 	return s.cfg.Memory.Enabled
 }
 
-func (s *Service) ensureSession(ctx context.Context, userID int64, requested *uuid.UUID) (uuid.UUID, error) {
+func (s *Service) ensureSession(ctx context.Context, userID int64, requested *uuid.UUID, collectionID *uuid.UUID) (QASession, error) {
 	if requested != nil {
 		session, found, err := s.sessions.Find(ctx, *requested, userID)
 		if err != nil {
-			return uuid.Nil, apperrors.Wrap("storage_error", "failed to load session", err)
+			return QASession{}, apperrors.Wrap("storage_error", "failed to load session", err)
 		}
 		if !found || session.UserID != userID {
-			return uuid.Nil, apperrors.Wrap("not_found", "session not found", nil)
+			return QASession{}, apperrors.Wrap("not_found", "session not found", nil)
 		}
-		return session.ID, nil
+		if collectionID != nil && (session.CollectionID == nil || *session.CollectionID != *collectionID) {
+			return QASession{}, apperrors.Wrap("invalid_input", "session is not bound to this collection", nil)
+		}
+		return session, nil
 	}
-	id := uuid.New()
 	session := QASession{
-		ID:        id,
+		ID:        uuid.New(),
 		UserID:    userID,
 		CreatedAt: time.Now(),
 	}
+	if collectionID != nil {
+		if _, err := s.GetCollection(ctx, userID, *collectionID); err != nil {
+			return QASession{}, err
+		}
+		session.CollectionID = collectionID
+	}
 	_ = s.sessions.Create(ctx, session)
-	return id, nil
+	return session, nil
 }
 
 func (s *Service) loadHistory(ctx context.Context, userID int64, sessionID uuid.UUID, maxTokens int, include bool) ([]ConversationMessage, int) {
@@ -426,7 +759,7 @@ func (s *Service) searchMemories(ctx context.Context, userID int64, sessionID uu
 
 func (s *Service) buildPrompt(query string, chunks []RetrievedChunk, memories []RetrievedMemory, history []ConversationMessage, includeHistory bool) []LLMMessage {
 	messages := []LLMMessage{
-		{Role: "system", Content: "You are a helpful assistant that answers questions using the provided context. Cite document and chunk numbers when using document content."},
+		{Role: "system", Content: "You are a helpful assistant that answers questions using the provided context. Cite document, chunk, and page numbers when using document content."},
 	}
 	if ctx := s.buildContextBlock(chunks, memories); ctx != "" {
 		messages = append(messages, LLMMessage{Role: "system", Content: "Context:\n" + ctx})
@@ -444,7 +777,14 @@ func (s *Service) buildContextBlock(chunks []RetrievedChunk, memories []Retrieve
 	var builder strings.Builder
 	for _, rc := range chunks {
 		chunk := rc.Chunk
-		builder.WriteString(fmt.Sprintf("Doc %s chunk %d:\n%s\n\n", chunk.DocumentID.String(), chunk.ChunkIndex, chunk.Content))
+		label := fmt.Sprintf("Doc %s chunk %d", chunk.DocumentID.String(), chunk.ChunkIndex)
+		if chunk.PageNumber > 0 {
+			label += fmt.Sprintf(" (page %d)", chunk.PageNumber)
+		}
+		if len(chunk.HeadingPath) > 0 {
+			label += " [" + strings.Join(chunk.HeadingPath, " > ") + "]"
+		}
+		builder.WriteString(fmt.Sprintf("%s:\n%s\n\n", label, chunk.Content))
 	}
 	if len(memories) > 0 {
 		builder.WriteString("Memories:\n")
@@ -460,22 +800,29 @@ func (s *Service) answerWithPrompt(ctx context.Context, query string, chunks []R
 	answer, err := s.llm.Chat(ctx, messages)
 	if err != nil || strings.TrimSpace(answer) == "" {
 		s.logger.Warn("llm chat failed, falling back to heuristic answer", "error", err)
-		if len(chunks) == 0 && len(memories) == 0 {
-			return "No relevant context available to answer this question."
-		}
-		return fmt.Sprintf("%s\n\nBased on %d context items.", query, len(chunks)+len(memories))
+		return fallbackAnswer(query, chunks, memories)
 	}
 	return answer
 }
 
+func fallbackAnswer(query string, chunks []RetrievedChunk, memories []RetrievedMemory) string {
+	if len(chunks) == 0 && len(memories) == 0 {
+		return "No relevant context available to answer this question."
+	}
+	return fmt.Sprintf("%s\n\nBased on %d context items.", query, len(chunks)+len(memories))
+}
+
 func (s *Service) buildChunkSources(results []RetrievedChunk) []ChunkSource {
 	sources := make([]ChunkSource, 0, len(results))
 	for _, r := range results {
 		sources = append(sources, ChunkSource{
-			DocumentID: r.Chunk.DocumentID,
-			ChunkIndex: r.Chunk.ChunkIndex,
-			Score:      r.Score,
-			Preview:    snippet(r.Chunk.Content, s.cfg.MaxPreviewChars),
+			DocumentID:  r.Chunk.DocumentID,
+			ChunkIndex:  r.Chunk.ChunkIndex,
+			PageNumber:  r.Chunk.PageNumber,
+			HeadingPath: r.Chunk.HeadingPath,
+			Score:       r.Score,
+			RerankScore: r.RerankScore,
+			Preview:     snippet(r.Chunk.Content, s.cfg.MaxPreviewChars),
 		})
 	}
 	return sources
@@ -640,19 +987,56 @@ func (s *Service) ListDocuments(ctx context.Context, userID int64, filter Docume
 	if userID == 0 {
 		return nil, apperrors.Wrap("unauthorized", "missing user", nil)
 	}
+	filter, err := normalizeScope(filter)
+	if err != nil {
+		return nil, err
+	}
 	return s.docs.List(ctx, userID, filter)
 }
 
-// GetDocument fetches a single document.
+// GetDocument fetches a document the user owns or that is shared with them.
 func (s *Service) GetDocument(ctx context.Context, userID int64, docID uuid.UUID) (Document, error) {
-	doc, found, err := s.docs.Get(ctx, docID, userID)
+	return s.accessibleDocument(ctx, userID, docID, accessViewer)
+}
+
+// DeleteDocument removes a document with its stored blob, file metadata,
+// chunks and shares, so it no longer appears in retrieval. Only the owner may
+// delete it. The blob goes first and the document row last, which keeps a
+// failed delete retryable.
+func (s *Service) DeleteDocument(ctx context.Context, userID int64, docID uuid.UUID) error {
+	if _, err := s.accessibleDocument(ctx, userID, docID, accessOwner); err != nil {
+		return err
+	}
+	file, found, err := s.files.FindByDocument(ctx, docID)
 	if err != nil {
-		return Document{}, apperrors.Wrap("storage_error", "failed to fetch document", err)
+		return apperrors.Wrap("storage_error", "failed to load file metadata", err)
 	}
-	if !found {
-		return Document{}, apperrors.Wrap("not_found", "document not found", nil)
+	if found {
+		if err := s.storage.Delete(ctx, file.StorageKey); err != nil {
+			return apperrors.Wrap("storage_error", "failed to delete stored file", err)
+		}
+	}
+	if err := s.chunks.DeleteByDocument(ctx, docID); err != nil {
+		return apperrors.Wrap("storage_error", "failed to delete chunks", err)
+	}
+	if err := s.files.DeleteByDocument(ctx, docID); err != nil {
+		return apperrors.Wrap("storage_error", "failed to delete file metadata", err)
+	}
+	if err := s.collections.DeleteByDocument(ctx, docID); err != nil {
+		return apperrors.Wrap("storage_error", "failed to remove document from collections", err)
+	}
+	if err := s.shares.DeleteByResource(ctx, ShareResourceDocument, docID); err != nil {
+		return apperrors.Wrap("storage_error", "failed to delete document shares", err)
+	}
+	deleted, err := s.docs.Delete(ctx, docID, userID)
+	if err != nil {
+		return apperrors.Wrap("storage_error", "failed to delete document", err)
+	}
+	if !deleted {
+		return apperrors.Wrap("not_found", "document not found", nil)
 	}
-	return doc, nil
+	s.logger.Info("document deleted", "document_id", docID, "user_id", userID)
+	return nil
 }
 
 // ListSessionLogs returns historical Q&A exchanges.
@@ -686,10 +1070,36 @@ func (s *Service) embedText(ctx context.Context, text string) ([]float32, error)
 	return embeddings[0], nil
 }
 
+// extensionMimeTypes covers formats that http.DetectContentType reports only
+// generically (Markdown as text/plain, DOCX as application/zip).
+var extensionMimeTypes = map[string]string{
+	".md":       "text/markdown",
+	".markdown": "text/markdown",
+	".docx":     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+}
+
+// detectMimeType trusts an explicit client type unless it is missing or
+// generic, in which case the content is sniffed and refined by file extension.
+func detectMimeType(filename, declared string, content []byte) string {
+	declared = strings.TrimSpace(declared)
+	if declared != "" && !strings.HasPrefix(declared, "application/octet-stream") {
+		return declared
+	}
+	sniffed := http.DetectContentType(content)
+	if byExt, ok := extensionMimeTypes[strings.ToLower(filepath.Ext(filename))]; ok {
+		if strings.HasPrefix(sniffed, "text/plain") || sniffed == "application/zip" || sniffed == "application/octet-stream" {
+			return byExt
+		}
+	}
+	return sniffed
+}
+
+// sanitizeFilename makes name safe as the last segment of a storage key: no
+// spaces, no path separators and never "." or "..".
 func sanitizeFilename(name string) string {
 	name = strings.TrimSpace(name)
-	name = strings.ReplaceAll(name, " ", "_")
-	if name == "" {
+	name = strings.NewReplacer(" ", "_", "/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
 		return "file"
 	}
 	return name
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"log/slog"
+	"math"
 	"strings"
 	"testing"
 
@@ -48,6 +49,16 @@ func (f fakeLLM) Chat(context.Context, []LLMMessage) (string, error) {
 	return f.resp, f.err
 }
 
+func (f fakeLLM) ChatStream(context.Context, []LLMMessage) (<-chan LLMStreamChunk, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	out := make(chan LLMStreamChunk, 1)
+	out <- LLMStreamChunk{Delta: f.resp}
+	close(out)
+	return out, nil
+}
+
 type fakeEmbedder struct {
 	vec []float32
 	err error
@@ -222,3 +233,87 @@ func TestSummarizeSessionStoresMemory(t *testing.T) {
 		t.Fatalf("expected embedding to be set")
 	}
 }
+
+func TestFuseReciprocalRankPrefersChunksInBothLists(t *testing.T) {
+	docID := uuid.New()
+	chunk := func(index int) RetrievedChunk {
+		return RetrievedChunk{Chunk: DocumentChunk{DocumentID: docID, ChunkIndex: index}}
+	}
+	vector := []RetrievedChunk{chunk(0), chunk(1), chunk(2)}
+	lexical := []RetrievedChunk{chunk(2), chunk(3)}
+
+	fused := fuseReciprocalRank(vector, lexical)
+
+	if len(fused) != 4 {
+		t.Fatalf("expected 4 fused chunks, got %d", len(fused))
+	}
+	for i, want := range []int{2, 0, 1, 3} {
+		if fused[i].Chunk.ChunkIndex != want {
+			t.Fatalf("position %d expected chunk %d, got %d", i, want, fused[i].Chunk.ChunkIndex)
+		}
+	}
+	if want := 1.0/63 + 1.0/61; math.Abs(fused[0].Score-want) > 1e-9 {
+		t.Fatalf("expected fused score %f, got %f", want, fused[0].Score)
+	}
+}
+
+func TestFuseScopesRanksEachScopeOnItsOwn(t *testing.T) {
+	own, shared := uuid.New(), uuid.New()
+	chunk := func(docID uuid.UUID, index int, score float64) RetrievedChunk {
+		return RetrievedChunk{Chunk: DocumentChunk{DocumentID: docID, ChunkIndex: index}, Score: score}
+	}
+	// The owner's BM25 scores dwarf those from the smaller shared corpus.
+	ownList := []RetrievedChunk{chunk(own, 0, 12), chunk(own, 1, 11), chunk(own, 2, 10)}
+	sharedList := []RetrievedChunk{chunk(shared, 0, 2), chunk(shared, 1, 1)}
+
+	fused := fuseScopes([][]RetrievedChunk{ownList, sharedList})
+
+	want := []RetrievedChunk{ownList[0], sharedList[0], ownList[1], sharedList[1], ownList[2]}
+	if len(fused) != len(want) {
+		t.Fatalf("expected %d fused chunks, got %d", len(want), len(fused))
+	}
+	for i := range want {
+		if fused[i].Chunk.DocumentID != want[i].Chunk.DocumentID || fused[i].Chunk.ChunkIndex != want[i].Chunk.ChunkIndex {
+			t.Fatalf("position %d expected %v, got %v", i, want[i].Chunk, fused[i].Chunk)
+		}
+	}
+	if single := fuseScopes([][]RetrievedChunk{ownList}); single[0].Score != 12 {
+		t.Fatalf("a single scope keeps its raw scores, got %f", single[0].Score)
+	}
+}
+
+func TestMergeByScoreKeepsStrongOwnMatchesAhead(t *testing.T) {
+	own, shared := uuid.New(), uuid.New()
+	chunk := func(docID uuid.UUID, index int, score float64) RetrievedChunk {
+		return RetrievedChunk{Chunk: DocumentChunk{DocumentID: docID, ChunkIndex: index}, Score: score}
+	}
+	ownList := []RetrievedChunk{chunk(own, 0, 0.92), chunk(own, 1, 0.88)}
+	sharedList := []RetrievedChunk{chunk(shared, 0, 0.31)}
+
+	merged := mergeByScore([][]RetrievedChunk{ownList, sharedList})
+
+	want := []RetrievedChunk{ownList[0], ownList[1], sharedList[0]}
+	if len(merged) != len(want) {
+		t.Fatalf("expected %d merged chunks, got %d", len(want), len(merged))
+	}
+	for i := range want {
+		if merged[i].Chunk.DocumentID != want[i].Chunk.DocumentID || merged[i].Chunk.ChunkIndex != want[i].Chunk.ChunkIndex || merged[i].Score != want[i].Score {
+			t.Fatalf("position %d expected chunk %d (%f), got chunk %d (%f)", i, want[i].Chunk.ChunkIndex, want[i].Score, merged[i].Chunk.ChunkIndex, merged[i].Score)
+		}
+	}
+}
+
+func TestSanitizeFilenameKeepsKeySegmentLocal(t *testing.T) {
+	cases := map[string]string{
+		"my notes.txt":     "my_notes.txt",
+		"../../etc/passwd": ".._.._etc_passwd",
+		`..\secret.txt`:    ".._secret.txt",
+		"..":               "file",
+		" ":                "file",
+	}
+	for in, want := range cases {
+		if got := sanitizeFilename(in); got != want {
+			t.Fatalf("sanitizeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
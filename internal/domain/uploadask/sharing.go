@@ -0,0 +1,357 @@
+package uploadask
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+// access is what a user may do with a document or collection. Each level
+// includes the ones below it.
+type access int
+
+const (
+	accessNone access = iota
+	accessViewer
+	accessEditor
+	accessOwner
+)
+
+func roleAccess(role ShareRole) access {
+	switch role {
+	case ShareRoleViewer:
+		return accessViewer
+	case ShareRoleEditor:
+		return accessEditor
+	}
+	return accessNone
+}
+
+// SharedDocument is a document shared with the user and the role they hold.
+type SharedDocument struct {
+	Document
+	Role ShareRole `json:"role"`
+}
+
+// SharedCollection is a collection shared with the user and the role they
+// hold.
+type SharedCollection struct {
+	Collection
+	Role ShareRole `json:"role"`
+}
+
+// SharedResources lists what other users shared with the user.
+type SharedResources struct {
+	Documents   []SharedDocument   `json:"documents"`
+	Collections []SharedCollection `json:"collections"`
+}
+
+// Share grants userID role on one of the owner's documents or collections,
+// replacing the role they held before.
+func (s *Service) Share(ctx context.Context, ownerID int64, resourceType ShareResourceType, resourceID uuid.UUID, userID int64, role ShareRole) (Share, error) {
+	if err := s.checkOwner(ctx, ownerID, resourceType, resourceID); err != nil {
+		return Share{}, err
+	}
+	if roleAccess(role) == accessNone {
+		return Share{}, apperrors.Wrap("invalid_input", "role must be viewer or editor", nil)
+	}
+	if userID <= 0 {
+		return Share{}, apperrors.Wrap("invalid_input", "userId is required", nil)
+	}
+	if userID == ownerID {
+		return Share{}, apperrors.Wrap("invalid_input", "cannot share with yourself", nil)
+	}
+	now := time.Now().UTC()
+	share, found, err := s.shares.Get(ctx, resourceType, resourceID, userID)
+	if err != nil {
+		return Share{}, apperrors.Wrap("storage_error", "failed to load share", err)
+	}
+	if !found {
+		share = Share{
+			ResourceType: resourceType,
+			ResourceID:   resourceID,
+			OwnerID:      ownerID,
+			UserID:       userID,
+			CreatedAt:    now,
+		}
+	}
+	share.Role = role
+	share.UpdatedAt = now
+	if err := s.shares.Upsert(ctx, share); err != nil {
+		return Share{}, apperrors.Wrap("storage_error", "failed to save share", err)
+	}
+	return share, nil
+}
+
+// ListShares returns who one of the owner's documents or collections is
+// shared with.
+func (s *Service) ListShares(ctx context.Context, ownerID int64, resourceType ShareResourceType, resourceID uuid.UUID) ([]Share, error) {
+	if err := s.checkOwner(ctx, ownerID, resourceType, resourceID); err != nil {
+		return nil, err
+	}
+	shares, err := s.shares.ListByResource(ctx, resourceType, resourceID)
+	if err != nil {
+		return nil, apperrors.Wrap("storage_error", "failed to list shares", err)
+	}
+	return shares, nil
+}
+
+// Unshare revokes userID's access. The owner may revoke anyone's access, and
+// a user may give up their own.
+func (s *Service) Unshare(ctx context.Context, callerID int64, resourceType ShareResourceType, resourceID uuid.UUID, userID int64) error {
+	if callerID != userID {
+		if err := s.checkOwner(ctx, callerID, resourceType, resourceID); err != nil {
+			return err
+		}
+	}
+	deleted, err := s.shares.Delete(ctx, resourceType, resourceID, userID)
+	if err != nil {
+		return apperrors.Wrap("storage_error", "failed to delete share", err)
+	}
+	if !deleted {
+		return apperrors.Wrap("not_found", "share not found", nil)
+	}
+	return nil
+}
+
+// ListShared returns the documents and collections shared with the user.
+// Documents reachable only through a shared collection are listed with that
+// collection.
+func (s *Service) ListShared(ctx context.Context, userID int64) (SharedResources, error) {
+	if userID == 0 {
+		return SharedResources{}, apperrors.Wrap("unauthorized", "missing user", nil)
+	}
+	shares, err := s.shares.ListByUser(ctx, userID)
+	if err != nil {
+		return SharedResources{}, apperrors.Wrap("storage_error", "failed to list shares", err)
+	}
+	out := SharedResources{Documents: []SharedDocument{}, Collections: []SharedCollection{}}
+	for _, share := range shares {
+		switch share.ResourceType {
+		case ShareResourceDocument:
+			doc, found, err := s.docs.Get(ctx, share.ResourceID, share.OwnerID)
+			if err != nil {
+				return SharedResources{}, apperrors.Wrap("storage_error", "failed to load shared document", err)
+			}
+			if found {
+				out.Documents = append(out.Documents, SharedDocument{Document: doc, Role: share.Role})
+			}
+		case ShareResourceCollection:
+			collection, found, err := s.collections.Get(ctx, share.ResourceID, share.OwnerID)
+			if err != nil {
+				return SharedResources{}, apperrors.Wrap("storage_error", "failed to load shared collection", err)
+			}
+			if found {
+				out.Collections = append(out.Collections, SharedCollection{Collection: collection, Role: share.Role})
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *Service) checkOwner(ctx context.Context, ownerID int64, resourceType ShareResourceType, resourceID uuid.UUID) error {
+	switch resourceType {
+	case ShareResourceDocument:
+		_, err := s.accessibleDocument(ctx, ownerID, resourceID, accessOwner)
+		return err
+	case ShareResourceCollection:
+		_, err := s.accessibleCollection(ctx, ownerID, resourceID, accessOwner)
+		return err
+	}
+	return apperrors.Wrap("invalid_input", "unknown resource type", nil)
+}
+
+// accessibleDocument returns the document when userID holds at least need on
+// it. Documents the user cannot see at all are reported as not found.
+func (s *Service) accessibleDocument(ctx context.Context, userID int64, docID uuid.UUID, need access) (Document, error) {
+	if userID == 0 {
+		return Document{}, apperrors.Wrap("unauthorized", "missing user", nil)
+	}
+	doc, found, err := s.docs.Get(ctx, docID, userID)
+	if err != nil {
+		return Document{}, apperrors.Wrap("storage_error", "failed to fetch document", err)
+	}
+	if found {
+		return doc, nil
+	}
+	shared, err := s.sharedDocuments(ctx, userID)
+	if err != nil {
+		return Document{}, err
+	}
+	grant, ok := shared[docID]
+	if !ok {
+		return Document{}, apperrors.Wrap("not_found", "document not found", nil)
+	}
+	if grant.access < need {
+		return Document{}, accessDenied("document", need)
+	}
+	doc, found, err = s.docs.Get(ctx, docID, grant.ownerID)
+	if err != nil {
+		return Document{}, apperrors.Wrap("storage_error", "failed to fetch document", err)
+	}
+	if !found {
+		return Document{}, apperrors.Wrap("not_found", "document not found", nil)
+	}
+	return doc, nil
+}
+
+// accessibleCollection returns the collection when userID holds at least
+// need on it. Collections the user cannot see at all are reported as not
+// found.
+func (s *Service) accessibleCollection(ctx context.Context, userID int64, id uuid.UUID, need access) (Collection, error) {
+	if userID == 0 {
+		return Collection{}, apperrors.Wrap("unauthorized", "missing user", nil)
+	}
+	collection, found, err := s.collections.Get(ctx, id, userID)
+	if err != nil {
+		return Collection{}, apperrors.Wrap("storage_error", "failed to fetch collection", err)
+	}
+	if found {
+		return collection, nil
+	}
+	share, found, err := s.shares.Get(ctx, ShareResourceCollection, id, userID)
+	if err != nil {
+		return Collection{}, apperrors.Wrap("storage_error", "failed to load share", err)
+	}
+	if !found {
+		return Collection{}, apperrors.Wrap("not_found", "collection not found", nil)
+	}
+	if roleAccess(share.Role) < need {
+		return Collection{}, accessDenied("collection", need)
+	}
+	collection, found, err = s.collections.Get(ctx, id, share.OwnerID)
+	if err != nil {
+		return Collection{}, apperrors.Wrap("storage_error", "failed to fetch collection", err)
+	}
+	if !found {
+		return Collection{}, apperrors.Wrap("not_found", "collection not found", nil)
+	}
+	return collection, nil
+}
+
+func accessDenied(resource string, need access) error {
+	if need == accessOwner {
+		return apperrors.Wrap("forbidden", "only the owner can do this to the "+resource, nil)
+	}
+	return apperrors.Wrap("forbidden", resource+" is shared with you read-only", nil)
+}
+
+type documentGrant struct {
+	ownerID int64
+	access  access
+}
+
+// sharedDocuments maps every document shared with userID to its owner and
+// the access granted. Documents reached through a shared collection are
+// readable only; editing them takes a share of the document itself.
+func (s *Service) sharedDocuments(ctx context.Context, userID int64) (map[uuid.UUID]documentGrant, error) {
+	shares, err := s.shares.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, apperrors.Wrap("storage_error", "failed to list shares", err)
+	}
+	out := make(map[uuid.UUID]documentGrant)
+	var sharedCollections []uuid.UUID
+	for _, share := range shares {
+		switch share.ResourceType {
+		case ShareResourceDocument:
+			if granted := roleAccess(share.Role); granted > out[share.ResourceID].access {
+				out[share.ResourceID] = documentGrant{ownerID: share.OwnerID, access: granted}
+			}
+		case ShareResourceCollection:
+			sharedCollections = append(sharedCollections, share.ResourceID)
+		}
+	}
+	if len(sharedCollections) == 0 {
+		return out, nil
+	}
+	viaCollections, err := s.collections.DocumentIDsIn(ctx, sharedCollections)
+	if err != nil {
+		return nil, apperrors.Wrap("storage_error", "failed to load collection documents", err)
+	}
+	if len(viaCollections) == 0 {
+		return out, nil
+	}
+	owners, err := s.docs.OwnerIDs(ctx, viaCollections)
+	if err != nil {
+		return nil, apperrors.Wrap("storage_error", "failed to load document owners", err)
+	}
+	for docID, ownerID := range owners {
+		if _, ok := out[docID]; !ok && ownerID != userID {
+			out[docID] = documentGrant{ownerID: ownerID, access: accessViewer}
+		}
+	}
+	return out, nil
+}
+
+// searchScope is one owner's part of a retrieval.
+type searchScope struct {
+	ownerID int64
+	filter  DocumentFilter
+}
+
+// retrievalScopes splits what the session may retrieve from by document
+// owner. A session bound to a collection reads every document in it. Other
+// sessions read the user's own documents and those shared with them, limited
+// to filter.DocumentIDs when set. No scopes means nothing may be retrieved.
+func (s *Service) retrievalScopes(ctx context.Context, session QASession, filter DocumentFilter) ([]searchScope, error) {
+	if session.CollectionID != nil {
+		scoped, inScope, err := s.scopeToCollection(ctx, session, filter)
+		if err != nil || !inScope {
+			return nil, err
+		}
+		owners, err := s.docs.OwnerIDs(ctx, scoped.DocumentIDs)
+		if err != nil {
+			return nil, apperrors.Wrap("storage_error", "failed to load document owners", err)
+		}
+		return scopesByOwner(scoped.DocumentIDs, owners, filter), nil
+	}
+	shared, err := s.sharedDocuments(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if len(filter.DocumentIDs) == 0 {
+		owners := make(map[uuid.UUID]int64, len(shared))
+		docIDs := make([]uuid.UUID, 0, len(shared))
+		for docID, grant := range shared {
+			owners[docID] = grant.ownerID
+			docIDs = append(docIDs, docID)
+		}
+		own := searchScope{ownerID: session.UserID, filter: filter}
+		return append([]searchScope{own}, scopesByOwner(docIDs, owners, filter)...), nil
+	}
+	owners, err := s.docs.OwnerIDs(ctx, filter.DocumentIDs)
+	if err != nil {
+		return nil, apperrors.Wrap("storage_error", "failed to load document owners", err)
+	}
+	for docID, ownerID := range owners {
+		if _, ok := shared[docID]; !ok && ownerID != session.UserID {
+			delete(owners, docID)
+		}
+	}
+	return scopesByOwner(filter.DocumentIDs, owners, filter), nil
+}
+
+// scopesByOwner groups the docIDs found in owners into one scope per owner,
+// each keeping filter's other restrictions.
+func scopesByOwner(docIDs []uuid.UUID, owners map[uuid.UUID]int64, filter DocumentFilter) []searchScope {
+	byOwner := make(map[int64][]uuid.UUID)
+	for _, docID := range docIDs {
+		if ownerID, ok := owners[docID]; ok {
+			byOwner[ownerID] = append(byOwner[ownerID], docID)
+		}
+	}
+	scopes := make([]searchScope, 0, len(byOwner))
+	for ownerID, ids := range byOwner {
+		scoped := filter
+		scoped.DocumentIDs = ids
+		scopes = append(scopes, searchScope{ownerID: ownerID, filter: scoped})
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		return scopes[i].ownerID < scopes[j].ownerID
+	})
+	return scopes
+}
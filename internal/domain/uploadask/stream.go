@@ -0,0 +1,108 @@
+package uploadask
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event names of a streamed answer, in the order they are emitted.
+const (
+	AskStreamEventSources = "sources"
+	AskStreamEventDelta   = "delta"
+	AskStreamEventDone    = "done"
+)
+
+// AskStreamEvent is one event of a streamed answer. Data is an
+// AskStreamSources, AskStreamDelta or AskStreamDone depending on Event.
+type AskStreamEvent struct {
+	Event string
+	Data  any
+}
+
+// AskStreamSources lists the retrieved context before the answer starts.
+type AskStreamSources struct {
+	Sources  []ChunkSource     `json:"sources"`
+	Memories []RetrievedMemory `json:"memories,omitempty"`
+}
+
+// AskStreamDelta carries the next piece of the answer.
+type AskStreamDelta struct {
+	Delta string `json:"delta"`
+}
+
+// AskStreamDone closes the stream once the turn has been recorded.
+type AskStreamDone struct {
+	SessionID         uuid.UUID `json:"sessionId"`
+	UsedHistoryTokens int       `json:"usedHistoryTokens"`
+	LatencyMs         int64     `json:"latencyMs"`
+}
+
+// AskStream runs retrieval like Ask and streams the answer. Validation and
+// retrieval errors are returned before any event is sent. The query log,
+// conversation messages and memories are written after the answer completes,
+// even when the client disconnects mid-stream, and before the done event.
+func (s *Service) AskStream(ctx context.Context, userID int64, req AskRequest) (<-chan AskStreamEvent, error) {
+	turn, err := s.prepareAsk(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan AskStreamEvent)
+	go func() {
+		defer close(out)
+		send := func(event string, data any) {
+			select {
+			case out <- AskStreamEvent{Event: event, Data: data}:
+			case <-ctx.Done():
+			}
+		}
+
+		send(AskStreamEventSources, AskStreamSources{Sources: turn.sources, Memories: turn.memories})
+		start := time.Now()
+		answer := s.streamAnswer(ctx, turn, func(delta string) {
+			send(AskStreamEventDelta, AskStreamDelta{Delta: delta})
+		})
+		latency := time.Since(start).Milliseconds()
+
+		s.recordTurn(context.WithoutCancel(ctx), turn, answer, latency)
+		send(AskStreamEventDone, AskStreamDone{
+			SessionID:         turn.sessionID,
+			UsedHistoryTokens: turn.usedHistoryTokens,
+			LatencyMs:         latency,
+		})
+	}()
+	return out, nil
+}
+
+// streamAnswer forwards LLM deltas to emit and returns the full answer. When
+// the stream fails before producing text, the heuristic fallback answer is
+// emitted as a single delta, matching Ask.
+func (s *Service) streamAnswer(ctx context.Context, turn askTurn, emit func(string)) string {
+	chunks, err := s.llm.ChatStream(ctx, turn.messages)
+	var builder strings.Builder
+	if err == nil {
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				err = chunk.Err
+				continue
+			}
+			if chunk.Delta == "" {
+				continue
+			}
+			builder.WriteString(chunk.Delta)
+			emit(chunk.Delta)
+		}
+	}
+	if answer := strings.TrimSpace(builder.String()); answer != "" {
+		if err != nil {
+			s.logger.Warn("llm stream interrupted, keeping partial answer", "error", err)
+		}
+		return answer
+	}
+	s.logger.Warn("llm stream failed, falling back to heuristic answer", "error", err)
+	answer := fallbackAnswer(turn.query, turn.results, turn.memories)
+	emit(answer)
+	return answer
+}
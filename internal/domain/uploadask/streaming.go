@@ -0,0 +1,90 @@
+package uploadask
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// sniffBytes is how much of an upload detectMimeType looks at.
+const sniffBytes = 512
+
+// uploadReader passes an upload through to storage while hashing and
+// counting it. Reading past max fails with ErrFileTooLarge.
+type uploadReader struct {
+	r        *bufio.Reader
+	max      int64
+	hash     hash.Hash
+	size     int64
+	tooLarge bool
+}
+
+func newUploadReader(r io.Reader, max int64) *uploadReader {
+	return &uploadReader{r: bufio.NewReaderSize(r, sniffBytes), max: max, hash: sha256.New()}
+}
+
+// peek returns the start of the content without consuming it.
+func (u *uploadReader) peek() []byte {
+	head, _ := u.r.Peek(sniffBytes)
+	return head
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	if u.tooLarge {
+		return 0, ErrFileTooLarge
+	}
+	n, err := u.r.Read(p)
+	u.size += int64(n)
+	u.hash.Write(p[:n])
+	if u.max > 0 && u.size > u.max {
+		u.tooLarge = true
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
+
+// sum is the hex SHA-256 of everything read so far.
+func (u *uploadReader) sum() string {
+	return hex.EncodeToString(u.hash.Sum(nil))
+}
+
+// blobReader remembers the first error reading the stored blob so it can be
+// told apart from extraction failures.
+type blobReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// extractSections feeds the stored blob through the extractor one section at
+// a time. Extractors that cannot stream get the whole blob, and without an
+// extractor the blob is treated as a single page of plain text.
+func (s *Service) extractSections(ctx context.Context, mimeType string, r io.Reader, emit func(ExtractedPage) error) error {
+	if streaming, ok := s.extractor.(StreamingExtractor); ok {
+		return streaming.ExtractStream(ctx, mimeType, r, emit)
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	pages, err := s.extractText(ctx, mimeType, raw)
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if err := emit(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -11,8 +11,16 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// Process modes. ModeServer serves HTTP (and runs background jobs when
+// uploadAsk.worker.enabled is set); ModeWorker only runs background jobs.
+const (
+	ModeServer = "server"
+	ModeWorker = "worker"
+)
+
 // Config aggregates runtime configuration used across the service.
 type Config struct {
+	Mode      string          `yaml:"mode"`
 	HTTP      HTTPConfig      `yaml:"http"`
 	SQLite    SQLiteConfig    `yaml:"sqlite"`
 	Summary   SummaryConfig   `yaml:"summary"`
@@ -64,11 +72,23 @@ type SummaryConfig struct {
 // LLMConfig contains ChatGPT/OpenAI settings.
 // TODO : support other LLM providers and for different features, use different LLMs.
 type LLMConfig struct {
-	APIKey         string  `yaml:"apiKey"`
-	BaseURL        string  `yaml:"baseUrl"`
-	Model          string  `yaml:"model"`
-	EmbeddingModel string  `yaml:"embeddingModel"`
-	Temperature    float32 `yaml:"temperature"`
+	APIKey         string               `yaml:"apiKey"`
+	BaseURL        string               `yaml:"baseUrl"`
+	Model          string               `yaml:"model"`
+	EmbeddingModel string               `yaml:"embeddingModel"`
+	Temperature    float32              `yaml:"temperature"`
+	EmbeddingCache EmbeddingCacheConfig `yaml:"embeddingCache"`
+}
+
+// EmbeddingCacheConfig controls the cache shared by FAQ and upload-ask
+// embedding calls. Entries persist in SQLite when it is enabled, keeping at
+// most MaxEntries for up to TTL (0 means no limit); LRUSize entries are also
+// kept in memory, and 0 disables that layer.
+type EmbeddingCacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	LRUSize    int           `yaml:"lruSize"`
+	MaxEntries int           `yaml:"maxEntries"`
+	TTL        time.Duration `yaml:"ttl"`
 }
 
 // UVAdvisorConfig controls the UV clothing recommendation domain.
@@ -92,15 +112,39 @@ type UploadAskConfig struct {
 	VectorDim       int                   `yaml:"vectorDim"`
 	MaxFileMB       int                   `yaml:"maxFileMb"`
 	MaxPreviewChars int                   `yaml:"maxPreviewChars"`
+	RetrievalMode   string                `yaml:"retrievalMode"`
+	EmbedBatchSize  int                   `yaml:"embedBatchSize"`
+	EmbedRetry      UploadEmbedRetry      `yaml:"embedRetry"`
 	Memory          UploadAskMemoryConfig `yaml:"memory"`
 	Storage         UploadStorageConfig   `yaml:"storage"`
+	Chunker         UploadChunkerConfig   `yaml:"chunker"`
+	Rerank          UploadRerankConfig    `yaml:"rerank"`
+	URLFetch        UploadURLFetchConfig  `yaml:"urlFetch"`
+	DirectUpload    UploadDirectConfig    `yaml:"directUpload"`
+	Quota           UploadQuotaConfig     `yaml:"quota"`
+	VectorIndex     UploadVectorIndex     `yaml:"vectorIndex"`
 	Redis           RedisConfig           `yaml:"redis"`
 	Postgres        PostgresConfig        `yaml:"postgres"`
 	Worker          UploadWorkerConfig    `yaml:"worker"`
+	Queue           UploadQueueConfig     `yaml:"queue"`
+	Recovery        UploadRecoveryConfig  `yaml:"recovery"`
 }
 
-// UploadStorageConfig configures object storage for uploads.
+// UploadEmbedRetry bounds how embedding batches run while processing a
+// document. Failed batches are retried MaxAttempts times with exponential
+// backoff starting at BaseBackoff; a provider's Retry-After wins when longer.
+type UploadEmbedRetry struct {
+	Concurrency int           `yaml:"concurrency"`
+	MaxAttempts int           `yaml:"maxAttempts"`
+	BaseBackoff time.Duration `yaml:"baseBackoff"`
+}
+
+// UploadStorageConfig configures object storage for uploads. Driver is
+// "local" (files under LocalPath), "r2" or "memory"; empty picks r2 when its
+// credentials are set and local otherwise.
 type UploadStorageConfig struct {
+	Driver    string `yaml:"driver"`
+	LocalPath string `yaml:"localPath"`
 	Endpoint  string `yaml:"endpoint"`
 	AccessKey string `yaml:"accessKey"`
 	SecretKey string `yaml:"secretKey"`
@@ -108,9 +152,86 @@ type UploadStorageConfig struct {
 	Region    string `yaml:"region"`
 }
 
-// UploadWorkerConfig toggles background processing.
+// UploadChunkerConfig selects how extracted text is split into chunks.
+// Strategy is "simple" (token-budget packing) or "structured" (outline-aware).
+type UploadChunkerConfig struct {
+	Strategy  string `yaml:"strategy"`
+	MaxTokens int    `yaml:"maxTokens"`
+	Overlap   int    `yaml:"overlap"`
+}
+
+// UploadRerankConfig enables a second scoring pass over retrieved chunks.
+// Strategy is "none", "deterministic" (offline term coverage) or "llm".
+type UploadRerankConfig struct {
+	Strategy   string `yaml:"strategy"`
+	Candidates int    `yaml:"candidates"`
+}
+
+// UploadURLFetchConfig limits ingestion of documents from remote URLs.
+type UploadURLFetchConfig struct {
+	Timeout              time.Duration `yaml:"timeout"`
+	AllowPrivateNetworks bool          `yaml:"allowPrivateNetworks"`
+}
+
+// UploadDirectConfig controls presigned direct-to-storage uploads. URLTTL is
+// how long a presigned URL stays valid. With local storage the URLs point at
+// this server under PublicBaseURL (relative when empty) and are signed with
+// SigningKey, which defaults to a key derived from auth.jwtSecret.
+type UploadDirectConfig struct {
+	URLTTL        time.Duration `yaml:"urlTtl"`
+	PublicBaseURL string        `yaml:"publicBaseUrl"`
+	SigningKey    string        `yaml:"signingKey"`
+}
+
+// UploadQuotaConfig caps what each user's documents may consume in total;
+// zero leaves a limit off.
+type UploadQuotaConfig struct {
+	MaxTotalMB   int `yaml:"maxTotalMb"`
+	MaxDocuments int `yaml:"maxDocuments"`
+	MaxChunks    int `yaml:"maxChunks"`
+}
+
+// UploadVectorIndex configures the in-process HNSW index that answers SQLite
+// similarity searches. Path defaults to the SQLite path with a .hnsw suffix;
+// users with fewer than MinChunks chunks are searched exactly.
+type UploadVectorIndex struct {
+	Enabled        bool   `yaml:"enabled"`
+	Path           string `yaml:"path"`
+	M              int    `yaml:"m"`
+	EfConstruction int    `yaml:"efConstruction"`
+	EfSearch       int    `yaml:"efSearch"`
+	MinChunks      int    `yaml:"minChunks"`
+}
+
+// UploadWorkerConfig toggles background processing in server mode. Disable
+// it when separate worker-mode processes consume the queue. ShutdownTimeout
+// bounds how long shutdown waits for running jobs.
 type UploadWorkerConfig struct {
-	Enabled bool `yaml:"enabled"`
+	Enabled         bool          `yaml:"enabled"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+// UploadQueueConfig selects the background job queue. Driver "sqlite" keeps
+// jobs in the SQLite database with leases, retries and a dead-letter table;
+// "immediate" runs each job once in-process. Enabling uploadAsk.redis still
+// selects the legacy Valkey queue.
+type UploadQueueConfig struct {
+	Driver        string        `yaml:"driver"`
+	Workers       int           `yaml:"workers"`
+	MaxAttempts   int           `yaml:"maxAttempts"`
+	BaseBackoff   time.Duration `yaml:"baseBackoff"`
+	LeaseDuration time.Duration `yaml:"leaseDuration"`
+	PollInterval  time.Duration `yaml:"pollInterval"`
+}
+
+// UploadRecoveryConfig controls the sweep that requeues documents left pending
+// or processing by a crash. It runs in server mode at startup and then every
+// Interval (never again when zero).
+type UploadRecoveryConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	StaleAfter  time.Duration `yaml:"staleAfter"`
+	MaxAttempts int           `yaml:"maxAttempts"`
+	Interval    time.Duration `yaml:"interval"`
 }
 
 // UploadAskMemoryConfig toggles conversational memory.
@@ -128,6 +249,7 @@ type AuthConfig struct {
 	JWTSecret       string           `yaml:"jwtSecret"`
 	AccessTokenTTL  time.Duration    `yaml:"accessTokenTtl"`
 	RefreshTokenTTL time.Duration    `yaml:"refreshTokenTtl"`
+	AdminEmails     []string         `yaml:"adminEmails"`
 	Postgres        PostgresConfig   `yaml:"postgres"`
 	Google          GoogleAuthConfig `yaml:"google"`
 }
@@ -202,6 +324,9 @@ func applyEnvOverrides(cfg *Config) {
 			cfg.HTTP.WriteTimeout = parsed
 		}
 	}
+	if v := os.Getenv("APP_MODE"); v != "" {
+		cfg.Mode = strings.ToLower(strings.TrimSpace(v))
+	}
 	if v := os.Getenv("HTTP_ALLOWED_ORIGINS"); v != "" {
 		cfg.HTTP.AllowedOrigins = splitAndTrim(v)
 	}
@@ -241,6 +366,24 @@ func applyEnvOverrides(cfg *Config) {
 			cfg.LLM.Temperature = float32(parsed)
 		}
 	}
+	if v := os.Getenv("LLM_EMBEDDING_CACHE_ENABLED"); v != "" {
+		cfg.LLM.EmbeddingCache.Enabled = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("LLM_EMBEDDING_CACHE_LRU_SIZE"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.LLM.EmbeddingCache.LRUSize = parsed
+		}
+	}
+	if v := os.Getenv("LLM_EMBEDDING_CACHE_MAX_ENTRIES"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.LLM.EmbeddingCache.MaxEntries = parsed
+		}
+	}
+	if v := os.Getenv("LLM_EMBEDDING_CACHE_TTL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.LLM.EmbeddingCache.TTL = parsed
+		}
+	}
 	if v := os.Getenv("UV_API_BASE_URL"); v != "" {
 		cfg.UVAdvisor.APIBaseURL = v
 	}
@@ -327,6 +470,12 @@ func applyEnvOverrides(cfg *Config) {
 			cfg.UploadAsk.Memory.PruneLimit = parsed
 		}
 	}
+	if v := os.Getenv("UPLOADASK_STORAGE_DRIVER"); v != "" {
+		cfg.UploadAsk.Storage.Driver = v
+	}
+	if v := os.Getenv("UPLOADASK_STORAGE_LOCAL_PATH"); v != "" {
+		cfg.UploadAsk.Storage.LocalPath = v
+	}
 	if v := os.Getenv("UPLOADASK_STORAGE_ENDPOINT"); v != "" {
 		cfg.UploadAsk.Storage.Endpoint = v
 	}
@@ -355,9 +504,129 @@ func applyEnvOverrides(cfg *Config) {
 			cfg.UploadAsk.Postgres.MinConns = int32(parsed)
 		}
 	}
+	if v := os.Getenv("UPLOADASK_DIRECT_UPLOAD_URL_TTL"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.UploadAsk.DirectUpload.URLTTL = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_DIRECT_UPLOAD_PUBLIC_BASE_URL"); v != "" {
+		cfg.UploadAsk.DirectUpload.PublicBaseURL = v
+	}
+	if v := os.Getenv("UPLOADASK_DIRECT_UPLOAD_SIGNING_KEY"); v != "" {
+		cfg.UploadAsk.DirectUpload.SigningKey = v
+	}
+	if v := os.Getenv("UPLOADASK_QUOTA_MAX_TOTAL_MB"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.Quota.MaxTotalMB = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_QUOTA_MAX_DOCUMENTS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.Quota.MaxDocuments = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_QUOTA_MAX_CHUNKS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.Quota.MaxChunks = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_VECTOR_INDEX_ENABLED"); v != "" {
+		cfg.UploadAsk.VectorIndex.Enabled = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("UPLOADASK_VECTOR_INDEX_PATH"); v != "" {
+		cfg.UploadAsk.VectorIndex.Path = v
+	}
+	if v := os.Getenv("UPLOADASK_VECTOR_INDEX_EF_SEARCH"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.VectorIndex.EfSearch = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_VECTOR_INDEX_MIN_CHUNKS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.VectorIndex.MinChunks = parsed
+		}
+	}
 	if v := os.Getenv("UPLOADASK_WORKER_ENABLED"); v != "" {
 		cfg.UploadAsk.Worker.Enabled = v == "1" || strings.EqualFold(v, "true")
 	}
+	if v := os.Getenv("UPLOADASK_RETRIEVAL_MODE"); v != "" {
+		cfg.UploadAsk.RetrievalMode = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("UPLOADASK_EMBED_BATCH_SIZE"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.EmbedBatchSize = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_EMBED_CONCURRENCY"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.EmbedRetry.Concurrency = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_EMBED_MAX_ATTEMPTS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.EmbedRetry.MaxAttempts = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_EMBED_BASE_BACKOFF"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.UploadAsk.EmbedRetry.BaseBackoff = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_RECOVERY_ENABLED"); v != "" {
+		cfg.UploadAsk.Recovery.Enabled = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("UPLOADASK_RECOVERY_STALE_AFTER"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.UploadAsk.Recovery.StaleAfter = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_RECOVERY_MAX_ATTEMPTS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.Recovery.MaxAttempts = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_RERANK_STRATEGY"); v != "" {
+		cfg.UploadAsk.Rerank.Strategy = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("UPLOADASK_RERANK_CANDIDATES"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.Rerank.Candidates = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_CHUNKER_STRATEGY"); v != "" {
+		cfg.UploadAsk.Chunker.Strategy = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("UPLOADASK_CHUNKER_MAX_TOKENS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.Chunker.MaxTokens = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_URL_FETCH_TIMEOUT"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.UploadAsk.URLFetch.Timeout = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_URL_FETCH_ALLOW_PRIVATE"); v != "" {
+		cfg.UploadAsk.URLFetch.AllowPrivateNetworks = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := os.Getenv("UPLOADASK_WORKER_SHUTDOWN_TIMEOUT"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil {
+			cfg.UploadAsk.Worker.ShutdownTimeout = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_QUEUE_DRIVER"); v != "" {
+		cfg.UploadAsk.Queue.Driver = strings.ToLower(strings.TrimSpace(v))
+	}
+	if v := os.Getenv("UPLOADASK_QUEUE_WORKERS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.Queue.Workers = parsed
+		}
+	}
+	if v := os.Getenv("UPLOADASK_QUEUE_MAX_ATTEMPTS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			cfg.UploadAsk.Queue.MaxAttempts = parsed
+		}
+	}
 	if v := os.Getenv("UPLOADASK_REDIS_ENABLED"); v != "" {
 		cfg.UploadAsk.Redis.Enabled = v == "1" || strings.EqualFold(v, "true")
 	}
@@ -380,6 +649,9 @@ func applyEnvOverrides(cfg *Config) {
 			cfg.Auth.RefreshTokenTTL = parsed
 		}
 	}
+	if v := os.Getenv("AUTH_ADMIN_EMAILS"); v != "" {
+		cfg.Auth.AdminEmails = splitAndTrim(v)
+	}
 	if v := os.Getenv("AUTH_GOOGLE_CLIENT_ID"); v != "" {
 		cfg.Auth.Google.ClientID = v
 	}
@@ -438,6 +710,7 @@ func applyEnvOverrides(cfg *Config) {
 
 func defaultConfig() *Config {
 	return &Config{
+		Mode: ModeServer,
 		HTTP: HTTPConfig{
 			Address:      ":8080",
 			ReadTimeout:  10 * time.Second,
@@ -460,6 +733,8 @@ func defaultConfig() *Config {
 					"/api/v1/auth/register",
 					"/api/v1/auth/refresh",
 					"/api/v1/upload-ask/documents",
+					"/api/v1/upload-ask/documents/from-url",
+					"/api/v1/upload-ask/qa/query/stream",
 				},
 			},
 		},
@@ -476,6 +751,12 @@ func defaultConfig() *Config {
 			Model:          "gpt-4o-mini",
 			EmbeddingModel: "text-embedding-3-small",
 			Temperature:    0.2,
+			EmbeddingCache: EmbeddingCacheConfig{
+				Enabled:    true,
+				LRUSize:    10000,
+				MaxEntries: 200000,
+				TTL:        30 * 24 * time.Hour,
+			},
 		},
 		UVAdvisor: UVAdvisorConfig{
 			APIBaseURL: "https://api-open.data.gov.sg/v2/real-time/api/uv",
@@ -510,6 +791,13 @@ func defaultConfig() *Config {
 			VectorDim:       1536,
 			MaxFileMB:       20,
 			MaxPreviewChars: 240,
+			RetrievalMode:   "hybrid",
+			EmbedBatchSize:  32,
+			EmbedRetry: UploadEmbedRetry{
+				Concurrency: 2,
+				MaxAttempts: 3,
+				BaseBackoff: 500 * time.Millisecond,
+			},
 			Memory: UploadAskMemoryConfig{
 				Enabled:            false,
 				TopKMems:           3,
@@ -518,7 +806,31 @@ func defaultConfig() *Config {
 				SummaryEveryNTurns: 0,
 				PruneLimit:         200,
 			},
-			Storage: UploadStorageConfig{},
+			Storage: UploadStorageConfig{
+				LocalPath: "data/uploads",
+			},
+			Chunker: UploadChunkerConfig{
+				Strategy:  "simple",
+				MaxTokens: 800,
+				Overlap:   80,
+			},
+			Rerank: UploadRerankConfig{
+				Strategy:   "none",
+				Candidates: 20,
+			},
+			URLFetch: UploadURLFetchConfig{
+				Timeout: 15 * time.Second,
+			},
+			DirectUpload: UploadDirectConfig{
+				URLTTL: 15 * time.Minute,
+			},
+			VectorIndex: UploadVectorIndex{
+				Enabled:        true,
+				M:              16,
+				EfConstruction: 200,
+				EfSearch:       256,
+				MinChunks:      1000,
+			},
 			Redis: RedisConfig{
 				Enabled: false,
 				Addr:    "",
@@ -529,7 +841,22 @@ func defaultConfig() *Config {
 				MinConns: 1,
 			},
 			Worker: UploadWorkerConfig{
-				Enabled: true,
+				Enabled:         true,
+				ShutdownTimeout: 30 * time.Second,
+			},
+			Queue: UploadQueueConfig{
+				Driver:        "sqlite",
+				Workers:       2,
+				MaxAttempts:   5,
+				BaseBackoff:   5 * time.Second,
+				LeaseDuration: time.Minute,
+				PollInterval:  time.Second,
+			},
+			Recovery: UploadRecoveryConfig{
+				Enabled:     true,
+				StaleAfter:  10 * time.Minute,
+				MaxAttempts: 3,
+				Interval:    5 * time.Minute,
 			},
 		},
 	}
@@ -537,12 +864,33 @@ func defaultConfig() *Config {
 
 // Validate ensures the configuration is safe to use.
 func (c *Config) Validate() error {
+	switch c.Mode {
+	case "", ModeServer:
+		if !c.UploadAsk.Worker.Enabled && !c.durableUploadQueue() {
+			return errors.New("uploadAsk.worker.enabled=false needs a durable queue (uploadAsk.queue.driver=sqlite with sqlite enabled, or uploadAsk.redis)")
+		}
+	case ModeWorker:
+		if !c.durableUploadQueue() {
+			return errors.New("worker mode needs a durable queue (uploadAsk.queue.driver=sqlite with sqlite enabled, or uploadAsk.redis)")
+		}
+	default:
+		return fmt.Errorf("mode must be server or worker, got %q", c.Mode)
+	}
 	if c.HTTP.Address == "" {
 		return errors.New("http.address cannot be empty")
 	}
 	if c.SQLite.Enabled && strings.TrimSpace(c.SQLite.Path) == "" {
 		return errors.New("sqlite.path cannot be empty when sqlite is enabled")
 	}
+	if c.LLM.EmbeddingCache.LRUSize < 0 {
+		return errors.New("llm.embeddingCache.lruSize cannot be negative")
+	}
+	if c.LLM.EmbeddingCache.MaxEntries < 0 {
+		return errors.New("llm.embeddingCache.maxEntries cannot be negative")
+	}
+	if c.LLM.EmbeddingCache.TTL < 0 {
+		return errors.New("llm.embeddingCache.ttl cannot be negative")
+	}
 	if c.Summary.MaxSummaryLen <= 0 {
 		return errors.New("summary.maxSummaryLen must be positive")
 	}
@@ -625,6 +973,69 @@ func (c *Config) Validate() error {
 	if c.UploadAsk.MaxPreviewChars < 0 {
 		return errors.New("uploadAsk.maxPreviewChars cannot be negative")
 	}
+	switch c.UploadAsk.RetrievalMode {
+	case "", "vector", "lexical", "hybrid":
+	default:
+		return fmt.Errorf("uploadAsk.retrievalMode must be vector, lexical or hybrid, got %q", c.UploadAsk.RetrievalMode)
+	}
+	switch c.UploadAsk.Rerank.Strategy {
+	case "", "none", "deterministic", "llm":
+	default:
+		return fmt.Errorf("uploadAsk.rerank.strategy must be none, deterministic or llm, got %q", c.UploadAsk.Rerank.Strategy)
+	}
+	if c.UploadAsk.EmbedBatchSize < 0 {
+		return errors.New("uploadAsk.embedBatchSize cannot be negative")
+	}
+	switch c.UploadAsk.Storage.Driver {
+	case "", "memory", "r2":
+	case "local":
+		if strings.TrimSpace(c.UploadAsk.Storage.LocalPath) == "" {
+			return errors.New("uploadAsk.storage.localPath cannot be empty when the local storage driver is used")
+		}
+	default:
+		return fmt.Errorf("uploadAsk.storage.driver must be local, r2 or memory, got %q", c.UploadAsk.Storage.Driver)
+	}
+	switch c.UploadAsk.Queue.Driver {
+	case "", "sqlite", "immediate":
+	default:
+		return fmt.Errorf("uploadAsk.queue.driver must be sqlite or immediate, got %q", c.UploadAsk.Queue.Driver)
+	}
+	if c.UploadAsk.Queue.Workers < 0 || c.UploadAsk.Queue.MaxAttempts < 0 {
+		return errors.New("uploadAsk.queue.workers and maxAttempts cannot be negative")
+	}
+	if c.UploadAsk.EmbedRetry.Concurrency < 0 || c.UploadAsk.EmbedRetry.MaxAttempts < 0 || c.UploadAsk.EmbedRetry.BaseBackoff < 0 {
+		return errors.New("uploadAsk.embedRetry values cannot be negative")
+	}
+	if c.UploadAsk.Worker.ShutdownTimeout < 0 {
+		return errors.New("uploadAsk.worker.shutdownTimeout cannot be negative")
+	}
+	if c.UploadAsk.Recovery.StaleAfter < 0 || c.UploadAsk.Recovery.MaxAttempts < 0 || c.UploadAsk.Recovery.Interval < 0 {
+		return errors.New("uploadAsk.recovery values cannot be negative")
+	}
+	if c.UploadAsk.Rerank.Candidates < 0 {
+		return errors.New("uploadAsk.rerank.candidates cannot be negative")
+	}
+	switch c.UploadAsk.Chunker.Strategy {
+	case "", "simple", "structured":
+	default:
+		return fmt.Errorf("uploadAsk.chunker.strategy must be simple or structured, got %q", c.UploadAsk.Chunker.Strategy)
+	}
+	if c.UploadAsk.Chunker.MaxTokens < 0 || c.UploadAsk.Chunker.Overlap < 0 {
+		return errors.New("uploadAsk.chunker.maxTokens and overlap cannot be negative")
+	}
+	if c.UploadAsk.URLFetch.Timeout < 0 {
+		return errors.New("uploadAsk.urlFetch.timeout cannot be negative")
+	}
+	if c.UploadAsk.DirectUpload.URLTTL < 0 {
+		return errors.New("uploadAsk.directUpload.urlTtl cannot be negative")
+	}
+	if c.UploadAsk.Quota.MaxTotalMB < 0 || c.UploadAsk.Quota.MaxDocuments < 0 || c.UploadAsk.Quota.MaxChunks < 0 {
+		return errors.New("uploadAsk.quota values cannot be negative")
+	}
+	vectorIndex := c.UploadAsk.VectorIndex
+	if vectorIndex.M < 0 || vectorIndex.EfConstruction < 0 || vectorIndex.EfSearch < 0 || vectorIndex.MinChunks < 0 {
+		return errors.New("uploadAsk.vectorIndex values cannot be negative")
+	}
 	if c.UploadAsk.Memory.MaxHistoryTokens < 0 {
 		return errors.New("uploadAsk.memory.maxHistoryTokens cannot be negative")
 	}
@@ -664,3 +1075,12 @@ func googleConfigEnabled(cfg GoogleAuthConfig) bool {
 		strings.TrimSpace(cfg.TokenEncryptionKey) != "" ||
 		strings.TrimSpace(cfg.PostLoginRedirectURL) != ""
 }
+
+// durableUploadQueue reports whether upload jobs outlive the process that
+// enqueued them, so another process can consume them.
+func (c *Config) durableUploadQueue() bool {
+	if c.UploadAsk.Redis.Enabled {
+		return true
+	}
+	return c.UploadAsk.Queue.Driver == "sqlite" && c.SQLite.Enabled
+}
@@ -15,3 +15,28 @@ func TestDefaultHTTPTimeoutsAllowLiveLLMResponses(t *testing.T) {
 		t.Fatalf("write timeout = %s, want 60s", cfg.HTTP.WriteTimeout)
 	}
 }
+
+func TestValidateWorkerModeNeedsDurableQueue(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Mode = ModeWorker
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("worker mode with the sqlite queue: %v", err)
+	}
+
+	cfg.UploadAsk.Queue.Driver = "immediate"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("worker mode with the immediate queue should be rejected")
+	}
+
+	cfg.Mode = ModeServer
+	cfg.UploadAsk.Worker.Enabled = false
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("server mode without a worker should need a durable queue")
+	}
+
+	cfg.Mode = "batch"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("unknown mode should be rejected")
+	}
+}
@@ -0,0 +1,146 @@
+package embeddingcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+)
+
+// Store persists embeddings by cache key.
+type Store interface {
+	// Get returns the stored vectors for the keys it knows.
+	Get(ctx context.Context, keys []string) (map[string][]float32, error)
+	Put(ctx context.Context, entries []Entry) error
+}
+
+// Entry is one cached embedding.
+type Entry struct {
+	Key    string
+	Model  string
+	Vector []float32
+}
+
+// Stats counts cache lookups per text since startup.
+type Stats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// Cache answers embedding lookups from an optional in-process LRU and an
+// optional persistent Store. Store failures are logged and treated as misses
+// so the provider is still called.
+type Cache struct {
+	store  Store
+	lru    *lru
+	logger *slog.Logger
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// New constructs a cache. A nil store keeps entries in memory only and
+// lruSize <= 0 disables the LRU.
+func New(store Store, lruSize int, logger *slog.Logger) *Cache {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	c := &Cache{
+		store:  store,
+		logger: logger.With("component", "embeddingcache"),
+	}
+	if lruSize > 0 {
+		c.lru = newLRU(lruSize)
+	}
+	return c
+}
+
+// Stats reports hit and miss counts. A nil cache reports zeros.
+func (c *Cache) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// Lookup returns a vector per text, nil where the text is not cached, and the
+// indexes of the missing texts. dim is the expected vector length; cached
+// vectors of another length count as missing. Zero accepts any length.
+func (c *Cache) Lookup(ctx context.Context, model string, dim int, texts []string) ([][]float32, []int) {
+	vectors := make([][]float32, len(texts))
+	pending := make(map[string][]int)
+	var keys []string
+	for i, text := range texts {
+		key := Key(model, dim, text)
+		if c.lru != nil {
+			if vec, ok := c.lru.get(key); ok && fitsDim(vec, dim) {
+				vectors[i] = vec
+				continue
+			}
+		}
+		if _, seen := pending[key]; !seen {
+			keys = append(keys, key)
+		}
+		pending[key] = append(pending[key], i)
+	}
+	if c.store != nil && len(keys) > 0 {
+		stored, err := c.store.Get(ctx, keys)
+		if err != nil {
+			c.logger.Warn("embedding cache read failed", "error", err)
+		}
+		for key, vec := range stored {
+			if !fitsDim(vec, dim) {
+				continue
+			}
+			for _, i := range pending[key] {
+				vectors[i] = vec
+			}
+			if c.lru != nil {
+				c.lru.add(key, vec)
+			}
+		}
+	}
+	var missing []int
+	for i, vec := range vectors {
+		if vec == nil {
+			missing = append(missing, i)
+		}
+	}
+	c.hits.Add(int64(len(texts) - len(missing)))
+	c.misses.Add(int64(len(missing)))
+	return vectors, missing
+}
+
+// Save caches vectors[i] as the embedding of texts[i]. Vectors that do not
+// have dim values are not cached.
+func (c *Cache) Save(ctx context.Context, model string, dim int, texts []string, vectors [][]float32) {
+	entries := make([]Entry, 0, len(texts))
+	for i, text := range texts {
+		if i >= len(vectors) || len(vectors[i]) == 0 || !fitsDim(vectors[i], dim) {
+			continue
+		}
+		entry := Entry{Key: Key(model, dim, text), Model: model, Vector: vectors[i]}
+		if c.lru != nil {
+			c.lru.add(entry.Key, entry.Vector)
+		}
+		entries = append(entries, entry)
+	}
+	if c.store == nil || len(entries) == 0 {
+		return
+	}
+	if err := c.store.Put(ctx, entries); err != nil {
+		c.logger.Warn("embedding cache write failed", "error", err)
+	}
+}
+
+// Key identifies text embedded by model into dim-dimensional vectors, so a
+// VectorDim change never serves vectors of the old size.
+func Key(model string, dim int, text string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + strconv.Itoa(dim) + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+func fitsDim(vec []float32, dim int) bool {
+	return dim <= 0 || len(vec) == dim
+}
@@ -0,0 +1,186 @@
+package embeddingcache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/yanqian/ai-helloworld/internal/infra/llm/chatgpt"
+	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
+)
+
+type countingEmbedder struct {
+	calls [][]string
+}
+
+func (e *countingEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	e.calls = append(e.calls, append([]string(nil), texts...))
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = []float32{float32(len(text)), 1}
+	}
+	return out, nil
+}
+
+type countingChatClient struct {
+	inputs [][]string
+}
+
+func (c *countingChatClient) CreateChatCompletion(context.Context, chatgpt.ChatCompletionRequest) (chatgpt.ChatCompletionResponse, error) {
+	return chatgpt.ChatCompletionResponse{}, nil
+}
+
+func (c *countingChatClient) CreateEmbedding(_ context.Context, req chatgpt.EmbeddingRequest) (chatgpt.EmbeddingResponse, error) {
+	texts := req.Input.([]string)
+	c.inputs = append(c.inputs, texts)
+	resp := chatgpt.EmbeddingResponse{Usage: chatgpt.TokenUsage{PromptTokens: len(texts), TotalTokens: len(texts)}}
+	resp.Data = make([]struct {
+		Embedding []float32 `json:"embedding"`
+	}, len(texts))
+	for i, text := range texts {
+		resp.Data[i].Embedding = []float32{float32(len(text))}
+	}
+	return resp, nil
+}
+
+func TestEmbedderEmbedsOnlyUncachedTexts(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingEmbedder{}
+	embedder := NewEmbedder(inner, "model-a", 2, New(nil, 10, nil))
+
+	first, err := embedder.Embed(ctx, []string{"alpha", "beta", "alpha"})
+	require.NoError(t, err)
+	require.Len(t, first, 3)
+	require.Equal(t, first[0], first[2])
+
+	second, err := embedder.Embed(ctx, []string{"beta", "gamma"})
+	require.NoError(t, err)
+	require.Equal(t, first[1], second[0])
+	require.Equal(t, [][]string{{"alpha", "beta", "alpha"}, {"gamma"}}, inner.calls)
+	require.Equal(t, Stats{Hits: 1, Misses: 4}, embedder.cache.Stats())
+
+	// The same text under another model is a miss.
+	other := NewEmbedder(inner, "model-b", 2, embedder.cache)
+	_, err = other.Embed(ctx, []string{"beta"})
+	require.NoError(t, err)
+	require.Len(t, inner.calls, 3)
+
+	// So is the same text under another vector dimension.
+	resized := NewEmbedder(inner, "model-a", 3, embedder.cache)
+	_, err = resized.Embed(ctx, []string{"beta"})
+	require.NoError(t, err)
+	require.Len(t, inner.calls, 4)
+}
+
+func TestSQLiteStoreServesCacheAcrossRestarts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.db")
+	db, err := sqliteinfra.Open(ctx, path)
+	require.NoError(t, err)
+	inner := &countingEmbedder{}
+	_, err = NewEmbedder(inner, "model-a", 2, New(NewSQLiteStore(db, 0, 0), 0, nil)).Embed(ctx, []string{"alpha", "beta"})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = sqliteinfra.Open(ctx, path)
+	require.NoError(t, err)
+	defer db.Close()
+	cache := New(NewSQLiteStore(db, 0, 0), 0, nil)
+	vectors, err := NewEmbedder(inner, "model-a", 2, cache).Embed(ctx, []string{"beta", "alpha"})
+	require.NoError(t, err)
+	require.Equal(t, [][]float32{{4, 1}, {5, 1}}, vectors)
+	require.Len(t, inner.calls, 1)
+	require.Equal(t, Stats{Hits: 2}, cache.Stats())
+}
+
+func TestSQLiteStoreEvictsOldAndSurplusEntries(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	store := NewSQLiteStore(db, 2, time.Hour)
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, store.Put(ctx, []Entry{{Key: key, Model: "model-a", Vector: []float32{1, 2}}}))
+		time.Sleep(time.Millisecond)
+	}
+	got, err := store.Get(ctx, []string{"a", "b", "c"})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.NotContains(t, got, "a")
+	require.Equal(t, []float32{1, 2}, got["c"])
+
+	_, err = db.ExecContext(ctx, `UPDATE embedding_cache SET created_at = ? WHERE cache_key = 'b'`,
+		formatCacheTime(time.Now().Add(-2*time.Hour)))
+	require.NoError(t, err)
+	got, err = store.Get(ctx, []string{"b", "c"})
+	require.NoError(t, err)
+	require.Len(t, got, 1, "expired entries are not served")
+	require.NoError(t, store.Put(ctx, []Entry{{Key: "d", Model: "model-a", Vector: []float32{3, 4}}}))
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM embedding_cache WHERE cache_key = 'b'`).Scan(&count))
+	require.Zero(t, count, "expired entries are evicted on Put")
+}
+
+func TestSQLiteMigrationNormalizesCacheTimestamps(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.db")
+	db, err := sqliteinfra.Open(ctx, path)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO embedding_cache (cache_key, model, embedding, created_at) VALUES ('a', 'm', x'00', '2026-10-16T09:30:05.5Z'), ('b', 'm', x'00', '2026-10-16T09:30:05Z')`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = sqliteinfra.Open(ctx, path)
+	require.NoError(t, err)
+	defer db.Close()
+	var first string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT cache_key FROM embedding_cache ORDER BY created_at LIMIT 1`).Scan(&first))
+	require.Equal(t, "b", first)
+	var created string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT created_at FROM embedding_cache WHERE cache_key = 'a'`).Scan(&created))
+	require.Equal(t, "2026-10-16T09:30:05.500000000Z", created)
+}
+
+func TestFormatCacheTimeSortsChronologically(t *testing.T) {
+	whole := time.Date(2026, 10, 16, 9, 30, 5, 0, time.UTC)
+	half := whole.Add(500 * time.Millisecond)
+	require.Less(t, formatCacheTime(whole), formatCacheTime(half))
+	require.Len(t, formatCacheTime(whole), len(formatCacheTime(half)))
+}
+
+func TestChatClientCachesEmbeddings(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingChatClient{}
+	client := NewChatClient(inner, New(nil, 10, nil))
+
+	resp, err := client.CreateEmbedding(ctx, chatgpt.EmbeddingRequest{Model: "model-a", Input: "how do I reset my password?"})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 1)
+	require.Equal(t, 1, resp.Usage.TotalTokens)
+
+	resp, err = client.CreateEmbedding(ctx, chatgpt.EmbeddingRequest{Model: "model-a", Input: "how do I reset my password?"})
+	require.NoError(t, err)
+	require.Equal(t, []float32{27}, resp.Data[0].Embedding)
+	require.Zero(t, resp.Usage.TotalTokens)
+	require.Len(t, inner.inputs, 1)
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newLRU(2)
+	cache.add("a", []float32{1})
+	cache.add("b", []float32{2})
+	_, ok := cache.get("a")
+	require.True(t, ok)
+	cache.add("c", []float32{3})
+
+	_, ok = cache.get("b")
+	require.False(t, ok)
+	_, ok = cache.get("a")
+	require.True(t, ok)
+	_, ok = cache.get("c")
+	require.True(t, ok)
+}
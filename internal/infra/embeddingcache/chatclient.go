@@ -0,0 +1,75 @@
+package embeddingcache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yanqian/ai-helloworld/internal/domain/faq"
+	"github.com/yanqian/ai-helloworld/internal/infra/llm/chatgpt"
+)
+
+// ChatClient caches CreateEmbedding results of a FAQ chat client. Chat
+// completions pass through untouched.
+type ChatClient struct {
+	next  faq.ChatClient
+	cache *Cache
+}
+
+// NewChatClient wraps next with cache.
+func NewChatClient(next faq.ChatClient, cache *Cache) *ChatClient {
+	return &ChatClient{next: next, cache: cache}
+}
+
+// CreateChatCompletion delegates to the wrapped client.
+func (c *ChatClient) CreateChatCompletion(ctx context.Context, req chatgpt.ChatCompletionRequest) (chatgpt.ChatCompletionResponse, error) {
+	return c.next.CreateChatCompletion(ctx, req)
+}
+
+// CreateEmbedding answers cached inputs locally and requests only the rest.
+// Usage covers the provider call alone, so a full hit reports none.
+func (c *ChatClient) CreateEmbedding(ctx context.Context, req chatgpt.EmbeddingRequest) (chatgpt.EmbeddingResponse, error) {
+	var texts []string
+	switch input := req.Input.(type) {
+	case string:
+		texts = []string{input}
+	case []string:
+		texts = input
+	default:
+		return c.next.CreateEmbedding(ctx, req)
+	}
+	if len(texts) == 0 {
+		return c.next.CreateEmbedding(ctx, req)
+	}
+	vectors, missing := c.cache.Lookup(ctx, req.Model, 0, texts)
+	var usage chatgpt.TokenUsage
+	if len(missing) > 0 {
+		pending := make([]string, len(missing))
+		for i, idx := range missing {
+			pending[i] = texts[idx]
+		}
+		resp, err := c.next.CreateEmbedding(ctx, chatgpt.EmbeddingRequest{Model: req.Model, Input: pending})
+		if err != nil {
+			return chatgpt.EmbeddingResponse{}, err
+		}
+		if len(resp.Data) != len(pending) {
+			return chatgpt.EmbeddingResponse{}, fmt.Errorf("embedding response has %d vectors for %d inputs", len(resp.Data), len(pending))
+		}
+		embedded := make([][]float32, len(pending))
+		for i, item := range resp.Data {
+			embedded[i] = item.Embedding
+			vectors[missing[i]] = item.Embedding
+		}
+		c.cache.Save(ctx, req.Model, 0, pending, embedded)
+		usage = resp.Usage
+	}
+	out := chatgpt.EmbeddingResponse{Usage: usage}
+	out.Data = make([]struct {
+		Embedding []float32 `json:"embedding"`
+	}, len(vectors))
+	for i, vector := range vectors {
+		out.Data[i].Embedding = vector
+	}
+	return out, nil
+}
+
+var _ faq.ChatClient = (*ChatClient)(nil)
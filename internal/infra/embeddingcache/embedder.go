@@ -0,0 +1,51 @@
+package embeddingcache
+
+import (
+	"context"
+	"fmt"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// Embedder serves repeated texts from the cache and embeds only the rest.
+type Embedder struct {
+	next  domain.Embedder
+	model string
+	dim   int
+	cache *Cache
+}
+
+// NewEmbedder wraps next, whose dim-dimensional vectors are cached under
+// model.
+func NewEmbedder(next domain.Embedder, model string, dim int, cache *Cache) *Embedder {
+	return &Embedder{next: next, model: model, dim: dim, cache: cache}
+}
+
+// Embed returns one vector per text.
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	vectors, missing := e.cache.Lookup(ctx, e.model, e.dim, texts)
+	if len(missing) == 0 {
+		return vectors, nil
+	}
+	pending := make([]string, len(missing))
+	for i, idx := range missing {
+		pending[i] = texts[idx]
+	}
+	embedded, err := e.next.Embed(ctx, pending)
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded) != len(pending) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(embedded), len(pending))
+	}
+	for i, idx := range missing {
+		vectors[idx] = embedded[i]
+	}
+	e.cache.Save(ctx, e.model, e.dim, pending, embedded)
+	return vectors, nil
+}
+
+var _ domain.Embedder = (*Embedder)(nil)
@@ -0,0 +1,132 @@
+package embeddingcache
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
+)
+
+// sqliteMaxKeysPerQuery keeps IN lists under SQLite's bound parameter limit.
+const sqliteMaxKeysPerQuery = 500
+
+// sqliteTimeLayout is fixed width so created_at compares correctly as text.
+const sqliteTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// SQLiteStore persists cached embeddings in the embedding_cache table. Entries
+// older than ttl are ignored and, like those beyond the newest maxEntries,
+// evicted on Put; zero disables either limit.
+type SQLiteStore struct {
+	db         *sql.DB
+	maxEntries int
+	ttl        time.Duration
+}
+
+// NewSQLiteStore constructs a SQLite-backed embedding store.
+func NewSQLiteStore(db *sql.DB, maxEntries int, ttl time.Duration) *SQLiteStore {
+	return &SQLiteStore{db: db, maxEntries: maxEntries, ttl: ttl}
+}
+
+// Get loads the vectors stored under keys.
+func (s *SQLiteStore) Get(ctx context.Context, keys []string) (map[string][]float32, error) {
+	out := make(map[string][]float32, len(keys))
+	for start := 0; start < len(keys); start += sqliteMaxKeysPerQuery {
+		end := min(start+sqliteMaxKeysPerQuery, len(keys))
+		batch := keys[start:end]
+		args := make([]any, 0, len(batch)+1)
+		for _, key := range batch {
+			args = append(args, key)
+		}
+		args = append(args, s.cutoff())
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT cache_key, embedding
+			FROM embedding_cache
+			WHERE cache_key IN (?`+strings.Repeat(", ?", len(batch)-1)+`) AND created_at >= ?
+		`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var key string
+			var raw []byte
+			if err := rows.Scan(&key, &raw); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			vector, err := sqliteinfra.DecodeVector(raw)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			out[key] = vector
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Put stores or replaces entries, then evicts expired and surplus ones.
+func (s *SQLiteStore) Put(ctx context.Context, entries []Entry) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO embedding_cache (cache_key, model, embedding, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(cache_key) DO UPDATE SET
+			embedding = excluded.embedding,
+			created_at = excluded.created_at
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	now := formatCacheTime(time.Now())
+	for _, entry := range entries {
+		if _, err := stmt.ExecContext(ctx, entry.Key, entry.Model, sqliteinfra.EncodeVector(entry.Vector), now); err != nil {
+			return err
+		}
+	}
+	if s.ttl > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM embedding_cache WHERE created_at < ?`, s.cutoff()); err != nil {
+			return err
+		}
+	}
+	if s.maxEntries > 0 {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM embedding_cache
+			WHERE cache_key IN (
+				SELECT cache_key FROM embedding_cache
+				ORDER BY created_at DESC
+				LIMIT -1 OFFSET ?
+			)
+		`, s.maxEntries); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// cutoff is the oldest created_at still served; everything qualifies without
+// a TTL.
+func (s *SQLiteStore) cutoff() string {
+	if s.ttl <= 0 {
+		return ""
+	}
+	return formatCacheTime(time.Now().Add(-s.ttl))
+}
+
+func formatCacheTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+var _ Store = (*SQLiteStore)(nil)
@@ -9,6 +9,7 @@ import (
 	"hash/fnv"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -231,11 +232,43 @@ func (c *Client) doEmbeddingRequest(ctx context.Context, req EmbeddingRequest) (
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
 		payload, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
-		return nil, fmt.Errorf("embedding request failed: status=%d body=%s", resp.StatusCode, string(payload))
+		return nil, &EmbeddingError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(payload),
+		}
 	}
 	return io.ReadAll(resp.Body)
 }
 
+// EmbeddingError is returned when the embeddings API answers with a non-2xx
+// status. RetryAfter is parsed from the Retry-After header, if any.
+type EmbeddingError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *EmbeddingError) Error() string {
+	return fmt.Sprintf("embedding request failed: status=%d body=%s", e.StatusCode, e.Body)
+}
+
+// parseRetryAfter accepts both forms of the header: delay seconds and an
+// HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
 func (c *Client) newEmbeddingRequest(ctx context.Context, req EmbeddingRequest) (*http.Request, error) {
 	payload, err := json.Marshal(req)
 	if err != nil {
@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -39,3 +42,21 @@ func TestClientOfflineFallbackWithoutAPIKey(t *testing.T) {
 	require.Len(t, emb.Data[0].Embedding, 1536)
 	require.Equal(t, deterministicVector("alpha", 1536), emb.Data[0].Embedding)
 }
+
+func TestClientEmbeddingErrorCarriesRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"message":"slow down"}}`))
+	}))
+	defer server.Close()
+	client, err := NewClient("key", server.URL)
+	require.NoError(t, err)
+
+	_, err = client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: []string{"alpha"}})
+	var apiErr *EmbeddingError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	require.Equal(t, 7*time.Second, apiErr.RetryAfter)
+	require.Contains(t, err.Error(), "status=429")
+}
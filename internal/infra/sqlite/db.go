@@ -75,8 +75,10 @@ func migrate(ctx context.Context, db *sql.DB) error {
 			user_id INTEGER NOT NULL,
 			title TEXT NOT NULL,
 			source TEXT NOT NULL,
+			source_url TEXT,
 			status TEXT NOT NULL,
 			failure_reason TEXT,
+			progress TEXT,
 			created_at TEXT NOT NULL,
 			updated_at TEXT NOT NULL
 		)`,
@@ -92,13 +94,63 @@ func migrate(ctx context.Context, db *sql.DB) error {
 			created_at TEXT NOT NULL,
 			FOREIGN KEY(document_id) REFERENCES upload_documents(id) ON DELETE CASCADE
 		)`,
+		`CREATE TABLE IF NOT EXISTS upload_intents (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			filename TEXT NOT NULL,
+			title TEXT NOT NULL,
+			mime_type TEXT NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			storage_key TEXT NOT NULL,
+			status TEXT NOT NULL,
+			expires_at TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_upload_intents_storage_key
+			ON upload_intents(storage_key)`,
+		`CREATE TABLE IF NOT EXISTS upload_collections (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_upload_collections_user_created
+			ON upload_collections(user_id, created_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS upload_collection_documents (
+			collection_id TEXT NOT NULL,
+			document_id TEXT NOT NULL,
+			added_at TEXT NOT NULL,
+			PRIMARY KEY (collection_id, document_id),
+			FOREIGN KEY(collection_id) REFERENCES upload_collections(id) ON DELETE CASCADE,
+			FOREIGN KEY(document_id) REFERENCES upload_documents(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_upload_collection_documents_document
+			ON upload_collection_documents(document_id)`,
+		`CREATE TABLE IF NOT EXISTS upload_shares (
+			resource_type TEXT NOT NULL,
+			resource_id TEXT NOT NULL,
+			owner_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+			PRIMARY KEY (resource_type, resource_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_upload_shares_user_created
+			ON upload_shares(user_id, created_at DESC)`,
 		`CREATE TABLE IF NOT EXISTS upload_document_chunks (
 			id TEXT PRIMARY KEY,
 			document_id TEXT NOT NULL,
 			chunk_index INTEGER NOT NULL,
+			page_number INTEGER NOT NULL DEFAULT 0,
+			heading_path TEXT NOT NULL DEFAULT '',
 			content TEXT NOT NULL,
 			token_count INTEGER NOT NULL,
-			embedding TEXT NOT NULL,
+			embedding BLOB NOT NULL,
+			embedding_model TEXT NOT NULL DEFAULT '',
+			index_version TEXT NOT NULL DEFAULT '',
 			created_at TEXT NOT NULL,
 			UNIQUE(document_id, chunk_index),
 			FOREIGN KEY(document_id) REFERENCES upload_documents(id) ON DELETE CASCADE
@@ -142,7 +194,7 @@ func migrate(ctx context.Context, db *sql.DB) error {
 			user_id INTEGER NOT NULL,
 			source TEXT NOT NULL,
 			content TEXT NOT NULL,
-			embedding TEXT,
+			embedding BLOB,
 			importance INTEGER NOT NULL,
 			created_at TEXT NOT NULL,
 			UNIQUE(user_id, session_id, source, content),
@@ -150,6 +202,39 @@ func migrate(ctx context.Context, db *sql.DB) error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_upload_qa_memories_session_user
 			ON upload_qa_memories(user_id, session_id)`,
+		`CREATE TABLE IF NOT EXISTS upload_jobs (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			status TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL,
+			run_at TEXT NOT NULL,
+			lease_owner TEXT,
+			lease_expires_at TEXT,
+			last_error TEXT,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_upload_jobs_status_run_at
+			ON upload_jobs(status, run_at)`,
+		`CREATE TABLE IF NOT EXISTS upload_dead_jobs (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER NOT NULL,
+			max_attempts INTEGER NOT NULL,
+			last_error TEXT,
+			created_at TEXT NOT NULL,
+			failed_at TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS embedding_cache (
+			cache_key TEXT PRIMARY KEY,
+			model TEXT NOT NULL,
+			embedding BLOB NOT NULL,
+			created_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_embedding_cache_created ON embedding_cache(created_at)`,
 	}
 	for _, stmt := range stmts {
 		if _, err := db.ExecContext(ctx, stmt); err != nil {
@@ -162,6 +247,238 @@ func migrate(ctx context.Context, db *sql.DB) error {
 	if err := migrateFAQQuestions(ctx, db); err != nil {
 		return err
 	}
+	if err := migrateUploadAsk(ctx, db); err != nil {
+		return err
+	}
+	return nil
+}
+
+// migrateUploadAsk adds columns introduced after the upload-ask tables first shipped.
+func migrateUploadAsk(ctx context.Context, db *sql.DB) error {
+	if err := ensureColumn(ctx, db, "upload_document_chunks", "page_number", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_document_chunks", "heading_path", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_document_chunks", "embedding_model", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_document_chunks", "index_version", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_documents", "source_url", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_documents", "progress", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_documents", "recovery_attempts", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_documents", "mime_type", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_documents", "tags", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_documents", "metadata", "TEXT NOT NULL DEFAULT '{}'"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_qa_sessions", "collection_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(ctx, db, "upload_file_objects", "content_hash", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_upload_file_objects_content_hash ON upload_file_objects(content_hash)`); err != nil {
+		return fmt.Errorf("create upload file hash index: %w", err)
+	}
+	if err := ensureColumn(ctx, db, "upload_file_objects", "user_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// Files stored before user_id existed take their document's owner.
+	if _, err := db.ExecContext(ctx, `
+		UPDATE upload_file_objects
+		SET user_id = (SELECT user_id FROM upload_documents WHERE upload_documents.id = upload_file_objects.document_id)
+		WHERE user_id = 0
+	`); err != nil {
+		return fmt.Errorf("backfill upload file owners: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_upload_file_objects_user ON upload_file_objects(user_id)`); err != nil {
+		return fmt.Errorf("create upload file user index: %w", err)
+	}
+	// Documents stored before mime_type existed take their file's type.
+	if _, err := db.ExecContext(ctx, `
+		UPDATE upload_documents
+		SET mime_type = (SELECT mime_type FROM upload_file_objects WHERE upload_file_objects.document_id = upload_documents.id)
+		WHERE mime_type = '' AND EXISTS (SELECT 1 FROM upload_file_objects WHERE upload_file_objects.document_id = upload_documents.id)
+	`); err != nil {
+		return fmt.Errorf("backfill upload document mime types: %w", err)
+	}
+	if err := ensureChunkSearchIndex(ctx, db); err != nil {
+		return err
+	}
+	if err := ensureChunkVectorLog(ctx, db); err != nil {
+		return err
+	}
+	for _, table := range []string{"upload_document_chunks", "upload_qa_memories"} {
+		if err := migrateJSONVectors(ctx, db, table); err != nil {
+			return err
+		}
+	}
+	// Cache rows written as JSON predate dimension-aware keys and can never be
+	// hit again.
+	if _, err := db.ExecContext(ctx, `DELETE FROM embedding_cache WHERE typeof(embedding) = 'text'`); err != nil {
+		return fmt.Errorf("drop legacy embedding cache rows: %w", err)
+	}
+	// Early cache rows used RFC 3339 timestamps, whose variable width breaks
+	// the text comparisons TTL eviction relies on.
+	if _, err := db.ExecContext(ctx, `
+		UPDATE embedding_cache
+		SET created_at = strftime('%Y-%m-%dT%H:%M:%f', created_at) || '000000Z'
+		WHERE length(created_at) != 30
+	`); err != nil {
+		return fmt.Errorf("normalize embedding cache timestamps: %w", err)
+	}
+	return nil
+}
+
+// migrateJSONVectors rewrites embeddings stored as JSON text in table to the
+// binary EncodeVector form, a batch per transaction.
+func migrateJSONVectors(ctx context.Context, db *sql.DB, table string) error {
+	for {
+		converted, err := migrateJSONVectorBatch(ctx, db, table, 500)
+		if err != nil {
+			return fmt.Errorf("convert %s embeddings: %w", table, err)
+		}
+		if converted == 0 {
+			return nil
+		}
+	}
+}
+
+func migrateJSONVectorBatch(ctx context.Context, db *sql.DB, table string, limit int) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `SELECT rowid, embedding FROM `+table+` WHERE typeof(embedding) = 'text' LIMIT ?`, limit)
+	if err != nil {
+		return 0, err
+	}
+	type pending struct {
+		rowID  int64
+		vector []byte
+	}
+	var batch []pending
+	for rows.Next() {
+		var (
+			rowID int64
+			raw   []byte
+		)
+		if err := rows.Scan(&rowID, &raw); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		vector, err := DecodeVector(raw)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("row %d: %w", rowID, err)
+		}
+		batch = append(batch, pending{rowID: rowID, vector: EncodeVector(vector)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, p := range batch {
+		if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET embedding = ? WHERE rowid = ?`, p.vector, p.rowID); err != nil {
+			return 0, err
+		}
+	}
+	return len(batch), tx.Commit()
+}
+
+// ensureChunkSearchIndex creates the FTS5 keyword index over chunk content,
+// kept in sync by triggers, and back-fills it when it is created for a
+// database that already holds chunks.
+func ensureChunkSearchIndex(ctx context.Context, db *sql.DB) error {
+	var existing int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(1) FROM sqlite_master WHERE type = 'table' AND name = 'upload_document_chunks_fts'`).Scan(&existing); err != nil {
+		return fmt.Errorf("check chunk search index: %w", err)
+	}
+	stmts := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS upload_document_chunks_fts USING fts5(
+			chunk_id UNINDEXED,
+			content,
+			tokenize = "unicode61 tokenchars '-_'"
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS upload_document_chunks_fts_insert
+			AFTER INSERT ON upload_document_chunks BEGIN
+				INSERT INTO upload_document_chunks_fts (chunk_id, content) VALUES (new.id, new.content);
+			END`,
+		`CREATE TRIGGER IF NOT EXISTS upload_document_chunks_fts_delete
+			AFTER DELETE ON upload_document_chunks BEGIN
+				DELETE FROM upload_document_chunks_fts WHERE chunk_id = old.id;
+			END`,
+		`CREATE TRIGGER IF NOT EXISTS upload_document_chunks_fts_update
+			AFTER UPDATE OF content ON upload_document_chunks BEGIN
+				DELETE FROM upload_document_chunks_fts WHERE chunk_id = old.id;
+				INSERT INTO upload_document_chunks_fts (chunk_id, content) VALUES (new.id, new.content);
+			END`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("create chunk search index: %w", err)
+		}
+	}
+	if existing > 0 {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO upload_document_chunks_fts (chunk_id, content) SELECT id, content FROM upload_document_chunks`); err != nil {
+		return fmt.Errorf("backfill chunk search index: %w", err)
+	}
+	return nil
+}
+
+// ensureChunkVectorLog creates the append-only log of chunk inserts that
+// in-process vector indexes replay to pick up chunks written by any
+// connection, such as a separate worker process. Triggers keep it in sync and
+// it is back-filled when created for a database that already holds chunks.
+func ensureChunkVectorLog(ctx context.Context, db *sql.DB) error {
+	existing, err := tableExists(ctx, db, "upload_chunk_vector_log")
+	if err != nil {
+		return err
+	}
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS upload_chunk_vector_log (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			chunk_id TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_upload_chunk_vector_log_chunk
+			ON upload_chunk_vector_log(chunk_id)`,
+		`CREATE TRIGGER IF NOT EXISTS upload_chunk_vector_log_insert
+			AFTER INSERT ON upload_document_chunks BEGIN
+				INSERT INTO upload_chunk_vector_log (chunk_id) VALUES (new.id);
+			END`,
+		`CREATE TRIGGER IF NOT EXISTS upload_chunk_vector_log_delete
+			AFTER DELETE ON upload_document_chunks BEGIN
+				DELETE FROM upload_chunk_vector_log WHERE chunk_id = old.id;
+			END`,
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("create chunk vector log: %w", err)
+		}
+	}
+	if existing {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, `INSERT INTO upload_chunk_vector_log (chunk_id) SELECT id FROM upload_document_chunks ORDER BY rowid`); err != nil {
+		return fmt.Errorf("backfill chunk vector log: %w", err)
+	}
 	return nil
 }
 
@@ -416,6 +733,20 @@ func tableExists(ctx context.Context, db *sql.DB, name string) (bool, error) {
 	return count > 0, nil
 }
 
+func ensureColumn(ctx context.Context, db *sql.DB, table string, column string, definition string) error {
+	exists, err := columnExists(ctx, db, table, column)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("add %s.%s column: %w", table, column, err)
+	}
+	return nil
+}
+
 func columnExists(ctx context.Context, db *sql.DB, table string, column string) (bool, error) {
 	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
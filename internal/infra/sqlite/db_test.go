@@ -353,3 +353,79 @@ func testForeignKeyTable(t *testing.T, ctx context.Context, db *sql.DB, table st
 	t.Fatalf("foreign key for %s.%s not found", table, fromColumn)
 	return ""
 }
+
+func TestOpenBackfillsUploadFileOwners(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "upload-files.db")
+	raw := testRawDB(t, path)
+	_, err := raw.ExecContext(ctx, `
+		CREATE TABLE upload_documents (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			title TEXT NOT NULL,
+			source TEXT NOT NULL,
+			status TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		)
+	`)
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, `
+		CREATE TABLE upload_file_objects (
+			id TEXT PRIMARY KEY,
+			document_id TEXT NOT NULL UNIQUE,
+			storage_key TEXT NOT NULL,
+			size_bytes INTEGER NOT NULL,
+			mime_type TEXT NOT NULL,
+			etag TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		)
+	`)
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, `INSERT INTO upload_documents VALUES ('doc-1', 7, 'Notes', 'upload', 'processed', '2024-01-01T00:00:00Z', '2024-01-01T00:00:00Z')`)
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, `INSERT INTO upload_file_objects VALUES ('file-1', 'doc-1', 'key', 5, 'text/plain', 'etag', '2024-01-01T00:00:00Z')`)
+	require.NoError(t, err)
+	require.NoError(t, raw.Close())
+
+	db, err := Open(ctx, path)
+	require.NoError(t, err)
+	defer db.Close()
+	var userID int64
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT user_id FROM upload_file_objects WHERE id = 'file-1'`).Scan(&userID))
+	require.Equal(t, int64(7), userID)
+}
+
+func TestOpenConvertsJSONEmbeddingsToBinary(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vectors.db")
+	db, err := Open(ctx, path)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO upload_documents (id, user_id, title, source, status, created_at, updated_at) VALUES ('doc-1', 7, 'Notes', 'upload', 'processed', '2024-01-01T00:00:00Z', '2024-01-01T00:00:00Z')`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO upload_document_chunks (id, document_id, chunk_index, content, token_count, embedding, created_at) VALUES ('chunk-1', 'doc-1', 0, 'hello', 1, '[0.5,-1.25,3]', '2024-01-01T00:00:00Z')`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO upload_qa_sessions (id, user_id, created_at) VALUES ('session-1', 7, '2024-01-01T00:00:00Z')`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `INSERT INTO upload_qa_memories (session_id, user_id, source, content, embedding, importance, created_at) VALUES ('session-1', 7, 'qa', 'remember', '[1,2]', 1, '2024-01-01T00:00:00Z')`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = Open(ctx, path)
+	require.NoError(t, err)
+	defer db.Close()
+	for table, want := range map[string][]float32{
+		"upload_document_chunks": {0.5, -1.25, 3},
+		"upload_qa_memories":     {1, 2},
+	} {
+		var (
+			kind string
+			raw  []byte
+		)
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT typeof(embedding), embedding FROM `+table).Scan(&kind, &raw))
+		require.Equal(t, "blob", kind, table)
+		got, err := DecodeVector(raw)
+		require.NoError(t, err)
+		require.Equal(t, want, got, table)
+	}
+}
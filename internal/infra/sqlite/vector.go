@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// vectorFormatFloat32 leads a vector stored as little-endian float32 values.
+// JSON arrays, the format used before, start with '[' or 'n' instead and are
+// still read.
+const vectorFormatFloat32 byte = 1
+
+// EncodeVector stores vec as little-endian float32 values.
+func EncodeVector(vec []float32) []byte {
+	out := make([]byte, 1+4*len(vec))
+	out[0] = vectorFormatFloat32
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(out[1+4*i:], math.Float32bits(v))
+	}
+	return out
+}
+
+// DecodeVector reads a vector written by EncodeVector or as a JSON array.
+func DecodeVector(raw []byte) ([]float32, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	switch raw[0] {
+	case vectorFormatFloat32:
+		payload := raw[1:]
+		if len(payload)%4 != 0 {
+			return nil, fmt.Errorf("float32 vector has %d trailing bytes", len(payload)%4)
+		}
+		out := make([]float32, len(payload)/4)
+		for i := range out {
+			out[i] = math.Float32frombits(binary.LittleEndian.Uint32(payload[4*i:]))
+		}
+		return out, nil
+	case '[', 'n':
+		var out []float32
+		if err := json.Unmarshal(raw, &out); err != nil {
+			return nil, fmt.Errorf("decode json vector: %w", err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unknown vector format %d", raw[0])
+	}
+}
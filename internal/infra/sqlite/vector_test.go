@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVectorRoundTrips(t *testing.T) {
+	vec := []float32{0, -1.5, 3.25, 1e-7}
+	got, err := DecodeVector(EncodeVector(vec))
+	require.NoError(t, err)
+	require.Equal(t, vec, got)
+
+	got, err = DecodeVector([]byte(`[0.5,2]`))
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.5, 2}, got)
+
+	got, err = DecodeVector(nil)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = DecodeVector(EncodeVector(vec)[:6])
+	require.Error(t, err)
+	_, err = DecodeVector([]byte{9, 0, 0, 0, 0})
+	require.Error(t, err)
+}
+
+// BenchmarkDecodeVector compares parsing a 1536-dimension embedding from
+// JSON text with reading it from the binary encoding.
+func BenchmarkDecodeVector(b *testing.B) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	vec := make([]float32, 1536)
+	for i := range vec {
+		vec[i] = float32(rng.NormFloat64())
+	}
+	text, err := json.Marshal(vec)
+	require.NoError(b, err)
+	for name, raw := range map[string][]byte{"json": text, "binary": EncodeVector(vec)} {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(raw)))
+			for i := 0; i < b.N; i++ {
+				if _, err := DecodeVector(raw); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package chunker
+
+import (
+	"regexp"
+	"strings"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+var (
+	headingLine = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.+?)\s*#*\s*$`)
+	fenceLine   = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	listLine    = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+)
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockList
+	blockTable
+	blockCode
+)
+
+// block is a run of lines that belong together. Units are the smallest parts
+// a block may be split into: list items, table rows or paragraph lines. Code
+// blocks are never split.
+type block struct {
+	kind  blockKind
+	units []string
+}
+
+func (b block) text() string {
+	return strings.Join(b.units, "\n")
+}
+
+// section is the body that follows a heading (or the document preamble).
+type section struct {
+	heading string
+	path    []string
+	blocks  []block
+}
+
+// StructuredChunker splits Markdown-style text along its outline. Chunks never
+// span two sections, carry the heading path of their section and start with
+// the section heading. Lists and tables are split only between items or rows
+// and fenced code blocks are always kept whole, even when they exceed the
+// token budget.
+type StructuredChunker struct {
+	MaxTokens int
+	fallback  *SimpleChunker
+}
+
+// NewStructuredChunker constructs a chunker that packs blocks up to maxTokens.
+func NewStructuredChunker(maxTokens int) *StructuredChunker {
+	fallback := NewSimpleChunker(maxTokens, 0)
+	return &StructuredChunker{MaxTokens: fallback.MaxTokens, fallback: fallback}
+}
+
+// Chunk splits by heading, then packs the section blocks by token budget.
+func (c *StructuredChunker) Chunk(text string) []domain.ChunkCandidate {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return nil
+	}
+	var out []domain.ChunkCandidate
+	for _, sec := range parseSections(text) {
+		for _, content := range c.packSection(sec) {
+			out = append(out, domain.ChunkCandidate{
+				Index:       len(out),
+				Content:     content,
+				TokenCount:  c.fallback.countTokens(content),
+				HeadingPath: append([]string(nil), sec.path...),
+			})
+		}
+	}
+	if len(out) == 0 {
+		// Only headings: fall back so the text is still searchable.
+		return c.fallback.Chunk(text)
+	}
+	return out
+}
+
+// packSection groups the section blocks into chunk contents, each prefixed by
+// the section heading line.
+func (c *StructuredChunker) packSection(sec section) []string {
+	if len(sec.blocks) == 0 {
+		return nil
+	}
+	budget := c.MaxTokens
+	if sec.heading != "" {
+		budget -= c.fallback.countTokens(sec.heading + "\n\n")
+	}
+	budget = max(budget, c.MaxTokens/4, 1)
+
+	var (
+		out     []string
+		current []string
+		used    int
+	)
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		body := strings.Join(current, "\n\n")
+		if sec.heading != "" {
+			body = sec.heading + "\n\n" + body
+		}
+		out = append(out, body)
+		current, used = nil, 0
+	}
+	add := func(piece string) {
+		tokens := c.fallback.countTokens(piece)
+		if len(current) > 0 && used+tokens > budget {
+			flush()
+		}
+		current = append(current, piece)
+		used += tokens
+	}
+	for _, b := range sec.blocks {
+		content := b.text()
+		if b.kind == blockCode || c.fallback.countTokens(content) <= budget {
+			add(content)
+			continue
+		}
+		for _, piece := range c.splitBlock(b, budget) {
+			add(piece)
+		}
+	}
+	flush()
+	return out
+}
+
+// splitBlock breaks an oversize block at unit boundaries. Units that are too
+// large on their own are split by the simple chunker.
+func (c *StructuredChunker) splitBlock(b block, budget int) []string {
+	var (
+		out     []string
+		current []string
+		used    int
+	)
+	flush := func() {
+		if len(current) > 0 {
+			out = append(out, strings.Join(current, "\n"))
+		}
+		current, used = nil, 0
+	}
+	for _, unit := range b.units {
+		tokens := c.fallback.countTokens(unit)
+		if tokens > budget {
+			flush()
+			for _, candidate := range c.fallback.Chunk(unit) {
+				out = append(out, candidate.Content)
+			}
+			continue
+		}
+		if used+tokens > budget {
+			flush()
+		}
+		current = append(current, unit)
+		used += tokens
+	}
+	flush()
+	return out
+}
+
+// parseSections splits text into sections at ATX headings and each section
+// into paragraph, list, table and code blocks.
+func parseSections(text string) []section {
+	type heading struct {
+		level int
+		title string
+	}
+	var (
+		stack    []heading
+		sections = []section{{}}
+		current  *block
+		fence    string
+	)
+	sec := func() *section { return &sections[len(sections)-1] }
+	closeBlock := func() {
+		if current != nil {
+			sec().blocks = append(sec().blocks, *current)
+			current = nil
+		}
+	}
+	startBlock := func(kind blockKind, line string) {
+		closeBlock()
+		current = &block{kind: kind, units: []string{line}}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if fence != "" {
+			current.units = append(current.units, line)
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+				closeBlock()
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if m := fenceLine.FindStringSubmatch(line); m != nil {
+			startBlock(blockCode, line)
+			fence = m[1]
+			continue
+		}
+		if trimmed == "" {
+			closeBlock()
+			continue
+		}
+		if m := headingLine.FindStringSubmatch(line); m != nil {
+			closeBlock()
+			level := len(m[1])
+			for len(stack) > 0 && stack[len(stack)-1].level >= level {
+				stack = stack[:len(stack)-1]
+			}
+			stack = append(stack, heading{level: level, title: m[2]})
+			path := make([]string, len(stack))
+			for i, h := range stack {
+				path[i] = h.title
+			}
+			sections = append(sections, section{heading: trimmed, path: path})
+			continue
+		}
+		switch {
+		case listLine.MatchString(line):
+			if current == nil || current.kind != blockList {
+				startBlock(blockList, line)
+			} else {
+				current.units = append(current.units, line)
+			}
+		case current != nil && current.kind == blockList && line != trimmed:
+			// Indented continuation of the previous list item.
+			last := len(current.units) - 1
+			current.units[last] += "\n" + line
+		case strings.HasPrefix(trimmed, "|") || strings.Contains(trimmed, " | "):
+			if current == nil || current.kind != blockTable {
+				startBlock(blockTable, line)
+			} else {
+				current.units = append(current.units, line)
+			}
+		default:
+			if current == nil || current.kind != blockParagraph {
+				startBlock(blockParagraph, line)
+			} else {
+				current.units = append(current.units, line)
+			}
+		}
+	}
+	closeBlock()
+	return sections
+}
+
+var _ domain.Chunker = (*StructuredChunker)(nil)
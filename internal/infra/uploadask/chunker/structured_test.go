@@ -0,0 +1,70 @@
+package chunker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructuredChunkerSplitsOnHeadingsAndCarriesPath(t *testing.T) {
+	text := "Intro line.\n\n" +
+		"# Guide\n\n" +
+		"## Install\n\nRun the installer.\n\n- step one\n- step two\n  continued\n\n" +
+		"## Usage\n\n```sh\napp run\n\napp stop\n```\n\n" +
+		"# Appendix\n\nKey | Value\na | 1"
+
+	chunks := NewStructuredChunker(200).Chunk(text)
+
+	require.Len(t, chunks, 4)
+	require.Equal(t, "Intro line.", chunks[0].Content)
+	require.Empty(t, chunks[0].HeadingPath)
+
+	require.Equal(t, []string{"Guide", "Install"}, chunks[1].HeadingPath)
+	require.Equal(t, "## Install\n\nRun the installer.\n\n- step one\n- step two\n  continued", chunks[1].Content)
+
+	require.Equal(t, []string{"Guide", "Usage"}, chunks[2].HeadingPath)
+	require.Equal(t, "## Usage\n\n```sh\napp run\n\napp stop\n```", chunks[2].Content)
+
+	require.Equal(t, []string{"Appendix"}, chunks[3].HeadingPath)
+	for i, chunk := range chunks {
+		require.Equal(t, i, chunk.Index)
+		require.Positive(t, chunk.TokenCount)
+	}
+}
+
+func TestStructuredChunkerNeverSplitsCodeFences(t *testing.T) {
+	code := "```go\n" + strings.Repeat("fmt.Println(\"a long line of code\")\n", 40) + "```"
+	text := "# Code\n\nBefore.\n\n" + code + "\n\nAfter."
+
+	chunks := NewStructuredChunker(50).Chunk(text)
+
+	var found bool
+	for _, chunk := range chunks {
+		require.Equal(t, []string{"Code"}, chunk.HeadingPath)
+		require.True(t, strings.HasPrefix(chunk.Content, "# Code\n\n"))
+		require.Zero(t, strings.Count(chunk.Content, "```")%2, "fence split across chunks")
+		if strings.Contains(chunk.Content, code) {
+			found = true
+		}
+	}
+	require.True(t, found)
+}
+
+func TestStructuredChunkerSplitsListsBetweenItems(t *testing.T) {
+	var items []string
+	for i := 0; i < 30; i++ {
+		items = append(items, "- item with a handful of words in it")
+	}
+	text := "# List\n\n" + strings.Join(items, "\n")
+
+	chunks := NewStructuredChunker(60).Chunk(text)
+
+	require.Greater(t, len(chunks), 1)
+	for _, chunk := range chunks {
+		body := strings.TrimPrefix(chunk.Content, "# List\n\n")
+		for _, line := range strings.Split(body, "\n") {
+			require.Equal(t, "- item with a handful of words in it", line)
+		}
+	}
+}
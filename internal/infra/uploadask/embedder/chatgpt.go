@@ -2,11 +2,14 @@ package embedder
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"strings"
 	"unicode/utf8"
 
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
 	"github.com/yanqian/ai-helloworld/internal/infra/llm/chatgpt"
 )
 
@@ -51,6 +54,10 @@ func (e *ChatGPTEmbedder) Embed(ctx context.Context, texts []string) ([][]float3
 		}
 		resp, err := e.client.CreateEmbedding(ctx, req)
 		if err != nil {
+			var apiErr *chatgpt.EmbeddingError
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+				return &domain.RateLimitError{RetryAfter: apiErr.RetryAfter, Err: err}
+			}
 			return fmt.Errorf("create embedding: %w", err)
 		}
 		for _, item := range resp.Data {
@@ -85,9 +92,7 @@ func (e *ChatGPTEmbedder) Embed(ctx context.Context, texts []string) ([][]float3
 	return out, nil
 }
 
-var _ interface {
-	Embed(context.Context, []string) ([][]float32, error)
-} = (*ChatGPTEmbedder)(nil)
+var _ domain.Embedder = (*ChatGPTEmbedder)(nil)
 
 // estimateTokens provides a rough, upper-biased token count without external dependencies.
 func estimateTokens(text string) int {
@@ -0,0 +1,139 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// maxDOCXBodyBytes caps the decompressed size of word/document.xml to guard
+// against zip bombs.
+const maxDOCXBodyBytes = 64 << 20
+
+// DOCXExtractor reads the body of Word (.docx) documents. Paragraphs styled
+// as Title or Heading N become Markdown-style headings and numbered or
+// bulleted paragraphs become list items.
+type DOCXExtractor struct{}
+
+// Extract returns the document body as a single unpaged unit of text.
+func (DOCXExtractor) Extract(_ context.Context, _ string, data []byte) ([]domain.ExtractedPage, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open docx archive: %w", err)
+	}
+	var body *zip.File
+	for _, f := range archive.File {
+		if f.Name == "word/document.xml" {
+			body = f
+			break
+		}
+	}
+	if body == nil {
+		return nil, errors.New("docx archive has no word/document.xml")
+	}
+	rc, err := body.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open docx body: %w", err)
+	}
+	defer rc.Close()
+	text, err := parseDOCXBody(io.LimitReader(rc, maxDOCXBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	return []domain.ExtractedPage{{Text: text}}, nil
+}
+
+var _ domain.TextExtractor = DOCXExtractor{}
+
+func parseDOCXBody(r io.Reader) (string, error) {
+	decoder := xml.NewDecoder(r)
+	var (
+		lines     []string
+		paragraph strings.Builder
+		style     string
+		listItem  bool
+		inText    bool
+	)
+	for {
+		tok, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("parse docx body: %w", err)
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "p":
+				paragraph.Reset()
+				style, listItem = "", false
+			case "pStyle":
+				style = xmlAttr(el, "val")
+			case "numPr":
+				listItem = true
+			case "t":
+				inText = true
+			case "tab":
+				paragraph.WriteByte('\t')
+			case "br", "cr":
+				paragraph.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch el.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(paragraph.String())
+				if text == "" {
+					continue
+				}
+				if level := docxHeadingLevel(style); level > 0 {
+					text = strings.Repeat("#", level) + " " + strings.Join(strings.Fields(text), " ")
+				} else if listItem || strings.HasPrefix(strings.ToLower(style), "listbullet") {
+					text = "- " + text
+				}
+				lines = append(lines, text)
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(el)
+			}
+		}
+	}
+	return joinBlocks(lines), nil
+}
+
+// docxHeadingLevel maps built-in paragraph style ids (Title, Heading1..9) to
+// Markdown heading levels, capped at 6.
+func docxHeadingLevel(style string) int {
+	normalized := strings.ToLower(strings.ReplaceAll(style, " ", ""))
+	if normalized == "title" {
+		return 1
+	}
+	if !strings.HasPrefix(normalized, "heading") {
+		return 0
+	}
+	level, err := strconv.Atoi(strings.TrimPrefix(normalized, "heading"))
+	if err != nil || level <= 0 {
+		return 0
+	}
+	return min(level, 6)
+}
+
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,226 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// MimeTypeDOCX is the MIME type of Word (Office Open XML) documents.
+const MimeTypeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// Registry dispatches extraction to the extractor registered for a MIME type.
+type Registry struct {
+	byType       map[string]domain.TextExtractor
+	maxBlobBytes int64
+}
+
+// NewRegistry constructs a registry with the built-in extractors registered.
+// maxBlobBytes caps how much ExtractStream buffers for extractors that cannot
+// stream; 0 means unlimited.
+func NewRegistry(maxBlobBytes int64) *Registry {
+	r := &Registry{byType: make(map[string]domain.TextExtractor), maxBlobBytes: maxBlobBytes}
+	r.Register("application/pdf", NewPDFExtractor())
+	r.Register("text/plain", PlainTextExtractor{})
+	r.Register("text/csv", PlainTextExtractor{})
+	r.Register("text/markdown", MarkdownExtractor{})
+	r.Register("text/x-markdown", MarkdownExtractor{})
+	r.Register("text/html", HTMLExtractor{})
+	r.Register("application/xhtml+xml", HTMLExtractor{})
+	r.Register(MimeTypeDOCX, DOCXExtractor{})
+	return r
+}
+
+// Register associates an extractor with a MIME type, replacing any previous entry.
+func (r *Registry) Register(mimeType string, extractor domain.TextExtractor) {
+	r.byType[normalizeMimeType(mimeType)] = extractor
+}
+
+// Extract picks an extractor by MIME type. Generic or missing types are
+// sniffed from the content; types without an extractor fail with
+// domain.ErrUnsupportedFileType rather than being embedded as raw bytes.
+func (r *Registry) Extract(ctx context.Context, mimeType string, data []byte) ([]domain.ExtractedPage, error) {
+	mime := sniffMimeType(mimeType, data)
+	if mime == "application/zip" && isDOCX(data) {
+		mime = MimeTypeDOCX
+	}
+	extractor, ok := r.byType[mime]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", domain.ErrUnsupportedFileType, mime)
+	}
+	return extractor.Extract(ctx, mime, data)
+}
+
+// ExtractStream is Extract for a reader. Extractors that can stream read it
+// incrementally; the others (PDF, HTML, DOCX and zip archives, which need
+// random access or a full parse) get the whole blob in memory, so a blob over
+// maxBlobBytes fails with domain.ErrFileTooLarge instead.
+func (r *Registry) ExtractStream(ctx context.Context, mimeType string, src io.Reader, emit func(domain.ExtractedPage) error) error {
+	br := bufio.NewReaderSize(src, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	mime := sniffMimeType(mimeType, head)
+	if extractor, ok := r.byType[mime].(domain.StreamingExtractor); ok {
+		return extractor.ExtractStream(ctx, mime, br, emit)
+	}
+	if _, ok := r.byType[mime]; !ok && mime != "application/zip" {
+		return fmt.Errorf("%w: %s", domain.ErrUnsupportedFileType, mime)
+	}
+	data, err := r.readBlob(br)
+	if err != nil {
+		return err
+	}
+	pages, err := r.Extract(ctx, mime, data)
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		if err := emit(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBlob reads src whole, failing once it exceeds maxBlobBytes.
+func (r *Registry) readBlob(src io.Reader) ([]byte, error) {
+	if r.maxBlobBytes <= 0 {
+		return io.ReadAll(src)
+	}
+	data, err := io.ReadAll(io.LimitReader(src, r.maxBlobBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > r.maxBlobBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes to extract", domain.ErrFileTooLarge, r.maxBlobBytes)
+	}
+	return data, nil
+}
+
+var _ domain.StreamingExtractor = (*Registry)(nil)
+
+// sniffLen is how much content http.DetectContentType considers.
+const sniffLen = 512
+
+// sniffMimeType normalizes mimeType, detecting generic or missing types from
+// the start of the content.
+func sniffMimeType(mimeType string, head []byte) string {
+	mime := normalizeMimeType(mimeType)
+	if mime == "" || mime == "application/octet-stream" {
+		mime = normalizeMimeType(http.DetectContentType(head))
+	}
+	return mime
+}
+
+// PlainTextExtractor returns the blob as a single page of UTF-8 text.
+type PlainTextExtractor struct{}
+
+// Extract strips a byte order mark and replaces invalid UTF-8 sequences.
+func (PlainTextExtractor) Extract(_ context.Context, _ string, data []byte) ([]domain.ExtractedPage, error) {
+	return []domain.ExtractedPage{{Text: decodeText(data)}}, nil
+}
+
+// Plain text is streamed in sections of at least plainTextSectionBytes, cut
+// at a blank line where possible and never longer than
+// plainTextMaxSectionBytes.
+const (
+	plainTextSectionBytes    = 64 << 10
+	plainTextMaxSectionBytes = 256 << 10
+)
+
+// ExtractStream emits the text a section at a time, splitting at paragraph
+// breaks so chunks rarely straddle a section boundary.
+func (PlainTextExtractor) ExtractStream(ctx context.Context, _ string, r io.Reader, emit func(domain.ExtractedPage) error) error {
+	br := bufio.NewReaderSize(r, plainTextSectionBytes)
+	var section []byte
+	flush := func(final bool) error {
+		cut := len(section)
+		if !final {
+			// Carry a rune split by the size cap over to the next section.
+			cut = completeRunes(section)
+		}
+		text := decodeText(section[:cut])
+		section = append(section[:0], section[cut:]...)
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return emit(domain.ExtractedPage{Text: text})
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line, err := br.ReadSlice('\n')
+		if len(section)+len(line) > plainTextMaxSectionBytes {
+			if ferr := flush(false); ferr != nil {
+				return ferr
+			}
+		}
+		section = append(section, line...)
+		if err == nil && len(section) >= plainTextSectionBytes && len(bytes.TrimSpace(line)) == 0 {
+			if ferr := flush(false); ferr != nil {
+				return ferr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return flush(true)
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return err
+		}
+	}
+}
+
+var _ domain.StreamingExtractor = PlainTextExtractor{}
+
+// completeRunes returns the length of b without a trailing, incomplete UTF-8
+// sequence.
+func completeRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return len(b)
+			}
+			return i
+		}
+	}
+	return len(b)
+}
+
+func decodeText(data []byte) string {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	if !utf8.ValidString(text) {
+		text = strings.ToValidUTF8(text, "\ufffd")
+	}
+	return text
+}
+
+// isDOCX reports whether a zip archive contains a Word document body.
+func isDOCX(data []byte) bool {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return false
+	}
+	for _, f := range archive.File {
+		if f.Name == "word/document.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeMimeType(mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if idx := strings.Index(mimeType, ";"); idx >= 0 {
+		mimeType = strings.TrimSpace(mimeType[:idx])
+	}
+	return mimeType
+}
@@ -0,0 +1,154 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+func TestMarkdownExtractorStripsMarkupAndKeepsHeadings(t *testing.T) {
+	src := "---\ntitle: Notes\n---\n" +
+		"Overview\n========\n\n" +
+		"Some **bold** and _italic_ text with a [link](https://example.com) and `code`.\n\n" +
+		"## Setup ##\n\n" +
+		"* install the_tool\n1. run it\n\n" +
+		"```go\nfmt.Println(\"hi\")\n```\n\n" +
+		"| Key | Value |\n|-----|-------|\n| a | 1 |\n\n---\n> quoted ![diagram](d.png)\n"
+
+	pages, err := MarkdownExtractor{}.Extract(context.Background(), "text/markdown", []byte(src))
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	require.Equal(t, "# Overview\n\n"+
+		"Some bold and italic text with a link and code.\n\n"+
+		"## Setup\n\n"+
+		"- install the_tool\n1. run it\n\n"+
+		"```go\nfmt.Println(\"hi\")\n```\n\n"+
+		"Key | Value\na | 1\n\n"+
+		"quoted diagram", pages[0].Text)
+}
+
+func TestHTMLExtractorDropsNonContentAndKeepsHeadings(t *testing.T) {
+	src := `<!doctype html><html><head><title>Ignored</title><style>p{}</style></head>
+<body><h1>Release  notes</h1><script>alert(1)</script>
+<p>Version <b>2.0</b> ships&nbsp;today &amp; more.</p>
+<h2>Changes</h2><ul><li>Faster</li><li>Smaller</li></ul>
+<table><tr><th>Area</th><th>Status</th></tr><tr><td>API</td><td>done</td></tr></table>
+<pre>line one
+  line two</pre></body></html>`
+
+	pages, err := HTMLExtractor{}.Extract(context.Background(), "text/html", []byte(src))
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	require.Equal(t, "# Release notes\n"+
+		"Version 2.0 ships today & more.\n"+
+		"## Changes\n- Faster\n- Smaller\n"+
+		"Area | Status\nAPI | done\n"+
+		"```\nline one\n  line two\n```", pages[0].Text)
+}
+
+func TestDOCXExtractorKeepsHeadingsAndLists(t *testing.T) {
+	data := buildTestDOCX(t, `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Title"/></w:pPr><w:r><w:t>Handbook</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Heading2"/></w:pPr><w:r><w:t>Leave</w:t></w:r><w:r><w:t xml:space="preserve"> policy</w:t></w:r></w:p>
+<w:p><w:r><w:t>Staff get</w:t></w:r><w:r><w:tab/><w:t>20 days.</w:t></w:r></w:p>
+<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Apply early</w:t></w:r></w:p>
+<w:p><w:r><w:delText>removed</w:delText></w:r></w:p>
+</w:body></w:document>`)
+
+	pages, err := DOCXExtractor{}.Extract(context.Background(), MimeTypeDOCX, data)
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	require.Equal(t, "# Handbook\n## Leave policy\nStaff get\t20 days.\n- Apply early", pages[0].Text)
+}
+
+func TestRegistryDetectsDOCXFromZipContent(t *testing.T) {
+	data := buildTestDOCX(t, `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>Zipped</w:t></w:r></w:p></w:body></w:document>`)
+
+	pages, err := NewRegistry(0).Extract(context.Background(), "application/octet-stream", data)
+	require.NoError(t, err)
+	require.Equal(t, "Zipped", pages[0].Text)
+}
+
+func TestRegistryRejectsUnsupportedTypes(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	_, err := NewRegistry(0).Extract(context.Background(), "", png)
+	require.ErrorIs(t, err, domain.ErrUnsupportedFileType)
+	require.EqualError(t, err, "unsupported file type: image/png")
+}
+
+func buildTestDOCX(t *testing.T, documentXML string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`,
+		"word/document.xml":   documentXML,
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestPlainTextExtractorStreamsSectionsAtParagraphBreaks(t *testing.T) {
+	paragraph := strings.Repeat("héllo wörld ", 100) + "\n\n"
+	src := strings.Repeat(paragraph, 200)
+	// A single line longer than a section is split without breaking runes.
+	src += strings.Repeat("ü", 200<<10)
+
+	var sections []string
+	err := PlainTextExtractor{}.ExtractStream(context.Background(), "text/plain", strings.NewReader(src), func(page domain.ExtractedPage) error {
+		sections = append(sections, page.Text)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Greater(t, len(sections), 2)
+	for _, section := range sections[:len(sections)-1] {
+		require.True(t, utf8.ValidString(section))
+		require.LessOrEqual(t, len(section), plainTextMaxSectionBytes)
+	}
+	require.True(t, strings.HasSuffix(sections[0], "\n\n"), "sections end at a paragraph break")
+	require.Equal(t, src, strings.Join(sections, ""))
+}
+
+func TestRegistryExtractStreamSniffsType(t *testing.T) {
+	registry := NewRegistry(0)
+	var pages []domain.ExtractedPage
+	emit := func(page domain.ExtractedPage) error {
+		pages = append(pages, page)
+		return nil
+	}
+	require.NoError(t, registry.ExtractStream(context.Background(), "", strings.NewReader("\ufeffplain notes"), emit))
+	require.Equal(t, []domain.ExtractedPage{{Text: "plain notes"}}, pages)
+
+	err := registry.ExtractStream(context.Background(), "application/octet-stream", strings.NewReader("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), emit)
+	require.ErrorIs(t, err, domain.ErrUnsupportedFileType)
+}
+
+func TestRegistryExtractStreamLimitsBufferedFormats(t *testing.T) {
+	body := strings.Repeat("<w:p><w:r><w:t>clause</w:t></w:r></w:p>", 200)
+	docx := buildTestDOCX(t, `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`+body+`</w:body></w:document>`)
+	html := "<html><body>" + strings.Repeat("<p>clause</p>", 1000) + "</body></html>"
+	emit := func(domain.ExtractedPage) error { return nil }
+
+	limited := NewRegistry(int64(len(docx)) - 1)
+	err := limited.ExtractStream(context.Background(), MimeTypeDOCX, bytes.NewReader(docx), emit)
+	require.ErrorIs(t, err, domain.ErrFileTooLarge)
+	err = limited.ExtractStream(context.Background(), "text/html", strings.NewReader(html), emit)
+	require.ErrorIs(t, err, domain.ErrFileTooLarge)
+
+	// Plain text streams, so the limit does not apply to it.
+	require.NoError(t, limited.ExtractStream(context.Background(), "text/plain", strings.NewReader(html), emit))
+	require.NoError(t, NewRegistry(int64(len(docx))).ExtractStream(context.Background(), MimeTypeDOCX, bytes.NewReader(docx), emit))
+}
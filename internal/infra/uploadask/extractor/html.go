@@ -0,0 +1,229 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// HTMLExtractor converts saved web pages to text. Scripts, styles and other
+// non-content elements are dropped, block elements become line breaks, h1-h6
+// are rendered as Markdown-style headings and <pre> blocks as ``` fences.
+type HTMLExtractor struct{}
+
+// Extract returns the page body as a single unpaged unit of text.
+func (HTMLExtractor) Extract(_ context.Context, _ string, data []byte) ([]domain.ExtractedPage, error) {
+	tokenizer := html.NewTokenizer(bytes.NewReader(data))
+	w := &blockWriter{}
+	var (
+		skipDepth int
+		preDepth  int
+		rowCells  int
+	)
+	for {
+		tt := tokenizer.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			return []domain.ExtractedPage{{Text: w.String()}}, nil
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			text := string(tokenizer.Text())
+			if preDepth > 0 {
+				w.writePreformatted(text)
+			} else {
+				w.write(text)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			tag := atom.Lookup(name)
+			if skippedHTMLElements[tag] {
+				if tt == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			switch {
+			case headingLevel(tag) > 0:
+				w.breakLine()
+				w.write(strings.Repeat("#", headingLevel(tag)) + " ")
+			case tag == atom.Li:
+				w.breakLine()
+				w.write("- ")
+			case tag == atom.Pre:
+				w.breakLine()
+				if preDepth == 0 {
+					w.lines = append(w.lines, "```")
+				}
+				preDepth++
+			case tag == atom.Tr:
+				w.breakLine()
+				rowCells = 0
+			case tag == atom.Td || tag == atom.Th:
+				if rowCells > 0 {
+					w.write(" | ")
+				}
+				rowCells++
+			case tag == atom.Img:
+				if alt := htmlAttr(tokenizer, "alt"); alt != "" {
+					w.write(" " + alt + " ")
+				}
+			case blockHTMLElements[tag]:
+				w.breakLine()
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := atom.Lookup(name)
+			if skippedHTMLElements[tag] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			switch {
+			case tag == atom.Pre:
+				if preDepth > 0 {
+					preDepth--
+					w.breakLine()
+					if preDepth == 0 {
+						w.lines = append(w.lines, "```")
+					}
+				}
+			case headingLevel(tag) > 0 || tag == atom.Li || tag == atom.Tr || blockHTMLElements[tag]:
+				w.breakLine()
+			}
+		}
+	}
+}
+
+var _ domain.TextExtractor = HTMLExtractor{}
+
+var skippedHTMLElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Iframe: true, atom.Object: true,
+	atom.Canvas: true, atom.Select: true, atom.Button: true,
+}
+
+var blockHTMLElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Hr: true, atom.Section: true,
+	atom.Article: true, atom.Header: true, atom.Footer: true, atom.Main: true,
+	atom.Nav: true, atom.Aside: true, atom.Blockquote: true, atom.Ul: true,
+	atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Table: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Address: true, atom.Details: true,
+	atom.Summary: true, atom.Form: true, atom.Fieldset: true, atom.Caption: true,
+}
+
+func headingLevel(tag atom.Atom) int {
+	switch tag {
+	case atom.H1:
+		return 1
+	case atom.H2:
+		return 2
+	case atom.H3:
+		return 3
+	case atom.H4:
+		return 4
+	case atom.H5:
+		return 5
+	case atom.H6:
+		return 6
+	}
+	return 0
+}
+
+func htmlAttr(tokenizer *html.Tokenizer, name string) string {
+	for {
+		key, val, more := tokenizer.TagAttr()
+		if string(key) == name {
+			return strings.TrimSpace(string(val))
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// blockWriter collects text into lines, collapsing inline whitespace except
+// inside preformatted blocks.
+type blockWriter struct {
+	lines   []string
+	current strings.Builder
+	raw     bool
+}
+
+func (w *blockWriter) write(s string) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s != "" && w.current.Len() > 0 {
+			w.current.WriteByte(' ')
+		}
+		return
+	}
+	if w.current.Len() > 0 && startsWithSpace(s) {
+		w.current.WriteByte(' ')
+	}
+	w.current.WriteString(strings.Join(fields, " "))
+	if endsWithSpace(s) {
+		w.current.WriteByte(' ')
+	}
+}
+
+func (w *blockWriter) writePreformatted(s string) {
+	parts := strings.Split(s, "\n")
+	for i, part := range parts {
+		if i > 0 {
+			w.flush()
+		}
+		w.raw = true
+		w.current.WriteString(part)
+	}
+}
+
+func (w *blockWriter) breakLine() {
+	w.flush()
+}
+
+func (w *blockWriter) flush() {
+	line := w.current.String()
+	if !w.raw {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" || strings.Trim(line, "#- ") == "" {
+			line = ""
+		}
+	}
+	if line != "" || w.raw {
+		w.lines = append(w.lines, line)
+	}
+	w.current.Reset()
+	w.raw = false
+}
+
+func (w *blockWriter) String() string {
+	w.flush()
+	return joinBlocks(w.lines)
+}
+
+func startsWithSpace(s string) bool {
+	return s != "" && strings.ContainsRune(" \t\r\n\f", rune(s[0]))
+}
+
+func endsWithSpace(s string) bool {
+	return s != "" && strings.ContainsRune(" \t\r\n\f", rune(s[len(s)-1]))
+}
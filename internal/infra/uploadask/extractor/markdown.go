@@ -0,0 +1,164 @@
+package extractor
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// MarkdownExtractor strips Markdown syntax while keeping ATX-style headings
+// ("# Title"), list items and ``` code fences so chunks stay readable and the
+// structured chunker can still see the document outline.
+type MarkdownExtractor struct{}
+
+var (
+	mdATXHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdSetextH1     = regexp.MustCompile(`^ {0,3}=+\s*$`)
+	mdSetextH2     = regexp.MustCompile(`^ {0,3}-+\s*$`)
+	mdRule         = regexp.MustCompile(`^ {0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdFence        = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	mdBullet       = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	mdOrdered      = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	mdRefDef       = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s+\S+`)
+	mdTableDivider = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdImage        = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink         = regexp.MustCompile(`\[([^\]]+)\](\([^)]*\)|\[[^\]]*\])`)
+	mdAutolink     = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+	mdHTMLTag      = regexp.MustCompile(`</?[A-Za-z][^>]*>`)
+	mdInlineCode   = regexp.MustCompile("`+([^`]+)`+")
+	mdStrong       = regexp.MustCompile(`(\*\*|__)([^*_]+)(\*\*|__)`)
+	mdEmphasisStar = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	mdEmphasisBar  = regexp.MustCompile(`(^|[^\w])_([^_\s][^_]*)_([^\w]|$)`)
+	mdStrike       = regexp.MustCompile(`~~([^~]+)~~`)
+	mdEscape       = regexp.MustCompile("\\\\([\\\\`*_{}\\[\\]()#+\\-.!|>~])")
+)
+
+// Extract returns the document as a single unpaged unit of text.
+func (MarkdownExtractor) Extract(_ context.Context, _ string, data []byte) ([]domain.ExtractedPage, error) {
+	lines := strings.Split(strings.ReplaceAll(decodeText(data), "\r\n", "\n"), "\n")
+	lines = skipFrontMatter(lines)
+	out := make([]string, 0, len(lines))
+	var fence string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+				out = append(out, "```")
+				continue
+			}
+			out = append(out, line)
+			continue
+		}
+		if m := mdFence.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			out = append(out, "```"+strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "`~")))
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			out = append(out, "")
+			continue
+		}
+		if m := mdATXHeading.FindStringSubmatch(line); m != nil {
+			out = append(out, m[1]+" "+stripInlineMarkdown(m[2]))
+			continue
+		}
+		if i+1 < len(lines) && !mdBullet.MatchString(line) && !mdRule.MatchString(line) {
+			next := lines[i+1]
+			if mdSetextH1.MatchString(next) {
+				out = append(out, "# "+stripInlineMarkdown(trimmed))
+				i++
+				continue
+			}
+			if mdSetextH2.MatchString(next) && !mdTableDivider.MatchString(line) {
+				out = append(out, "## "+stripInlineMarkdown(trimmed))
+				i++
+				continue
+			}
+		}
+		if mdRule.MatchString(line) || mdRefDef.MatchString(line) {
+			continue
+		}
+		for strings.HasPrefix(trimmed, ">") {
+			trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+		}
+		if strings.Contains(trimmed, "|") {
+			if mdTableDivider.MatchString(trimmed) {
+				continue
+			}
+			trimmed = markdownTableRow(trimmed)
+		}
+		if m := mdBullet.FindStringSubmatch(trimmed); m != nil {
+			out = append(out, "- "+stripInlineMarkdown(m[2]))
+			continue
+		}
+		if m := mdOrdered.FindStringSubmatch(trimmed); m != nil {
+			out = append(out, m[2]+". "+stripInlineMarkdown(m[3]))
+			continue
+		}
+		out = append(out, stripInlineMarkdown(trimmed))
+	}
+	if fence != "" {
+		out = append(out, "```")
+	}
+	return []domain.ExtractedPage{{Text: joinBlocks(out)}}, nil
+}
+
+var _ domain.TextExtractor = MarkdownExtractor{}
+
+func skipFrontMatter(lines []string) []string {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines
+	}
+	for i := 1; i < len(lines); i++ {
+		if trimmed := strings.TrimSpace(lines[i]); trimmed == "---" || trimmed == "..." {
+			return lines[i+1:]
+		}
+	}
+	return lines
+}
+
+func markdownTableRow(line string) string {
+	line = strings.Trim(strings.TrimSpace(line), "|")
+	cells := strings.Split(line, "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return strings.Join(cells, " | ")
+}
+
+func stripInlineMarkdown(s string) string {
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1")
+	s = mdAutolink.ReplaceAllString(s, "$1")
+	s = mdHTMLTag.ReplaceAllString(s, "")
+	s = mdInlineCode.ReplaceAllString(s, "$1")
+	s = mdStrong.ReplaceAllString(s, "$2")
+	s = mdStrike.ReplaceAllString(s, "$1")
+	s = mdEmphasisStar.ReplaceAllString(s, "$1")
+	s = mdEmphasisBar.ReplaceAllString(s, "$1$2$3")
+	s = mdEscape.ReplaceAllString(s, "$1")
+	return strings.TrimSpace(s)
+}
+
+// joinBlocks joins extracted lines, collapsing runs of blank lines into one.
+func joinBlocks(lines []string) string {
+	out := make([]string, 0, len(lines))
+	blank := true
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if strings.TrimSpace(line) == "" {
+			if !blank {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		out = append(out, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,1086 @@
+package extractor
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// PDFExtractor pulls the text layer out of PDF files page by page. It handles
+// classic and compressed (object stream) files, FlateDecode content streams,
+// ToUnicode CMaps and form XObjects. Scanned PDFs without a text layer and
+// encrypted files are rejected rather than producing garbage.
+type PDFExtractor struct {
+	// MaxPages bounds the number of pages extracted; zero means no limit.
+	MaxPages int
+	// MaxStreamBytes bounds the decompressed size of a single stream and
+	// MaxInflatedBytes the total decompressed across the file, so a small
+	// file cannot inflate into gigabytes. Zero means no limit.
+	MaxStreamBytes   int64
+	MaxInflatedBytes int64
+}
+
+// NewPDFExtractor constructs a PDF extractor with defaults.
+func NewPDFExtractor() *PDFExtractor {
+	return &PDFExtractor{MaxPages: 2000, MaxStreamBytes: 32 << 20, MaxInflatedBytes: 256 << 20}
+}
+
+// maxPDFNesting bounds how deeply arrays and dictionaries may nest, keeping
+// the recursive parser well clear of the goroutine stack limit.
+const maxPDFNesting = 64
+
+var (
+	errPDFNesting      = errors.New("pdf objects are nested too deeply")
+	errPDFStreamTooBig = errors.New("pdf stream decompresses beyond the size limit")
+)
+
+// Extract returns one ExtractedPage per PDF page, numbered from 1.
+func (e *PDFExtractor) Extract(ctx context.Context, _ string, data []byte) ([]domain.ExtractedPage, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return nil, errors.New("file is not a valid PDF")
+	}
+	doc := parsePDF(data, e.MaxStreamBytes, e.MaxInflatedBytes)
+	if doc.err != nil {
+		return nil, doc.err
+	}
+	if doc.encrypted {
+		return nil, errors.New("encrypted PDFs are not supported")
+	}
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return nil, errors.New("pdf contains no pages")
+	}
+	if e.MaxPages > 0 && len(pages) > e.MaxPages {
+		pages = pages[:e.MaxPages]
+	}
+	out := make([]domain.ExtractedPage, 0, len(pages))
+	hasText := false
+	for i, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		text := doc.pageText(page)
+		if doc.err != nil {
+			return nil, doc.err
+		}
+		if strings.TrimSpace(text) != "" {
+			hasText = true
+		}
+		out = append(out, domain.ExtractedPage{Number: i + 1, Text: text})
+	}
+	if !hasText {
+		return nil, errors.New("pdf contains no extractable text (scanned documents are not supported)")
+	}
+	return out, nil
+}
+
+var _ domain.TextExtractor = (*PDFExtractor)(nil)
+
+// PDF object model. Dictionaries and arrays are resolved lazily through
+// pdfDocument.resolve so indirect references can point anywhere in the file.
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfString  []byte
+	pdfArray   []any
+	pdfDict    map[pdfName]any
+	pdfRef     struct{ num, gen int }
+	pdfDelim   string
+)
+
+type pdfObject struct {
+	value  any
+	stream []byte
+}
+
+type pdfDocument struct {
+	objects   map[int]pdfObject
+	trailers  []pdfDict
+	encrypted bool
+	cmaps     map[int]*pdfCMap
+	// maxStream and maxInflated limit decompression; err records the first
+	// limit hit, after which no more streams are decoded.
+	maxStream   int64
+	maxInflated int64
+	inflated    int64
+	err         error
+}
+
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+func parsePDF(data []byte, maxStream, maxInflated int64) *pdfDocument {
+	doc := &pdfDocument{
+		objects:     make(map[int]pdfObject),
+		cmaps:       make(map[int]*pdfCMap),
+		maxStream:   maxStream,
+		maxInflated: maxInflated,
+	}
+	pos := 0
+	for pos < len(data) {
+		loc := pdfObjectHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		lex := &pdfLexer{data: data, pos: pos + loc[1]}
+		value, err := lex.parseObject(0)
+		if err != nil {
+			pos += loc[1]
+			continue
+		}
+		obj := pdfObject{value: value}
+		if dict, ok := value.(pdfDict); ok {
+			if stream, end, ok := readStream(data, lex.pos, dict); ok {
+				obj.stream = stream
+				lex.pos = end
+			}
+		}
+		doc.objects[num] = obj
+		pos = lex.pos
+	}
+	doc.collectTrailers(data)
+	doc.expandObjectStreams()
+	return doc
+}
+
+// readStream locates the raw stream bytes following a stream dictionary.
+func readStream(data []byte, pos int, dict pdfDict) ([]byte, int, bool) {
+	lex := &pdfLexer{data: data, pos: pos}
+	lex.skipSpace()
+	if !bytes.HasPrefix(data[lex.pos:], []byte("stream")) {
+		return nil, pos, false
+	}
+	start := lex.pos + len("stream")
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if end <= len(data) && end >= start {
+			tail := bytes.TrimLeft(data[end:], "\r\n \t")
+			if bytes.HasPrefix(tail, []byte("endstream")) {
+				return data[start:end], len(data) - len(tail) + len("endstream"), true
+			}
+		}
+	}
+	idx := bytes.Index(data[start:], []byte("endstream"))
+	if idx < 0 {
+		return data[start:], len(data), true
+	}
+	end := start + idx
+	stream := bytes.TrimRight(data[start:end], "\r\n")
+	return stream, end + len("endstream"), true
+}
+
+func (d *pdfDocument) collectTrailers(data []byte) {
+	marker := []byte("trailer")
+	for pos := 0; ; {
+		idx := bytes.Index(data[pos:], marker)
+		if idx < 0 {
+			break
+		}
+		lex := &pdfLexer{data: data, pos: pos + idx + len(marker)}
+		if value, err := lex.parseObject(0); err == nil {
+			if dict, ok := value.(pdfDict); ok {
+				d.trailers = append(d.trailers, dict)
+			}
+		}
+		pos += idx + len(marker)
+	}
+	for _, obj := range d.objects {
+		if dict, ok := obj.value.(pdfDict); ok && dict["Type"] == pdfName("XRef") {
+			d.trailers = append(d.trailers, dict)
+		}
+	}
+	for _, trailer := range d.trailers {
+		if _, ok := trailer["Encrypt"]; ok {
+			d.encrypted = true
+		}
+	}
+}
+
+// expandObjectStreams registers objects packed into /Type /ObjStm streams.
+func (d *pdfDocument) expandObjectStreams() {
+	var streams []pdfObject
+	for _, obj := range d.objects {
+		if dict, ok := obj.value.(pdfDict); ok && dict["Type"] == pdfName("ObjStm") {
+			streams = append(streams, obj)
+		}
+	}
+	for _, obj := range streams {
+		dict := obj.value.(pdfDict)
+		decoded := d.decodeStream(obj)
+		if decoded == nil {
+			continue
+		}
+		n, _ := d.resolve(dict["N"]).(float64)
+		first, _ := d.resolve(dict["First"]).(float64)
+		header := &pdfLexer{data: decoded}
+		for i := 0; i < int(n); i++ {
+			numTok, err1 := header.next()
+			offTok, err2 := header.next()
+			if err1 != nil || err2 != nil {
+				break
+			}
+			num, ok1 := numTok.(float64)
+			off, ok2 := offTok.(float64)
+			if !ok1 || !ok2 {
+				break
+			}
+			if _, exists := d.objects[int(num)]; exists {
+				continue
+			}
+			start := int(first) + int(off)
+			if start < 0 || start >= len(decoded) {
+				continue
+			}
+			lex := &pdfLexer{data: decoded, pos: start}
+			value, err := lex.parseObject(0)
+			if err != nil {
+				continue
+			}
+			d.objects[int(num)] = pdfObject{value: value}
+		}
+	}
+}
+
+func (d *pdfDocument) resolve(v any) any {
+	for depth := 0; depth < 32; depth++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		obj, found := d.objects[ref.num]
+		if !found {
+			return nil
+		}
+		v = obj.value
+	}
+	return nil
+}
+
+func (d *pdfDocument) resolveDict(v any) pdfDict {
+	dict, _ := d.resolve(v).(pdfDict)
+	return dict
+}
+
+// streamFor returns the stream object referenced by v, if any.
+func (d *pdfDocument) streamFor(v any) (pdfObject, bool) {
+	ref, ok := v.(pdfRef)
+	if !ok {
+		return pdfObject{}, false
+	}
+	obj, found := d.objects[ref.num]
+	if !found || obj.stream == nil {
+		return pdfObject{}, false
+	}
+	return obj, true
+}
+
+// decodeStream applies the stream filters. Unsupported filters (image codecs,
+// LZW, etc.) yield nil so the caller can skip the stream. Exceeding a
+// decompression limit sets d.err and yields nil.
+func (d *pdfDocument) decodeStream(obj pdfObject) []byte {
+	if d.err != nil {
+		return nil
+	}
+	dict, _ := obj.value.(pdfDict)
+	var filters []pdfName
+	switch f := d.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		filters = append(filters, f)
+	case pdfArray:
+		for _, item := range f {
+			if name, ok := d.resolve(item).(pdfName); ok {
+				filters = append(filters, name)
+			}
+		}
+	}
+	data := obj.stream
+	for _, filter := range filters {
+		switch filter {
+		case "FlateDecode", "Fl":
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil
+			}
+			decoded, err := d.inflate(reader)
+			if d.err != nil || (err != nil && len(decoded) == 0) {
+				return nil
+			}
+			data = decoded
+		default:
+			return nil
+		}
+	}
+	return data
+}
+
+// inflate reads a decompressing reader within the per-stream limit and what
+// is left of the document budget.
+func (d *pdfDocument) inflate(reader io.Reader) ([]byte, error) {
+	if d.maxStream <= 0 && d.maxInflated <= 0 {
+		return io.ReadAll(reader)
+	}
+	limit := d.maxStream
+	if d.maxInflated > 0 {
+		if remaining := d.maxInflated - d.inflated; limit <= 0 || remaining < limit {
+			limit = remaining
+		}
+	}
+	decoded, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if int64(len(decoded)) > limit {
+		d.err = errPDFStreamTooBig
+		return nil, d.err
+	}
+	d.inflated += int64(len(decoded))
+	return decoded, err
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages walks the page tree from the catalog, inheriting resources from parents.
+func (d *pdfDocument) pages() []pdfPage {
+	var root pdfDict
+	for _, trailer := range d.trailers {
+		if dict := d.resolveDict(trailer["Root"]); dict != nil {
+			root = dict
+			break
+		}
+	}
+	if root == nil {
+		for _, obj := range d.objects {
+			if dict, ok := obj.value.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+				root = dict
+				break
+			}
+		}
+	}
+	var out []pdfPage
+	if root != nil {
+		visited := make(map[int]bool)
+		d.walkPages(root["Pages"], nil, visited, &out, 0)
+	}
+	if len(out) > 0 {
+		return out
+	}
+	// Broken page tree: fall back to every page object in file order.
+	nums := make([]int, 0)
+	for num, obj := range d.objects {
+		if dict, ok := obj.value.(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		dict := d.objects[num].value.(pdfDict)
+		out = append(out, pdfPage{dict: dict, resources: d.resolveDict(dict["Resources"])})
+	}
+	return out
+}
+
+func (d *pdfDocument) walkPages(node any, inherited pdfDict, visited map[int]bool, out *[]pdfPage, depth int) {
+	if depth > 64 {
+		return
+	}
+	if ref, ok := node.(pdfRef); ok {
+		if visited[ref.num] {
+			return
+		}
+		visited[ref.num] = true
+	}
+	dict := d.resolveDict(node)
+	if dict == nil {
+		return
+	}
+	resources := inherited
+	if own := d.resolveDict(dict["Resources"]); own != nil {
+		resources = own
+	}
+	kids, hasKids := d.resolve(dict["Kids"]).(pdfArray)
+	if !hasKids || dict["Type"] == pdfName("Page") {
+		*out = append(*out, pdfPage{dict: dict, resources: resources})
+		return
+	}
+	for _, kid := range kids {
+		d.walkPages(kid, resources, visited, out, depth+1)
+	}
+}
+
+func (d *pdfDocument) pageText(page pdfPage) string {
+	var content []byte
+	switch contents := page.dict["Contents"].(type) {
+	case pdfRef:
+		if obj, ok := d.streamFor(contents); ok {
+			content = d.decodeStream(obj)
+		} else if arr, ok := d.resolve(contents).(pdfArray); ok {
+			content = d.concatStreams(arr)
+		}
+	case pdfArray:
+		content = d.concatStreams(contents)
+	}
+	if len(content) == 0 {
+		return ""
+	}
+	w := &pdfTextWriter{}
+	d.runContent(content, page.resources, w, 0)
+	return w.String()
+}
+
+func (d *pdfDocument) concatStreams(refs pdfArray) []byte {
+	var buf bytes.Buffer
+	for _, ref := range refs {
+		if obj, ok := d.streamFor(ref); ok {
+			buf.Write(d.decodeStream(obj))
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+type pdfFont struct {
+	cmap      *pdfCMap
+	composite bool
+}
+
+func (d *pdfDocument) fontFor(resources pdfDict, name pdfName) pdfFont {
+	fonts := d.resolveDict(resources["Font"])
+	fontRef := fonts[name]
+	dict := d.resolveDict(fontRef)
+	if dict == nil {
+		return pdfFont{}
+	}
+	font := pdfFont{composite: dict["Subtype"] == pdfName("Type0")}
+	if ref, ok := dict["ToUnicode"].(pdfRef); ok {
+		if cached, ok := d.cmaps[ref.num]; ok {
+			font.cmap = cached
+		} else if obj, ok := d.streamFor(ref); ok {
+			font.cmap = parseCMap(d.decodeStream(obj))
+			d.cmaps[ref.num] = font.cmap
+		}
+	}
+	return font
+}
+
+// runContent interprets the text operators of a content stream.
+func (d *pdfDocument) runContent(content []byte, resources pdfDict, w *pdfTextWriter, depth int) {
+	if depth > 8 {
+		return
+	}
+	lex := &pdfLexer{data: content}
+	var (
+		operands []any
+		font     pdfFont
+		lastY    float64
+		haveY    bool
+	)
+	moveTo := func(y float64) {
+		if haveY && y != lastY {
+			w.newline()
+		} else {
+			w.space()
+		}
+		lastY, haveY = y, true
+	}
+	for {
+		tok, err := lex.parseObject(0)
+		if err != nil {
+			return
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp {
+			operands = append(operands, tok)
+			if len(operands) > 64 {
+				operands = operands[len(operands)-64:]
+			}
+			continue
+		}
+		switch op {
+		case "BT":
+			haveY = false
+		case "ET":
+			w.space()
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = d.fontFor(resources, name)
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				ty, _ := operands[len(operands)-1].(float64)
+				if ty != 0 {
+					w.newline()
+				} else {
+					w.space()
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				y, _ := operands[len(operands)-1].(float64)
+				moveTo(y)
+			}
+		case "T*":
+			w.newline()
+		case "Tj":
+			if len(operands) >= 1 {
+				w.write(decodePDFText(operands[len(operands)-1], font))
+			}
+		case "'", "\"":
+			w.newline()
+			if len(operands) >= 1 {
+				w.write(decodePDFText(operands[len(operands)-1], font))
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				if arr, ok := operands[len(operands)-1].(pdfArray); ok {
+					for _, item := range arr {
+						switch v := item.(type) {
+						case pdfString:
+							w.write(decodePDFText(v, font))
+						case float64:
+							if v < -200 {
+								w.space()
+							}
+						}
+					}
+				}
+			}
+		case "Do":
+			if len(operands) >= 1 {
+				if name, ok := operands[len(operands)-1].(pdfName); ok {
+					d.runXObject(resources, name, w, depth)
+				}
+			}
+		case "BI":
+			lex.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+func (d *pdfDocument) runXObject(resources pdfDict, name pdfName, w *pdfTextWriter, depth int) {
+	xobjects := d.resolveDict(resources["XObject"])
+	obj, ok := d.streamFor(xobjects[name])
+	if !ok {
+		return
+	}
+	dict, _ := obj.value.(pdfDict)
+	if dict["Subtype"] != pdfName("Form") {
+		return
+	}
+	formResources := resources
+	if own := d.resolveDict(dict["Resources"]); own != nil {
+		formResources = own
+	}
+	if content := d.decodeStream(obj); len(content) > 0 {
+		d.runContent(content, formResources, w, depth+1)
+	}
+}
+
+func decodePDFText(v any, font pdfFont) string {
+	raw, ok := v.(pdfString)
+	if !ok {
+		return ""
+	}
+	if font.cmap != nil {
+		return font.cmap.decode(raw)
+	}
+	if font.composite {
+		// Two-byte glyph ids without a ToUnicode map cannot be mapped to text.
+		return ""
+	}
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		return decodeUTF16BE(raw[2:])
+	}
+	runes := make([]rune, 0, len(raw))
+	for _, b := range raw {
+		runes = append(runes, rune(b))
+	}
+	return string(runes)
+}
+
+func decodeUTF16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// pdfCMap maps character codes to Unicode text as declared by a ToUnicode CMap.
+type pdfCMap struct {
+	codeLengths []int
+	mapping     map[int]map[uint32]string
+}
+
+func parseCMap(data []byte) *pdfCMap {
+	cm := &pdfCMap{mapping: make(map[int]map[uint32]string)}
+	lex := &pdfLexer{data: data}
+	var operands []any
+	lengths := make(map[int]bool)
+	for {
+		tok, err := lex.parseObject(0)
+		if err != nil {
+			break
+		}
+		kw, isKw := tok.(pdfKeyword)
+		if !isKw {
+			operands = append(operands, tok)
+			continue
+		}
+		switch kw {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if lo, ok := operands[i].(pdfString); ok && len(lo) > 0 {
+					lengths[len(lo)] = true
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 && len(src) > 0 {
+					cm.set(src, decodeUTF16BE(dst))
+					lengths[len(src)] = true
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 || len(lo) == 0 || len(lo) != len(hi) {
+					continue
+				}
+				lengths[len(lo)] = true
+				start, end := codeValue(lo), codeValue(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					base := []rune(decodeUTF16BE(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for code := start; code <= end; code++ {
+						out := append([]rune(nil), base...)
+						out[len(out)-1] += rune(code - start)
+						cm.setCode(len(lo), code, string(out))
+					}
+				case pdfArray:
+					for j, item := range dst {
+						code := start + uint32(j)
+						if code > end {
+							break
+						}
+						if s, ok := item.(pdfString); ok {
+							cm.setCode(len(lo), code, decodeUTF16BE(s))
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	for n := range lengths {
+		cm.codeLengths = append(cm.codeLengths, n)
+	}
+	sort.Ints(cm.codeLengths)
+	if len(cm.codeLengths) == 0 {
+		cm.codeLengths = []int{1}
+	}
+	return cm
+}
+
+func (cm *pdfCMap) set(code []byte, text string) {
+	cm.setCode(len(code), codeValue(code), text)
+}
+
+func (cm *pdfCMap) setCode(length int, code uint32, text string) {
+	byCode, ok := cm.mapping[length]
+	if !ok {
+		byCode = make(map[uint32]string)
+		cm.mapping[length] = byCode
+	}
+	byCode[code] = text
+}
+
+func (cm *pdfCMap) decode(raw []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(raw); {
+		matched := false
+		for _, n := range cm.codeLengths {
+			if i+n > len(raw) {
+				continue
+			}
+			if text, ok := cm.mapping[n][codeValue(raw[i:i+n])]; ok {
+				b.WriteString(text)
+				i += n
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			i += cm.codeLengths[0]
+		}
+	}
+	return b.String()
+}
+
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+// pdfTextWriter accumulates text while collapsing redundant whitespace.
+type pdfTextWriter struct {
+	buf     strings.Builder
+	pending byte
+}
+
+func (w *pdfTextWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	if w.pending != 0 && w.buf.Len() > 0 {
+		w.buf.WriteByte(w.pending)
+	}
+	w.pending = 0
+	w.buf.WriteString(s)
+}
+
+func (w *pdfTextWriter) space() {
+	if w.pending == 0 {
+		w.pending = ' '
+	}
+}
+
+func (w *pdfTextWriter) newline() {
+	w.pending = '\n'
+}
+
+func (w *pdfTextWriter) String() string {
+	lines := strings.Split(w.buf.String(), "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			out = append(out, line)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// pdfLexer tokenizes PDF object syntax and content streams.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+var errPDFEOF = errors.New("pdf: unexpected end of data")
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFSpace(c) {
+			l.pos++
+			continue
+		}
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// next returns the next primitive token.
+func (l *pdfLexer) next() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errPDFEOF
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		return l.readName(), nil
+	case c == '(':
+		l.pos++
+		return l.readLiteralString(), nil
+	case c == '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return pdfDelim("<<"), nil
+		}
+		l.pos++
+		return l.readHexString(), nil
+	case c == '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfDelim(">>"), nil
+		}
+		l.pos++
+		return l.next()
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		l.pos++
+		return pdfDelim(string(c)), nil
+	case c == ')':
+		l.pos++
+		return l.next()
+	}
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if f, err := strconv.ParseFloat(word, 64); err == nil && (word[0] == '-' || word[0] == '+' || word[0] == '.' || (word[0] >= '0' && word[0] <= '9')) {
+		return f, nil
+	}
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+// parseObject reads a complete object, assembling arrays, dictionaries and
+// indirect references ("12 0 R"). depth is how many arrays and dictionaries
+// enclose the object; beyond maxPDFNesting parsing fails.
+func (l *pdfLexer) parseObject(depth int) (any, error) {
+	tok, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case float64:
+		save := l.pos
+		if gen, err := l.next(); err == nil {
+			if g, ok := gen.(float64); ok {
+				if r, err := l.next(); err == nil && r == pdfKeyword("R") {
+					return pdfRef{num: int(v), gen: int(g)}, nil
+				}
+			}
+		}
+		l.pos = save
+		return v, nil
+	case pdfDelim:
+		switch v {
+		case "[":
+			return l.parseArray(depth + 1)
+		case "<<":
+			return l.parseDict(depth + 1)
+		}
+	}
+	return tok, nil
+}
+
+// parseArray reads array items up to the closing bracket.
+func (l *pdfLexer) parseArray(depth int) (any, error) {
+	if depth > maxPDFNesting {
+		return nil, errPDFNesting
+	}
+	arr := pdfArray{}
+	for {
+		l.skipSpace()
+		if l.pos < len(l.data) && l.data[l.pos] == ']' {
+			l.pos++
+			return arr, nil
+		}
+		item, err := l.parseObject(depth)
+		if err != nil {
+			return arr, err
+		}
+		arr = append(arr, item)
+	}
+}
+
+// parseDict reads key/value pairs up to the closing ">>".
+func (l *pdfLexer) parseDict(depth int) (any, error) {
+	if depth > maxPDFNesting {
+		return nil, errPDFNesting
+	}
+	dict := pdfDict{}
+	for {
+		key, err := l.parseObject(depth)
+		if err != nil {
+			return dict, err
+		}
+		if key == pdfDelim(">>") {
+			return dict, nil
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			continue
+		}
+		value, err := l.parseObject(depth)
+		if err != nil {
+			return dict, err
+		}
+		if value == pdfDelim(">>") {
+			return dict, nil
+		}
+		dict[name] = value
+	}
+}
+
+func (l *pdfLexer) readName() pdfName {
+	var b []byte
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFSpace(c) || isPDFDelimiter(c) {
+			break
+		}
+		if c == '#' && l.pos+2 < len(l.data) {
+			if v, err := strconv.ParseUint(string(l.data[l.pos+1:l.pos+3]), 16, 8); err == nil {
+				b = append(b, byte(v))
+				l.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		l.pos++
+	}
+	return pdfName(b)
+}
+
+func (l *pdfLexer) readLiteralString() pdfString {
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+			out = append(out, c)
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (l *pdfLexer) readHexString() pdfString {
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		c := l.data[l.pos]
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out
+}
+
+// skipInlineImage jumps past BI ... ID <binary> EI so image bytes are not
+// misread as operators.
+func (l *pdfLexer) skipInlineImage() {
+	idx := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if idx < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += idx + 2
+	for l.pos < len(l.data) {
+		idx := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if idx < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		at := l.pos + idx
+		before := at == 0 || isPDFSpace(l.data[at-1])
+		after := at+2 >= len(l.data) || isPDFSpace(l.data[at+2])
+		l.pos = at + 2
+		if before && after {
+			return
+		}
+	}
+}
+
+func (r pdfRef) String() string {
+	return fmt.Sprintf("%d %d R", r.num, r.gen)
+}
@@ -0,0 +1,123 @@
+package extractor
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPDFExtractorReturnsTextPerPage(t *testing.T) {
+	pdf := buildTestPDF(t, []string{
+		"BT /F1 12 Tf 72 720 Td (Quarterly report) Tj 0 -14 Td [(Revenue) -300 (grew)] TJ ET",
+		"BT /F2 12 Tf 72 720 Td <00010002> Tj ET",
+	})
+
+	pages, err := NewPDFExtractor().Extract(context.Background(), "application/pdf", pdf)
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+	require.Equal(t, 1, pages[0].Number)
+	require.Equal(t, "Quarterly report\nRevenue grew", pages[0].Text)
+	require.Equal(t, 2, pages[1].Number)
+	require.Equal(t, "Hi", pages[1].Text)
+}
+
+func TestPDFExtractorRejectsPDFWithoutText(t *testing.T) {
+	pdf := buildTestPDF(t, []string{"0 0 m 100 100 l S"})
+
+	_, err := NewPDFExtractor().Extract(context.Background(), "application/pdf", pdf)
+	require.ErrorContains(t, err, "no extractable text")
+}
+
+func TestRegistrySniffsPDFFromGenericMimeType(t *testing.T) {
+	pdf := buildTestPDF(t, []string{"BT /F1 12 Tf (Sniffed) Tj ET"})
+
+	pages, err := NewRegistry(0).Extract(context.Background(), "application/octet-stream", pdf)
+	require.NoError(t, err)
+	require.Len(t, pages, 1)
+	require.Equal(t, "Sniffed", pages[0].Text)
+}
+
+func TestPDFExtractorRejectsDeeplyNestedObjects(t *testing.T) {
+	nested := strings.Repeat("[", 100000) + strings.Repeat("]", 100000)
+	pdf := buildTestPDF(t, []string{"BT /F1 12 Tf (Nested) Tj ET " + nested})
+
+	lex := &pdfLexer{data: []byte(nested)}
+	_, err := lex.parseObject(0)
+	require.ErrorIs(t, err, errPDFNesting)
+	pages, err := NewPDFExtractor().Extract(context.Background(), "application/pdf", pdf)
+	require.NoError(t, err)
+	require.Equal(t, "Nested", pages[0].Text)
+}
+
+func TestPDFExtractorLimitsDecompressedSize(t *testing.T) {
+	pdf := buildTestPDF(t, []string{
+		"BT /F1 12 Tf (First) Tj ET " + strings.Repeat(" ", 4096),
+		"BT /F1 12 Tf (Second) Tj ET " + strings.Repeat(" ", 4096),
+	})
+
+	_, err := (&PDFExtractor{MaxStreamBytes: 1024}).Extract(context.Background(), "application/pdf", pdf)
+	require.ErrorIs(t, err, errPDFStreamTooBig)
+	_, err = (&PDFExtractor{MaxStreamBytes: 8192, MaxInflatedBytes: 6000}).Extract(context.Background(), "application/pdf", pdf)
+	require.ErrorIs(t, err, errPDFStreamTooBig)
+	pages, err := (&PDFExtractor{MaxStreamBytes: 8192, MaxInflatedBytes: 16384}).Extract(context.Background(), "application/pdf", pdf)
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+}
+
+// buildTestPDF writes a minimal PDF with one page per content stream. Page
+// content streams are Flate-compressed; /F2 is a Type0 font whose ToUnicode
+// map decodes 0x0001 -> "H" and 0x0002 -> "i".
+func buildTestPDF(t *testing.T, contents []string) []byte {
+	t.Helper()
+	var objects []string
+	add := func(body string) int {
+		objects = append(objects, body)
+		return len(objects)
+	}
+	stream := func(dict string, data []byte) string {
+		return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+	}
+
+	catalog := add("")
+	pagesObj := add("")
+	font1 := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	cmap := "/CIDInit /ProcSet findresource begin\n1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"2 beginbfchar <0001> <0048> <0002> <0069> endbfchar\nendcmap"
+	toUnicode := add(stream("", []byte(cmap)))
+	font2 := add(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /Custom /ToUnicode %d 0 R >>", toUnicode))
+
+	var kids []string
+	for _, content := range contents {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		_, err := zw.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		contentObj := add(stream("/Filter /FlateDecode", compressed.Bytes()))
+		page := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /Contents %d 0 R >>", pagesObj, contentObj))
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objects[catalog-1] = fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj)
+	objects[pagesObj-1] = fmt.Sprintf("<< /Type /Pages /Kids %v /Count %d /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> >>",
+		kids, len(kids), font1, font2)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, body := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, body)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, catalog, xref)
+	return buf.Bytes()
+}
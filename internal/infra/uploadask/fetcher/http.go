@@ -0,0 +1,205 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+const maxRedirects = 5
+
+// errPrivateAddress is returned when a URL resolves to a loopback, private,
+// link-local or other non-public address and private networks are not
+// allowed.
+var errPrivateAddress = errors.New("destination address is not allowed")
+
+// HTTPFetcher downloads documents over HTTP(S) with a total time limit and a
+// streaming size limit. By default it refuses to connect to private network
+// addresses so user-supplied URLs cannot reach internal services.
+type HTTPFetcher struct {
+	client *http.Client
+}
+
+// NewHTTPFetcher constructs a fetcher. allowPrivateNetworks disables the
+// private address guard and is intended for local development and tests.
+func NewHTTPFetcher(timeout time.Duration, allowPrivateNetworks bool) *HTTPFetcher {
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivateNetworks {
+		dialer.Control = rejectPrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &HTTPFetcher{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+					return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Fetch requests rawURL and returns its body for streaming. Reading the body
+// fails with domain.ErrFileTooLarge once more than maxBytes have been
+// received. A non-positive maxBytes disables the limit. The caller must close
+// the body.
+func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string, maxBytes int64) (domain.FetchedDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return domain.FetchedDocument{}, err
+	}
+	req.Header.Set("User-Agent", "ai-helloworld-uploadask/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/markdown,text/plain,application/pdf,*/*;q=0.8")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return domain.FetchedDocument{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return domain.FetchedDocument{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if maxBytes > 0 && resp.ContentLength > maxBytes {
+		resp.Body.Close()
+		return domain.FetchedDocument{}, domain.ErrFileTooLarge
+	}
+	mimeType := resp.Header.Get("Content-Type")
+	return domain.FetchedDocument{
+		URL:      resp.Request.URL.String(),
+		Filename: filenameFor(resp.Request.URL, mimeType),
+		MimeType: mimeType,
+		Body:     &limitedBody{ReadCloser: resp.Body, remaining: maxBytes, limited: maxBytes > 0},
+	}, nil
+}
+
+// limitedBody fails with domain.ErrFileTooLarge once the response yields more
+// than the allowed number of bytes.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limited   bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if !b.limited {
+		return b.ReadCloser.Read(p)
+	}
+	if b.remaining < 0 {
+		return 0, domain.ErrFileTooLarge
+	}
+	// Read one byte past the limit so an exact fit still ends cleanly.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), domain.ErrFileTooLarge
+	}
+	return n, err
+}
+
+var _ domain.URLFetcher = (*HTTPFetcher)(nil)
+
+var extensionsByMimeType = map[string]string{
+	"text/html":             ".html",
+	"application/xhtml+xml": ".html",
+	"text/markdown":         ".md",
+	"text/plain":            ".txt",
+	"application/pdf":       ".pdf",
+}
+
+// filenameFor derives a storage filename from the final URL path, adding an
+// extension from the response type when the path has none.
+func filenameFor(u *url.URL, mimeType string) string {
+	name := path.Base(u.Path)
+	if name == "." || name == "/" || name == "" {
+		name = "index"
+	}
+	if path.Ext(name) == "" {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+		name += extensionsByMimeType[mediaType]
+	}
+	return name
+}
+
+func rejectPrivateAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errPrivateAddress, host)
+	}
+	if isDeniedAddress(addr) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, addr)
+	}
+	return nil
+}
+
+// deniedPrefixes are special-purpose ranges the net/netip predicates miss
+// that often still route to internal services.
+var deniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+}
+
+var (
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFour   = netip.MustParsePrefix("2002::/16")
+)
+
+// isDeniedAddress reports whether addr, or the IPv4 address it maps or
+// translates to, is not a public unicast destination.
+func isDeniedAddress(addr netip.Addr) bool {
+	addr = embeddedIPv4(addr.WithZone("").Unmap())
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsUnspecified() || addr.IsMulticast() || addr.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, prefix := range deniedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddedIPv4 returns the IPv4 address a NAT64 or 6to4 address reaches, and
+// addr itself otherwise.
+func embeddedIPv4(addr netip.Addr) netip.Addr {
+	raw := addr.As16()
+	switch {
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte(raw[12:16]))
+	case sixToFour.Contains(addr):
+		return netip.AddrFrom4([4]byte(raw[2:6]))
+	}
+	return addr
+}
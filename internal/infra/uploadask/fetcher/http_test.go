@@ -0,0 +1,125 @@
+package fetcher
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+func TestHTTPFetcherFollowsRedirectsAndNamesFile(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/docs/guide", http.StatusFound)
+	})
+	mux.HandleFunc("/docs/guide", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte("<h1>Guide</h1>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	doc, err := NewHTTPFetcher(time.Second, true).Fetch(context.Background(), server.URL+"/old", 1024)
+	require.NoError(t, err)
+	require.Equal(t, server.URL+"/docs/guide", doc.URL)
+	require.Equal(t, "guide.html", doc.Filename)
+	require.Equal(t, "text/html; charset=utf-8", doc.MimeType)
+	defer doc.Body.Close()
+	body, err := io.ReadAll(doc.Body)
+	require.NoError(t, err)
+	require.Equal(t, "<h1>Guide</h1>", string(body))
+}
+
+func TestHTTPFetcherEnforcesSizeWhileStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		// Flushing forces chunked encoding so the limit cannot rely on Content-Length.
+		for i := 0; i < 4; i++ {
+			_, _ = w.Write([]byte(strings.Repeat("x", 64)))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	doc, err := NewHTTPFetcher(time.Second, true).Fetch(context.Background(), server.URL, 100)
+	require.NoError(t, err)
+	defer doc.Body.Close()
+	body, err := io.ReadAll(doc.Body)
+	require.ErrorIs(t, err, domain.ErrFileTooLarge)
+	require.Len(t, body, 100)
+}
+
+func TestHTTPFetcherTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	_, err := NewHTTPFetcher(50*time.Millisecond, true).Fetch(context.Background(), server.URL, 1024)
+	require.Error(t, err)
+}
+
+func TestHTTPFetcherRejectsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := NewHTTPFetcher(time.Second, true).Fetch(context.Background(), server.URL, 1024)
+	require.EqualError(t, err, "unexpected status 404")
+}
+
+func TestHTTPFetcherBlocksPrivateAddressesByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	_, err := NewHTTPFetcher(time.Second, false).Fetch(context.Background(), server.URL, 1024)
+	require.ErrorIs(t, err, errPrivateAddress)
+}
+
+func TestRejectPrivateAddressDeniesNonPublicRanges(t *testing.T) {
+	cases := map[string]bool{
+		"127.0.0.1:80":               false,
+		"10.1.2.3:80":                false,
+		"169.254.169.254:80":         false,
+		"0.1.2.3:80":                 false,
+		"100.64.0.1:80":              false,
+		"100.127.255.254:80":         false,
+		"198.18.0.1:80":              false,
+		"198.19.255.255:80":          false,
+		"255.255.255.255:80":         false,
+		"[::1]:80":                   false,
+		"[fd00::1]:80":               false,
+		"[fe80::1%eth0]:80":          false,
+		"[::ffff:127.0.0.1]:80":      false,
+		"[::ffff:100.64.0.1]:80":     false,
+		"[64:ff9b::a9fe:a9fe]:80":    false,
+		"[64:ff9b:1::1]:80":          false,
+		"[2002:0a00:0001::1]:80":     false,
+		"93.184.216.34:443":          true,
+		"100.128.0.1:443":            true,
+		"198.20.0.1:443":             true,
+		"[::ffff:93.184.216.34]:443": true,
+		"[64:ff9b::5db8:d822]:443":   true,
+		"[2606:2800:220:1::248]:443": true,
+	}
+	for address, allowed := range cases {
+		err := rejectPrivateAddress("tcp", address, nil)
+		if allowed {
+			require.NoError(t, err, address)
+		} else {
+			require.ErrorIs(t, err, errPrivateAddress, address)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"io"
 	"strings"
 
 	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
@@ -22,6 +24,46 @@ func NewChatGPTLLM(client *chatgpt.Client, model string, temperature float32) *C
 
 // Chat sends a chat completion request.
 func (l *ChatGPTLLM) Chat(ctx context.Context, messages []domain.LLMMessage) (string, error) {
+	resp, err := l.client.CreateChatCompletion(ctx, l.request(messages))
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", nil
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// ChatStream sends a streaming chat completion request and forwards content
+// deltas until the stream ends.
+func (l *ChatGPTLLM) ChatStream(ctx context.Context, messages []domain.LLMMessage) (<-chan domain.LLMStreamChunk, error) {
+	stream, err := l.client.CreateChatCompletionStream(ctx, l.request(messages))
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan domain.LLMStreamChunk)
+	go func() {
+		defer close(out)
+		defer stream.Close()
+		for {
+			chunk, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					out <- domain.LLMStreamChunk{Err: err}
+				}
+				return
+			}
+			for _, choice := range chunk.Choices {
+				if choice.Delta.Content != "" {
+					out <- domain.LLMStreamChunk{Delta: choice.Delta.Content}
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (l *ChatGPTLLM) request(messages []domain.LLMMessage) chatgpt.ChatCompletionRequest {
 	req := chatgpt.ChatCompletionRequest{
 		Model:       l.model,
 		Temperature: l.temperature,
@@ -33,14 +75,7 @@ func (l *ChatGPTLLM) Chat(ctx context.Context, messages []domain.LLMMessage) (st
 			Content: msg.Content,
 		})
 	}
-	resp, err := l.client.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return "", err
-	}
-	if len(resp.Choices) == 0 {
-		return "", nil
-	}
-	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+	return req
 }
 
 var _ domain.LLM = (*ChatGPTLLM)(nil)
@@ -56,4 +91,18 @@ func (EchoLLM) Chat(_ context.Context, messages []domain.LLMMessage) (string, er
 	return "Answer: " + messages[len(messages)-1].Content, nil
 }
 
+// ChatStream streams the echoed answer word by word.
+func (e EchoLLM) ChatStream(ctx context.Context, messages []domain.LLMMessage) (<-chan domain.LLMStreamChunk, error) {
+	answer, _ := e.Chat(ctx, messages)
+	words := strings.SplitAfter(answer, " ")
+	out := make(chan domain.LLMStreamChunk, len(words))
+	for _, word := range words {
+		if word != "" {
+			out <- domain.LLMStreamChunk{Delta: word}
+		}
+	}
+	close(out)
+	return out, nil
+}
+
 var _ domain.LLM = (*EchoLLM)(nil)
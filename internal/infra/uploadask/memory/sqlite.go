@@ -3,7 +3,6 @@ package memory
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
@@ -13,6 +12,7 @@ import (
 	"github.com/google/uuid"
 
 	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
 )
 
 // SQLiteMessageLog persists Upload & Ask conversation turns in SQLite.
@@ -100,11 +100,7 @@ func (s *SQLiteMemoryStore) Upsert(ctx context.Context, mem domain.MemoryRecord)
 	}
 	var embedding any
 	if len(mem.Embedding) > 0 {
-		payload, err := json.Marshal(mem.Embedding)
-		if err != nil {
-			return err
-		}
-		embedding = string(payload)
+		embedding = sqliteinfra.EncodeVector(mem.Embedding)
 	}
 	_, err := s.db.ExecContext(ctx, `
 		INSERT INTO upload_qa_memories (session_id, user_id, source, content, embedding, importance, created_at)
@@ -221,7 +217,7 @@ func scanSQLiteMemory(row sqliteScanner) (domain.MemoryRecord, error) {
 		mem       domain.MemoryRecord
 		sessionID string
 		source    string
-		embedding sql.NullString
+		embedding []byte
 		created   string
 	)
 	if err := row.Scan(&mem.ID, &sessionID, &mem.UserID, &source, &mem.Content, &embedding, &mem.Importance, &created); err != nil {
@@ -238,12 +234,9 @@ func scanSQLiteMemory(row sqliteScanner) (domain.MemoryRecord, error) {
 	if err != nil {
 		return domain.MemoryRecord{}, err
 	}
-	if embedding.Valid && embedding.String != "" {
-		var parsed []float32
-		if err := json.Unmarshal([]byte(embedding.String), &parsed); err != nil {
-			return domain.MemoryRecord{}, err
-		}
-		mem.Embedding = parsed
+	mem.Embedding, err = sqliteinfra.DecodeVector(embedding)
+	if err != nil {
+		return domain.MemoryRecord{}, err
 	}
 	mem.SessionID = parsedSessionID
 	mem.Source = domain.MemorySource(source)
@@ -2,6 +2,8 @@ package queue
 
 import (
 	"context"
+	"errors"
+	"sync"
 
 	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
 )
@@ -10,14 +12,47 @@ import (
 type HandlerQueue interface {
 	domain.JobQueue
 	SetHandler(handler Handler)
+	// Close stops delivering jobs and waits for running ones to finish.
+	Close()
 }
 
-// Handler executes jobs synchronously or in the background.
-type Handler func(ctx context.Context, name string, payload map[string]any)
+// Aborter is implemented by queues that can cancel running jobs when a
+// graceful Close runs out of time.
+type Aborter interface {
+	Abort()
+}
+
+// Handler executes jobs synchronously or in the background. Queues that retry
+// run the job again when it returns an error, unless the error is Permanent.
+type Handler func(ctx context.Context, name string, payload map[string]any) error
+
+// Permanent marks a job failure that retrying cannot fix.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var target permanentError
+	return errors.As(err, &target)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
 
 // ImmediateQueue calls the handler immediately on enqueue.
 type ImmediateQueue struct {
+	mu      sync.Mutex
 	handler Handler
+	closed  bool
+	running sync.WaitGroup
 }
 
 // NewImmediateQueue constructs the queue.
@@ -27,21 +62,39 @@ func NewImmediateQueue(handler Handler) *ImmediateQueue {
 
 // SetHandler replaces the handler used for queued jobs.
 func (q *ImmediateQueue) SetHandler(handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 	q.handler = handler
 }
 
+// Close drops jobs enqueued from now on and waits for running ones.
+func (q *ImmediateQueue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.running.Wait()
+}
+
 // Enqueue invokes the handler asynchronously with a job context detached from
 // request cancellation. Enqueue itself still honors the caller before this point.
+// Jobs run once; failures are left to the handler to report.
 func (q *ImmediateQueue) Enqueue(ctx context.Context, name string, payload any) error {
 	typed, ok := payload.(map[string]any)
 	if !ok {
 		typed = map[string]any{}
 	}
-	if q.handler == nil {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	handler := q.handler
+	if handler == nil || q.closed {
 		return nil
 	}
 	jobCtx := context.WithoutCancel(ctx)
-	go q.handler(jobCtx, name, typed)
+	q.running.Add(1)
+	go func() {
+		defer q.running.Done()
+		_ = handler(jobCtx, name, typed)
+	}()
 	return nil
 }
 
@@ -12,8 +12,9 @@ type contextKey string
 
 func TestImmediateQueueHandlerContextSurvivesEnqueueContextCancellation(t *testing.T) {
 	handlerCtx := make(chan context.Context, 1)
-	q := NewImmediateQueue(func(ctx context.Context, name string, payload map[string]any) {
+	q := NewImmediateQueue(func(ctx context.Context, name string, payload map[string]any) error {
 		handlerCtx <- ctx
+		return nil
 	})
 
 	parent := context.WithValue(context.Background(), contextKey("trace"), "upload-request")
@@ -36,3 +37,34 @@ func TestImmediateQueueHandlerContextSurvivesEnqueueContextCancellation(t *testi
 	default:
 	}
 }
+
+func TestImmediateQueueCloseWaitsForRunningJobs(t *testing.T) {
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	q := NewImmediateQueue(func(ctx context.Context, name string, payload map[string]any) error {
+		<-release
+		close(finished)
+		return nil
+	})
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", nil))
+
+	closed := make(chan struct{})
+	go func() {
+		q.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("close returned while a job was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close did not return after the job finished")
+	}
+	<-finished
+
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", nil))
+}
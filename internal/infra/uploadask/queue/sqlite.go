@@ -0,0 +1,534 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// SQLiteQueueConfig tunes SQLiteQueue. Zero values fall back to defaults.
+type SQLiteQueueConfig struct {
+	// Workers is how many jobs run at once.
+	Workers int
+	// MaxAttempts is how many times a job runs before it is dead-lettered.
+	MaxAttempts int
+	// BaseBackoff is the delay before the first retry; it doubles with every
+	// further attempt.
+	BaseBackoff time.Duration
+	// LeaseDuration is how long a worker owns a job without renewing the
+	// lease. Jobs of a crashed worker become runnable once it expires.
+	LeaseDuration time.Duration
+	// PollInterval is how often idle workers look for due jobs.
+	PollInterval time.Duration
+}
+
+const (
+	defaultSQLiteQueueWorkers       = 1
+	defaultSQLiteQueueMaxAttempts   = 5
+	defaultSQLiteQueueBaseBackoff   = 5 * time.Second
+	defaultSQLiteQueueLeaseDuration = time.Minute
+	defaultSQLiteQueuePollInterval  = time.Second
+)
+
+// sqliteQueueTimeLayout is fixed width so stored timestamps compare correctly
+// as text.
+const sqliteQueueTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// SQLiteQueue persists jobs in SQLite and runs them on a pool of workers. A
+// worker leases a job before running it and renews the lease while it runs.
+// Failed jobs are retried with exponential backoff and moved to the
+// dead-letter table once they run out of attempts or fail permanently.
+type SQLiteQueue struct {
+	db      *sql.DB
+	cfg     SQLiteQueueConfig
+	owner   string
+	logger  *slog.Logger
+	handler Handler
+	// ctx is the parent of every job's context; Abort cancels it.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	started bool
+	stop    chan struct{}
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewSQLiteQueue constructs a queue over the upload_jobs and upload_dead_jobs
+// tables created by the sqlite package migrations.
+func NewSQLiteQueue(db *sql.DB, cfg SQLiteQueueConfig, logger *slog.Logger) *SQLiteQueue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultSQLiteQueueWorkers
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultSQLiteQueueMaxAttempts
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = defaultSQLiteQueueBaseBackoff
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = defaultSQLiteQueueLeaseDuration
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultSQLiteQueuePollInterval
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SQLiteQueue{
+		db:     db,
+		cfg:    cfg,
+		owner:  uuid.NewString(),
+		logger: logger.With("component", "uploadask.queue.sqlite"),
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan struct{}),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// SetHandler sets the job handler and starts the workers on first use.
+func (q *SQLiteQueue) SetHandler(handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handler = handler
+	if handler == nil || q.started {
+		return
+	}
+	q.started = true
+	q.logger.Info("sqlite queue workers starting", "workers", q.cfg.Workers)
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+// Close stops claiming jobs and waits for running ones to finish. Jobs still
+// queued stay in the table for the next process.
+func (q *SQLiteQueue) Close() {
+	q.mu.Lock()
+	select {
+	case <-q.stop:
+	default:
+		close(q.stop)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// Abort cancels the context of running jobs, for when Close takes too long.
+// Cancelled jobs are put back without using up an attempt.
+func (q *SQLiteQueue) Abort() {
+	q.cancel()
+}
+
+// Enqueue stores the job so it survives restarts and wakes an idle worker.
+func (q *SQLiteQueue) Enqueue(ctx context.Context, name string, payload any) error {
+	typed, ok := payload.(map[string]any)
+	if !ok {
+		typed = map[string]any{}
+	}
+	encoded, err := json.Marshal(typed)
+	if err != nil {
+		return err
+	}
+	now := formatQueueTime(time.Now())
+	if _, err := q.db.ExecContext(ctx, `
+		INSERT INTO upload_jobs (id, name, payload, status, attempts, max_attempts, run_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 0, ?, ?, ?, ?)
+	`, uuid.NewString(), name, string(encoded), string(domain.JobStateQueued), q.cfg.MaxAttempts, now, now, now); err != nil {
+		return err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+type leasedJob struct {
+	id          string
+	name        string
+	payload     map[string]any
+	attempts    int
+	maxAttempts int
+}
+
+func (q *SQLiteQueue) work() {
+	defer q.wg.Done()
+	ctx := q.ctx
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+		job, found, err := q.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			q.logger.Warn("sqlite queue claim failed", "error", err)
+		}
+		if found {
+			q.run(ctx, job)
+			continue
+		}
+		timer := time.NewTimer(q.cfg.PollInterval)
+		select {
+		case <-q.stop:
+			timer.Stop()
+			return
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// sqliteDueJob matches jobs a worker may lease: queued jobs whose run_at has
+// passed and running jobs whose lease expired because their worker died. It
+// takes the queued state, now, the running state and now again.
+const sqliteDueJob = `((status = ? AND run_at <= ?) OR (status = ? AND lease_expires_at <= ?))`
+
+// claim leases the next due job. Due jobs that already used every attempt,
+// because their worker crashed or hung past the lease, are dead-lettered
+// instead of being run again.
+func (q *SQLiteQueue) claim(ctx context.Context) (leasedJob, bool, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return leasedJob{}, false, err
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	due := []any{string(domain.JobStateQueued), formatQueueTime(now), string(domain.JobStateRunning), formatQueueTime(now)}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO upload_dead_jobs (id, name, payload, attempts, max_attempts, last_error, created_at, failed_at)
+		SELECT id, name, payload, attempts, max_attempts, COALESCE(last_error, 'lease expired'), created_at, ?
+		FROM upload_jobs WHERE `+sqliteDueJob+` AND attempts >= max_attempts
+	`, append([]any{formatQueueTime(now)}, due...)...); err != nil {
+		return leasedJob{}, false, err
+	}
+	exhausted, err := tx.ExecContext(ctx, `DELETE FROM upload_jobs WHERE `+sqliteDueJob+` AND attempts >= max_attempts`, due...)
+	if err != nil {
+		return leasedJob{}, false, err
+	}
+	if n, err := exhausted.RowsAffected(); err == nil && n > 0 {
+		q.logger.Warn("sqlite queue dead-lettered jobs whose lease expired on their last attempt", "jobs", n)
+	}
+	var (
+		job     leasedJob
+		payload string
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, name, payload, attempts, max_attempts
+		FROM upload_jobs
+		WHERE `+sqliteDueJob+`
+		ORDER BY run_at, created_at
+		LIMIT 1
+	`, due...).Scan(&job.id, &job.name, &payload, &job.attempts, &job.maxAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return leasedJob{}, false, tx.Commit()
+	}
+	if err != nil {
+		return leasedJob{}, false, err
+	}
+	if err := json.Unmarshal([]byte(payload), &job.payload); err != nil {
+		return leasedJob{}, false, fmt.Errorf("decode job %s payload: %w", job.id, err)
+	}
+	job.attempts++
+	leased, err := tx.ExecContext(ctx, `
+		UPDATE upload_jobs
+		SET status = ?, attempts = ?, lease_owner = ?, lease_expires_at = ?, updated_at = ?
+		WHERE id = ? AND `+sqliteDueJob,
+		append([]any{string(domain.JobStateRunning), job.attempts, q.owner, formatQueueTime(now.Add(q.cfg.LeaseDuration)), formatQueueTime(now), job.id}, due...)...)
+	if err != nil {
+		return leasedJob{}, false, err
+	}
+	if n, err := leased.RowsAffected(); err != nil || n == 0 {
+		// Another worker leased the job first.
+		return leasedJob{}, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return leasedJob{}, false, err
+	}
+	return job, true, nil
+}
+
+// run executes the job under ctx. Acknowledging, rescheduling and releasing
+// the job still happen after ctx is cancelled.
+func (q *SQLiteQueue) run(ctx context.Context, job leasedJob) {
+	q.mu.Lock()
+	handler := q.handler
+	q.mu.Unlock()
+
+	jobCtx := ctx
+	ctx = context.WithoutCancel(ctx)
+	done := make(chan struct{})
+	go q.renewLease(ctx, job.id, done)
+	q.logger.Info("sqlite queue job started", "job_id", job.id, "name", job.name, "attempt", job.attempts)
+	err := runHandler(jobCtx, handler, job)
+	close(done)
+
+	if err == nil {
+		if _, err := q.db.ExecContext(ctx, `DELETE FROM upload_jobs WHERE id = ? AND lease_owner = ?`, job.id, q.owner); err != nil {
+			q.logger.Warn("sqlite queue ack failed", "job_id", job.id, "error", err)
+		}
+		return
+	}
+	if jobCtx.Err() != nil {
+		q.logger.Info("sqlite queue job cancelled, releasing", "job_id", job.id, "name", job.name)
+		if _, err := q.db.ExecContext(ctx, `
+			UPDATE upload_jobs
+			SET status = ?, attempts = attempts - 1, run_at = ?, lease_owner = NULL, lease_expires_at = NULL, updated_at = ?
+			WHERE id = ? AND lease_owner = ?
+		`, string(domain.JobStateQueued), formatQueueTime(time.Now()), formatQueueTime(time.Now()), job.id, q.owner); err != nil {
+			q.logger.Error("sqlite queue release failed", "job_id", job.id, "error", err)
+		}
+		return
+	}
+	if IsPermanent(err) || job.attempts >= job.maxAttempts {
+		q.logger.Warn("sqlite queue job dead-lettered", "job_id", job.id, "name", job.name, "attempts", job.attempts, "error", err)
+		if err := q.deadLetter(ctx, job.id, err.Error()); err != nil {
+			q.logger.Error("sqlite queue dead-letter failed", "job_id", job.id, "error", err)
+		}
+		return
+	}
+	delay := q.cfg.BaseBackoff * time.Duration(1<<(job.attempts-1))
+	q.logger.Warn("sqlite queue job failed, retrying", "job_id", job.id, "name", job.name, "attempt", job.attempts, "delay", delay, "error", err)
+	now := time.Now()
+	if _, err := q.db.ExecContext(ctx, `
+		UPDATE upload_jobs
+		SET status = ?, run_at = ?, lease_owner = NULL, lease_expires_at = NULL, last_error = ?, updated_at = ?
+		WHERE id = ? AND lease_owner = ?
+	`, string(domain.JobStateQueued), formatQueueTime(now.Add(delay)), err.Error(), formatQueueTime(now), job.id, q.owner); err != nil {
+		q.logger.Error("sqlite queue reschedule failed", "job_id", job.id, "error", err)
+	}
+}
+
+// runHandler turns a handler panic into a job failure.
+func runHandler(ctx context.Context, handler Handler, job leasedJob) (err error) {
+	if handler == nil {
+		return errors.New("no job handler configured")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job.name, job.payload)
+}
+
+// renewLease extends the job's lease until done is closed.
+func (q *SQLiteQueue) renewLease(ctx context.Context, id string, done <-chan struct{}) {
+	ticker := time.NewTicker(q.cfg.LeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		if _, err := q.db.ExecContext(ctx, `
+			UPDATE upload_jobs SET lease_expires_at = ?, updated_at = ?
+			WHERE id = ? AND lease_owner = ?
+		`, formatQueueTime(now.Add(q.cfg.LeaseDuration)), formatQueueTime(now), id, q.owner); err != nil {
+			q.logger.Warn("sqlite queue lease renewal failed", "job_id", id, "error", err)
+		}
+	}
+}
+
+func (q *SQLiteQueue) deadLetter(ctx context.Context, id, lastError string) error {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO upload_dead_jobs (id, name, payload, attempts, max_attempts, last_error, created_at, failed_at)
+		SELECT id, name, payload, attempts, max_attempts, ?, created_at, ?
+		FROM upload_jobs WHERE id = ? AND lease_owner = ?
+	`, lastError, formatQueueTime(time.Now()), id, q.owner); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM upload_jobs WHERE id = ? AND lease_owner = ?`, id, q.owner); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListJobs returns up to limit jobs in state, oldest first. An empty state
+// lists live and dead jobs together.
+func (q *SQLiteQueue) ListJobs(ctx context.Context, state domain.JobState, limit int) ([]domain.Job, error) {
+	var jobs []domain.Job
+	if state != domain.JobStateDead {
+		query := `
+			SELECT id, name, payload, status, attempts, max_attempts, last_error, run_at, lease_expires_at, created_at, updated_at
+			FROM upload_jobs`
+		args := []any{}
+		if state != "" {
+			query += ` WHERE status = ?`
+			args = append(args, string(state))
+		}
+		query += ` ORDER BY created_at LIMIT ?`
+		args = append(args, limit)
+		live, err := q.queryJobs(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, live...)
+	}
+	if state == "" || state == domain.JobStateDead {
+		dead, err := q.queryJobs(ctx, `
+			SELECT id, name, payload, 'dead', attempts, max_attempts, last_error, failed_at, NULL, created_at, failed_at
+			FROM upload_dead_jobs
+			ORDER BY created_at
+			LIMIT ?
+		`, limit)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, dead...)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// LiveJobs returns every queued or running job named name, oldest first.
+func (q *SQLiteQueue) LiveJobs(ctx context.Context, name string) ([]domain.Job, error) {
+	return q.queryJobs(ctx, `
+		SELECT id, name, payload, status, attempts, max_attempts, last_error, run_at, lease_expires_at, created_at, updated_at
+		FROM upload_jobs
+		WHERE name = ?
+		ORDER BY created_at
+	`, name)
+}
+
+func (q *SQLiteQueue) queryJobs(ctx context.Context, query string, args ...any) ([]domain.Job, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]domain.Job, 0)
+	for rows.Next() {
+		var (
+			job                         domain.Job
+			id, payload, state          string
+			lastError, leaseExpiresAt   sql.NullString
+			runAt, createdAt, updatedAt string
+		)
+		if err := rows.Scan(&id, &job.Name, &payload, &state, &job.Attempts, &job.MaxAttempts, &lastError, &runAt, &leaseExpiresAt, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		parsedID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(payload), &job.Payload); err != nil {
+			return nil, err
+		}
+		job.ID = parsedID
+		job.State = domain.JobState(state)
+		if lastError.Valid {
+			value := lastError.String
+			job.LastError = &value
+		}
+		if job.RunAt, err = parseQueueTime(runAt); err != nil {
+			return nil, err
+		}
+		if leaseExpiresAt.Valid {
+			expires, err := parseQueueTime(leaseExpiresAt.String)
+			if err != nil {
+				return nil, err
+			}
+			job.LeaseExpiresAt = &expires
+		}
+		if job.CreatedAt, err = parseQueueTime(createdAt); err != nil {
+			return nil, err
+		}
+		if job.UpdatedAt, err = parseQueueTime(updatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, job)
+	}
+	return out, rows.Err()
+}
+
+// RequeueJob makes a queued or dead job due now with a fresh attempt budget.
+// Running jobs are refused with domain.ErrJobRunning.
+func (q *SQLiteQueue) RequeueJob(ctx context.Context, id uuid.UUID) (bool, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	now := formatQueueTime(time.Now())
+
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM upload_jobs WHERE id = ?`, id.String()).Scan(&status)
+	switch {
+	case err == nil && status == string(domain.JobStateRunning):
+		return true, domain.ErrJobRunning
+	case err == nil:
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE upload_jobs SET attempts = 0, run_at = ?, updated_at = ? WHERE id = ?
+		`, now, now, id.String()); err != nil {
+			return false, err
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO upload_jobs (id, name, payload, status, attempts, max_attempts, run_at, last_error, created_at, updated_at)
+			SELECT id, name, payload, ?, 0, ?, ?, last_error, created_at, ?
+			FROM upload_dead_jobs WHERE id = ?
+		`, string(domain.JobStateQueued), q.cfg.MaxAttempts, now, now, id.String())
+		if err != nil {
+			return false, err
+		}
+		if moved, err := result.RowsAffected(); err != nil || moved == 0 {
+			return false, err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM upload_dead_jobs WHERE id = ?`, id.String()); err != nil {
+			return false, err
+		}
+	default:
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+func formatQueueTime(value time.Time) string {
+	return value.UTC().Format(sqliteQueueTimeLayout)
+}
+
+func parseQueueTime(value string) (time.Time, error) {
+	return time.Parse(sqliteQueueTimeLayout, value)
+}
+
+var (
+	_ HandlerQueue = (*SQLiteQueue)(nil)
+	_ Aborter      = (*SQLiteQueue)(nil)
+)
+var _ domain.JobInspector = (*SQLiteQueue)(nil)
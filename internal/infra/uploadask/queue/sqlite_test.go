@@ -0,0 +1,277 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
+)
+
+func newSQLiteQueueForTest(t *testing.T, maxAttempts int) (*SQLiteQueue, *sql.DB) {
+	t.Helper()
+	db, err := sqliteinfra.Open(context.Background(), filepath.Join(t.TempDir(), "queue.db"))
+	require.NoError(t, err)
+	q := NewSQLiteQueue(db, SQLiteQueueConfig{
+		Workers:       2,
+		MaxAttempts:   maxAttempts,
+		BaseBackoff:   5 * time.Millisecond,
+		LeaseDuration: time.Second,
+		PollInterval:  5 * time.Millisecond,
+	}, nil)
+	t.Cleanup(func() {
+		q.Abort()
+		q.Close()
+		_ = db.Close()
+	})
+	return q, db
+}
+
+func waitForJobs(t *testing.T, q *SQLiteQueue, state domain.JobState, want int) []domain.Job {
+	t.Helper()
+	var jobs []domain.Job
+	require.Eventually(t, func() bool {
+		var err error
+		jobs, err = q.ListJobs(context.Background(), state, 10)
+		require.NoError(t, err)
+		return len(jobs) == want
+	}, 2*time.Second, 5*time.Millisecond)
+	return jobs
+}
+
+func TestSQLiteQueueRetriesUntilSuccess(t *testing.T) {
+	q, _ := newSQLiteQueueForTest(t, 5)
+	var calls atomic.Int32
+	done := make(chan map[string]any, 1)
+	q.SetHandler(func(ctx context.Context, name string, payload map[string]any) error {
+		if calls.Add(1) < 3 {
+			return errors.New("transient")
+		}
+		done <- payload
+		return nil
+	})
+
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", map[string]any{"document_id": "doc-1"}))
+
+	select {
+	case payload := <-done:
+		require.Equal(t, "doc-1", payload["document_id"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not succeed")
+	}
+	require.EqualValues(t, 3, calls.Load())
+	waitForJobs(t, q, "", 0)
+}
+
+func TestSQLiteQueueDeadLettersAfterMaxAttempts(t *testing.T) {
+	q, _ := newSQLiteQueueForTest(t, 2)
+	var calls atomic.Int32
+	q.SetHandler(func(ctx context.Context, name string, payload map[string]any) error {
+		calls.Add(1)
+		return errors.New("embedding provider down")
+	})
+
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", map[string]any{"document_id": "doc-1"}))
+
+	dead := waitForJobs(t, q, domain.JobStateDead, 1)
+	require.Equal(t, "process_document", dead[0].Name)
+	require.Equal(t, 2, dead[0].Attempts)
+	require.NotNil(t, dead[0].LastError)
+	require.Equal(t, "embedding provider down", *dead[0].LastError)
+	require.EqualValues(t, 2, calls.Load())
+	waitForJobs(t, q, domain.JobStateQueued, 0)
+}
+
+func TestSQLiteQueuePermanentErrorSkipsRetries(t *testing.T) {
+	q, _ := newSQLiteQueueForTest(t, 5)
+	var calls atomic.Int32
+	q.SetHandler(func(ctx context.Context, name string, payload map[string]any) error {
+		calls.Add(1)
+		return Permanent(errors.New("unsupported file type"))
+	})
+
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", nil))
+
+	dead := waitForJobs(t, q, domain.JobStateDead, 1)
+	require.Equal(t, 1, dead[0].Attempts)
+	require.EqualValues(t, 1, calls.Load())
+}
+
+func TestSQLiteQueueRequeueRunsDeadJobAgain(t *testing.T) {
+	q, _ := newSQLiteQueueForTest(t, 1)
+	var fail atomic.Bool
+	fail.Store(true)
+	succeeded := make(chan struct{}, 1)
+	q.SetHandler(func(ctx context.Context, name string, payload map[string]any) error {
+		if fail.Load() {
+			return errors.New("boom")
+		}
+		succeeded <- struct{}{}
+		return nil
+	})
+
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", nil))
+	dead := waitForJobs(t, q, domain.JobStateDead, 1)
+
+	fail.Store(false)
+	found, err := q.RequeueJob(context.Background(), dead[0].ID)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	select {
+	case <-succeeded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("requeued job did not run")
+	}
+	waitForJobs(t, q, "", 0)
+
+	found, err = q.RequeueJob(context.Background(), dead[0].ID)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestSQLiteQueueReclaimsExpiredLease(t *testing.T) {
+	q, db := newSQLiteQueueForTest(t, 3)
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", nil))
+
+	// Simulate a worker that claimed the job and then crashed.
+	expired := formatQueueTime(time.Now().Add(-time.Minute))
+	_, err := db.Exec(`UPDATE upload_jobs SET status = ?, attempts = 1, lease_owner = 'crashed', lease_expires_at = ?`, string(domain.JobStateRunning), expired)
+	require.NoError(t, err)
+	running := waitForJobs(t, q, domain.JobStateRunning, 1)
+
+	found, err := q.RequeueJob(context.Background(), running[0].ID)
+	require.ErrorIs(t, err, domain.ErrJobRunning)
+	require.True(t, found)
+
+	attempts := make(chan struct{}, 1)
+	q.SetHandler(func(ctx context.Context, name string, payload map[string]any) error {
+		attempts <- struct{}{}
+		return nil
+	})
+
+	select {
+	case <-attempts:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expired lease was not reclaimed")
+	}
+	waitForJobs(t, q, "", 0)
+}
+
+func TestSQLiteQueueDeadLettersExpiredLeaseOnLastAttempt(t *testing.T) {
+	q, db := newSQLiteQueueForTest(t, 2)
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", nil))
+
+	// The worker crashed during the job's last attempt.
+	expired := formatQueueTime(time.Now().Add(-time.Minute))
+	_, err := db.Exec(`UPDATE upload_jobs SET status = ?, attempts = 2, lease_owner = 'crashed', lease_expires_at = ?`, string(domain.JobStateRunning), expired)
+	require.NoError(t, err)
+
+	var calls atomic.Int32
+	q.SetHandler(func(ctx context.Context, name string, payload map[string]any) error {
+		calls.Add(1)
+		return nil
+	})
+
+	dead := waitForJobs(t, q, domain.JobStateDead, 1)
+	require.Equal(t, 2, dead[0].Attempts)
+	require.NotNil(t, dead[0].LastError)
+	require.Equal(t, "lease expired", *dead[0].LastError)
+	require.Zero(t, calls.Load())
+	waitForJobs(t, q, domain.JobStateRunning, 0)
+}
+
+func TestSQLiteQueueClaimLeasesJobOnce(t *testing.T) {
+	q, db := newSQLiteQueueForTest(t, 3)
+	other := NewSQLiteQueue(db, SQLiteQueueConfig{LeaseDuration: time.Second}, nil)
+	t.Cleanup(other.Close)
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", nil))
+
+	job, found, err := q.claim(context.Background())
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, 1, job.attempts)
+	_, found, err = other.claim(context.Background())
+	require.NoError(t, err)
+	require.False(t, found, "a live lease must not be claimed twice")
+}
+
+func TestSQLiteQueueCloseWaitsForRunningJob(t *testing.T) {
+	q, db := newSQLiteQueueForTest(t, 3)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var handled atomic.Bool
+	q.SetHandler(func(ctx context.Context, name string, payload map[string]any) error {
+		close(started)
+		<-release
+		handled.Store(ctx.Err() == nil)
+		return nil
+	})
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", nil))
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not start")
+	}
+	closed := make(chan struct{})
+	go func() {
+		q.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while the job was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not return after the job finished")
+	}
+
+	require.True(t, handled.Load(), "the job runs to the end with a live context")
+	var remaining int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM upload_jobs`).Scan(&remaining))
+	require.Zero(t, remaining, "the finished job is acknowledged")
+}
+
+func TestSQLiteQueueAbortCancelsRunningJob(t *testing.T) {
+	q, _ := newSQLiteQueueForTest(t, 3)
+	started := make(chan struct{})
+	q.SetHandler(func(ctx context.Context, name string, payload map[string]any) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, q.Enqueue(context.Background(), "process_document", nil))
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not start")
+	}
+	closed := make(chan struct{})
+	go func() {
+		q.Close()
+		close(closed)
+	}()
+	q.Abort()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Abort did not cancel the running job")
+	}
+
+	queued := waitForJobs(t, q, domain.JobStateQueued, 1)
+	require.Zero(t, queued[0].Attempts, "a cancelled job keeps its attempt")
+	require.Nil(t, queued[0].LeaseExpiresAt)
+}
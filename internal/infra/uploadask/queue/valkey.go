@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/valkey-io/valkey-go"
@@ -21,6 +22,8 @@ type ValkeyQueue struct {
 	handler     Handler
 	logger      *slog.Logger
 	stop        chan struct{}
+	stopOnce    sync.Once
+	consuming   sync.WaitGroup
 	pollTimeout time.Duration
 }
 
@@ -45,9 +48,17 @@ func (q *ValkeyQueue) SetHandler(handler Handler) {
 		return
 	}
 	q.logger.Info("valkey queue worker starting", "queue", q.queueKey)
+	q.consuming.Add(1)
 	go q.consume()
 }
 
+// Close stops popping jobs and waits for the job in hand to finish. It can
+// take up to the poll timeout while the worker is blocked waiting for a job.
+func (q *ValkeyQueue) Close() {
+	q.stopOnce.Do(func() { close(q.stop) })
+	q.consuming.Wait()
+}
+
 // Enqueue pushes a job onto the queue.
 func (q *ValkeyQueue) Enqueue(ctx context.Context, name string, payload any) error {
 	typed, ok := payload.(map[string]any)
@@ -63,6 +74,7 @@ func (q *ValkeyQueue) Enqueue(ctx context.Context, name string, payload any) err
 }
 
 func (q *ValkeyQueue) consume() {
+	defer q.consuming.Done()
 	ctx := context.Background()
 	for {
 		select {
@@ -92,7 +104,9 @@ func (q *ValkeyQueue) consume() {
 			continue
 		}
 		q.logger.Info("valkey queue job received", "name", job.Name)
-		q.handler(ctx, job.Name, job.Payload)
+		if err := q.handler(ctx, job.Name, job.Payload); err != nil {
+			q.logger.Warn("valkey queue job failed", "name", job.Name, "error", err)
+		}
 	}
 }
 
@@ -0,0 +1,153 @@
+package repo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
+)
+
+func TestMemoryRepositoriesDeleteDocument(t *testing.T) {
+	docs := NewMemoryDocumentRepository()
+	assertDocumentDeleteRemovesEverything(t, docs, NewMemoryFileRepository(), NewMemoryChunkRepository(docs), 3)
+}
+
+func TestSQLiteRepositoriesDeleteDocument(t *testing.T) {
+	db, err := sqliteinfra.Open(context.Background(), filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	assertDocumentDeleteRemovesEverything(t, NewSQLiteDocumentRepository(db), NewSQLiteFileRepository(db), NewSQLiteChunkRepository(db, nil), 3)
+}
+
+func TestSQLiteDocumentDeleteCascadesToFilesAndChunks(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	files := NewSQLiteFileRepository(db)
+	chunks := NewSQLiteChunkRepository(db, nil)
+	userID := int64(5)
+	docID := seedDeletableDocument(t, docs, files, chunks, userID, 3)
+
+	deleted, err := docs.Delete(ctx, docID, userID)
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	_, found, err := files.FindByDocument(ctx, docID)
+	require.NoError(t, err)
+	require.False(t, found)
+	results, err := chunks.SearchLexical(ctx, userID, "retention", domain.DocumentFilter{})
+	require.NoError(t, err)
+	require.Empty(t, results)
+	var remaining int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM upload_document_chunks_fts`).Scan(&remaining))
+	require.Zero(t, remaining)
+}
+
+// TestPostgresRepositoriesDeleteDocument runs against a pgvector database when
+// UPLOADASK_TEST_POSTGRES_DSN is set; docs/upload-ask/schema.sql is applied first.
+func TestPostgresRepositoriesDeleteDocument(t *testing.T) {
+	dsn := os.Getenv("UPLOADASK_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("UPLOADASK_TEST_POSTGRES_DSN not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	defer pool.Close()
+	schema, err := os.ReadFile(filepath.Join("..", "..", "..", "..", "docs", "upload-ask", "schema.sql"))
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, string(schema))
+	require.NoError(t, err)
+	assertDocumentDeleteRemovesEverything(t, NewPostgresDocumentRepository(pool), NewPostgresFileRepository(pool), NewPostgresChunkRepository(pool), 1536)
+}
+
+// assertDocumentDeleteRemovesEverything deletes a document the way the service
+// does and checks that nothing of it can be found or retrieved afterwards.
+func assertDocumentDeleteRemovesEverything(t *testing.T, docs domain.DocumentRepository, files domain.FileObjectRepository, chunks domain.ChunkRepository, dim int) {
+	t.Helper()
+	ctx := context.Background()
+	userID := time.Now().UnixNano()
+	docID := seedDeletableDocument(t, docs, files, chunks, userID, dim)
+	keptID := seedDeletableDocument(t, docs, files, chunks, userID, dim)
+
+	deleted, err := docs.Delete(ctx, docID, userID+1)
+	require.NoError(t, err)
+	require.False(t, deleted, "other users must not delete the document")
+
+	require.NoError(t, chunks.DeleteByDocument(ctx, docID))
+	require.NoError(t, files.DeleteByDocument(ctx, docID))
+	deleted, err = docs.Delete(ctx, docID, userID)
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	_, found, err := docs.Get(ctx, docID, userID)
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = files.FindByDocument(ctx, docID)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	similar, err := chunks.SearchSimilar(ctx, userID, testEmbedding(dim), domain.DocumentFilter{})
+	require.NoError(t, err)
+	require.Len(t, similar, 1)
+	require.Equal(t, keptID, similar[0].Document.ID)
+	lexical, err := chunks.SearchLexical(ctx, userID, "retention", domain.DocumentFilter{})
+	require.NoError(t, err)
+	require.Len(t, lexical, 1)
+	require.Equal(t, keptID, lexical[0].Document.ID)
+
+	deleted, err = docs.Delete(ctx, docID, userID)
+	require.NoError(t, err)
+	require.False(t, deleted)
+}
+
+func seedDeletableDocument(t *testing.T, docs domain.DocumentRepository, files domain.FileObjectRepository, chunks domain.ChunkRepository, userID int64, dim int) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Date(2026, 6, 13, 10, 0, 0, 0, time.UTC)
+	docID := uuid.New()
+	require.NoError(t, docs.Create(ctx, domain.Document{
+		ID:        docID,
+		UserID:    userID,
+		Title:     "Retention policy",
+		Source:    domain.DocumentSourceUpload,
+		Status:    domain.DocumentStatusProcessed,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}))
+	require.NoError(t, files.Create(ctx, domain.FileObject{
+		ID:         uuid.New(),
+		DocumentID: docID,
+		StorageKey: "uploads/" + docID.String() + "/policy.txt",
+		SizeBytes:  64,
+		MimeType:   "text/plain",
+		ETag:       "etag",
+		CreatedAt:  now,
+	}))
+	require.NoError(t, chunks.InsertBatch(ctx, []domain.DocumentChunk{{
+		ID:         uuid.New(),
+		DocumentID: docID,
+		ChunkIndex: 0,
+		Content:    "Backups follow the retention policy.",
+		TokenCount: 6,
+		Embedding:  testEmbedding(dim),
+		CreatedAt:  now,
+	}}))
+	return docID
+}
+
+func testEmbedding(dim int) []float32 {
+	embedding := make([]float32, dim)
+	embedding[0] = 1
+	return embedding
+}
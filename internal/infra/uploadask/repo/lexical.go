@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// maxLexicalTerms bounds the number of OR-ed terms sent to the keyword index.
+const maxLexicalTerms = 32
+
+// lexicalTokens splits text into lower-cased keyword tokens. Hyphens and
+// underscores inside a token are kept so identifiers such as "ERR-1042" or
+// "sku_88213" match as a whole, mirroring the FTS5 tokenizer configuration.
+func lexicalTokens(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+	})
+	tokens := fields[:0]
+	for _, field := range fields {
+		if field = strings.Trim(field, "-_"); field != "" {
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
+
+// lexicalTerms returns the distinct query tokens in order of appearance.
+func lexicalTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, token := range lexicalTokens(query) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		terms = append(terms, token)
+		if len(terms) == maxLexicalTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// ftsMatchQuery builds an FTS5 MATCH expression that ORs the quoted terms.
+func ftsMatchQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"`
+	}
+	return strings.Join(quoted, " OR ")
+}
+
+// tsQuery builds a Postgres to_tsquery expression that ORs the quoted terms.
+func tsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = "'" + term + "'"
+	}
+	return strings.Join(quoted, " | ")
+}
+
+// bm25Scorer ranks in-memory documents with Okapi BM25.
+type bm25Scorer struct {
+	docFreq map[string]int
+	docs    int
+	avgLen  float64
+}
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+func newBM25Scorer(corpus [][]string) *bm25Scorer {
+	scorer := &bm25Scorer{docFreq: make(map[string]int), docs: len(corpus)}
+	total := 0
+	for _, tokens := range corpus {
+		total += len(tokens)
+		seen := make(map[string]bool)
+		for _, token := range tokens {
+			if !seen[token] {
+				seen[token] = true
+				scorer.docFreq[token]++
+			}
+		}
+	}
+	if scorer.docs > 0 {
+		scorer.avgLen = float64(total) / float64(scorer.docs)
+	}
+	return scorer
+}
+
+func (s *bm25Scorer) score(terms []string, tokens []string) float64 {
+	if s.docs == 0 || len(tokens) == 0 {
+		return 0
+	}
+	freq := make(map[string]int, len(tokens))
+	for _, token := range tokens {
+		freq[token]++
+	}
+	var score float64
+	for _, term := range terms {
+		tf := float64(freq[term])
+		if tf == 0 {
+			continue
+		}
+		df := float64(s.docFreq[term])
+		idf := math.Log(1 + (float64(s.docs)-df+0.5)/(df+0.5))
+		norm := 1 - bm25B + bm25B*float64(len(tokens))/s.avgLen
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+	}
+	return score
+}
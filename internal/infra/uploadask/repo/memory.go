@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,14 +14,16 @@ import (
 
 // MemoryDocumentRepository is a simple in-memory store for documents.
 type MemoryDocumentRepository struct {
-	mu   sync.RWMutex
-	data map[uuid.UUID]domain.Document
+	mu         sync.RWMutex
+	data       map[uuid.UUID]domain.Document
+	recoveries map[uuid.UUID]int
 }
 
 // NewMemoryDocumentRepository constructs a document repository.
 func NewMemoryDocumentRepository() *MemoryDocumentRepository {
 	return &MemoryDocumentRepository{
-		data: make(map[uuid.UUID]domain.Document),
+		data:       make(map[uuid.UUID]domain.Document),
+		recoveries: make(map[uuid.UUID]int),
 	}
 }
 
@@ -45,6 +48,19 @@ func (r *MemoryDocumentRepository) UpdateStatus(_ context.Context, docID uuid.UU
 	return nil
 }
 
+func (r *MemoryDocumentRepository) UpdateProgress(_ context.Context, docID uuid.UUID, progress domain.DocumentProgress) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc, ok := r.data[docID]
+	if !ok {
+		return nil
+	}
+	doc.Progress = &progress
+	doc.UpdatedAt = time.Now()
+	r.data[docID] = doc
+	return nil
+}
+
 func (r *MemoryDocumentRepository) Get(_ context.Context, docID uuid.UUID, userID int64) (domain.Document, bool, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -82,11 +98,81 @@ func (r *MemoryDocumentRepository) List(_ context.Context, userID int64, filter
 				continue
 			}
 		}
+		if !filter.MatchesScope(doc) {
+			continue
+		}
 		out = append(out, doc)
 	}
 	return out, nil
 }
 
+func (r *MemoryDocumentRepository) Delete(_ context.Context, docID uuid.UUID, userID int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc, ok := r.data[docID]
+	if !ok || doc.UserID != userID {
+		return false, nil
+	}
+	delete(r.data, docID)
+	delete(r.recoveries, docID)
+	return true, nil
+}
+
+func (r *MemoryDocumentRepository) ListStale(_ context.Context, statuses []domain.DocumentStatus, updatedBefore time.Time) ([]domain.Document, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	allowed := statusSet(statuses)
+	out := make([]domain.Document, 0)
+	for _, doc := range r.data {
+		if allowed[doc.Status] && doc.UpdatedAt.Before(updatedBefore) {
+			out = append(out, doc)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].UpdatedAt.Before(out[j].UpdatedAt)
+	})
+	return out, nil
+}
+
+func (r *MemoryDocumentRepository) IncrementRecoveryAttempts(_ context.Context, docID uuid.UUID) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc, ok := r.data[docID]
+	if !ok {
+		return 0, nil
+	}
+	r.recoveries[docID]++
+	doc.UpdatedAt = time.Now()
+	r.data[docID] = doc
+	return r.recoveries[docID], nil
+}
+
+func (r *MemoryDocumentRepository) UpdateLabels(_ context.Context, docID uuid.UUID, userID int64, tags []string, metadata map[string]string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc, ok := r.data[docID]
+	if !ok || doc.UserID != userID {
+		return false, nil
+	}
+	doc.Tags = tags
+	doc.Metadata = metadata
+	doc.UpdatedAt = time.Now()
+	r.data[docID] = doc
+	return true, nil
+}
+
+func (r *MemoryDocumentRepository) OwnerIDs(_ context.Context, docIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[uuid.UUID]int64, len(docIDs))
+	for _, docID := range docIDs {
+		if doc, ok := r.data[docID]; ok {
+			out[docID] = doc.UserID
+		}
+	}
+	return out, nil
+}
+
 var _ domain.DocumentRepository = (*MemoryDocumentRepository)(nil)
 
 // MemoryFileRepository stores file metadata.
@@ -116,8 +202,332 @@ func (r *MemoryFileRepository) FindByDocument(_ context.Context, docID uuid.UUID
 	return file, ok, nil
 }
 
+func (r *MemoryFileRepository) ListByContentHash(_ context.Context, hash string) ([]domain.FileObject, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]domain.FileObject, 0)
+	for _, file := range r.files {
+		if file.ContentHash == hash {
+			out = append(out, file)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func (r *MemoryFileRepository) UsageByUser(_ context.Context, userID int64) (domain.StorageUsage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var usage domain.StorageUsage
+	for _, file := range r.files {
+		if file.UserID == userID {
+			usage.Documents++
+			usage.Bytes += file.SizeBytes
+		}
+	}
+	return usage, nil
+}
+
+func (r *MemoryFileRepository) DeleteByDocument(_ context.Context, docID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.files, docID)
+	return nil
+}
+
 var _ domain.FileObjectRepository = (*MemoryFileRepository)(nil)
 
+// MemoryUploadIntentRepository stores direct upload intents.
+type MemoryUploadIntentRepository struct {
+	mu      sync.Mutex
+	intents map[uuid.UUID]domain.UploadIntent
+}
+
+// NewMemoryUploadIntentRepository constructs an upload intent repository.
+func NewMemoryUploadIntentRepository() *MemoryUploadIntentRepository {
+	return &MemoryUploadIntentRepository{
+		intents: make(map[uuid.UUID]domain.UploadIntent),
+	}
+}
+
+func (r *MemoryUploadIntentRepository) Create(_ context.Context, intent domain.UploadIntent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.intents[intent.ID] = intent
+	return nil
+}
+
+func (r *MemoryUploadIntentRepository) Get(_ context.Context, id uuid.UUID, userID int64) (domain.UploadIntent, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	intent, ok := r.intents[id]
+	if !ok || intent.UserID != userID {
+		return domain.UploadIntent{}, false, nil
+	}
+	return intent, true, nil
+}
+
+func (r *MemoryUploadIntentRepository) GetByStorageKey(_ context.Context, key string) (domain.UploadIntent, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, intent := range r.intents {
+		if intent.StorageKey == key {
+			return intent, true, nil
+		}
+	}
+	return domain.UploadIntent{}, false, nil
+}
+
+func (r *MemoryUploadIntentRepository) MarkConfirmed(_ context.Context, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	intent, ok := r.intents[id]
+	if !ok || intent.Status != domain.UploadIntentPending {
+		return false, nil
+	}
+	intent.Status = domain.UploadIntentConfirmed
+	r.intents[id] = intent
+	return true, nil
+}
+
+var _ domain.UploadIntentRepository = (*MemoryUploadIntentRepository)(nil)
+
+// MemoryCollectionRepository stores collections and their members.
+type MemoryCollectionRepository struct {
+	mu          sync.RWMutex
+	collections map[uuid.UUID]domain.Collection
+	members     map[uuid.UUID]map[uuid.UUID]time.Time
+}
+
+// NewMemoryCollectionRepository constructs a collection repository.
+func NewMemoryCollectionRepository() *MemoryCollectionRepository {
+	return &MemoryCollectionRepository{
+		collections: make(map[uuid.UUID]domain.Collection),
+		members:     make(map[uuid.UUID]map[uuid.UUID]time.Time),
+	}
+}
+
+func (r *MemoryCollectionRepository) Create(_ context.Context, collection domain.Collection) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collections[collection.ID] = collection
+	r.members[collection.ID] = make(map[uuid.UUID]time.Time)
+	return nil
+}
+
+func (r *MemoryCollectionRepository) Get(_ context.Context, id uuid.UUID, userID int64) (domain.Collection, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	collection, ok := r.collections[id]
+	if !ok || collection.UserID != userID {
+		return domain.Collection{}, false, nil
+	}
+	collection.DocumentCount = len(r.members[id])
+	return collection, true, nil
+}
+
+func (r *MemoryCollectionRepository) List(_ context.Context, userID int64) ([]domain.Collection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]domain.Collection, 0)
+	for id, collection := range r.collections {
+		if collection.UserID != userID {
+			continue
+		}
+		collection.DocumentCount = len(r.members[id])
+		out = append(out, collection)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func (r *MemoryCollectionRepository) Update(_ context.Context, collection domain.Collection) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.collections[collection.ID]
+	if !ok || existing.UserID != collection.UserID {
+		return false, nil
+	}
+	existing.Name = collection.Name
+	existing.Description = collection.Description
+	existing.UpdatedAt = collection.UpdatedAt
+	r.collections[collection.ID] = existing
+	return true, nil
+}
+
+func (r *MemoryCollectionRepository) Delete(_ context.Context, id uuid.UUID, userID int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	collection, ok := r.collections[id]
+	if !ok || collection.UserID != userID {
+		return false, nil
+	}
+	delete(r.collections, id)
+	delete(r.members, id)
+	return true, nil
+}
+
+func (r *MemoryCollectionRepository) AddDocuments(_ context.Context, collectionID uuid.UUID, docIDs []uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	members, ok := r.members[collectionID]
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	for _, docID := range docIDs {
+		if _, exists := members[docID]; !exists {
+			members[docID] = now
+		}
+	}
+	return nil
+}
+
+func (r *MemoryCollectionRepository) RemoveDocuments(_ context.Context, collectionID uuid.UUID, docIDs []uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, docID := range docIDs {
+		delete(r.members[collectionID], docID)
+	}
+	return nil
+}
+
+func (r *MemoryCollectionRepository) DocumentIDs(_ context.Context, collectionID uuid.UUID) ([]uuid.UUID, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	members := r.members[collectionID]
+	out := make([]uuid.UUID, 0, len(members))
+	for docID := range members {
+		out = append(out, docID)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return members[out[i]].Before(members[out[j]])
+	})
+	return out, nil
+}
+
+func (r *MemoryCollectionRepository) DocumentIDsIn(_ context.Context, collectionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	seen := make(map[uuid.UUID]bool)
+	out := make([]uuid.UUID, 0)
+	for _, collectionID := range collectionIDs {
+		for docID := range r.members[collectionID] {
+			if !seen[docID] {
+				seen[docID] = true
+				out = append(out, docID)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *MemoryCollectionRepository) DeleteByDocument(_ context.Context, docID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, members := range r.members {
+		delete(members, docID)
+	}
+	return nil
+}
+
+var _ domain.CollectionRepository = (*MemoryCollectionRepository)(nil)
+
+type shareKey struct {
+	resourceType domain.ShareResourceType
+	resourceID   uuid.UUID
+	userID       int64
+}
+
+// MemoryShareRepository stores shares in memory.
+type MemoryShareRepository struct {
+	mu     sync.RWMutex
+	shares map[shareKey]domain.Share
+}
+
+// NewMemoryShareRepository constructs a share repository.
+func NewMemoryShareRepository() *MemoryShareRepository {
+	return &MemoryShareRepository{shares: make(map[shareKey]domain.Share)}
+}
+
+func (r *MemoryShareRepository) Upsert(_ context.Context, share domain.Share) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := shareKey{resourceType: share.ResourceType, resourceID: share.ResourceID, userID: share.UserID}
+	if existing, ok := r.shares[key]; ok {
+		existing.Role = share.Role
+		existing.UpdatedAt = share.UpdatedAt
+		share = existing
+	}
+	r.shares[key] = share
+	return nil
+}
+
+func (r *MemoryShareRepository) Get(_ context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID, userID int64) (domain.Share, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	share, ok := r.shares[shareKey{resourceType: resourceType, resourceID: resourceID, userID: userID}]
+	return share, ok, nil
+}
+
+func (r *MemoryShareRepository) ListByResource(_ context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID) ([]domain.Share, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]domain.Share, 0)
+	for key, share := range r.shares {
+		if key.resourceType == resourceType && key.resourceID == resourceID {
+			out = append(out, share)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func (r *MemoryShareRepository) ListByUser(_ context.Context, userID int64) ([]domain.Share, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]domain.Share, 0)
+	for key, share := range r.shares {
+		if key.userID == userID {
+			out = append(out, share)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func (r *MemoryShareRepository) Delete(_ context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID, userID int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := shareKey{resourceType: resourceType, resourceID: resourceID, userID: userID}
+	if _, ok := r.shares[key]; !ok {
+		return false, nil
+	}
+	delete(r.shares, key)
+	return true, nil
+}
+
+func (r *MemoryShareRepository) DeleteByResource(_ context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.shares {
+		if key.resourceType == resourceType && key.resourceID == resourceID {
+			delete(r.shares, key)
+		}
+	}
+	return nil
+}
+
+var _ domain.ShareRepository = (*MemoryShareRepository)(nil)
+
 // MemoryChunkRepository stores embedded chunks for retrieval.
 type MemoryChunkRepository struct {
 	mu   sync.RWMutex
@@ -146,6 +556,94 @@ func (r *MemoryChunkRepository) SearchSimilar(ctx context.Context, userID int64,
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	results := r.candidates(ctx, userID, filter)
+	for i := range results {
+		results[i].Score = cosineSimilarity(embedding, results[i].Chunk.Embedding)
+	}
+	sortByScore(results)
+	return results, nil
+}
+
+func (r *MemoryChunkRepository) SearchLexical(ctx context.Context, userID int64, query string, filter domain.DocumentFilter) ([]domain.RetrievedChunk, error) {
+	terms := lexicalTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	candidates := r.candidates(ctx, userID, filter)
+	corpus := make([][]string, len(candidates))
+	for i, candidate := range candidates {
+		corpus[i] = lexicalTokens(candidate.Chunk.Content)
+	}
+	scorer := newBM25Scorer(corpus)
+	results := make([]domain.RetrievedChunk, 0)
+	for i, candidate := range candidates {
+		if score := scorer.score(terms, corpus[i]); score > 0 {
+			candidate.Score = score
+			results = append(results, candidate)
+		}
+	}
+	sortByScore(results)
+	return results, nil
+}
+
+func (r *MemoryChunkRepository) ListByDocument(_ context.Context, docID uuid.UUID) ([]domain.DocumentChunk, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := append([]domain.DocumentChunk{}, r.data[docID]...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].ChunkIndex < out[j].ChunkIndex
+	})
+	return out, nil
+}
+
+func (r *MemoryChunkRepository) DeleteByDocument(_ context.Context, docID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.data, docID)
+	return nil
+}
+
+func (r *MemoryChunkRepository) ListStaleDocuments(ctx context.Context, userID int64, embeddingModel, indexVersion string) ([]uuid.UUID, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]uuid.UUID, 0)
+	for docID, chunks := range r.data {
+		if _, found, _ := r.docs.Get(ctx, docID, userID); !found {
+			continue
+		}
+		for _, chunk := range chunks {
+			if chunk.EmbeddingModel != embeddingModel || chunk.IndexVersion != indexVersion {
+				out = append(out, docID)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *MemoryChunkRepository) CountByUser(ctx context.Context, userID int64) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	count := 0
+	for docID, chunks := range r.data {
+		if _, found, _ := r.docs.Get(ctx, docID, userID); found {
+			count += len(chunks)
+		}
+	}
+	return count, nil
+}
+
+func (r *MemoryChunkRepository) CountByDocument(_ context.Context, docID uuid.UUID) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.data[docID]), nil
+}
+
+// candidates returns the user's chunks that pass filter. Callers hold r.mu.
+func (r *MemoryChunkRepository) candidates(ctx context.Context, userID int64, filter domain.DocumentFilter) []domain.RetrievedChunk {
 	allowedDocs := make(map[uuid.UUID]bool)
 	for _, id := range filter.DocumentIDs {
 		allowedDocs[id] = true
@@ -167,18 +665,22 @@ func (r *MemoryChunkRepository) SearchSimilar(ctx context.Context, userID int64,
 		if len(allowedStatuses) > 0 && !allowedStatuses[doc.Status] {
 			continue
 		}
+		if !filter.MatchesScope(doc) {
+			continue
+		}
 		for _, chunk := range chunks {
-			score := cosineSimilarity(embedding, chunk.Embedding)
 			results = append(results, domain.RetrievedChunk{
 				Chunk:     chunk,
 				Document:  doc,
-				Score:     score,
 				CreatedAt: chunk.CreatedAt,
 			})
 		}
 	}
+	return results
+}
 
-	// simple sort by score descending
+// sortByScore orders results by score descending.
+func sortByScore(results []domain.RetrievedChunk) {
 	for i := 0; i < len(results); i++ {
 		for j := i + 1; j < len(results); j++ {
 			if results[j].Score > results[i].Score {
@@ -186,7 +688,6 @@ func (r *MemoryChunkRepository) SearchSimilar(ctx context.Context, userID int64,
 			}
 		}
 	}
-	return results, nil
 }
 
 var _ domain.ChunkRepository = (*MemoryChunkRepository)(nil)
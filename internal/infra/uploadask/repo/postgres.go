@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -26,10 +27,14 @@ func NewPostgresDocumentRepository(pool *pgxpool.Pool) *PostgresDocumentReposito
 }
 
 func (r *PostgresDocumentRepository) Create(ctx context.Context, doc domain.Document) error {
-	_, err := r.pool.Exec(ctx, `
-		INSERT INTO upload_documents (id, user_id, title, source, status, failure_reason, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, doc.ID, doc.UserID, doc.Title, doc.Source, doc.Status, doc.FailureReason, doc.CreatedAt, doc.UpdatedAt)
+	tags, metadata, err := postgresLabels(doc.Tags, doc.Metadata)
+	if err != nil {
+		return err
+	}
+	_, err = r.pool.Exec(ctx, `
+		INSERT INTO upload_documents (id, user_id, title, source, source_url, status, failure_reason, mime_type, tags, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, doc.ID, doc.UserID, doc.Title, doc.Source, doc.SourceURL, doc.Status, doc.FailureReason, doc.MimeType, tags, metadata, doc.CreatedAt, doc.UpdatedAt)
 	return err
 }
 
@@ -42,16 +47,29 @@ func (r *PostgresDocumentRepository) UpdateStatus(ctx context.Context, docID uui
 	return err
 }
 
+func (r *PostgresDocumentRepository) UpdateProgress(ctx context.Context, docID uuid.UUID, progress domain.DocumentProgress) error {
+	payload, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	_, err = r.pool.Exec(ctx, `
+		UPDATE upload_documents
+		SET progress = $1, updated_at = NOW()
+		WHERE id = $2
+	`, payload, docID)
+	return err
+}
+
 func (r *PostgresDocumentRepository) Get(ctx context.Context, docID uuid.UUID, userID int64) (domain.Document, bool, error) {
 	row := r.pool.QueryRow(ctx, `
-		SELECT id, user_id, title, source, status, failure_reason, created_at, updated_at
+		SELECT id, user_id, title, source, source_url, status, failure_reason, mime_type, tags, metadata, progress, created_at, updated_at
 		FROM upload_documents
 		WHERE id = $1 AND user_id = $2
 		LIMIT 1
 	`, docID, userID)
 	var doc domain.Document
 	var failureReason *string
-	if err := row.Scan(&doc.ID, &doc.UserID, &doc.Title, &doc.Source, &doc.Status, &failureReason, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
+	if err := row.Scan(&doc.ID, &doc.UserID, &doc.Title, &doc.Source, &doc.SourceURL, &doc.Status, &failureReason, &doc.MimeType, &doc.Tags, &doc.Metadata, &doc.Progress, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			return domain.Document{}, false, nil
 		}
@@ -63,7 +81,7 @@ func (r *PostgresDocumentRepository) Get(ctx context.Context, docID uuid.UUID, u
 
 func (r *PostgresDocumentRepository) List(ctx context.Context, userID int64, filter domain.DocumentFilter) ([]domain.Document, error) {
 	query := `
-		SELECT id, user_id, title, source, status, failure_reason, created_at, updated_at
+		SELECT id, user_id, title, source, source_url, status, failure_reason, mime_type, tags, metadata, progress, created_at, updated_at
 		FROM upload_documents
 		WHERE user_id = $1
 	`
@@ -79,7 +97,8 @@ func (r *PostgresDocumentRepository) List(ctx context.Context, userID int64, fil
 		args = append(args, filter.DocumentIDs)
 		argPos++
 	}
-	query += ` ORDER BY created_at DESC`
+	scope, args, _ := postgresScope(filter, "", args, argPos)
+	query += scope + ` ORDER BY created_at DESC`
 
 	rows, err := r.pool.Query(ctx, query, args...)
 	if err != nil {
@@ -91,7 +110,44 @@ func (r *PostgresDocumentRepository) List(ctx context.Context, userID int64, fil
 	for rows.Next() {
 		var doc domain.Document
 		var failureReason *string
-		if err := rows.Scan(&doc.ID, &doc.UserID, &doc.Title, &doc.Source, &doc.Status, &failureReason, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
+		if err := rows.Scan(&doc.ID, &doc.UserID, &doc.Title, &doc.Source, &doc.SourceURL, &doc.Status, &failureReason, &doc.MimeType, &doc.Tags, &doc.Metadata, &doc.Progress, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
+			return nil, err
+		}
+		doc.FailureReason = failureReason
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// Delete removes the document row. File metadata and chunks cascade.
+func (r *PostgresDocumentRepository) Delete(ctx context.Context, docID uuid.UUID, userID int64) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM upload_documents
+		WHERE id = $1 AND user_id = $2
+	`, docID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *PostgresDocumentRepository) ListStale(ctx context.Context, statuses []domain.DocumentStatus, updatedBefore time.Time) ([]domain.Document, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, user_id, title, source, source_url, status, failure_reason, mime_type, tags, metadata, progress, created_at, updated_at
+		FROM upload_documents
+		WHERE status = ANY($1) AND updated_at < $2
+		ORDER BY updated_at
+	`, statuses, updatedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []domain.Document
+	for rows.Next() {
+		var doc domain.Document
+		var failureReason *string
+		if err := rows.Scan(&doc.ID, &doc.UserID, &doc.Title, &doc.Source, &doc.SourceURL, &doc.Status, &failureReason, &doc.MimeType, &doc.Tags, &doc.Metadata, &doc.Progress, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
 			return nil, err
 		}
 		doc.FailureReason = failureReason
@@ -100,6 +156,63 @@ func (r *PostgresDocumentRepository) List(ctx context.Context, userID int64, fil
 	return docs, rows.Err()
 }
 
+func (r *PostgresDocumentRepository) IncrementRecoveryAttempts(ctx context.Context, docID uuid.UUID) (int, error) {
+	var attempts int
+	err := r.pool.QueryRow(ctx, `
+		UPDATE upload_documents
+		SET recovery_attempts = recovery_attempts + 1, updated_at = NOW()
+		WHERE id = $1
+		RETURNING recovery_attempts
+	`, docID).Scan(&attempts)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+	return attempts, err
+}
+
+func (r *PostgresDocumentRepository) UpdateLabels(ctx context.Context, docID uuid.UUID, userID int64, tags []string, metadata map[string]string) (bool, error) {
+	rawTags, rawMetadata, err := postgresLabels(tags, metadata)
+	if err != nil {
+		return false, err
+	}
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE upload_documents
+		SET tags = $1, metadata = $2, updated_at = NOW()
+		WHERE id = $3 AND user_id = $4
+	`, rawTags, rawMetadata, docID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *PostgresDocumentRepository) OwnerIDs(ctx context.Context, docIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	out := make(map[uuid.UUID]int64, len(docIDs))
+	if len(docIDs) == 0 {
+		return out, nil
+	}
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, user_id
+		FROM upload_documents
+		WHERE id = ANY($1)
+	`, docIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			docID  uuid.UUID
+			userID int64
+		)
+		if err := rows.Scan(&docID, &userID); err != nil {
+			return nil, err
+		}
+		out[docID] = userID
+	}
+	return out, rows.Err()
+}
+
 var _ domain.DocumentRepository = (*PostgresDocumentRepository)(nil)
 
 // PostgresFileRepository persists file metadata.
@@ -114,21 +227,21 @@ func NewPostgresFileRepository(pool *pgxpool.Pool) *PostgresFileRepository {
 
 func (r *PostgresFileRepository) Create(ctx context.Context, file domain.FileObject) error {
 	_, err := r.pool.Exec(ctx, `
-		INSERT INTO upload_file_objects (id, document_id, storage_key, size_bytes, mime_type, etag, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, file.ID, file.DocumentID, file.StorageKey, file.SizeBytes, file.MimeType, file.ETag, file.CreatedAt)
+		INSERT INTO upload_file_objects (id, document_id, user_id, storage_key, size_bytes, mime_type, etag, content_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, file.ID, file.DocumentID, file.UserID, file.StorageKey, file.SizeBytes, file.MimeType, file.ETag, file.ContentHash, file.CreatedAt)
 	return err
 }
 
 func (r *PostgresFileRepository) FindByDocument(ctx context.Context, docID uuid.UUID) (domain.FileObject, bool, error) {
 	row := r.pool.QueryRow(ctx, `
-		SELECT id, document_id, storage_key, size_bytes, mime_type, etag, created_at
+		SELECT id, document_id, user_id, storage_key, size_bytes, mime_type, etag, content_hash, created_at
 		FROM upload_file_objects
 		WHERE document_id = $1
 		LIMIT 1
 	`, docID)
 	var file domain.FileObject
-	if err := row.Scan(&file.ID, &file.DocumentID, &file.StorageKey, &file.SizeBytes, &file.MimeType, &file.ETag, &file.CreatedAt); err != nil {
+	if err := row.Scan(&file.ID, &file.DocumentID, &file.UserID, &file.StorageKey, &file.SizeBytes, &file.MimeType, &file.ETag, &file.ContentHash, &file.CreatedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			return domain.FileObject{}, false, nil
 		}
@@ -137,8 +250,363 @@ func (r *PostgresFileRepository) FindByDocument(ctx context.Context, docID uuid.
 	return file, true, nil
 }
 
+func (r *PostgresFileRepository) ListByContentHash(ctx context.Context, hash string) ([]domain.FileObject, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, document_id, user_id, storage_key, size_bytes, mime_type, etag, content_hash, created_at
+		FROM upload_file_objects
+		WHERE content_hash = $1
+		ORDER BY created_at DESC
+	`, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []domain.FileObject
+	for rows.Next() {
+		var file domain.FileObject
+		if err := rows.Scan(&file.ID, &file.DocumentID, &file.UserID, &file.StorageKey, &file.SizeBytes, &file.MimeType, &file.ETag, &file.ContentHash, &file.CreatedAt); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
+
+func (r *PostgresFileRepository) UsageByUser(ctx context.Context, userID int64) (domain.StorageUsage, error) {
+	var usage domain.StorageUsage
+	err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(1), COALESCE(SUM(size_bytes), 0)
+		FROM upload_file_objects
+		WHERE user_id = $1
+	`, userID).Scan(&usage.Documents, &usage.Bytes)
+	return usage, err
+}
+
+func (r *PostgresFileRepository) DeleteByDocument(ctx context.Context, docID uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM upload_file_objects WHERE document_id = $1`, docID)
+	return err
+}
+
 var _ domain.FileObjectRepository = (*PostgresFileRepository)(nil)
 
+// PostgresUploadIntentRepository persists direct upload intents.
+type PostgresUploadIntentRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresUploadIntentRepository constructs the repository.
+func NewPostgresUploadIntentRepository(pool *pgxpool.Pool) *PostgresUploadIntentRepository {
+	return &PostgresUploadIntentRepository{pool: pool}
+}
+
+func (r *PostgresUploadIntentRepository) Create(ctx context.Context, intent domain.UploadIntent) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO upload_intents (id, user_id, filename, title, mime_type, size_bytes, storage_key, status, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, intent.ID, intent.UserID, intent.Filename, intent.Title, intent.MimeType, intent.SizeBytes, intent.StorageKey, string(intent.Status), intent.ExpiresAt, intent.CreatedAt)
+	return err
+}
+
+func (r *PostgresUploadIntentRepository) Get(ctx context.Context, id uuid.UUID, userID int64) (domain.UploadIntent, bool, error) {
+	return r.find(ctx, `id = $1 AND user_id = $2`, id, userID)
+}
+
+func (r *PostgresUploadIntentRepository) GetByStorageKey(ctx context.Context, key string) (domain.UploadIntent, bool, error) {
+	return r.find(ctx, `storage_key = $1`, key)
+}
+
+func (r *PostgresUploadIntentRepository) find(ctx context.Context, where string, args ...any) (domain.UploadIntent, bool, error) {
+	row := r.pool.QueryRow(ctx, `
+		SELECT id, user_id, filename, title, mime_type, size_bytes, storage_key, status, expires_at, created_at
+		FROM upload_intents
+		WHERE `+where, args...)
+	var (
+		intent domain.UploadIntent
+		status string
+	)
+	if err := row.Scan(&intent.ID, &intent.UserID, &intent.Filename, &intent.Title, &intent.MimeType, &intent.SizeBytes, &intent.StorageKey, &status, &intent.ExpiresAt, &intent.CreatedAt); err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.UploadIntent{}, false, nil
+		}
+		return domain.UploadIntent{}, false, err
+	}
+	intent.Status = domain.UploadIntentStatus(status)
+	return intent, true, nil
+}
+
+func (r *PostgresUploadIntentRepository) MarkConfirmed(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE upload_intents SET status = $1 WHERE id = $2 AND status = $3
+	`, string(domain.UploadIntentConfirmed), id, string(domain.UploadIntentPending))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+var _ domain.UploadIntentRepository = (*PostgresUploadIntentRepository)(nil)
+
+// PostgresCollectionRepository persists collections.
+type PostgresCollectionRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresCollectionRepository constructs the repository.
+func NewPostgresCollectionRepository(pool *pgxpool.Pool) *PostgresCollectionRepository {
+	return &PostgresCollectionRepository{pool: pool}
+}
+
+func (r *PostgresCollectionRepository) Create(ctx context.Context, collection domain.Collection) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO upload_collections (id, user_id, name, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, collection.ID, collection.UserID, collection.Name, collection.Description, collection.CreatedAt, collection.UpdatedAt)
+	return err
+}
+
+func (r *PostgresCollectionRepository) Get(ctx context.Context, id uuid.UUID, userID int64) (domain.Collection, bool, error) {
+	row := r.pool.QueryRow(ctx, `
+		SELECT c.id, c.user_id, c.name, c.description, c.created_at, c.updated_at,
+			(SELECT COUNT(*) FROM upload_collection_documents m WHERE m.collection_id = c.id)
+		FROM upload_collections c
+		WHERE c.id = $1 AND c.user_id = $2
+	`, id, userID)
+	var collection domain.Collection
+	if err := row.Scan(&collection.ID, &collection.UserID, &collection.Name, &collection.Description, &collection.CreatedAt, &collection.UpdatedAt, &collection.DocumentCount); err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.Collection{}, false, nil
+		}
+		return domain.Collection{}, false, err
+	}
+	return collection, true, nil
+}
+
+func (r *PostgresCollectionRepository) List(ctx context.Context, userID int64) ([]domain.Collection, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT c.id, c.user_id, c.name, c.description, c.created_at, c.updated_at,
+			(SELECT COUNT(*) FROM upload_collection_documents m WHERE m.collection_id = c.id)
+		FROM upload_collections c
+		WHERE c.user_id = $1
+		ORDER BY c.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []domain.Collection
+	for rows.Next() {
+		var collection domain.Collection
+		if err := rows.Scan(&collection.ID, &collection.UserID, &collection.Name, &collection.Description, &collection.CreatedAt, &collection.UpdatedAt, &collection.DocumentCount); err != nil {
+			return nil, err
+		}
+		collections = append(collections, collection)
+	}
+	return collections, rows.Err()
+}
+
+func (r *PostgresCollectionRepository) Update(ctx context.Context, collection domain.Collection) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE upload_collections
+		SET name = $1, description = $2, updated_at = $3
+		WHERE id = $4 AND user_id = $5
+	`, collection.Name, collection.Description, collection.UpdatedAt, collection.ID, collection.UserID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Delete removes the collection row. Memberships cascade.
+func (r *PostgresCollectionRepository) Delete(ctx context.Context, id uuid.UUID, userID int64) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM upload_collections
+		WHERE id = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *PostgresCollectionRepository) AddDocuments(ctx context.Context, collectionID uuid.UUID, docIDs []uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO upload_collection_documents (collection_id, document_id)
+		SELECT $1, unnest($2::uuid[])
+		ON CONFLICT (collection_id, document_id) DO NOTHING
+	`, collectionID, docIDs)
+	return err
+}
+
+func (r *PostgresCollectionRepository) RemoveDocuments(ctx context.Context, collectionID uuid.UUID, docIDs []uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `
+		DELETE FROM upload_collection_documents
+		WHERE collection_id = $1 AND document_id = ANY($2)
+	`, collectionID, docIDs)
+	return err
+}
+
+func (r *PostgresCollectionRepository) DocumentIDs(ctx context.Context, collectionID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT document_id
+		FROM upload_collection_documents
+		WHERE collection_id = $1
+		ORDER BY added_at, document_id
+	`, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *PostgresCollectionRepository) DocumentIDsIn(ctx context.Context, collectionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT DISTINCT document_id
+		FROM upload_collection_documents
+		WHERE collection_id = ANY($1)
+	`, collectionIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *PostgresCollectionRepository) DeleteByDocument(ctx context.Context, docID uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM upload_collection_documents WHERE document_id = $1`, docID)
+	return err
+}
+
+var _ domain.CollectionRepository = (*PostgresCollectionRepository)(nil)
+
+// PostgresShareRepository persists shares.
+type PostgresShareRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresShareRepository constructs the repository.
+func NewPostgresShareRepository(pool *pgxpool.Pool) *PostgresShareRepository {
+	return &PostgresShareRepository{pool: pool}
+}
+
+func (r *PostgresShareRepository) Upsert(ctx context.Context, share domain.Share) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO upload_shares (resource_type, resource_id, owner_id, user_id, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (resource_type, resource_id, user_id) DO UPDATE SET
+			role = EXCLUDED.role,
+			updated_at = EXCLUDED.updated_at
+	`, string(share.ResourceType), share.ResourceID, share.OwnerID, share.UserID, string(share.Role), share.CreatedAt, share.UpdatedAt)
+	return err
+}
+
+func (r *PostgresShareRepository) Get(ctx context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID, userID int64) (domain.Share, bool, error) {
+	row := r.pool.QueryRow(ctx, `
+		SELECT resource_type, resource_id, owner_id, user_id, role, created_at, updated_at
+		FROM upload_shares
+		WHERE resource_type = $1 AND resource_id = $2 AND user_id = $3
+	`, string(resourceType), resourceID, userID)
+	share, err := scanPostgresShare(row)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.Share{}, false, nil
+		}
+		return domain.Share{}, false, err
+	}
+	return share, true, nil
+}
+
+func (r *PostgresShareRepository) ListByResource(ctx context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID) ([]domain.Share, error) {
+	return r.list(ctx, `
+		SELECT resource_type, resource_id, owner_id, user_id, role, created_at, updated_at
+		FROM upload_shares
+		WHERE resource_type = $1 AND resource_id = $2
+		ORDER BY created_at, user_id
+	`, string(resourceType), resourceID)
+}
+
+func (r *PostgresShareRepository) ListByUser(ctx context.Context, userID int64) ([]domain.Share, error) {
+	return r.list(ctx, `
+		SELECT resource_type, resource_id, owner_id, user_id, role, created_at, updated_at
+		FROM upload_shares
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+}
+
+func (r *PostgresShareRepository) list(ctx context.Context, query string, args ...any) ([]domain.Share, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := make([]domain.Share, 0)
+	for rows.Next() {
+		share, err := scanPostgresShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+func (r *PostgresShareRepository) Delete(ctx context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID, userID int64) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM upload_shares
+		WHERE resource_type = $1 AND resource_id = $2 AND user_id = $3
+	`, string(resourceType), resourceID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *PostgresShareRepository) DeleteByResource(ctx context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `
+		DELETE FROM upload_shares
+		WHERE resource_type = $1 AND resource_id = $2
+	`, string(resourceType), resourceID)
+	return err
+}
+
+func scanPostgresShare(row pgx.Row) (domain.Share, error) {
+	var (
+		share        domain.Share
+		resourceType string
+		role         string
+	)
+	if err := row.Scan(&resourceType, &share.ResourceID, &share.OwnerID, &share.UserID, &role, &share.CreatedAt, &share.UpdatedAt); err != nil {
+		return domain.Share{}, err
+	}
+	share.ResourceType = domain.ShareResourceType(resourceType)
+	share.Role = domain.ShareRole(role)
+	return share, nil
+}
+
+var _ domain.ShareRepository = (*PostgresShareRepository)(nil)
+
 // PostgresChunkRepository stores chunks and supports similarity search via pgvector.
 type PostgresChunkRepository struct {
 	pool *pgxpool.Pool
@@ -153,9 +621,9 @@ func (r *PostgresChunkRepository) InsertBatch(ctx context.Context, chunks []doma
 	batch := &pgx.Batch{}
 	for _, chunk := range chunks {
 		batch.Queue(`
-			INSERT INTO upload_document_chunks (id, document_id, chunk_index, content, token_count, embedding, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-		`, chunk.ID, chunk.DocumentID, chunk.ChunkIndex, chunk.Content, chunk.TokenCount, pgvector.NewVector(chunk.Embedding), chunk.CreatedAt)
+			INSERT INTO upload_document_chunks (id, document_id, chunk_index, page_number, heading_path, content, token_count, embedding, embedding_model, index_version, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		`, chunk.ID, chunk.DocumentID, chunk.ChunkIndex, chunk.PageNumber, chunk.HeadingPath, chunk.Content, chunk.TokenCount, pgvector.NewVector(chunk.Embedding), chunk.EmbeddingModel, chunk.IndexVersion, chunk.CreatedAt)
 	}
 	return r.pool.SendBatch(ctx, batch).Close()
 }
@@ -163,8 +631,8 @@ func (r *PostgresChunkRepository) InsertBatch(ctx context.Context, chunks []doma
 func (r *PostgresChunkRepository) SearchSimilar(ctx context.Context, userID int64, embedding []float32, filter domain.DocumentFilter) ([]domain.RetrievedChunk, error) {
 	query := `
 		SELECT
-			c.id, c.document_id, c.chunk_index, c.content, c.token_count, c.embedding, c.created_at,
-			d.id, d.user_id, d.title, d.source, d.status, d.failure_reason, d.created_at, d.updated_at,
+			c.id, c.document_id, c.chunk_index, c.page_number, c.heading_path, c.content, c.token_count, c.embedding, c.embedding_model, c.index_version, c.created_at,
+			d.id, d.user_id, d.title, d.source, d.source_url, d.status, d.failure_reason, d.mime_type, d.tags, d.metadata, d.created_at, d.updated_at,
 			(1.0 / (1.0 + (c.embedding <-> $1))) AS score
 		FROM upload_document_chunks c
 		JOIN upload_documents d ON d.id = c.document_id
@@ -182,6 +650,8 @@ func (r *PostgresChunkRepository) SearchSimilar(ctx context.Context, userID int6
 		args = append(args, filter.DocumentIDs)
 		argPos++
 	}
+	scope, args, _ := postgresScope(filter, "d.", args, argPos)
+	query += scope
 	query += ` ORDER BY (c.embedding <-> $1) ASC LIMIT 64`
 
 	rows, err := r.pool.Query(ctx, query, args...)
@@ -189,7 +659,128 @@ func (r *PostgresChunkRepository) SearchSimilar(ctx context.Context, userID int6
 		return nil, err
 	}
 	defer rows.Close()
+	return scanPostgresRetrievedChunks(rows)
+}
+
+func (r *PostgresChunkRepository) SearchLexical(ctx context.Context, userID int64, query string, filter domain.DocumentFilter) ([]domain.RetrievedChunk, error) {
+	terms := lexicalTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	sqlQuery := `
+		SELECT
+			c.id, c.document_id, c.chunk_index, c.page_number, c.heading_path, c.content, c.token_count, c.embedding, c.embedding_model, c.index_version, c.created_at,
+			d.id, d.user_id, d.title, d.source, d.source_url, d.status, d.failure_reason, d.mime_type, d.tags, d.metadata, d.created_at, d.updated_at,
+			ts_rank_cd(c.content_tsv, q) AS score
+		FROM upload_document_chunks c
+		JOIN upload_documents d ON d.id = c.document_id
+		CROSS JOIN to_tsquery('simple', $1) q
+		WHERE d.user_id = $2 AND c.content_tsv @@ q
+	`
+	args := []any{tsQuery(terms), userID}
+	argPos := 3
+	if len(filter.Statuses) > 0 {
+		sqlQuery += ` AND d.status = ANY($` + itoa(argPos) + `)`
+		args = append(args, filter.Statuses)
+		argPos++
+	}
+	if len(filter.DocumentIDs) > 0 {
+		sqlQuery += ` AND c.document_id = ANY($` + itoa(argPos) + `)`
+		args = append(args, filter.DocumentIDs)
+		argPos++
+	}
+	scope, args, _ := postgresScope(filter, "d.", args, argPos)
+	sqlQuery += scope
+	sqlQuery += ` ORDER BY score DESC LIMIT 64`
+
+	rows, err := r.pool.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPostgresRetrievedChunks(rows)
+}
+
+func (r *PostgresChunkRepository) ListByDocument(ctx context.Context, docID uuid.UUID) ([]domain.DocumentChunk, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, document_id, chunk_index, page_number, heading_path, content, token_count, embedding, embedding_model, index_version, created_at
+		FROM upload_document_chunks
+		WHERE document_id = $1
+		ORDER BY chunk_index
+	`, docID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]domain.DocumentChunk, 0)
+	for rows.Next() {
+		var (
+			chunk        domain.DocumentChunk
+			embeddingRaw any
+		)
+		if err := rows.Scan(&chunk.ID, &chunk.DocumentID, &chunk.ChunkIndex, &chunk.PageNumber, &chunk.HeadingPath, &chunk.Content, &chunk.TokenCount, &embeddingRaw, &chunk.EmbeddingModel, &chunk.IndexVersion, &chunk.CreatedAt); err != nil {
+			return nil, err
+		}
+		parsed, err := normalizeEmbedding(embeddingRaw)
+		if err != nil {
+			return nil, err
+		}
+		chunk.Embedding = parsed
+		out = append(out, chunk)
+	}
+	return out, rows.Err()
+}
 
+func (r *PostgresChunkRepository) DeleteByDocument(ctx context.Context, docID uuid.UUID) error {
+	_, err := r.pool.Exec(ctx, `DELETE FROM upload_document_chunks WHERE document_id = $1`, docID)
+	return err
+}
+
+func (r *PostgresChunkRepository) ListStaleDocuments(ctx context.Context, userID int64, embeddingModel, indexVersion string) ([]uuid.UUID, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT DISTINCT c.document_id
+		FROM upload_document_chunks c
+		JOIN upload_documents d ON d.id = c.document_id
+		WHERE d.user_id = $1 AND (c.embedding_model <> $2 OR c.index_version <> $3)
+		ORDER BY c.document_id
+	`, userID, embeddingModel, indexVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func (r *PostgresChunkRepository) CountByUser(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(1)
+		FROM upload_document_chunks c
+		JOIN upload_documents d ON d.id = c.document_id
+		WHERE d.user_id = $1
+	`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresChunkRepository) CountByDocument(ctx context.Context, docID uuid.UUID) (int, error) {
+	var count int
+	err := r.pool.QueryRow(ctx, `SELECT COUNT(1) FROM upload_document_chunks WHERE document_id = $1`, docID).Scan(&count)
+	return count, err
+}
+
+var _ domain.ChunkRepository = (*PostgresChunkRepository)(nil)
+
+// scanPostgresRetrievedChunks reads chunk, document and score columns as
+// selected by the search queries.
+func scanPostgresRetrievedChunks(rows pgx.Rows) ([]domain.RetrievedChunk, error) {
 	var results []domain.RetrievedChunk
 	for rows.Next() {
 		var (
@@ -200,8 +791,8 @@ func (r *PostgresChunkRepository) SearchSimilar(ctx context.Context, userID int6
 			embeddingRaw  any
 		)
 		if err := rows.Scan(
-			&chunk.ID, &chunk.DocumentID, &chunk.ChunkIndex, &chunk.Content, &chunk.TokenCount, &embeddingRaw, &chunk.CreatedAt,
-			&doc.ID, &doc.UserID, &doc.Title, &doc.Source, &doc.Status, &failureReason, &doc.CreatedAt, &doc.UpdatedAt,
+			&chunk.ID, &chunk.DocumentID, &chunk.ChunkIndex, &chunk.PageNumber, &chunk.HeadingPath, &chunk.Content, &chunk.TokenCount, &embeddingRaw, &chunk.EmbeddingModel, &chunk.IndexVersion, &chunk.CreatedAt,
+			&doc.ID, &doc.UserID, &doc.Title, &doc.Source, &doc.SourceURL, &doc.Status, &failureReason, &doc.MimeType, &doc.Tags, &doc.Metadata, &doc.CreatedAt, &doc.UpdatedAt,
 			&score,
 		); err != nil {
 			return nil, err
@@ -222,8 +813,6 @@ func (r *PostgresChunkRepository) SearchSimilar(ctx context.Context, userID int6
 	return results, rows.Err()
 }
 
-var _ domain.ChunkRepository = (*PostgresChunkRepository)(nil)
-
 // PostgresQASessionRepository stores sessions.
 type PostgresQASessionRepository struct {
 	pool *pgxpool.Pool
@@ -236,21 +825,21 @@ func NewPostgresQASessionRepository(pool *pgxpool.Pool) *PostgresQASessionReposi
 
 func (r *PostgresQASessionRepository) Create(ctx context.Context, session domain.QASession) error {
 	_, err := r.pool.Exec(ctx, `
-		INSERT INTO upload_qa_sessions (id, user_id, created_at)
-		VALUES ($1, $2, $3)
-	`, session.ID, session.UserID, session.CreatedAt)
+		INSERT INTO upload_qa_sessions (id, user_id, collection_id, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, session.ID, session.UserID, session.CollectionID, session.CreatedAt)
 	return err
 }
 
 func (r *PostgresQASessionRepository) Find(ctx context.Context, id uuid.UUID, userID int64) (domain.QASession, bool, error) {
 	row := r.pool.QueryRow(ctx, `
-		SELECT id, user_id, created_at
+		SELECT id, user_id, collection_id, created_at
 		FROM upload_qa_sessions
 		WHERE id = $1 AND user_id = $2
 		LIMIT 1
 	`, id, userID)
 	var session domain.QASession
-	if err := row.Scan(&session.ID, &session.UserID, &session.CreatedAt); err != nil {
+	if err := row.Scan(&session.ID, &session.UserID, &session.CollectionID, &session.CreatedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			return domain.QASession{}, false, nil
 		}
@@ -261,7 +850,7 @@ func (r *PostgresQASessionRepository) Find(ctx context.Context, id uuid.UUID, us
 
 func (r *PostgresQASessionRepository) List(ctx context.Context, userID int64) ([]domain.QASession, error) {
 	rows, err := r.pool.Query(ctx, `
-		SELECT id, user_id, created_at
+		SELECT id, user_id, collection_id, created_at
 		FROM upload_qa_sessions
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -274,7 +863,7 @@ func (r *PostgresQASessionRepository) List(ctx context.Context, userID int64) ([
 	var sessions []domain.QASession
 	for rows.Next() {
 		var session domain.QASession
-		if err := rows.Scan(&session.ID, &session.UserID, &session.CreatedAt); err != nil {
+		if err := rows.Scan(&session.ID, &session.UserID, &session.CollectionID, &session.CreatedAt); err != nil {
 			return nil, err
 		}
 		sessions = append(sessions, session)
@@ -336,6 +925,47 @@ func (r *PostgresQueryLogRepository) ListBySession(ctx context.Context, sessionI
 
 var _ domain.QueryLogRepository = (*PostgresQueryLogRepository)(nil)
 
+// postgresScope appends the filter's label and creation time conditions on
+// the documents table, whose columns carry prefix, to args.
+func postgresScope(filter domain.DocumentFilter, prefix string, args []any, argPos int) (string, []any, int) {
+	var clause strings.Builder
+	add := func(condition string, arg any) {
+		clause.WriteString(` AND ` + strings.ReplaceAll(condition, "$?", "$"+itoa(argPos)))
+		args = append(args, arg)
+		argPos++
+	}
+	if len(filter.Tags) > 0 {
+		add(prefix+`tags @> $?`, filter.Tags)
+	}
+	if len(filter.Metadata) > 0 {
+		raw, _ := json.Marshal(filter.Metadata)
+		add(prefix+`metadata @> $?::jsonb`, string(raw))
+	}
+	if len(filter.MimeTypes) > 0 {
+		add(prefix+`mime_type = ANY($?)`, filter.MimeTypes)
+	}
+	if filter.CreatedAfter != nil {
+		add(prefix+`created_at >= $?`, *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		add(prefix+`created_at < $?`, *filter.CreatedBefore)
+	}
+	return clause.String(), args, argPos
+}
+
+// postgresLabels turns missing labels into the empty values the NOT NULL
+// columns expect.
+func postgresLabels(tags []string, metadata map[string]string) ([]string, []byte, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	rawMetadata, err := json.Marshal(metadata)
+	return tags, rawMetadata, err
+}
+
 func itoa(v int) string {
 	return strconv.Itoa(v)
 }
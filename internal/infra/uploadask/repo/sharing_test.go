@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
+)
+
+func TestMemoryRepositoriesEnforceSharing(t *testing.T) {
+	docs := NewMemoryDocumentRepository()
+	assertServiceEnforcesSharing(t, docs, NewMemoryFileRepository(), NewMemoryCollectionRepository(), NewMemoryShareRepository(),
+		NewMemoryChunkRepository(docs), NewMemoryQASessionRepository(), NewMemoryQueryLogRepository(), 3)
+}
+
+func TestSQLiteRepositoriesEnforceSharing(t *testing.T) {
+	db, err := sqliteinfra.Open(context.Background(), filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	assertServiceEnforcesSharing(t, NewSQLiteDocumentRepository(db), NewSQLiteFileRepository(db), NewSQLiteCollectionRepository(db), NewSQLiteShareRepository(db),
+		NewSQLiteChunkRepository(db, nil), NewSQLiteQASessionRepository(db), NewSQLiteQueryLogRepository(db), 3)
+}
+
+// TestPostgresRepositoriesEnforceSharing runs against a pgvector database when
+// UPLOADASK_TEST_POSTGRES_DSN is set; docs/upload-ask/schema.sql is applied first.
+func TestPostgresRepositoriesEnforceSharing(t *testing.T) {
+	dsn := os.Getenv("UPLOADASK_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("UPLOADASK_TEST_POSTGRES_DSN not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	defer pool.Close()
+	schema, err := os.ReadFile(filepath.Join("..", "..", "..", "..", "docs", "upload-ask", "schema.sql"))
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, string(schema))
+	require.NoError(t, err)
+	assertServiceEnforcesSharing(t, NewPostgresDocumentRepository(pool), NewPostgresFileRepository(pool), NewPostgresCollectionRepository(pool), NewPostgresShareRepository(pool),
+		NewPostgresChunkRepository(pool), NewPostgresQASessionRepository(pool), NewPostgresQueryLogRepository(pool), 1536)
+}
+
+// assertServiceEnforcesSharing runs the service's access checks on top of one
+// backend: what viewers and editors of a document or collection may do, what
+// they retrieve, and that revoking or deleting takes access away.
+func assertServiceEnforcesSharing(t *testing.T, docs domain.DocumentRepository, files domain.FileObjectRepository, collections domain.CollectionRepository, shares domain.ShareRepository, chunks domain.ChunkRepository, sessions domain.QASessionRepository, logs domain.QueryLogRepository, dim int) {
+	t.Helper()
+	ctx := context.Background()
+	svc := domain.NewService(domain.Config{}, docs, files, NewMemoryUploadIntentRepository(), collections, shares, chunks, sessions, logs,
+		nil, nil, discardStorage{}, fixedEmbedder{dim: dim}, echoLLM{}, nil, nil, nil, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	owner := time.Now().UnixNano()
+	viewer, editor, stranger := owner+1, owner+2, owner+3
+	sharedDoc := seedDeletableDocument(t, docs, files, chunks, owner, dim)
+	memberDoc := seedDeletableDocument(t, docs, files, chunks, owner, dim)
+
+	_, err := svc.Share(ctx, viewer, domain.ShareResourceDocument, sharedDoc, stranger, domain.ShareRoleViewer)
+	require.True(t, apperrors.IsCode(err, "not_found"), "only the owner may share: %v", err)
+	_, err = svc.Share(ctx, owner, domain.ShareResourceDocument, sharedDoc, viewer, domain.ShareRoleViewer)
+	require.NoError(t, err)
+	_, err = svc.Share(ctx, owner, domain.ShareResourceDocument, sharedDoc, editor, domain.ShareRoleEditor)
+	require.NoError(t, err)
+
+	_, err = svc.GetDocument(ctx, stranger, sharedDoc)
+	require.True(t, apperrors.IsCode(err, "not_found"), "unshared documents stay hidden: %v", err)
+	doc, err := svc.GetDocument(ctx, viewer, sharedDoc)
+	require.NoError(t, err)
+	require.Equal(t, owner, doc.UserID)
+	_, err = svc.GetDocument(ctx, viewer, memberDoc)
+	require.True(t, apperrors.IsCode(err, "not_found"), "%v", err)
+
+	tags := []string{"policy"}
+	_, err = svc.UpdateDocumentLabels(ctx, viewer, sharedDoc, domain.DocumentLabelsUpdate{Tags: &tags})
+	require.True(t, apperrors.IsCode(err, "forbidden"), "viewers cannot edit: %v", err)
+	doc, err = svc.UpdateDocumentLabels(ctx, editor, sharedDoc, domain.DocumentLabelsUpdate{Tags: &tags})
+	require.NoError(t, err)
+	require.Equal(t, tags, doc.Tags)
+	require.True(t, apperrors.IsCode(svc.DeleteDocument(ctx, editor, sharedDoc), "forbidden"), "only the owner may delete")
+
+	resp, err := svc.Ask(ctx, viewer, domain.AskRequest{Query: "retention"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uuid.UUID{sharedDoc}, sourceDocuments(resp.Sources))
+	resp, err = svc.Ask(ctx, stranger, domain.AskRequest{Query: "retention"})
+	require.NoError(t, err)
+	require.Empty(t, resp.Sources)
+
+	collection, err := svc.CreateCollection(ctx, owner, "Policies", "")
+	require.NoError(t, err)
+	_, err = svc.AddCollectionDocuments(ctx, owner, collection.ID, []uuid.UUID{memberDoc})
+	require.NoError(t, err)
+	_, err = svc.Share(ctx, owner, domain.ShareResourceCollection, collection.ID, viewer, domain.ShareRoleViewer)
+	require.NoError(t, err)
+
+	doc, err = svc.GetDocument(ctx, viewer, memberDoc)
+	require.NoError(t, err)
+	require.Equal(t, memberDoc, doc.ID)
+	name := "Renamed"
+	_, err = svc.UpdateCollection(ctx, viewer, collection.ID, domain.CollectionUpdate{Name: &name})
+	require.True(t, apperrors.IsCode(err, "forbidden"), "%v", err)
+	resp, err = svc.Ask(ctx, viewer, domain.AskRequest{Query: "retention"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uuid.UUID{sharedDoc, memberDoc}, sourceDocuments(resp.Sources))
+	resp, err = svc.Ask(ctx, viewer, domain.AskRequest{Query: "retention", CollectionID: &collection.ID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uuid.UUID{memberDoc}, sourceDocuments(resp.Sources))
+
+	shared, err := svc.ListShared(ctx, viewer)
+	require.NoError(t, err)
+	require.Len(t, shared.Documents, 1)
+	require.Equal(t, sharedDoc, shared.Documents[0].ID)
+	require.Len(t, shared.Collections, 1)
+	require.Equal(t, collection.ID, shared.Collections[0].ID)
+
+	require.NoError(t, svc.Unshare(ctx, viewer, domain.ShareResourceDocument, sharedDoc, viewer))
+	_, err = svc.GetDocument(ctx, viewer, sharedDoc)
+	require.True(t, apperrors.IsCode(err, "not_found"), "%v", err)
+
+	require.NoError(t, svc.DeleteCollection(ctx, owner, collection.ID))
+	_, err = svc.GetDocument(ctx, viewer, memberDoc)
+	require.True(t, apperrors.IsCode(err, "not_found"), "%v", err)
+	require.NoError(t, svc.DeleteDocument(ctx, owner, sharedDoc))
+	for _, user := range []int64{viewer, editor} {
+		remaining, err := shares.ListByUser(ctx, user)
+		require.NoError(t, err)
+		require.Empty(t, remaining, "deleting a resource drops its shares")
+	}
+}
+
+func sourceDocuments(sources []domain.ChunkSource) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(sources))
+	for _, source := range sources {
+		ids = append(ids, source.DocumentID)
+	}
+	return ids
+}
+
+type fixedEmbedder struct {
+	dim int
+}
+
+func (e fixedEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i := range texts {
+		out[i] = testEmbedding(e.dim)
+	}
+	return out, nil
+}
+
+type echoLLM struct{}
+
+func (echoLLM) Chat(_ context.Context, _ []domain.LLMMessage) (string, error) {
+	return "answer", nil
+}
+
+func (echoLLM) ChatStream(_ context.Context, _ []domain.LLMMessage) (<-chan domain.LLMStreamChunk, error) {
+	out := make(chan domain.LLMStreamChunk, 1)
+	out <- domain.LLMStreamChunk{Delta: "answer"}
+	close(out)
+	return out, nil
+}
+
+type discardStorage struct{}
+
+func (discardStorage) Put(_ context.Context, key string, body io.Reader, mimeType string) (domain.StoredObject, error) {
+	n, err := io.Copy(io.Discard, body)
+	return domain.StoredObject{Key: key, Size: n, MimeType: mimeType}, err
+}
+
+func (discardStorage) Get(_ context.Context, _ string) (io.ReadCloser, error) {
+	return nil, os.ErrNotExist
+}
+
+func (discardStorage) Delete(_ context.Context, _ string) error {
+	return nil
+}
@@ -8,11 +8,14 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
 	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
+	"github.com/yanqian/ai-helloworld/internal/infra/uploadask/vectorindex"
 )
 
 // SQLiteDocumentRepository persists upload documents in SQLite.
@@ -26,10 +29,14 @@ func NewSQLiteDocumentRepository(db *sql.DB) *SQLiteDocumentRepository {
 }
 
 func (r *SQLiteDocumentRepository) Create(ctx context.Context, doc domain.Document) error {
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO upload_documents (id, user_id, title, source, status, failure_reason, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, doc.ID.String(), doc.UserID, doc.Title, string(doc.Source), string(doc.Status), doc.FailureReason, formatSQLiteTime(doc.CreatedAt), formatSQLiteTime(doc.UpdatedAt))
+	tags, metadata, err := encodeSQLiteLabels(doc.Tags, doc.Metadata)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO upload_documents (id, user_id, title, source, source_url, status, failure_reason, mime_type, tags, metadata, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, doc.ID.String(), doc.UserID, doc.Title, string(doc.Source), doc.SourceURL, string(doc.Status), doc.FailureReason, doc.MimeType, tags, metadata, formatSQLiteTime(doc.CreatedAt), formatSQLiteTime(doc.UpdatedAt))
 	return err
 }
 
@@ -42,9 +49,23 @@ func (r *SQLiteDocumentRepository) UpdateStatus(ctx context.Context, docID uuid.
 	return err
 }
 
+// UpdateProgress stores the progress as JSON and bumps updated_at.
+func (r *SQLiteDocumentRepository) UpdateProgress(ctx context.Context, docID uuid.UUID, progress domain.DocumentProgress) error {
+	payload, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE upload_documents
+		SET progress = ?, updated_at = ?
+		WHERE id = ?
+	`, string(payload), formatSQLiteTime(time.Now().UTC()), docID.String())
+	return err
+}
+
 func (r *SQLiteDocumentRepository) Get(ctx context.Context, docID uuid.UUID, userID int64) (domain.Document, bool, error) {
 	return scanSQLiteDocument(r.db.QueryRowContext(ctx, `
-		SELECT id, user_id, title, source, status, failure_reason, created_at, updated_at
+		SELECT id, user_id, title, source, source_url, status, failure_reason, mime_type, tags, metadata, progress, created_at, updated_at
 		FROM upload_documents
 		WHERE id = ? AND user_id = ?
 		LIMIT 1
@@ -53,7 +74,7 @@ func (r *SQLiteDocumentRepository) Get(ctx context.Context, docID uuid.UUID, use
 
 func (r *SQLiteDocumentRepository) List(ctx context.Context, userID int64, filter domain.DocumentFilter) ([]domain.Document, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, user_id, title, source, status, failure_reason, created_at, updated_at
+		SELECT id, user_id, title, source, source_url, status, failure_reason, mime_type, tags, metadata, progress, created_at, updated_at
 		FROM upload_documents
 		WHERE user_id = ?
 		ORDER BY created_at DESC
@@ -77,11 +98,132 @@ func (r *SQLiteDocumentRepository) List(ctx context.Context, userID int64, filte
 		if len(allowedStatuses) > 0 && !allowedStatuses[doc.Status] {
 			continue
 		}
+		if !filter.MatchesScope(doc) {
+			continue
+		}
+		out = append(out, doc)
+	}
+	return out, rows.Err()
+}
+
+// Delete removes the document row. File metadata and chunks cascade.
+func (r *SQLiteDocumentRepository) Delete(ctx context.Context, docID uuid.UUID, userID int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM upload_documents
+		WHERE id = ? AND user_id = ?
+	`, docID.String(), userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteDocumentRepository) ListStale(ctx context.Context, statuses []domain.DocumentStatus, updatedBefore time.Time) ([]domain.Document, error) {
+	if len(statuses) == 0 {
+		return []domain.Document{}, nil
+	}
+	placeholders := make([]string, len(statuses))
+	args := make([]any, 0, len(statuses)+1)
+	for i, status := range statuses {
+		placeholders[i] = "?"
+		args = append(args, string(status))
+	}
+	args = append(args, formatSQLiteTime(updatedBefore.UTC()))
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, title, source, source_url, status, failure_reason, mime_type, tags, metadata, progress, created_at, updated_at
+		FROM upload_documents
+		WHERE status IN (`+strings.Join(placeholders, ", ")+`) AND updated_at < ?
+		ORDER BY updated_at
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]domain.Document, 0)
+	for rows.Next() {
+		doc, err := scanSQLiteDocumentRow(rows)
+		if err != nil {
+			return nil, err
+		}
 		out = append(out, doc)
 	}
 	return out, rows.Err()
 }
 
+func (r *SQLiteDocumentRepository) IncrementRecoveryAttempts(ctx context.Context, docID uuid.UUID) (int, error) {
+	var attempts int
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE upload_documents
+		SET recovery_attempts = recovery_attempts + 1, updated_at = ?
+		WHERE id = ?
+		RETURNING recovery_attempts
+	`, formatSQLiteTime(time.Now().UTC()), docID.String()).Scan(&attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return attempts, err
+}
+
+// UpdateLabels stores tags and metadata as JSON and bumps updated_at.
+func (r *SQLiteDocumentRepository) UpdateLabels(ctx context.Context, docID uuid.UUID, userID int64, tags []string, metadata map[string]string) (bool, error) {
+	rawTags, rawMetadata, err := encodeSQLiteLabels(tags, metadata)
+	if err != nil {
+		return false, err
+	}
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE upload_documents
+		SET tags = ?, metadata = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?
+	`, rawTags, rawMetadata, formatSQLiteTime(time.Now().UTC()), docID.String(), userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteDocumentRepository) OwnerIDs(ctx context.Context, docIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	out := make(map[uuid.UUID]int64, len(docIDs))
+	if len(docIDs) == 0 {
+		return out, nil
+	}
+	args := make([]any, 0, len(docIDs))
+	for _, docID := range docIDs {
+		args = append(args, docID.String())
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id
+		FROM upload_documents
+		WHERE id IN (`+placeholders(len(docIDs))+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			raw    string
+			userID int64
+		)
+		if err := rows.Scan(&raw, &userID); err != nil {
+			return nil, err
+		}
+		docID, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		out[docID] = userID
+	}
+	return out, rows.Err()
+}
+
 var _ domain.DocumentRepository = (*SQLiteDocumentRepository)(nil)
 
 // SQLiteFileRepository persists upload file metadata in SQLite.
@@ -96,59 +238,472 @@ func NewSQLiteFileRepository(db *sql.DB) *SQLiteFileRepository {
 
 func (r *SQLiteFileRepository) Create(ctx context.Context, file domain.FileObject) error {
 	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO upload_file_objects (id, document_id, storage_key, size_bytes, mime_type, etag, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, file.ID.String(), file.DocumentID.String(), file.StorageKey, file.SizeBytes, file.MimeType, file.ETag, formatSQLiteTime(file.CreatedAt))
+		INSERT INTO upload_file_objects (id, document_id, user_id, storage_key, size_bytes, mime_type, etag, content_hash, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, file.ID.String(), file.DocumentID.String(), file.UserID, file.StorageKey, file.SizeBytes, file.MimeType, file.ETag, file.ContentHash, formatSQLiteTime(file.CreatedAt))
 	return err
 }
 
 func (r *SQLiteFileRepository) FindByDocument(ctx context.Context, docID uuid.UUID) (domain.FileObject, bool, error) {
-	row := r.db.QueryRowContext(ctx, `
-		SELECT id, document_id, storage_key, size_bytes, mime_type, etag, created_at
+	file, err := scanSQLiteFile(r.db.QueryRowContext(ctx, `
+		SELECT id, document_id, user_id, storage_key, size_bytes, mime_type, etag, content_hash, created_at
 		FROM upload_file_objects
 		WHERE document_id = ?
 		LIMIT 1
-	`, docID.String())
+	`, docID.String()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.FileObject{}, false, nil
+	}
+	if err != nil {
+		return domain.FileObject{}, false, err
+	}
+	return file, true, nil
+}
+
+func (r *SQLiteFileRepository) ListByContentHash(ctx context.Context, hash string) ([]domain.FileObject, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, document_id, user_id, storage_key, size_bytes, mime_type, etag, content_hash, created_at
+		FROM upload_file_objects
+		WHERE content_hash = ?
+		ORDER BY created_at DESC
+	`, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]domain.FileObject, 0)
+	for rows.Next() {
+		file, err := scanSQLiteFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, file)
+	}
+	return out, rows.Err()
+}
+
+func scanSQLiteFile(row interface{ Scan(...any) error }) (domain.FileObject, error) {
 	var (
 		file      domain.FileObject
 		id        string
 		document  string
 		createdAt string
 	)
-	if err := row.Scan(&id, &document, &file.StorageKey, &file.SizeBytes, &file.MimeType, &file.ETag, &createdAt); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return domain.FileObject{}, false, nil
-		}
-		return domain.FileObject{}, false, err
+	if err := row.Scan(&id, &document, &file.UserID, &file.StorageKey, &file.SizeBytes, &file.MimeType, &file.ETag, &file.ContentHash, &createdAt); err != nil {
+		return domain.FileObject{}, err
 	}
 	parsedID, err := uuid.Parse(id)
 	if err != nil {
-		return domain.FileObject{}, false, err
+		return domain.FileObject{}, err
 	}
 	parsedDocumentID, err := uuid.Parse(document)
 	if err != nil {
-		return domain.FileObject{}, false, err
+		return domain.FileObject{}, err
 	}
 	created, err := parseSQLiteTime(createdAt)
 	if err != nil {
-		return domain.FileObject{}, false, err
+		return domain.FileObject{}, err
 	}
 	file.ID = parsedID
 	file.DocumentID = parsedDocumentID
 	file.CreatedAt = created
-	return file, true, nil
+	return file, nil
+}
+
+func (r *SQLiteFileRepository) UsageByUser(ctx context.Context, userID int64) (domain.StorageUsage, error) {
+	var usage domain.StorageUsage
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(1), COALESCE(SUM(size_bytes), 0)
+		FROM upload_file_objects
+		WHERE user_id = ?
+	`, userID).Scan(&usage.Documents, &usage.Bytes)
+	return usage, err
+}
+
+func (r *SQLiteFileRepository) DeleteByDocument(ctx context.Context, docID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM upload_file_objects WHERE document_id = ?`, docID.String())
+	return err
 }
 
 var _ domain.FileObjectRepository = (*SQLiteFileRepository)(nil)
 
-// SQLiteChunkRepository stores chunks and performs local similarity search.
-type SQLiteChunkRepository struct {
+// SQLiteUploadIntentRepository persists direct upload intents in SQLite.
+type SQLiteUploadIntentRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteUploadIntentRepository constructs a SQLite-backed intent repository.
+func NewSQLiteUploadIntentRepository(db *sql.DB) *SQLiteUploadIntentRepository {
+	return &SQLiteUploadIntentRepository{db: db}
+}
+
+func (r *SQLiteUploadIntentRepository) Create(ctx context.Context, intent domain.UploadIntent) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO upload_intents (id, user_id, filename, title, mime_type, size_bytes, storage_key, status, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, intent.ID.String(), intent.UserID, intent.Filename, intent.Title, intent.MimeType, intent.SizeBytes, intent.StorageKey, string(intent.Status), formatSQLiteTime(intent.ExpiresAt), formatSQLiteTime(intent.CreatedAt))
+	return err
+}
+
+func (r *SQLiteUploadIntentRepository) Get(ctx context.Context, id uuid.UUID, userID int64) (domain.UploadIntent, bool, error) {
+	return r.find(ctx, `id = ? AND user_id = ?`, id.String(), userID)
+}
+
+func (r *SQLiteUploadIntentRepository) GetByStorageKey(ctx context.Context, key string) (domain.UploadIntent, bool, error) {
+	return r.find(ctx, `storage_key = ?`, key)
+}
+
+func (r *SQLiteUploadIntentRepository) find(ctx context.Context, where string, args ...any) (domain.UploadIntent, bool, error) {
+	var (
+		intent    domain.UploadIntent
+		id        string
+		status    string
+		expiresAt string
+		createdAt string
+	)
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, user_id, filename, title, mime_type, size_bytes, storage_key, status, expires_at, created_at
+		FROM upload_intents
+		WHERE `+where, args...).Scan(&id, &intent.UserID, &intent.Filename, &intent.Title, &intent.MimeType, &intent.SizeBytes, &intent.StorageKey, &status, &expiresAt, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.UploadIntent{}, false, nil
+	}
+	if err != nil {
+		return domain.UploadIntent{}, false, err
+	}
+	if intent.ID, err = uuid.Parse(id); err != nil {
+		return domain.UploadIntent{}, false, err
+	}
+	if intent.ExpiresAt, err = parseSQLiteTime(expiresAt); err != nil {
+		return domain.UploadIntent{}, false, err
+	}
+	if intent.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+		return domain.UploadIntent{}, false, err
+	}
+	intent.Status = domain.UploadIntentStatus(status)
+	return intent, true, nil
+}
+
+func (r *SQLiteUploadIntentRepository) MarkConfirmed(ctx context.Context, id uuid.UUID) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE upload_intents SET status = ? WHERE id = ? AND status = ?
+	`, string(domain.UploadIntentConfirmed), id.String(), string(domain.UploadIntentPending))
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+var _ domain.UploadIntentRepository = (*SQLiteUploadIntentRepository)(nil)
+
+// SQLiteCollectionRepository persists collections in SQLite.
+type SQLiteCollectionRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteCollectionRepository constructs a SQLite-backed collection repository.
+func NewSQLiteCollectionRepository(db *sql.DB) *SQLiteCollectionRepository {
+	return &SQLiteCollectionRepository{db: db}
+}
+
+func (r *SQLiteCollectionRepository) Create(ctx context.Context, collection domain.Collection) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO upload_collections (id, user_id, name, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, collection.ID.String(), collection.UserID, collection.Name, collection.Description, formatSQLiteTime(collection.CreatedAt), formatSQLiteTime(collection.UpdatedAt))
+	return err
+}
+
+func (r *SQLiteCollectionRepository) Get(ctx context.Context, id uuid.UUID, userID int64) (domain.Collection, bool, error) {
+	collection, err := scanSQLiteCollection(r.db.QueryRowContext(ctx, `
+		SELECT c.id, c.user_id, c.name, c.description, c.created_at, c.updated_at,
+			(SELECT COUNT(*) FROM upload_collection_documents m WHERE m.collection_id = c.id)
+		FROM upload_collections c
+		WHERE c.id = ? AND c.user_id = ?
+	`, id.String(), userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Collection{}, false, nil
+	}
+	if err != nil {
+		return domain.Collection{}, false, err
+	}
+	return collection, true, nil
+}
+
+func (r *SQLiteCollectionRepository) List(ctx context.Context, userID int64) ([]domain.Collection, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT c.id, c.user_id, c.name, c.description, c.created_at, c.updated_at,
+			(SELECT COUNT(*) FROM upload_collection_documents m WHERE m.collection_id = c.id)
+		FROM upload_collections c
+		WHERE c.user_id = ?
+		ORDER BY c.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]domain.Collection, 0)
+	for rows.Next() {
+		collection, err := scanSQLiteCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, collection)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteCollectionRepository) Update(ctx context.Context, collection domain.Collection) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE upload_collections
+		SET name = ?, description = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?
+	`, collection.Name, collection.Description, formatSQLiteTime(collection.UpdatedAt), collection.ID.String(), collection.UserID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Delete removes the collection row. Memberships cascade.
+func (r *SQLiteCollectionRepository) Delete(ctx context.Context, id uuid.UUID, userID int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM upload_collections
+		WHERE id = ? AND user_id = ?
+	`, id.String(), userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteCollectionRepository) AddDocuments(ctx context.Context, collectionID uuid.UUID, docIDs []uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	addedAt := formatSQLiteTime(time.Now().UTC())
+	for _, docID := range docIDs {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO upload_collection_documents (collection_id, document_id, added_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT(collection_id, document_id) DO NOTHING
+		`, collectionID.String(), docID.String(), addedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SQLiteCollectionRepository) RemoveDocuments(ctx context.Context, collectionID uuid.UUID, docIDs []uuid.UUID) error {
+	if len(docIDs) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(docIDs)+1)
+	args = append(args, collectionID.String())
+	for _, docID := range docIDs {
+		args = append(args, docID.String())
+	}
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM upload_collection_documents
+		WHERE collection_id = ? AND document_id IN (`+placeholders(len(docIDs))+`)
+	`, args...)
+	return err
+}
+
+func (r *SQLiteCollectionRepository) DocumentIDs(ctx context.Context, collectionID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT document_id
+		FROM upload_collection_documents
+		WHERE collection_id = ?
+		ORDER BY added_at, document_id
+	`, collectionID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteCollectionRepository) DocumentIDsIn(ctx context.Context, collectionIDs []uuid.UUID) ([]uuid.UUID, error) {
+	out := make([]uuid.UUID, 0)
+	if len(collectionIDs) == 0 {
+		return out, nil
+	}
+	args := make([]any, 0, len(collectionIDs))
+	for _, collectionID := range collectionIDs {
+		args = append(args, collectionID.String())
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT DISTINCT document_id
+		FROM upload_collection_documents
+		WHERE collection_id IN (`+placeholders(len(collectionIDs))+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteCollectionRepository) DeleteByDocument(ctx context.Context, docID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM upload_collection_documents WHERE document_id = ?`, docID.String())
+	return err
+}
+
+var _ domain.CollectionRepository = (*SQLiteCollectionRepository)(nil)
+
+// SQLiteShareRepository persists shares in SQLite.
+type SQLiteShareRepository struct {
 	db *sql.DB
 }
 
-// NewSQLiteChunkRepository constructs a SQLite-backed chunk repository.
-func NewSQLiteChunkRepository(db *sql.DB) *SQLiteChunkRepository {
-	return &SQLiteChunkRepository{db: db}
+// NewSQLiteShareRepository constructs a SQLite-backed share repository.
+func NewSQLiteShareRepository(db *sql.DB) *SQLiteShareRepository {
+	return &SQLiteShareRepository{db: db}
+}
+
+func (r *SQLiteShareRepository) Upsert(ctx context.Context, share domain.Share) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO upload_shares (resource_type, resource_id, owner_id, user_id, role, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(resource_type, resource_id, user_id) DO UPDATE SET
+			role = excluded.role,
+			updated_at = excluded.updated_at
+	`, string(share.ResourceType), share.ResourceID.String(), share.OwnerID, share.UserID, string(share.Role),
+		formatSQLiteTime(share.CreatedAt), formatSQLiteTime(share.UpdatedAt))
+	return err
+}
+
+func (r *SQLiteShareRepository) Get(ctx context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID, userID int64) (domain.Share, bool, error) {
+	share, err := scanSQLiteShare(r.db.QueryRowContext(ctx, `
+		SELECT resource_type, resource_id, owner_id, user_id, role, created_at, updated_at
+		FROM upload_shares
+		WHERE resource_type = ? AND resource_id = ? AND user_id = ?
+	`, string(resourceType), resourceID.String(), userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Share{}, false, nil
+	}
+	if err != nil {
+		return domain.Share{}, false, err
+	}
+	return share, true, nil
+}
+
+func (r *SQLiteShareRepository) ListByResource(ctx context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID) ([]domain.Share, error) {
+	return r.list(ctx, `
+		SELECT resource_type, resource_id, owner_id, user_id, role, created_at, updated_at
+		FROM upload_shares
+		WHERE resource_type = ? AND resource_id = ?
+		ORDER BY created_at, user_id
+	`, string(resourceType), resourceID.String())
+}
+
+func (r *SQLiteShareRepository) ListByUser(ctx context.Context, userID int64) ([]domain.Share, error) {
+	return r.list(ctx, `
+		SELECT resource_type, resource_id, owner_id, user_id, role, created_at, updated_at
+		FROM upload_shares
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`, userID)
+}
+
+func (r *SQLiteShareRepository) list(ctx context.Context, query string, args ...any) ([]domain.Share, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]domain.Share, 0)
+	for rows.Next() {
+		share, err := scanSQLiteShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, share)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteShareRepository) Delete(ctx context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID, userID int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM upload_shares
+		WHERE resource_type = ? AND resource_id = ? AND user_id = ?
+	`, string(resourceType), resourceID.String(), userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *SQLiteShareRepository) DeleteByResource(ctx context.Context, resourceType domain.ShareResourceType, resourceID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM upload_shares
+		WHERE resource_type = ? AND resource_id = ?
+	`, string(resourceType), resourceID.String())
+	return err
+}
+
+var _ domain.ShareRepository = (*SQLiteShareRepository)(nil)
+
+// sqliteMaxResults caps the chunks a SQLite search returns.
+const sqliteMaxResults = 64
+
+// SQLiteChunkRepository stores chunks and performs local similarity search,
+// through the vector index when it has one and by scanning every chunk
+// otherwise.
+type SQLiteChunkRepository struct {
+	db    *sql.DB
+	index *vectorindex.Index
+
+	syncMu     sync.Mutex
+	reconciled bool
+}
+
+// NewSQLiteChunkRepository constructs a SQLite-backed chunk repository. A nil
+// index searches by exact scan.
+func NewSQLiteChunkRepository(db *sql.DB, index *vectorindex.Index) *SQLiteChunkRepository {
+	return &SQLiteChunkRepository{db: db, index: index}
 }
 
 func (r *SQLiteChunkRepository) InsertBatch(ctx context.Context, chunks []domain.DocumentChunk) error {
@@ -161,30 +716,49 @@ func (r *SQLiteChunkRepository) InsertBatch(ctx context.Context, chunks []domain
 	}
 	defer tx.Rollback()
 	stmt, err := tx.PrepareContext(ctx, `
-		INSERT INTO upload_document_chunks (id, document_id, chunk_index, content, token_count, embedding, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO upload_document_chunks (id, document_id, chunk_index, page_number, heading_path, content, token_count, embedding, embedding_model, index_version, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 	for _, chunk := range chunks {
-		payload, err := json.Marshal(chunk.Embedding)
+		headingPath, err := encodeHeadingPath(chunk.HeadingPath)
 		if err != nil {
 			return err
 		}
-		if _, err := stmt.ExecContext(ctx, chunk.ID.String(), chunk.DocumentID.String(), chunk.ChunkIndex, chunk.Content, chunk.TokenCount, string(payload), formatSQLiteTime(chunk.CreatedAt)); err != nil {
+		if _, err := stmt.ExecContext(ctx, chunk.ID.String(), chunk.DocumentID.String(), chunk.ChunkIndex, chunk.PageNumber, headingPath, chunk.Content, chunk.TokenCount, sqliteinfra.EncodeVector(chunk.Embedding), chunk.EmbeddingModel, chunk.IndexVersion, formatSQLiteTime(chunk.CreatedAt)); err != nil {
 			return err
 		}
 	}
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if r.index != nil {
+		// The chunks are stored; if this fails the next search catches up.
+		_ = r.syncIndex(ctx)
+	}
+	return nil
 }
 
 func (r *SQLiteChunkRepository) SearchSimilar(ctx context.Context, userID int64, embedding []float32, filter domain.DocumentFilter) ([]domain.RetrievedChunk, error) {
+	// Document and label filters select few chunks, which an exact scan
+	// handles well, and would empty an index result they do not match.
+	if r.index != nil && len(filter.DocumentIDs) == 0 && !filter.HasScope() {
+		results, ok, err := r.searchIndexed(ctx, userID, embedding, filter)
+		if err != nil || ok {
+			return results, err
+		}
+	}
+	return r.searchExact(ctx, userID, embedding, filter)
+}
+
+func (r *SQLiteChunkRepository) searchExact(ctx context.Context, userID int64, embedding []float32, filter domain.DocumentFilter) ([]domain.RetrievedChunk, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT
-			c.id, c.document_id, c.chunk_index, c.content, c.token_count, c.embedding, c.created_at,
-			d.id, d.user_id, d.title, d.source, d.status, d.failure_reason, d.created_at, d.updated_at
+			c.id, c.document_id, c.chunk_index, c.page_number, c.heading_path, c.content, c.token_count, c.embedding, c.embedding_model, c.index_version, c.created_at,
+			d.id, d.user_id, d.title, d.source, d.source_url, d.status, d.failure_reason, d.mime_type, d.tags, d.metadata, d.created_at, d.updated_at
 		FROM upload_document_chunks c
 		JOIN upload_documents d ON d.id = c.document_id
 		WHERE d.user_id = ?
@@ -208,6 +782,9 @@ func (r *SQLiteChunkRepository) SearchSimilar(ctx context.Context, userID int64,
 		if len(allowedStatuses) > 0 && !allowedStatuses[doc.Status] {
 			continue
 		}
+		if !filter.MatchesScope(doc) {
+			continue
+		}
 		results = append(results, domain.RetrievedChunk{
 			Chunk:     chunk,
 			Document:  doc,
@@ -218,16 +795,160 @@ func (r *SQLiteChunkRepository) SearchSimilar(ctx context.Context, userID int64,
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	return rankSimilar(results), nil
+}
+
+// rankSimilar orders results by score, newest first on ties, and caps them
+// at sqliteMaxResults.
+func rankSimilar(results []domain.RetrievedChunk) []domain.RetrievedChunk {
 	sort.SliceStable(results, func(i, j int) bool {
 		if results[i].Score == results[j].Score {
 			return results[i].CreatedAt.After(results[j].CreatedAt)
 		}
 		return results[i].Score > results[j].Score
 	})
-	if len(results) > 64 {
-		results = results[:64]
+	if len(results) > sqliteMaxResults {
+		results = results[:sqliteMaxResults]
 	}
-	return results, nil
+	return results
+}
+
+func (r *SQLiteChunkRepository) SearchLexical(ctx context.Context, userID int64, query string, filter domain.DocumentFilter) ([]domain.RetrievedChunk, error) {
+	terms := lexicalTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+			c.id, c.document_id, c.chunk_index, c.page_number, c.heading_path, c.content, c.token_count, c.embedding, c.embedding_model, c.index_version, c.created_at,
+			d.id, d.user_id, d.title, d.source, d.source_url, d.status, d.failure_reason, d.mime_type, d.tags, d.metadata, d.created_at, d.updated_at,
+			bm25(upload_document_chunks_fts) AS rank
+		FROM upload_document_chunks_fts f
+		JOIN upload_document_chunks c ON c.id = f.chunk_id
+		JOIN upload_documents d ON d.id = c.document_id
+		WHERE upload_document_chunks_fts MATCH ? AND d.user_id = ?
+		ORDER BY rank
+	`, ftsMatchQuery(terms), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	allowedDocs := uuidSet(filter.DocumentIDs)
+	allowedStatuses := statusSet(filter.Statuses)
+	results := make([]domain.RetrievedChunk, 0)
+	for rows.Next() {
+		var rank float64
+		chunk, doc, err := scanSQLiteRetrievedChunk(rows, &rank)
+		if err != nil {
+			return nil, err
+		}
+		if len(allowedDocs) > 0 && !allowedDocs[doc.ID] {
+			continue
+		}
+		if len(allowedStatuses) > 0 && !allowedStatuses[doc.Status] {
+			continue
+		}
+		if !filter.MatchesScope(doc) {
+			continue
+		}
+		// FTS5 bm25() is lower-is-better; negate it so higher scores rank first.
+		results = append(results, domain.RetrievedChunk{
+			Chunk:     chunk,
+			Document:  doc,
+			Score:     -rank,
+			CreatedAt: chunk.CreatedAt,
+		})
+		if len(results) == sqliteMaxResults {
+			break
+		}
+	}
+	return results, rows.Err()
+}
+
+func (r *SQLiteChunkRepository) ListByDocument(ctx context.Context, docID uuid.UUID) ([]domain.DocumentChunk, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+			c.id, c.document_id, c.chunk_index, c.page_number, c.heading_path, c.content, c.token_count, c.embedding, c.embedding_model, c.index_version, c.created_at,
+			d.id, d.user_id, d.title, d.source, d.source_url, d.status, d.failure_reason, d.mime_type, d.tags, d.metadata, d.created_at, d.updated_at
+		FROM upload_document_chunks c
+		JOIN upload_documents d ON d.id = c.document_id
+		WHERE c.document_id = ?
+		ORDER BY c.chunk_index
+	`, docID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]domain.DocumentChunk, 0)
+	for rows.Next() {
+		chunk, _, err := scanSQLiteRetrievedChunk(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, chunk)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteChunkRepository) DeleteByDocument(ctx context.Context, docID uuid.UUID) error {
+	if r.index == nil {
+		_, err := r.db.ExecContext(ctx, `DELETE FROM upload_document_chunks WHERE document_id = ?`, docID.String())
+		return err
+	}
+	userID, ids, err := r.documentChunkIDs(ctx, docID)
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM upload_document_chunks WHERE document_id = ?`, docID.String()); err != nil {
+		return err
+	}
+	r.index.Remove(userID, ids...)
+	return nil
+}
+
+func (r *SQLiteChunkRepository) ListStaleDocuments(ctx context.Context, userID int64, embeddingModel, indexVersion string) ([]uuid.UUID, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT DISTINCT c.document_id
+		FROM upload_document_chunks c
+		JOIN upload_documents d ON d.id = c.document_id
+		WHERE d.user_id = ? AND (c.embedding_model <> ? OR c.index_version <> ?)
+		ORDER BY c.document_id
+	`, userID, embeddingModel, indexVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]uuid.UUID, 0)
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+func (r *SQLiteChunkRepository) CountByUser(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(1)
+		FROM upload_document_chunks c
+		JOIN upload_documents d ON d.id = c.document_id
+		WHERE d.user_id = ?
+	`, userID).Scan(&count)
+	return count, err
+}
+
+func (r *SQLiteChunkRepository) CountByDocument(ctx context.Context, docID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(1) FROM upload_document_chunks WHERE document_id = ?`, docID.String()).Scan(&count)
+	return count, err
 }
 
 var _ domain.ChunkRepository = (*SQLiteChunkRepository)(nil)
@@ -243,16 +964,21 @@ func NewSQLiteQASessionRepository(db *sql.DB) *SQLiteQASessionRepository {
 }
 
 func (r *SQLiteQASessionRepository) Create(ctx context.Context, session domain.QASession) error {
+	var collectionID *string
+	if session.CollectionID != nil {
+		id := session.CollectionID.String()
+		collectionID = &id
+	}
 	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO upload_qa_sessions (id, user_id, created_at)
-		VALUES (?, ?, ?)
-	`, session.ID.String(), session.UserID, formatSQLiteTime(session.CreatedAt))
+		INSERT INTO upload_qa_sessions (id, user_id, collection_id, created_at)
+		VALUES (?, ?, ?, ?)
+	`, session.ID.String(), session.UserID, collectionID, formatSQLiteTime(session.CreatedAt))
 	return err
 }
 
 func (r *SQLiteQASessionRepository) Find(ctx context.Context, id uuid.UUID, userID int64) (domain.QASession, bool, error) {
 	return scanSQLiteSession(r.db.QueryRowContext(ctx, `
-		SELECT id, user_id, created_at
+		SELECT id, user_id, collection_id, created_at
 		FROM upload_qa_sessions
 		WHERE id = ? AND user_id = ?
 		LIMIT 1
@@ -261,7 +987,7 @@ func (r *SQLiteQASessionRepository) Find(ctx context.Context, id uuid.UUID, user
 
 func (r *SQLiteQASessionRepository) List(ctx context.Context, userID int64) ([]domain.QASession, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, user_id, created_at
+		SELECT id, user_id, collection_id, created_at
 		FROM upload_qa_sessions
 		WHERE user_id = ?
 		ORDER BY created_at DESC
@@ -352,12 +1078,19 @@ func scanSQLiteDocumentRow(row sqliteDocumentScanner) (domain.Document, error) {
 		doc           domain.Document
 		id            string
 		source        string
+		sourceURL     sql.NullString
 		status        string
 		failureReason sql.NullString
+		tags          string
+		metadata      string
+		progress      sql.NullString
 		createdAt     string
 		updatedAt     string
 	)
-	if err := row.Scan(&id, &doc.UserID, &doc.Title, &source, &status, &failureReason, &createdAt, &updatedAt); err != nil {
+	if err := row.Scan(&id, &doc.UserID, &doc.Title, &source, &sourceURL, &status, &failureReason, &doc.MimeType, &tags, &metadata, &progress, &createdAt, &updatedAt); err != nil {
+		return domain.Document{}, err
+	}
+	if err := decodeSQLiteLabels(tags, metadata, &doc); err != nil {
 		return domain.Document{}, err
 	}
 	parsedID, err := uuid.Parse(id)
@@ -377,32 +1110,47 @@ func scanSQLiteDocumentRow(row sqliteDocumentScanner) (domain.Document, error) {
 	doc.Status = domain.DocumentStatus(status)
 	doc.CreatedAt = created
 	doc.UpdatedAt = updated
+	if sourceURL.Valid {
+		url := sourceURL.String
+		doc.SourceURL = &url
+	}
 	if failureReason.Valid {
 		reason := failureReason.String
 		doc.FailureReason = &reason
 	}
+	if progress.Valid && progress.String != "" {
+		doc.Progress = &domain.DocumentProgress{}
+		if err := json.Unmarshal([]byte(progress.String), doc.Progress); err != nil {
+			return domain.Document{}, err
+		}
+	}
 	return doc, nil
 }
 
-func scanSQLiteRetrievedChunk(row sqliteDocumentScanner) (domain.DocumentChunk, domain.Document, error) {
+func scanSQLiteRetrievedChunk(row sqliteDocumentScanner, extra ...any) (domain.DocumentChunk, domain.Document, error) {
 	var (
 		chunk            domain.DocumentChunk
 		doc              domain.Document
 		chunkID          string
 		chunkDocumentID  string
-		rawEmbedding     string
+		rawHeadingPath   string
+		rawEmbedding     []byte
 		chunkCreatedAt   string
 		docID            string
 		docSource        string
+		docSourceURL     sql.NullString
 		docStatus        string
 		docFailureReason sql.NullString
+		docTags          string
+		docMetadata      string
 		docCreatedAt     string
 		docUpdatedAt     string
 	)
-	if err := row.Scan(
-		&chunkID, &chunkDocumentID, &chunk.ChunkIndex, &chunk.Content, &chunk.TokenCount, &rawEmbedding, &chunkCreatedAt,
-		&docID, &doc.UserID, &doc.Title, &docSource, &docStatus, &docFailureReason, &docCreatedAt, &docUpdatedAt,
-	); err != nil {
+	dest := []any{
+		&chunkID, &chunkDocumentID, &chunk.ChunkIndex, &chunk.PageNumber, &rawHeadingPath, &chunk.Content, &chunk.TokenCount, &rawEmbedding, &chunk.EmbeddingModel, &chunk.IndexVersion, &chunkCreatedAt,
+		&docID, &doc.UserID, &doc.Title, &docSource, &docSourceURL, &docStatus, &docFailureReason, &doc.MimeType, &docTags, &docMetadata, &docCreatedAt, &docUpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
 		return domain.DocumentChunk{}, domain.Document{}, err
 	}
 	parsedChunkID, err := uuid.Parse(chunkID)
@@ -429,10 +1177,15 @@ func scanSQLiteRetrievedChunk(row sqliteDocumentScanner) (domain.DocumentChunk,
 	if err != nil {
 		return domain.DocumentChunk{}, domain.Document{}, err
 	}
-	var embedding []float32
-	if err := json.Unmarshal([]byte(rawEmbedding), &embedding); err != nil {
+	embedding, err := sqliteinfra.DecodeVector(rawEmbedding)
+	if err != nil {
 		return domain.DocumentChunk{}, domain.Document{}, err
 	}
+	if rawHeadingPath != "" {
+		if err := json.Unmarshal([]byte(rawHeadingPath), &chunk.HeadingPath); err != nil {
+			return domain.DocumentChunk{}, domain.Document{}, err
+		}
+	}
 	chunk.ID = parsedChunkID
 	chunk.DocumentID = parsedChunkDocID
 	chunk.Embedding = embedding
@@ -442,13 +1195,106 @@ func scanSQLiteRetrievedChunk(row sqliteDocumentScanner) (domain.DocumentChunk,
 	doc.Status = domain.DocumentStatus(docStatus)
 	doc.CreatedAt = docCreated
 	doc.UpdatedAt = docUpdated
+	if docSourceURL.Valid {
+		url := docSourceURL.String
+		doc.SourceURL = &url
+	}
 	if docFailureReason.Valid {
 		reason := docFailureReason.String
 		doc.FailureReason = &reason
 	}
+	if err := decodeSQLiteLabels(docTags, docMetadata, &doc); err != nil {
+		return domain.DocumentChunk{}, domain.Document{}, err
+	}
 	return chunk, doc, nil
 }
 
+// encodeSQLiteLabels stores tags as a JSON array and metadata as a JSON
+// object.
+func encodeSQLiteLabels(tags []string, metadata map[string]string) (string, string, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	rawTags, err := json.Marshal(tags)
+	if err != nil {
+		return "", "", err
+	}
+	rawMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return "", "", err
+	}
+	return string(rawTags), string(rawMetadata), nil
+}
+
+func decodeSQLiteLabels(tags, metadata string, doc *domain.Document) error {
+	if tags != "" && tags != "[]" {
+		if err := json.Unmarshal([]byte(tags), &doc.Tags); err != nil {
+			return err
+		}
+	}
+	if metadata != "" && metadata != "{}" {
+		if err := json.Unmarshal([]byte(metadata), &doc.Metadata); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanSQLiteCollection(row interface{ Scan(...any) error }) (domain.Collection, error) {
+	var (
+		collection domain.Collection
+		id         string
+		createdAt  string
+		updatedAt  string
+	)
+	if err := row.Scan(&id, &collection.UserID, &collection.Name, &collection.Description, &createdAt, &updatedAt, &collection.DocumentCount); err != nil {
+		return domain.Collection{}, err
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return domain.Collection{}, err
+	}
+	if collection.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+		return domain.Collection{}, err
+	}
+	if collection.UpdatedAt, err = parseSQLiteTime(updatedAt); err != nil {
+		return domain.Collection{}, err
+	}
+	collection.ID = parsedID
+	return collection, nil
+}
+
+func scanSQLiteShare(row interface{ Scan(...any) error }) (domain.Share, error) {
+	var (
+		share        domain.Share
+		resourceType string
+		resourceID   string
+		role         string
+		createdAt    string
+		updatedAt    string
+	)
+	if err := row.Scan(&resourceType, &resourceID, &share.OwnerID, &share.UserID, &role, &createdAt, &updatedAt); err != nil {
+		return domain.Share{}, err
+	}
+	parsedID, err := uuid.Parse(resourceID)
+	if err != nil {
+		return domain.Share{}, err
+	}
+	if share.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+		return domain.Share{}, err
+	}
+	if share.UpdatedAt, err = parseSQLiteTime(updatedAt); err != nil {
+		return domain.Share{}, err
+	}
+	share.ResourceType = domain.ShareResourceType(resourceType)
+	share.ResourceID = parsedID
+	share.Role = domain.ShareRole(role)
+	return share, nil
+}
+
 type sqliteSessionScanner interface {
 	Scan(dest ...any) error
 }
@@ -466,13 +1312,21 @@ func scanSQLiteSession(row sqliteSessionScanner) (domain.QASession, bool, error)
 
 func scanSQLiteSessionRow(row sqliteSessionScanner) (domain.QASession, error) {
 	var (
-		session domain.QASession
-		id      string
-		created string
+		session      domain.QASession
+		id           string
+		collectionID sql.NullString
+		created      string
 	)
-	if err := row.Scan(&id, &session.UserID, &created); err != nil {
+	if err := row.Scan(&id, &session.UserID, &collectionID, &created); err != nil {
 		return domain.QASession{}, err
 	}
+	if collectionID.Valid {
+		parsed, err := uuid.Parse(collectionID.String)
+		if err != nil {
+			return domain.QASession{}, err
+		}
+		session.CollectionID = &parsed
+	}
 	parsedID, err := uuid.Parse(id)
 	if err != nil {
 		return domain.QASession{}, err
@@ -570,3 +1424,15 @@ func parseSQLiteTime(value string) (time.Time, error) {
 	}
 	return time.Time{}, fmt.Errorf("parse sqlite uploadask time %q: %w", value, lastErr)
 }
+
+// encodeHeadingPath stores the heading path as a JSON array, or "" when empty.
+func encodeHeadingPath(path []string) (string, error) {
+	if len(path) == 0 {
+		return "", nil
+	}
+	payload, err := json.Marshal(path)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
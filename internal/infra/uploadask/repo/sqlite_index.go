@@ -0,0 +1,238 @@
+package repo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
+	"github.com/yanqian/ai-helloworld/internal/infra/uploadask/vectorindex"
+)
+
+// indexSyncBatch bounds the chunks decoded per query while catching the
+// vector index up.
+const indexSyncBatch = 500
+
+// indexCandidates is how many neighbours are first taken from the vector
+// index before the status filter and exact rescoring trim them to
+// sqliteMaxResults. When too few survive the filter the search widens up to
+// indexMaxCandidates, then falls back to an exact scan.
+const (
+	indexCandidates    = 2 * sqliteMaxResults
+	indexMaxCandidates = 32 * sqliteMaxResults
+)
+
+// searchIndexed answers SearchSimilar from the vector index. It reports false
+// when the index leaves the user to an exact scan.
+func (r *SQLiteChunkRepository) searchIndexed(ctx context.Context, userID int64, embedding []float32, filter domain.DocumentFilter) ([]domain.RetrievedChunk, bool, error) {
+	if err := r.syncIndex(ctx); err != nil {
+		return nil, false, err
+	}
+	for k := indexCandidates; ; k *= 4 {
+		ids, ok := r.index.Search(userID, embedding, k)
+		if !ok {
+			return nil, false, nil
+		}
+		results, err := r.loadIndexed(ctx, userID, embedding, ids, statusSet(filter.Statuses))
+		if err != nil {
+			return nil, false, err
+		}
+		// Fewer IDs than asked for means the user's graph has no more.
+		if len(results) >= sqliteMaxResults || len(ids) < k {
+			return rankSimilar(results), true, nil
+		}
+		if k >= indexMaxCandidates {
+			return nil, false, nil
+		}
+	}
+}
+
+// loadIndexed loads the user's chunks among ids whose document has an
+// allowed status, scored against embedding.
+func (r *SQLiteChunkRepository) loadIndexed(ctx context.Context, userID int64, embedding []float32, ids []string, allowedStatuses map[domain.DocumentStatus]bool) ([]domain.RetrievedChunk, error) {
+	results := make([]domain.RetrievedChunk, 0, len(ids))
+	if len(ids) == 0 {
+		return results, nil
+	}
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, userID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+			c.id, c.document_id, c.chunk_index, c.page_number, c.heading_path, c.content, c.token_count, c.embedding, c.embedding_model, c.index_version, c.created_at,
+			d.id, d.user_id, d.title, d.source, d.source_url, d.status, d.failure_reason, d.mime_type, d.tags, d.metadata, d.created_at, d.updated_at
+		FROM upload_document_chunks c
+		JOIN upload_documents d ON d.id = c.document_id
+		WHERE d.user_id = ? AND c.id IN (`+placeholders(len(ids))+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]bool, len(ids))
+	for rows.Next() {
+		chunk, doc, err := scanSQLiteRetrievedChunk(rows)
+		if err != nil {
+			return nil, err
+		}
+		found[chunk.ID.String()] = true
+		if len(allowedStatuses) > 0 && !allowedStatuses[doc.Status] {
+			continue
+		}
+		results = append(results, domain.RetrievedChunk{
+			Chunk:     chunk,
+			Document:  doc,
+			Score:     cosineSimilarity(embedding, chunk.Embedding),
+			CreatedAt: chunk.CreatedAt,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Chunks deleted through another connection are dropped as they surface.
+	var gone []string
+	for _, id := range ids {
+		if !found[id] {
+			gone = append(gone, id)
+		}
+	}
+	if len(gone) > 0 {
+		r.index.Remove(userID, gone...)
+	}
+	return results, nil
+}
+
+// syncIndex replays the chunk vector log past the index watermark, so the
+// index also sees chunks written by other processes.
+func (r *SQLiteChunkRepository) syncIndex(ctx context.Context) error {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+	if !r.reconciled {
+		if err := r.reconcileIndex(ctx); err != nil {
+			return err
+		}
+		r.reconciled = true
+	}
+	for {
+		entries, last, scanned, err := r.loadIndexEntries(ctx, r.index.Watermark())
+		if err != nil {
+			return err
+		}
+		if scanned == 0 {
+			return nil
+		}
+		r.index.Apply(entries, last)
+		if scanned < indexSyncBatch {
+			return nil
+		}
+	}
+}
+
+func (r *SQLiteChunkRepository) loadIndexEntries(ctx context.Context, after int64) ([]vectorindex.Entry, int64, int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT l.seq, c.id, d.user_id, c.embedding
+		FROM upload_chunk_vector_log l
+		JOIN upload_document_chunks c ON c.id = l.chunk_id
+		JOIN upload_documents d ON d.id = c.document_id
+		WHERE l.seq > ?
+		ORDER BY l.seq
+		LIMIT ?
+	`, after, indexSyncBatch)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+	var (
+		entries []vectorindex.Entry
+		last    int64
+		scanned int
+	)
+	for rows.Next() {
+		var (
+			entry        vectorindex.Entry
+			rawEmbedding []byte
+		)
+		if err := rows.Scan(&last, &entry.ID, &entry.UserID, &rawEmbedding); err != nil {
+			return nil, 0, 0, err
+		}
+		scanned++
+		vector, err := sqliteinfra.DecodeVector(rawEmbedding)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		entry.Vector = vector
+		entries = append(entries, entry)
+	}
+	return entries, last, scanned, rows.Err()
+}
+
+// reconcileIndex checks a loaded index against the database once: an index
+// ahead of the log belongs to another database and is rebuilt, and chunks
+// deleted while it was not running are dropped.
+func (r *SQLiteChunkRepository) reconcileIndex(ctx context.Context) error {
+	var maxSeq int64
+	if err := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'upload_chunk_vector_log'), 0)
+	`).Scan(&maxSeq); err != nil {
+		return err
+	}
+	if r.index.Watermark() > maxSeq {
+		r.index.Reset()
+		return nil
+	}
+	if r.index.Watermark() == 0 {
+		return nil
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT id FROM upload_document_chunks`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	live := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		live[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	r.index.Retain(func(id string) bool { return live[id] })
+	return nil
+}
+
+func (r *SQLiteChunkRepository) documentChunkIDs(ctx context.Context, docID uuid.UUID) (int64, []string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT d.user_id, c.id
+		FROM upload_document_chunks c
+		JOIN upload_documents d ON d.id = c.document_id
+		WHERE c.document_id = ?
+	`, docID.String())
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	var (
+		userID int64
+		ids    []string
+	)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&userID, &id); err != nil {
+			return 0, nil, err
+		}
+		ids = append(ids, id)
+	}
+	return userID, ids, rows.Err()
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
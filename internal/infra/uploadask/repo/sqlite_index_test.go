@@ -0,0 +1,245 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
+	"github.com/yanqian/ai-helloworld/internal/infra/uploadask/vectorindex"
+)
+
+// seedVectorDocument stores a processed document for userID with n chunks
+// whose embeddings cluster around a few topics.
+func seedVectorDocument(tb testing.TB, docs *SQLiteDocumentRepository, chunks *SQLiteChunkRepository, rng *rand.Rand, userID int64, n, dim int) uuid.UUID {
+	tb.Helper()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	docID := uuid.New()
+	require.NoError(tb, docs.Create(ctx, domain.Document{
+		ID:        docID,
+		UserID:    userID,
+		Title:     "doc",
+		Source:    domain.DocumentSourceUpload,
+		Status:    domain.DocumentStatusProcessed,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}))
+	centroids := make([][]float32, 10)
+	for i := range centroids {
+		centroids[i] = randomVector(rng, dim, nil)
+	}
+	batch := make([]domain.DocumentChunk, 0, 200)
+	for i := 0; i < n; i++ {
+		batch = append(batch, domain.DocumentChunk{
+			ID:         uuid.New(),
+			DocumentID: docID,
+			ChunkIndex: i,
+			Content:    fmt.Sprintf("chunk %d", i),
+			TokenCount: 2,
+			Embedding:  randomVector(rng, dim, centroids[rng.IntN(len(centroids))]),
+			CreatedAt:  now,
+		})
+		if len(batch) == cap(batch) || i == n-1 {
+			require.NoError(tb, chunks.InsertBatch(ctx, batch))
+			batch = batch[:0]
+		}
+	}
+	return docID
+}
+
+func randomVector(rng *rand.Rand, dim int, around []float32) []float32 {
+	vec := make([]float32, dim)
+	for i := range vec {
+		vec[i] = float32(rng.NormFloat64())
+		if around != nil {
+			vec[i] = around[i] + 0.7*vec[i]
+		}
+	}
+	return vec
+}
+
+func chunkIDs(results []domain.RetrievedChunk) []uuid.UUID {
+	out := make([]uuid.UUID, len(results))
+	for i, r := range results {
+		out[i] = r.Chunk.ID
+	}
+	return out
+}
+
+func TestSQLiteChunkRepositorySearchesThroughVectorIndex(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "uploadask.db")
+	db, err := sqliteinfra.Open(ctx, path)
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	// Chunks written without the index, as a worker process would.
+	exact := NewSQLiteChunkRepository(db, nil)
+	rng := rand.New(rand.NewPCG(9, 9))
+	docID := seedVectorDocument(t, docs, exact, rng, 7, 300, 16)
+	seedVectorDocument(t, docs, exact, rng, 8, 300, 16)
+
+	indexPath := path + ".hnsw"
+	indexed := NewSQLiteChunkRepository(db, vectorindex.New(indexPath, vectorindex.Options{MinVectors: 10}, nil))
+	query := randomVector(rng, 16, nil)
+	want, err := exact.SearchSimilar(ctx, 7, query, domain.DocumentFilter{})
+	require.NoError(t, err)
+	got, err := indexed.SearchSimilar(ctx, 7, query, domain.DocumentFilter{})
+	require.NoError(t, err)
+	require.Len(t, got, sqliteMaxResults)
+	require.Equal(t, want[0].Chunk.ID, got[0].Chunk.ID)
+	require.InDelta(t, want[0].Score, got[0].Score, 1e-9)
+	for _, r := range got {
+		require.Equal(t, int64(7), r.Document.UserID)
+	}
+
+	got, err = indexed.SearchSimilar(ctx, 7, query, domain.DocumentFilter{Statuses: []domain.DocumentStatus{domain.DocumentStatusPending}})
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	// Deleting through the indexed repository drops the chunks from the index.
+	require.NoError(t, indexed.DeleteByDocument(ctx, docID))
+	other := seedVectorDocument(t, docs, indexed, rng, 7, 50, 16)
+	got, err = indexed.SearchSimilar(ctx, 7, query, domain.DocumentFilter{})
+	require.NoError(t, err)
+	require.Len(t, got, 50)
+	for _, r := range got {
+		require.Equal(t, other, r.Document.ID)
+	}
+
+	// A reopened index picks up where it stopped and drops chunks deleted
+	// while it was not running.
+	require.NoError(t, indexed.index.Save())
+	require.NoError(t, exact.DeleteByDocument(ctx, other))
+	third := seedVectorDocument(t, docs, exact, rng, 7, 20, 16)
+	index, err := vectorindex.Open(indexPath, vectorindex.Options{MinVectors: 10}, nil)
+	require.NoError(t, err)
+	reopened := NewSQLiteChunkRepository(db, index)
+	got, err = reopened.SearchSimilar(ctx, 7, query, domain.DocumentFilter{})
+	require.NoError(t, err)
+	want, err = exact.SearchSimilar(ctx, 7, query, domain.DocumentFilter{})
+	require.NoError(t, err)
+	require.Len(t, want, 20)
+	require.ElementsMatch(t, chunkIDs(want), chunkIDs(got))
+	require.Equal(t, third, got[0].Document.ID)
+}
+
+func TestSQLiteChunkRepositoryIndexedSearchWidensPastFilteredNeighbours(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "uploadask.db")
+	db, err := sqliteinfra.Open(ctx, path)
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	exact := NewSQLiteChunkRepository(db, nil)
+	rng := rand.New(rand.NewPCG(3, 3))
+	query := randomVector(rng, 16, nil)
+
+	// Every user has chunks right around the query; only user 7's processed
+	// document lies elsewhere, behind far more failed neighbours than the
+	// index is first asked for.
+	near := func(userID int64, n int, status domain.DocumentStatus) {
+		docID := seedVectorDocument(t, docs, exact, rng, userID, 0, 16)
+		batch := make([]domain.DocumentChunk, n)
+		for i := range batch {
+			batch[i] = domain.DocumentChunk{
+				ID:         uuid.New(),
+				DocumentID: docID,
+				ChunkIndex: i,
+				Content:    fmt.Sprintf("near %d", i),
+				TokenCount: 2,
+				Embedding:  randomVector(rng, 16, query),
+				CreatedAt:  time.Now().UTC(),
+			}
+		}
+		require.NoError(t, exact.InsertBatch(ctx, batch))
+		require.NoError(t, docs.UpdateStatus(ctx, docID, status, nil))
+	}
+	near(7, 400, domain.DocumentStatusFailed)
+	near(8, 300, domain.DocumentStatusProcessed)
+	near(9, 300, domain.DocumentStatusProcessed)
+	processed := seedVectorDocument(t, docs, exact, rng, 7, 40, 16)
+
+	indexed := NewSQLiteChunkRepository(db, vectorindex.New(path+".hnsw", vectorindex.Options{MinVectors: 10}, nil))
+	filter := domain.DocumentFilter{Statuses: []domain.DocumentStatus{domain.DocumentStatusProcessed}}
+	want, err := exact.SearchSimilar(ctx, 7, query, filter)
+	require.NoError(t, err)
+	require.Len(t, want, 40)
+	got, err := indexed.SearchSimilar(ctx, 7, query, filter)
+	require.NoError(t, err)
+	require.ElementsMatch(t, chunkIDs(want), chunkIDs(got))
+	for _, r := range got {
+		require.Equal(t, processed, r.Document.ID)
+	}
+
+	got, err = indexed.SearchSimilar(ctx, 8, query, domain.DocumentFilter{})
+	require.NoError(t, err)
+	require.Len(t, got, sqliteMaxResults)
+	for _, r := range got {
+		require.Equal(t, int64(8), r.Document.UserID)
+	}
+}
+
+// BenchmarkSQLiteSearchSimilar compares SearchSimilar by exact scan with the
+// vector index and reports recall@10 against the exact results.
+func BenchmarkSQLiteSearchSimilar(b *testing.B) {
+	ctx := context.Background()
+	for _, n := range []int{1000, 5000} {
+		db, err := sqliteinfra.Open(ctx, filepath.Join(b.TempDir(), "uploadask.db"))
+		require.NoError(b, err)
+		docs := NewSQLiteDocumentRepository(db)
+		exact := NewSQLiteChunkRepository(db, nil)
+		rng := rand.New(rand.NewPCG(5, 5))
+		seedVectorDocument(b, docs, exact, rng, 1, n, 256)
+		indexed := NewSQLiteChunkRepository(db, vectorindex.New("", vectorindex.Options{}, nil))
+		require.NoError(b, indexed.syncIndex(ctx))
+		queries := make([][]float32, 50)
+		for i := range queries {
+			queries[i] = randomVector(rng, 256, nil)
+		}
+
+		b.Run(fmt.Sprintf("exact/chunks=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := exact.SearchSimilar(ctx, 1, queries[i%len(queries)], domain.DocumentFilter{})
+				require.NoError(b, err)
+			}
+		})
+		b.Run(fmt.Sprintf("hnsw/chunks=%d", n), func(b *testing.B) {
+			var recall float64
+			for i := 0; i < b.N; i++ {
+				query := queries[i%len(queries)]
+				got, err := indexed.SearchSimilar(ctx, 1, query, domain.DocumentFilter{})
+				require.NoError(b, err)
+				b.StopTimer()
+				want, err := exact.SearchSimilar(ctx, 1, query, domain.DocumentFilter{})
+				require.NoError(b, err)
+				recall += overlap(chunkIDs(got[:10]), chunkIDs(want[:10]))
+				b.StartTimer()
+			}
+			b.ReportMetric(recall/float64(b.N), "recall@10")
+		})
+		db.Close()
+	}
+}
+
+func overlap(got, want []uuid.UUID) float64 {
+	wanted := make(map[uuid.UUID]bool, len(want))
+	for _, id := range want {
+		wanted[id] = true
+	}
+	hits := 0
+	for _, id := range got {
+		if wanted[id] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(want))
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"encoding/json"
+	"math/rand/v2"
 	"path/filepath"
 	"testing"
 	"time"
@@ -12,6 +13,7 @@ import (
 
 	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
 	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
+	"github.com/yanqian/ai-helloworld/internal/infra/uploadask/vectorindex"
 )
 
 func TestSQLiteUploadAskRepositoriesPersistAcrossReopen(t *testing.T) {
@@ -22,12 +24,13 @@ func TestSQLiteUploadAskRepositoriesPersistAcrossReopen(t *testing.T) {
 	docID := uuid.New()
 	otherDocID := uuid.New()
 	sessionID := uuid.New()
+	sourceURL := "https://example.com/notes"
 
 	db, err := sqliteinfra.Open(ctx, path)
 	require.NoError(t, err)
 	docs := NewSQLiteDocumentRepository(db)
 	files := NewSQLiteFileRepository(db)
-	chunks := NewSQLiteChunkRepository(db)
+	chunks := NewSQLiteChunkRepository(db, nil)
 	sessions := NewSQLiteQASessionRepository(db)
 	logs := NewSQLiteQueryLogRepository(db)
 
@@ -44,7 +47,8 @@ func TestSQLiteUploadAskRepositoriesPersistAcrossReopen(t *testing.T) {
 		ID:        otherDocID,
 		UserID:    userID,
 		Title:     "Pending notes",
-		Source:    domain.DocumentSourceUpload,
+		Source:    domain.DocumentSourceURL,
+		SourceURL: &sourceURL,
 		Status:    domain.DocumentStatusPending,
 		CreatedAt: now.Add(time.Minute),
 		UpdatedAt: now.Add(time.Minute),
@@ -60,13 +64,15 @@ func TestSQLiteUploadAskRepositoriesPersistAcrossReopen(t *testing.T) {
 	}))
 	require.NoError(t, chunks.InsertBatch(ctx, []domain.DocumentChunk{
 		{
-			ID:         uuid.New(),
-			DocumentID: docID,
-			ChunkIndex: 0,
-			Content:    "SQLite keeps local upload ask data.",
-			TokenCount: 7,
-			Embedding:  []float32{1, 0, 0},
-			CreatedAt:  now.Add(2 * time.Second),
+			ID:          uuid.New(),
+			DocumentID:  docID,
+			ChunkIndex:  0,
+			PageNumber:  3,
+			HeadingPath: []string{"Handbook", "Storage"},
+			Content:     "SQLite keeps local upload ask data.",
+			TokenCount:  7,
+			Embedding:   []float32{1, 0, 0},
+			CreatedAt:   now.Add(2 * time.Second),
 		},
 		{
 			ID:         uuid.New(),
@@ -101,7 +107,7 @@ func TestSQLiteUploadAskRepositoriesPersistAcrossReopen(t *testing.T) {
 	defer db.Close()
 	reopenedDocs := NewSQLiteDocumentRepository(db)
 	reopenedFiles := NewSQLiteFileRepository(db)
-	reopenedChunks := NewSQLiteChunkRepository(db)
+	reopenedChunks := NewSQLiteChunkRepository(db, nil)
 	reopenedSessions := NewSQLiteQASessionRepository(db)
 	reopenedLogs := NewSQLiteQueryLogRepository(db)
 
@@ -109,6 +115,12 @@ func TestSQLiteUploadAskRepositoriesPersistAcrossReopen(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, found)
 	require.Equal(t, "Local handbook", doc.Title)
+	require.Nil(t, doc.SourceURL)
+	urlDoc, found, err := reopenedDocs.Get(ctx, otherDocID, userID)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, domain.DocumentSourceURL, urlDoc.Source)
+	require.Equal(t, &sourceURL, urlDoc.SourceURL)
 
 	listed, err := reopenedDocs.List(ctx, userID, domain.DocumentFilter{
 		Statuses: []domain.DocumentStatus{domain.DocumentStatusProcessed},
@@ -129,6 +141,8 @@ func TestSQLiteUploadAskRepositoriesPersistAcrossReopen(t *testing.T) {
 	require.Len(t, results, 1)
 	require.Equal(t, docID, results[0].Document.ID)
 	require.Equal(t, 0, results[0].Chunk.ChunkIndex)
+	require.Equal(t, 3, results[0].Chunk.PageNumber)
+	require.Equal(t, []string{"Handbook", "Storage"}, results[0].Chunk.HeadingPath)
 	require.Greater(t, results[0].Score, 0.9)
 
 	session, found, err := reopenedSessions.Find(ctx, sessionID, userID)
@@ -194,7 +208,7 @@ func TestSQLiteUploadAskRepositoriesParseDatabaseStyleTimestamps(t *testing.T) {
 
 	docs := NewSQLiteDocumentRepository(db)
 	files := NewSQLiteFileRepository(db)
-	chunks := NewSQLiteChunkRepository(db)
+	chunks := NewSQLiteChunkRepository(db, nil)
 	sessions := NewSQLiteQASessionRepository(db)
 	logs := NewSQLiteQueryLogRepository(db)
 
@@ -259,3 +273,583 @@ func TestSQLiteUploadAskRepositoryRejectsInvalidTimestamp(t *testing.T) {
 	require.False(t, found)
 	require.Contains(t, err.Error(), "parse sqlite uploadask time")
 }
+
+func TestSQLiteChunkRepositorySearchLexicalMatchesIdentifiers(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	chunks := NewSQLiteChunkRepository(db, nil)
+	now := time.Date(2026, 6, 13, 10, 0, 0, 0, time.UTC)
+	userID := int64(77)
+	docID := uuid.New()
+
+	require.NoError(t, docs.Create(ctx, domain.Document{
+		ID:        docID,
+		UserID:    userID,
+		Title:     "Runbook",
+		Source:    domain.DocumentSourceUpload,
+		Status:    domain.DocumentStatusProcessed,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}))
+	contents := []string{
+		"General troubleshooting steps for the payment service.",
+		"ERR-1042 means the card issuer declined the payment.",
+		"Error codes are listed in the appendix.",
+	}
+	var batch []domain.DocumentChunk
+	for i, content := range contents {
+		batch = append(batch, domain.DocumentChunk{
+			ID:         uuid.New(),
+			DocumentID: docID,
+			ChunkIndex: i,
+			Content:    content,
+			TokenCount: 8,
+			Embedding:  []float32{1, 0, 0},
+			CreatedAt:  now,
+		})
+	}
+	require.NoError(t, chunks.InsertBatch(ctx, batch))
+
+	results, err := chunks.SearchLexical(ctx, userID, "What does err-1042 mean?", domain.DocumentFilter{
+		Statuses: []domain.DocumentStatus{domain.DocumentStatusProcessed},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	require.Equal(t, 1, results[0].Chunk.ChunkIndex)
+	require.Positive(t, results[0].Score)
+
+	results, err = chunks.SearchLexical(ctx, userID+1, "ERR-1042", domain.DocumentFilter{})
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	results, err = chunks.SearchLexical(ctx, userID, "?!", domain.DocumentFilter{})
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestSQLiteChunkRepositoryListStaleDocuments(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	chunks := NewSQLiteChunkRepository(db, nil)
+	now := time.Date(2026, 6, 13, 10, 0, 0, 0, time.UTC)
+	userID := int64(12)
+
+	insert := func(model, version string) uuid.UUID {
+		docID := uuid.New()
+		require.NoError(t, docs.Create(ctx, domain.Document{
+			ID:        docID,
+			UserID:    userID,
+			Title:     "Doc " + version,
+			Source:    domain.DocumentSourceUpload,
+			Status:    domain.DocumentStatusProcessed,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}))
+		require.NoError(t, chunks.InsertBatch(ctx, []domain.DocumentChunk{{
+			ID:             uuid.New(),
+			DocumentID:     docID,
+			Content:        "content",
+			TokenCount:     1,
+			Embedding:      []float32{1, 0, 0},
+			EmbeddingModel: model,
+			IndexVersion:   version,
+			CreatedAt:      now,
+		}}))
+		return docID
+	}
+	current := insert("text-embedding-3-small", "v2")
+	staleModel := insert("text-embedding-ada-002", "v2")
+	staleVersion := insert("text-embedding-3-small", "v1")
+
+	stale, err := chunks.ListStaleDocuments(ctx, userID, "text-embedding-3-small", "v2")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uuid.UUID{staleModel, staleVersion}, stale)
+
+	results, err := chunks.SearchSimilar(ctx, userID, []float32{1, 0, 0}, domain.DocumentFilter{DocumentIDs: []uuid.UUID{current}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "text-embedding-3-small", results[0].Chunk.EmbeddingModel)
+	require.Equal(t, "v2", results[0].Chunk.IndexVersion)
+
+	stale, err = chunks.ListStaleDocuments(ctx, userID+1, "text-embedding-3-small", "v2")
+	require.NoError(t, err)
+	require.Empty(t, stale)
+}
+
+func TestSQLiteDocumentRepositoryUpdateProgress(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	now := time.Date(2026, 6, 13, 10, 0, 0, 0, time.UTC)
+	docID := uuid.New()
+	require.NoError(t, docs.Create(ctx, domain.Document{
+		ID:        docID,
+		UserID:    3,
+		Title:     "Progress",
+		Source:    domain.DocumentSourceUpload,
+		Status:    domain.DocumentStatusProcessing,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}))
+
+	doc, found, err := docs.Get(ctx, docID, 3)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Nil(t, doc.Progress)
+
+	finished := now.Add(time.Second)
+	progress := domain.DocumentProgress{
+		Stage:       domain.ProcessingStageEmbed,
+		ChunksDone:  4,
+		ChunksTotal: 10,
+		Stages: []domain.StageTiming{
+			{Stage: domain.ProcessingStageExtract, StartedAt: now, FinishedAt: &finished},
+			{Stage: domain.ProcessingStageEmbed, StartedAt: finished},
+		},
+	}
+	require.NoError(t, docs.UpdateProgress(ctx, docID, progress))
+
+	doc, found, err = docs.Get(ctx, docID, 3)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.NotNil(t, doc.Progress)
+	require.Equal(t, domain.ProcessingStageEmbed, doc.Progress.Stage)
+	require.Equal(t, 4, doc.Progress.ChunksDone)
+	require.Equal(t, 10, doc.Progress.ChunksTotal)
+	require.Len(t, doc.Progress.Stages, 2)
+	require.True(t, doc.Progress.Stages[0].FinishedAt.Equal(finished))
+	require.Nil(t, doc.Progress.Stages[1].FinishedAt)
+	require.True(t, doc.UpdatedAt.After(now))
+
+	listed, err := docs.List(ctx, 3, domain.DocumentFilter{})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.NotNil(t, listed[0].Progress)
+}
+
+func TestSQLiteRepositoriesFilterByDocumentLabels(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	chunks := NewSQLiteChunkRepository(db, vectorindex.New("", vectorindex.Options{MinVectors: 1}, nil))
+	now := time.Date(2026, 6, 13, 10, 0, 0, 0, time.UTC)
+	policy := uuid.New()
+	notes := uuid.New()
+	for i, doc := range []domain.Document{
+		{ID: policy, UserID: 3, Title: "Policy", MimeType: "application/pdf", Tags: []string{"hr-policy"}, CreatedAt: now},
+		{ID: notes, UserID: 3, Title: "Notes", MimeType: "text/plain", CreatedAt: now.Add(time.Hour)},
+	} {
+		doc.Source = domain.DocumentSourceUpload
+		doc.Status = domain.DocumentStatusProcessed
+		doc.UpdatedAt = doc.CreatedAt
+		require.NoError(t, docs.Create(ctx, doc))
+		require.NoError(t, chunks.InsertBatch(ctx, []domain.DocumentChunk{{
+			ID:         uuid.New(),
+			DocumentID: doc.ID,
+			ChunkIndex: i,
+			Content:    "leave allowance",
+			TokenCount: 2,
+			Embedding:  []float32{1, float32(i)},
+			CreatedAt:  now,
+		}}))
+	}
+
+	found, err := docs.UpdateLabels(ctx, notes, 3, []string{"2026", "hr-policy"}, map[string]string{"team": "people"})
+	require.NoError(t, err)
+	require.True(t, found)
+	found, err = docs.UpdateLabels(ctx, notes, 4, []string{"other"}, nil)
+	require.NoError(t, err)
+	require.False(t, found, "labels belong to the document owner")
+	doc, _, err := docs.Get(ctx, notes, 3)
+	require.NoError(t, err)
+	require.Equal(t, "text/plain", doc.MimeType)
+	require.Equal(t, []string{"2026", "hr-policy"}, doc.Tags)
+	require.Equal(t, map[string]string{"team": "people"}, doc.Metadata)
+
+	docIDs := func(results []domain.RetrievedChunk) []uuid.UUID {
+		out := make([]uuid.UUID, len(results))
+		for i, r := range results {
+			out[i] = r.Document.ID
+		}
+		return out
+	}
+	tagged := domain.DocumentFilter{Tags: []string{"hr-policy", "2026"}}
+	listed, err := docs.List(ctx, 3, tagged)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.Equal(t, notes, listed[0].ID)
+	similar, err := chunks.SearchSimilar(ctx, 3, []float32{1, 0}, tagged)
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{notes}, docIDs(similar))
+	similar, err = chunks.SearchSimilar(ctx, 3, []float32{1, 0}, domain.DocumentFilter{Metadata: map[string]string{"team": "sales"}})
+	require.NoError(t, err)
+	require.Empty(t, similar)
+	lexical, err := chunks.SearchLexical(ctx, 3, "allowance", domain.DocumentFilter{MimeTypes: []string{"application/pdf"}})
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{policy}, docIDs(lexical))
+	cutoff := now.Add(30 * time.Minute)
+	lexical, err = chunks.SearchLexical(ctx, 3, "allowance", domain.DocumentFilter{CreatedAfter: &cutoff})
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{notes}, docIDs(lexical))
+}
+
+func TestSQLiteCollectionRepository(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	collections := NewSQLiteCollectionRepository(db)
+	sessions := NewSQLiteQASessionRepository(db)
+	now := time.Date(2026, 6, 13, 10, 0, 0, 0, time.UTC)
+	first := uuid.New()
+	second := uuid.New()
+	for _, id := range []uuid.UUID{first, second} {
+		require.NoError(t, docs.Create(ctx, domain.Document{ID: id, UserID: 3, Title: id.String(), Source: domain.DocumentSourceUpload, Status: domain.DocumentStatusProcessed, CreatedAt: now, UpdatedAt: now}))
+	}
+
+	collection := domain.Collection{ID: uuid.New(), UserID: 3, Name: "HR", Description: "people policies", CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, collections.Create(ctx, collection))
+	require.NoError(t, collections.AddDocuments(ctx, collection.ID, []uuid.UUID{second, first}))
+	require.NoError(t, collections.AddDocuments(ctx, collection.ID, []uuid.UUID{first}), "adding a member again is a no-op")
+	got, found, err := collections.Get(ctx, collection.ID, 3)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "people policies", got.Description)
+	require.Equal(t, 2, got.DocumentCount)
+	_, found, err = collections.Get(ctx, collection.ID, 4)
+	require.NoError(t, err)
+	require.False(t, found, "collections belong to their owner")
+	members, err := collections.DocumentIDs(ctx, collection.ID)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uuid.UUID{first, second}, members)
+	other := domain.Collection{ID: uuid.New(), UserID: 3, Name: "Onboarding", CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, collections.Create(ctx, other))
+	require.NoError(t, collections.AddDocuments(ctx, other.ID, []uuid.UUID{first}))
+	members, err = collections.DocumentIDsIn(ctx, []uuid.UUID{collection.ID, other.ID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uuid.UUID{first, second}, members, "documents in several collections appear once")
+	_, err = collections.Delete(ctx, other.ID, 3)
+	require.NoError(t, err)
+
+	collection.Name = "People"
+	updated, err := collections.Update(ctx, collection)
+	require.NoError(t, err)
+	require.True(t, updated)
+	listed, err := collections.List(ctx, 3)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.Equal(t, "People", listed[0].Name)
+	require.Equal(t, 2, listed[0].DocumentCount)
+
+	session := domain.QASession{ID: uuid.New(), UserID: 3, CollectionID: &collection.ID, CreatedAt: now}
+	require.NoError(t, sessions.Create(ctx, session))
+	stored, found, err := sessions.Find(ctx, session.ID, 3)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, collection.ID, *stored.CollectionID)
+
+	require.NoError(t, collections.RemoveDocuments(ctx, collection.ID, []uuid.UUID{second}))
+	require.NoError(t, collections.DeleteByDocument(ctx, first))
+	members, err = collections.DocumentIDs(ctx, collection.ID)
+	require.NoError(t, err)
+	require.Empty(t, members)
+
+	require.NoError(t, collections.AddDocuments(ctx, collection.ID, []uuid.UUID{first}))
+	deleted, err := collections.Delete(ctx, collection.ID, 4)
+	require.NoError(t, err)
+	require.False(t, deleted)
+	deleted, err = collections.Delete(ctx, collection.ID, 3)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	members, err = collections.DocumentIDs(ctx, collection.ID)
+	require.NoError(t, err)
+	require.Empty(t, members, "memberships go with the collection")
+	_, found, err = docs.Get(ctx, first, 3)
+	require.NoError(t, err)
+	require.True(t, found, "documents outlive their collections")
+}
+
+func TestSQLiteChunkRepositoryListByDocument(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	files := NewSQLiteFileRepository(db)
+	chunks := NewSQLiteChunkRepository(db, nil)
+	docID := seedDeletableDocument(t, docs, files, chunks, 4, 3)
+	other := seedDeletableDocument(t, docs, files, chunks, 4, 3)
+	now := time.Date(2026, 6, 13, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, chunks.InsertBatch(ctx, []domain.DocumentChunk{
+		{ID: uuid.New(), DocumentID: docID, ChunkIndex: 2, Content: "third", TokenCount: 1, Embedding: []float32{0, 0, 1}, EmbeddingModel: "m", IndexVersion: "v", CreatedAt: now},
+		{ID: uuid.New(), DocumentID: docID, ChunkIndex: 1, Content: "second", TokenCount: 1, Embedding: []float32{0, 1, 0}, EmbeddingModel: "m", IndexVersion: "v", CreatedAt: now},
+	}))
+
+	listed, err := chunks.ListByDocument(ctx, docID)
+	require.NoError(t, err)
+	require.Len(t, listed, 3)
+	for i, chunk := range listed {
+		require.Equal(t, i, chunk.ChunkIndex)
+		require.Equal(t, docID, chunk.DocumentID)
+	}
+	require.Equal(t, []float32{0, 1, 0}, listed[1].Embedding)
+	require.Equal(t, "m", listed[2].EmbeddingModel)
+
+	listed, err = chunks.ListByDocument(ctx, other)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	listed, err = chunks.ListByDocument(ctx, uuid.New())
+	require.NoError(t, err)
+	require.Empty(t, listed)
+}
+
+func TestSQLiteDocumentRepositoryListStaleAndRecoveryAttempts(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	old := time.Now().UTC().Add(-time.Hour)
+	create := func(userID int64, status domain.DocumentStatus, updatedAt time.Time) uuid.UUID {
+		id := uuid.New()
+		require.NoError(t, docs.Create(ctx, domain.Document{
+			ID:        id,
+			UserID:    userID,
+			Title:     string(status),
+			Source:    domain.DocumentSourceUpload,
+			Status:    status,
+			CreatedAt: updatedAt,
+			UpdatedAt: updatedAt,
+		}))
+		return id
+	}
+	processing := create(1, domain.DocumentStatusProcessing, old)
+	pending := create(2, domain.DocumentStatusPending, old.Add(time.Minute))
+	create(1, domain.DocumentStatusPending, time.Now().UTC())
+	create(2, domain.DocumentStatusProcessed, old)
+
+	stale, err := docs.ListStale(ctx, []domain.DocumentStatus{domain.DocumentStatusPending, domain.DocumentStatusProcessing}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.Len(t, stale, 2)
+	require.Equal(t, processing, stale[0].ID)
+	require.Equal(t, pending, stale[1].ID)
+
+	attempts, err := docs.IncrementRecoveryAttempts(ctx, processing)
+	require.NoError(t, err)
+	require.Equal(t, 1, attempts)
+	attempts, err = docs.IncrementRecoveryAttempts(ctx, processing)
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+	attempts, err = docs.IncrementRecoveryAttempts(ctx, uuid.New())
+	require.NoError(t, err)
+	require.Zero(t, attempts)
+
+	// Recording an attempt refreshes updated_at, so the document is no longer
+	// stale.
+	stale, err = docs.ListStale(ctx, []domain.DocumentStatus{domain.DocumentStatusProcessing}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, stale)
+}
+
+func TestSQLiteFileRepositoryListByContentHash(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	files := NewSQLiteFileRepository(db)
+	now := time.Now().UTC()
+	create := func(hash string, createdAt time.Time) uuid.UUID {
+		docID := uuid.New()
+		require.NoError(t, docs.Create(ctx, domain.Document{
+			ID:        docID,
+			UserID:    1,
+			Title:     hash,
+			Source:    domain.DocumentSourceUpload,
+			Status:    domain.DocumentStatusProcessed,
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+		}))
+		require.NoError(t, files.Create(ctx, domain.FileObject{
+			ID:          uuid.New(),
+			DocumentID:  docID,
+			StorageKey:  docID.String(),
+			SizeBytes:   1,
+			MimeType:    "text/plain",
+			ETag:        "etag",
+			ContentHash: hash,
+			CreatedAt:   createdAt,
+		}))
+		return docID
+	}
+	older := create("abc", now.Add(-time.Hour))
+	newer := create("abc", now)
+	create("def", now)
+
+	matches, err := files.ListByContentHash(ctx, "abc")
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	require.Equal(t, newer, matches[0].DocumentID)
+	require.Equal(t, older, matches[1].DocumentID)
+	require.Equal(t, "abc", matches[0].ContentHash)
+
+	file, found, err := files.FindByDocument(ctx, older)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "abc", file.ContentHash)
+
+	matches, err = files.ListByContentHash(ctx, "missing")
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
+
+func TestSQLiteUploadIntentRepositoryConfirmsOnce(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	intents := NewSQLiteUploadIntentRepository(db)
+	now := time.Now().UTC().Truncate(time.Second)
+	intent := domain.UploadIntent{
+		ID:         uuid.New(),
+		UserID:     7,
+		Filename:   "notes.txt",
+		Title:      "Notes",
+		MimeType:   "text/plain",
+		SizeBytes:  5,
+		StorageKey: "uploads/7/notes.txt",
+		Status:     domain.UploadIntentPending,
+		ExpiresAt:  now.Add(time.Minute),
+		CreatedAt:  now,
+	}
+	require.NoError(t, intents.Create(ctx, intent))
+
+	_, found, err := intents.Get(ctx, intent.ID, 8)
+	require.NoError(t, err)
+	require.False(t, found, "intents are scoped to their owner")
+	got, found, err := intents.Get(ctx, intent.ID, 7)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, intent.StorageKey, got.StorageKey)
+	require.True(t, intent.ExpiresAt.Equal(got.ExpiresAt))
+	got, found, err = intents.GetByStorageKey(ctx, intent.StorageKey)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, intent.ID, got.ID)
+	_, found, err = intents.GetByStorageKey(ctx, "uploads/7/other.txt")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	claimed, err := intents.MarkConfirmed(ctx, intent.ID)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	claimed, err = intents.MarkConfirmed(ctx, intent.ID)
+	require.NoError(t, err)
+	require.False(t, claimed)
+	got, _, err = intents.Get(ctx, intent.ID, 7)
+	require.NoError(t, err)
+	require.Equal(t, domain.UploadIntentConfirmed, got.Status)
+}
+
+func TestSQLiteRepositoriesReportUsageByUser(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(t.TempDir(), "uploadask.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	files := NewSQLiteFileRepository(db)
+	chunks := NewSQLiteChunkRepository(db, nil)
+	now := time.Now().UTC()
+	create := func(userID int64, size int64, chunkCount int) uuid.UUID {
+		docID := uuid.New()
+		require.NoError(t, docs.Create(ctx, domain.Document{
+			ID:        docID,
+			UserID:    userID,
+			Title:     "doc",
+			Source:    domain.DocumentSourceUpload,
+			Status:    domain.DocumentStatusProcessed,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}))
+		require.NoError(t, files.Create(ctx, domain.FileObject{
+			ID:         uuid.New(),
+			DocumentID: docID,
+			UserID:     userID,
+			StorageKey: docID.String(),
+			SizeBytes:  size,
+			MimeType:   "text/plain",
+			ETag:       "etag",
+			CreatedAt:  now,
+		}))
+		batch := make([]domain.DocumentChunk, chunkCount)
+		for i := range batch {
+			batch[i] = domain.DocumentChunk{ID: uuid.New(), DocumentID: docID, ChunkIndex: i, Content: "chunk", TokenCount: 1, Embedding: []float32{1, 0}, CreatedAt: now}
+		}
+		require.NoError(t, chunks.InsertBatch(ctx, batch))
+		return docID
+	}
+	first := create(7, 100, 2)
+	create(7, 50, 3)
+	create(8, 999, 1)
+
+	usage, err := files.UsageByUser(ctx, 7)
+	require.NoError(t, err)
+	require.Equal(t, domain.StorageUsage{Documents: 2, Bytes: 150}, usage)
+	count, err := chunks.CountByUser(ctx, 7)
+	require.NoError(t, err)
+	require.Equal(t, 5, count)
+	count, err = chunks.CountByDocument(ctx, first)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	file, found, err := files.FindByDocument(ctx, first)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(7), file.UserID)
+
+	usage, err = files.UsageByUser(ctx, 9)
+	require.NoError(t, err)
+	require.Zero(t, usage)
+}
+
+// BenchmarkSQLiteSearchSimilarEncoding measures the exact scan over chunks
+// stored as binary vectors and over the same chunks stored as JSON text.
+func BenchmarkSQLiteSearchSimilarEncoding(b *testing.B) {
+	ctx := context.Background()
+	db, err := sqliteinfra.Open(ctx, filepath.Join(b.TempDir(), "uploadask.db"))
+	require.NoError(b, err)
+	defer db.Close()
+	docs := NewSQLiteDocumentRepository(db)
+	chunks := NewSQLiteChunkRepository(db, nil)
+	rng := rand.New(rand.NewPCG(3, 3))
+	docID := seedVectorDocument(b, docs, chunks, rng, 1, 2000, 1536)
+	query := randomVector(rng, 1536, nil)
+	search := func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := chunks.SearchSimilar(ctx, 1, query, domain.DocumentFilter{})
+			require.NoError(b, err)
+		}
+	}
+
+	b.Run("binary", search)
+	stored, err := chunks.ListByDocument(ctx, docID)
+	require.NoError(b, err)
+	for _, chunk := range stored {
+		text, err := json.Marshal(chunk.Embedding)
+		require.NoError(b, err)
+		_, err = db.ExecContext(ctx, `UPDATE upload_document_chunks SET embedding = ? WHERE id = ?`, string(text), chunk.ID.String())
+		require.NoError(b, err)
+	}
+	b.Run("json", search)
+}
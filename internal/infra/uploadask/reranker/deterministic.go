@@ -0,0 +1,107 @@
+package reranker
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// stopwords are ignored when matching query terms against chunk text.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "can": true, "do": true, "does": true, "for": true, "from": true, "how": true,
+	"i": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true, "what": true,
+	"when": true, "where": true, "which": true, "who": true, "why": true, "with": true,
+}
+
+// DeterministicReranker scores candidates offline by how well they cover the
+// question: the share of query terms present in the chunk, plus bonuses for
+// adjacent query term pairs appearing together and for terms matching the
+// chunk's heading path. Scores fall in [0, 1].
+type DeterministicReranker struct{}
+
+// Rerank returns one score per candidate.
+func (DeterministicReranker) Rerank(_ context.Context, query string, candidates []domain.RetrievedChunk) ([]float64, error) {
+	terms := queryTerms(query)
+	scores := make([]float64, len(candidates))
+	if len(terms) == 0 {
+		return scores, nil
+	}
+	for i, candidate := range candidates {
+		scores[i] = coverageScore(terms, candidate.Chunk)
+	}
+	return scores, nil
+}
+
+var _ domain.Reranker = DeterministicReranker{}
+
+func coverageScore(terms []string, chunk domain.DocumentChunk) float64 {
+	tokens := tokenize(chunk.Content)
+	present := make(map[string]bool, len(tokens))
+	pairs := make(map[[2]string]bool, len(tokens))
+	for i, token := range tokens {
+		present[token] = true
+		if i > 0 {
+			pairs[[2]string{tokens[i-1], token}] = true
+		}
+	}
+	heading := make(map[string]bool)
+	for _, token := range tokenize(strings.Join(chunk.HeadingPath, " ")) {
+		heading[token] = true
+	}
+
+	var matched, inHeading int
+	for _, term := range terms {
+		if present[term] {
+			matched++
+		}
+		if heading[term] {
+			inHeading++
+		}
+	}
+	coverage := float64(matched) / float64(len(terms))
+
+	var adjacent, pairCount int
+	for i := 1; i < len(terms); i++ {
+		pairCount++
+		if pairs[[2]string{terms[i-1], terms[i]}] {
+			adjacent++
+		}
+	}
+	score := 0.7 * coverage
+	if pairCount > 0 {
+		score += 0.2 * float64(adjacent) / float64(pairCount)
+	} else {
+		score += 0.2 * coverage
+	}
+	score += 0.1 * float64(inHeading) / float64(len(terms))
+	return score
+}
+
+// queryTerms returns the query tokens without stopwords, keeping order so
+// adjacent pairs can be matched.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, token := range tokenize(query) {
+		if !stopwords[token] {
+			terms = append(terms, token)
+		}
+	}
+	return terms
+}
+
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+	})
+	tokens := fields[:0]
+	for _, field := range fields {
+		if field = strings.Trim(field, "-_"); field != "" {
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,88 @@
+package reranker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// maxPassageChars truncates each candidate in the rerank prompt to bound
+// prompt size.
+const maxPassageChars = 800
+
+const rerankInstructions = "You rank passages by how well they answer a question. " +
+	"Rate every passage from 0 (irrelevant) to 10 (fully answers the question). " +
+	`Reply with JSON only, in the form {"scores":[s1,s2,...]}, one score per passage in the given order.`
+
+// LLMReranker asks a chat model to grade each candidate against the
+// question in a single request. Scores are normalised to [0, 1].
+type LLMReranker struct {
+	llm domain.LLM
+}
+
+// NewLLMReranker constructs a reranker backed by llm.
+func NewLLMReranker(llm domain.LLM) *LLMReranker {
+	return &LLMReranker{llm: llm}
+}
+
+// Rerank returns one score per candidate or an error when the model reply
+// cannot be parsed.
+func (r *LLMReranker) Rerank(ctx context.Context, query string, candidates []domain.RetrievedChunk) ([]float64, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Question: %s\n\n", strings.TrimSpace(query))
+	for i, candidate := range candidates {
+		fmt.Fprintf(&prompt, "Passage %d:\n%s\n\n", i+1, truncate(candidate.Chunk.Content, maxPassageChars))
+	}
+	reply, err := r.llm.Chat(ctx, []domain.LLMMessage{
+		{Role: "system", Content: rerankInstructions},
+		{Role: "user", Content: prompt.String()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rerank chat: %w", err)
+	}
+	scores, err := parseScores(reply)
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) != len(candidates) {
+		return nil, fmt.Errorf("rerank reply has %d scores for %d passages", len(scores), len(candidates))
+	}
+	for i, score := range scores {
+		scores[i] = min(max(score, 0), 10) / 10
+	}
+	return scores, nil
+}
+
+var _ domain.Reranker = (*LLMReranker)(nil)
+
+// parseScores reads {"scores":[...]} from the reply, tolerating surrounding
+// prose or code fences.
+func parseScores(reply string) ([]float64, error) {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end <= start {
+		return nil, errors.New("rerank reply has no JSON object")
+	}
+	var payload struct {
+		Scores []float64 `json:"scores"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &payload); err != nil {
+		return nil, fmt.Errorf("decode rerank reply: %w", err)
+	}
+	return payload.Scores, nil
+}
+
+func truncate(text string, limit int) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= limit {
+		return string(runes)
+	}
+	return string(runes[:limit]) + "…"
+}
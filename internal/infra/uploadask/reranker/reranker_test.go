@@ -0,0 +1,64 @@
+package reranker
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+func candidates(contents ...string) []domain.RetrievedChunk {
+	out := make([]domain.RetrievedChunk, len(contents))
+	for i, content := range contents {
+		out[i] = domain.RetrievedChunk{Chunk: domain.DocumentChunk{ChunkIndex: i, Content: content}}
+	}
+	return out
+}
+
+func TestDeterministicRerankerPrefersFullCoverage(t *testing.T) {
+	scores, err := DeterministicReranker{}.Rerank(context.Background(), "What is the refund window?", candidates(
+		"Shipping takes five days.",
+		"The refund policy is generous.",
+		"Customers may request a refund window extension within 30 days.",
+	))
+	require.NoError(t, err)
+	require.Len(t, scores, 3)
+	require.Zero(t, scores[0])
+	require.Greater(t, scores[2], scores[1])
+	require.LessOrEqual(t, scores[2], 1.0)
+}
+
+type fakeLLM struct {
+	reply string
+	err   error
+}
+
+func (f fakeLLM) Chat(context.Context, []domain.LLMMessage) (string, error) {
+	return f.reply, f.err
+}
+
+func (f fakeLLM) ChatStream(context.Context, []domain.LLMMessage) (<-chan domain.LLMStreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestLLMRerankerParsesAndNormalisesScores(t *testing.T) {
+	reranker := NewLLMReranker(fakeLLM{reply: "```json\n{\"scores\": [2, 10, 12]}\n```"})
+
+	scores, err := reranker.Rerank(context.Background(), "q", candidates("a", "b", "c"))
+	require.NoError(t, err)
+	require.Equal(t, []float64{0.2, 1, 1}, scores)
+}
+
+func TestLLMRerankerRejectsMismatchedReplies(t *testing.T) {
+	_, err := NewLLMReranker(fakeLLM{reply: `{"scores":[1]}`}).Rerank(context.Background(), "q", candidates("a", "b"))
+	require.ErrorContains(t, err, "1 scores for 2 passages")
+
+	_, err = NewLLMReranker(fakeLLM{reply: "no idea"}).Rerank(context.Background(), "q", candidates("a"))
+	require.Error(t, err)
+
+	_, err = NewLLMReranker(fakeLLM{err: errors.New("boom")}).Rerank(context.Background(), "q", candidates("a"))
+	require.ErrorContains(t, err, "boom")
+}
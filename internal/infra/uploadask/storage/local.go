@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// LocalStorage keeps blobs as files under a root directory so uploads survive
+// restarts without an object store. It stands in for bucket presigned URLs
+// with URLs under uploadURL signed by signingKey, which this server receives.
+type LocalStorage struct {
+	root       string
+	uploadURL  string
+	signingKey []byte
+}
+
+// NewLocalStorage constructs storage rooted at dir, creating it if needed.
+// Without a signingKey it cannot presign uploads.
+func NewLocalStorage(dir, uploadURL string, signingKey []byte) (*LocalStorage, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve upload directory: %w", err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("create upload directory: %w", err)
+	}
+	return &LocalStorage{root: root, uploadURL: strings.TrimRight(uploadURL, "/"), signingKey: signingKey}, nil
+}
+
+// Put streams the blob into a temporary file and renames it into place, so
+// readers never see a partial file. A failed write leaves nothing behind.
+func (s *LocalStorage) Put(_ context.Context, key string, body io.Reader, mimeType string) (domain.StoredObject, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return domain.StoredObject{}, err
+	}
+	size, etag, err := s.write(path, body)
+	if err != nil {
+		s.removeEmptyDirs(filepath.Dir(path))
+		return domain.StoredObject{}, err
+	}
+	return domain.StoredObject{
+		Key:      key,
+		Size:     size,
+		MimeType: mimeType,
+		ETag:     etag,
+	}, nil
+}
+
+func (s *LocalStorage) write(path string, body io.Reader) (int64, string, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, "", fmt.Errorf("create blob directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return 0, "", fmt.Errorf("create temp blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), body)
+	if err != nil {
+		tmp.Close()
+		return 0, "", fmt.Errorf("write blob: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, "", fmt.Errorf("sync blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, "", fmt.Errorf("close blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, "", fmt.Errorf("move blob into place: %w", err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Get opens the stored blob.
+func (s *LocalStorage) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("blob not found: %w", err)
+		}
+		return nil, fmt.Errorf("open blob: %w", err)
+	}
+	return file, nil
+}
+
+// Delete removes the blob and any directories it leaves empty. Missing blobs
+// are not an error.
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete blob: %w", err)
+	}
+	s.removeEmptyDirs(filepath.Dir(path))
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents below the root while they are
+// empty.
+func (s *LocalStorage) removeEmptyDirs(dir string) {
+	for ; dir != s.root; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
+
+// path maps key to a file under the root, rejecting keys that are absolute or
+// climb out of it.
+func (s *LocalStorage) path(key string) (string, error) {
+	rel := filepath.FromSlash(key)
+	if key == "" || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.root, rel), nil
+}
+
+var _ domain.ObjectStorage = (*LocalStorage)(nil)
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+// PresignPut returns a signed PUT to this server's upload endpoint, which
+// hands the request to ReceiveSignedPut.
+func (s *LocalStorage) PresignPut(_ context.Context, key, mimeType string, ttl time.Duration) (domain.PresignedRequest, error) {
+	if len(s.signingKey) == 0 {
+		return domain.PresignedRequest{}, errors.New("local storage has no signing key")
+	}
+	if _, err := s.path(key); err != nil {
+		return domain.PresignedRequest{}, err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(key, expires))
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return domain.PresignedRequest{
+		Method:  "PUT",
+		URL:     s.uploadURL + "/" + strings.Join(segments, "/") + "?" + query.Encode(),
+		Headers: map[string]string{"Content-Type": mimeType},
+	}, nil
+}
+
+// ReceiveSignedPut stores body under key when query carries an unexpired
+// signature for it.
+func (s *LocalStorage) ReceiveSignedPut(ctx context.Context, key string, query url.Values, body io.Reader, mimeType string) (domain.StoredObject, error) {
+	if len(s.signingKey) == 0 {
+		return domain.StoredObject{}, domain.ErrInvalidSignature
+	}
+	expires := query.Get("expires")
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return domain.StoredObject{}, domain.ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(s.sign(key, expires))) {
+		return domain.StoredObject{}, domain.ErrInvalidSignature
+	}
+	return s.Put(ctx, key, body, mimeType)
+}
+
+// Stat reports the stored blob's size and MD5 ETag.
+func (s *LocalStorage) Stat(_ context.Context, key string) (domain.StoredObject, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return domain.StoredObject{}, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return domain.StoredObject{}, fmt.Errorf("open blob: %w", err)
+	}
+	defer file.Close()
+	hash := md5.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return domain.StoredObject{}, fmt.Errorf("read blob: %w", err)
+	}
+	return domain.StoredObject{Key: key, Size: size, ETag: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+func (s *LocalStorage) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte("PUT\n" + key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	_ domain.PresigningStorage    = (*LocalStorage)(nil)
+	_ domain.SignedUploadReceiver = (*LocalStorage)(nil)
+)
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	domain "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+)
+
+func TestLocalStoragePersistsAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	root := filepath.Join(t.TempDir(), "uploads")
+	store, err := NewLocalStorage(root, "", nil)
+	require.NoError(t, err)
+
+	obj, err := store.Put(ctx, "uploads/7/doc/notes.txt", strings.NewReader("hello"), "text/plain")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), obj.Size)
+	require.Equal(t, "5d41402abc4b2a76b9719d911017c592", obj.ETag)
+
+	reopened, err := NewLocalStorage(root, "", nil)
+	require.NoError(t, err)
+	reader, err := reopened.Get(ctx, "uploads/7/doc/notes.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, "hello", string(data))
+
+	entries, err := os.ReadDir(filepath.Join(root, "uploads", "7", "doc"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temp files are left behind")
+
+	require.NoError(t, reopened.Delete(ctx, "uploads/7/doc/notes.txt"))
+	_, err = reopened.Get(ctx, "uploads/7/doc/notes.txt")
+	require.Error(t, err)
+	require.NoError(t, reopened.Delete(ctx, "uploads/7/doc/notes.txt"))
+	_, err = os.Stat(filepath.Join(root, "uploads"))
+	require.True(t, os.IsNotExist(err), "empty directories are removed")
+	_, err = os.Stat(root)
+	require.NoError(t, err)
+}
+
+func TestLocalStorageRejectsKeysOutsideRoot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewLocalStorage(filepath.Join(dir, "uploads"), "", nil)
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "../escape.txt", "uploads/1/../../../escape.txt", "/etc/passwd"} {
+		_, err := store.Put(ctx, key, strings.NewReader("x"), "text/plain")
+		require.Error(t, err, key)
+		_, err = store.Get(ctx, key)
+		require.Error(t, err, key)
+		require.Error(t, store.Delete(ctx, key), key)
+	}
+	_, err = os.Stat(filepath.Join(dir, "escape.txt"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestLocalStorageLeavesNothingWhenBodyFails(t *testing.T) {
+	ctx := context.Background()
+	root := filepath.Join(t.TempDir(), "uploads")
+	store, err := NewLocalStorage(root, "", nil)
+	require.NoError(t, err)
+
+	readErr := errors.New("client went away")
+	body := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(readErr))
+	_, err = store.Put(ctx, "uploads/7/doc/notes.txt", body, "text/plain")
+	require.ErrorIs(t, err, readErr)
+
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestLocalStorageReceivesOnlySignedUploads(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStorage(t.TempDir(), "/api/v1/upload-ask/direct-uploads/", []byte("secret"))
+	require.NoError(t, err)
+
+	req, err := store.PresignPut(ctx, "uploads/7/doc/my notes.txt", "text/plain", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "PUT", req.Method)
+	require.Equal(t, "text/plain", req.Headers["Content-Type"])
+	signed, err := url.Parse(req.URL)
+	require.NoError(t, err)
+	require.Equal(t, "/api/v1/upload-ask/direct-uploads/uploads/7/doc/my%20notes.txt", signed.EscapedPath())
+
+	_, err = store.ReceiveSignedPut(ctx, "uploads/7/doc/other.txt", signed.Query(), strings.NewReader("hello"), "text/plain")
+	require.ErrorIs(t, err, domain.ErrInvalidSignature, "the signature covers the key")
+	expired := signed.Query()
+	expired.Set("expires", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+	_, err = store.ReceiveSignedPut(ctx, "uploads/7/doc/my notes.txt", expired, strings.NewReader("hello"), "text/plain")
+	require.ErrorIs(t, err, domain.ErrInvalidSignature)
+
+	obj, err := store.ReceiveSignedPut(ctx, "uploads/7/doc/my notes.txt", signed.Query(), strings.NewReader("hello"), "text/plain")
+	require.NoError(t, err)
+	stat, err := store.Stat(ctx, "uploads/7/doc/my notes.txt")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), stat.Size)
+	require.Equal(t, obj.ETag, stat.ETag)
+
+	unsigned, err := NewLocalStorage(t.TempDir(), "", nil)
+	require.NoError(t, err)
+	_, err = unsigned.PresignPut(ctx, "uploads/7/doc/notes.txt", "text/plain", time.Minute)
+	require.Error(t, err)
+}
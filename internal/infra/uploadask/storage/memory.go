@@ -29,8 +29,12 @@ func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{blobs: make(map[string]storedBlob)}
 }
 
-// Put stores the blob and returns metadata.
-func (s *MemoryStorage) Put(_ context.Context, key string, data []byte, mimeType string) (domain.StoredObject, error) {
+// Put reads the blob into memory and returns metadata.
+func (s *MemoryStorage) Put(_ context.Context, key string, body io.Reader, mimeType string) (domain.StoredObject, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return domain.StoredObject{}, fmt.Errorf("read blob: %w", err)
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	hash := md5.Sum(data)
@@ -1,12 +1,12 @@
 package storage
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -52,15 +52,18 @@ func (s *R2Storage) ensureBucket(ctx context.Context) error {
 	return nil
 }
 
-// Put uploads data to R2.
-func (s *R2Storage) Put(ctx context.Context, key string, data []byte, mimeType string) (domain.StoredObject, error) {
+// r2PartSize is the multipart chunk buffered per request when streaming an
+// upload of unknown length; bodies smaller than one part go up in one call.
+const r2PartSize = 16 << 20
+
+// Put streams body to R2. A failed read aborts the upload.
+func (s *R2Storage) Put(ctx context.Context, key string, body io.Reader, mimeType string) (domain.StoredObject, error) {
 	if err := s.ensureBucket(ctx); err != nil {
 		return domain.StoredObject{}, err
 	}
-	reader := bytes.NewReader(data)
-	info, err := s.client.PutObject(ctx, s.bucket, key, reader, int64(len(data)), minio.PutObjectOptions{
-		ContentType:      mimeType,
-		DisableMultipart: len(data) < 5*1024*1024, // small uploads as single part
+	info, err := s.client.PutObject(ctx, s.bucket, key, body, -1, minio.PutObjectOptions{
+		ContentType: mimeType,
+		PartSize:    r2PartSize,
 	})
 	if err != nil {
 		return domain.StoredObject{}, err
@@ -86,12 +89,43 @@ func (s *R2Storage) Get(ctx context.Context, key string) (io.ReadCloser, error)
 	return obj, nil
 }
 
+// PresignPut returns a presigned S3 PUT for key. The bucket's CORS rules
+// must allow browser PUTs and expose the ETag header.
+func (s *R2Storage) PresignPut(ctx context.Context, key, mimeType string, ttl time.Duration) (domain.PresignedRequest, error) {
+	if err := s.ensureBucket(ctx); err != nil {
+		return domain.PresignedRequest{}, err
+	}
+	u, err := s.client.PresignedPutObject(ctx, s.bucket, key, ttl)
+	if err != nil {
+		return domain.PresignedRequest{}, err
+	}
+	return domain.PresignedRequest{
+		Method:  "PUT",
+		URL:     u.String(),
+		Headers: map[string]string{"Content-Type": mimeType},
+	}, nil
+}
+
+// Stat reports an object's size and ETag.
+func (s *R2Storage) Stat(ctx context.Context, key string) (domain.StoredObject, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return domain.StoredObject{}, err
+	}
+	return domain.StoredObject{
+		Key:      key,
+		Size:     info.Size,
+		MimeType: info.ContentType,
+		ETag:     info.ETag,
+	}, nil
+}
+
 // Delete removes an object.
 func (s *R2Storage) Delete(ctx context.Context, key string) error {
 	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
 }
 
-var _ domain.ObjectStorage = (*R2Storage)(nil)
+var _ domain.PresigningStorage = (*R2Storage)(nil)
 
 // sanitizeEndpoint removes schemes and paths to satisfy minio.New expectations.
 func sanitizeEndpoint(raw string) string {
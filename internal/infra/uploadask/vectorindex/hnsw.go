@@ -0,0 +1,287 @@
+package vectorindex
+
+import (
+	"container/heap"
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// graph is a hierarchical navigable small world graph over unit vectors, so
+// cosine similarity reduces to a dot product. Removed nodes stay linked to
+// keep the graph navigable and are skipped in results until a rebuild.
+type graph struct {
+	Dim      int
+	Nodes    []node
+	Entry    int32
+	MaxLevel int
+	Removed  int
+
+	ids map[string]int32
+}
+
+type node struct {
+	ID      string
+	Vector  []float32
+	Links   [][]int32
+	Removed bool
+}
+
+// params are the construction parameters shared by every graph of an index.
+type params struct {
+	m              int
+	efConstruction int
+	levelMult      float64
+}
+
+type candidate struct {
+	node int32
+	sim  float32
+}
+
+func newGraph(dim int) *graph {
+	return &graph{Dim: dim, Entry: -1, ids: make(map[string]int32)}
+}
+
+// live returns the number of nodes that can appear in results.
+func (g *graph) live() int {
+	return len(g.Nodes) - g.Removed
+}
+
+// reindex rebuilds the ID lookup after the graph is decoded.
+func (g *graph) reindex() {
+	g.ids = make(map[string]int32, len(g.Nodes))
+	for i, n := range g.Nodes {
+		g.ids[n.ID] = int32(i)
+	}
+}
+
+// insert adds vec under id. Re-adding a removed ID revives it; chunk vectors
+// never change, so an existing node is kept as is.
+func (g *graph) insert(p params, rng *rand.Rand, id string, vec []float32) {
+	if idx, ok := g.ids[id]; ok {
+		if g.Nodes[idx].Removed {
+			g.Nodes[idx].Removed = false
+			g.Removed--
+		}
+		return
+	}
+	q := normalize(vec)
+	level := int(-math.Log(1-rng.Float64()) * p.levelMult)
+	idx := int32(len(g.Nodes))
+	g.Nodes = append(g.Nodes, node{ID: id, Vector: q, Links: make([][]int32, level+1)})
+	g.ids[id] = idx
+	if g.Entry < 0 {
+		g.Entry = idx
+		g.MaxLevel = level
+		return
+	}
+
+	ep := g.Entry
+	for l := g.MaxLevel; l > level; l-- {
+		ep = g.greedy(q, ep, l)
+	}
+	for l := min(level, g.MaxLevel); l >= 0; l-- {
+		found := g.searchLayer(q, ep, p.efConstruction, l)
+		maxLinks := p.m
+		if l == 0 {
+			maxLinks = 2 * p.m
+		}
+		neighbors := g.selectNeighbors(found, p.m)
+		g.Nodes[idx].Links[l] = neighbors
+		for _, nb := range neighbors {
+			links := append(g.Nodes[nb].Links[l], idx)
+			if len(links) > maxLinks {
+				links = g.prune(nb, links, maxLinks)
+			}
+			g.Nodes[nb].Links[l] = links
+		}
+		ep = found[0].node
+	}
+	if level > g.MaxLevel {
+		g.MaxLevel = level
+		g.Entry = idx
+	}
+}
+
+// remove marks id as removed and reports whether it was live.
+func (g *graph) remove(id string) bool {
+	idx, ok := g.ids[id]
+	if !ok || g.Nodes[idx].Removed {
+		return false
+	}
+	g.Nodes[idx].Removed = true
+	g.Removed++
+	return true
+}
+
+// search returns up to k live IDs most similar to query, best first.
+func (g *graph) search(query []float32, k, ef int) []string {
+	if g.Entry < 0 || k <= 0 {
+		return nil
+	}
+	q := normalize(query)
+	ep := g.Entry
+	for l := g.MaxLevel; l > 0; l-- {
+		ep = g.greedy(q, ep, l)
+	}
+	found := g.searchLayer(q, ep, max(ef, k), 0)
+	out := make([]string, 0, k)
+	for _, c := range found {
+		if g.Nodes[c.node].Removed {
+			continue
+		}
+		out = append(out, g.Nodes[c.node].ID)
+		if len(out) == k {
+			break
+		}
+	}
+	return out
+}
+
+// greedy walks level from ep towards q and returns the closest node found.
+func (g *graph) greedy(q []float32, ep int32, level int) int32 {
+	best := dot(q, g.Nodes[ep].Vector)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range g.Nodes[ep].Links[level] {
+			if sim := dot(q, g.Nodes[nb].Vector); sim > best {
+				best, ep, changed = sim, nb, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer runs a best-first search of level from ep and returns the ef
+// closest nodes, best first.
+func (g *graph) searchLayer(q []float32, ep int32, ef int, level int) []candidate {
+	visited := make([]uint64, (len(g.Nodes)+63)/64)
+	visit := func(n int32) bool {
+		word, bit := n/64, uint64(1)<<(n%64)
+		if visited[word]&bit != 0 {
+			return false
+		}
+		visited[word] |= bit
+		return true
+	}
+	visit(ep)
+	start := candidate{node: ep, sim: dot(q, g.Nodes[ep].Vector)}
+	frontier := &maxHeap{start}
+	results := &minHeap{start}
+	for frontier.Len() > 0 {
+		current := heap.Pop(frontier).(candidate)
+		if results.Len() >= ef && current.sim < (*results)[0].sim {
+			break
+		}
+		for _, nb := range g.Nodes[current.node].Links[level] {
+			if !visit(nb) {
+				continue
+			}
+			sim := dot(q, g.Nodes[nb].Vector)
+			if results.Len() < ef || sim > (*results)[0].sim {
+				heap.Push(frontier, candidate{node: nb, sim: sim})
+				heap.Push(results, candidate{node: nb, sim: sim})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	out := []candidate(*results)
+	sort.Slice(out, func(i, j int) bool { return out[i].sim > out[j].sim })
+	return out
+}
+
+// selectNeighbors picks up to m of the candidates, sorted best first,
+// preferring ones closer to the new node than to any neighbor already
+// picked so links spread in different directions. Skipped candidates fill
+// any remaining slots.
+func (g *graph) selectNeighbors(cands []candidate, m int) []int32 {
+	out := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range cands {
+		if len(out) == m {
+			break
+		}
+		diverse := true
+		for _, picked := range out {
+			if dot(g.Nodes[c.node].Vector, g.Nodes[picked].Vector) > c.sim {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			out = append(out, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, n := range skipped {
+		if len(out) == m {
+			break
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// prune cuts the links of n back to maxLinks.
+func (g *graph) prune(n int32, links []int32, maxLinks int) []int32 {
+	cands := make([]candidate, len(links))
+	for i, l := range links {
+		cands[i] = candidate{node: l, sim: dot(g.Nodes[n].Vector, g.Nodes[l].Vector)}
+	}
+	sort.Slice(cands, func(i, j int) bool { return cands[i].sim > cands[j].sim })
+	return g.selectNeighbors(cands, maxLinks)
+}
+
+func normalize(vec []float32) []float32 {
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	out := make([]float32, len(vec))
+	if norm == 0 {
+		return out
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i, v := range vec {
+		out[i] = v * scale
+	}
+	return out
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+type maxHeap []candidate
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i].sim > h[j].sim }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *maxHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+type minHeap []candidate
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].sim < h[j].sim }
+func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x any)        { *h = append(*h, x.(candidate)) }
+func (h *minHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,301 @@
+// Package vectorindex keeps an in-process approximate nearest neighbour index
+// over chunk embeddings, with one HNSW graph per user and embedding size.
+package vectorindex
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// snapshotVersion changes whenever the persisted layout does.
+const snapshotVersion = 1
+
+// Options tune the index. Zero values take the defaults.
+type Options struct {
+	// M is the number of links per node; the bottom layer keeps twice as many.
+	M int
+	// EfConstruction is the candidate list size used while inserting.
+	EfConstruction int
+	// EfSearch is the candidate list size used while searching.
+	EfSearch int
+	// MinVectors is the graph size below which Search declines, leaving small
+	// collections to an exact scan.
+	MinVectors int
+	// SaveEvery saves the index after this many changes.
+	SaveEvery int
+}
+
+func (o Options) withDefaults() Options {
+	if o.M <= 0 {
+		o.M = 16
+	}
+	if o.EfConstruction <= 0 {
+		o.EfConstruction = 200
+	}
+	if o.EfSearch <= 0 {
+		o.EfSearch = 256
+	}
+	if o.MinVectors < 0 {
+		o.MinVectors = 0
+	}
+	if o.SaveEvery <= 0 {
+		o.SaveEvery = 1000
+	}
+	return o
+}
+
+// Entry is a vector to add to the index.
+type Entry struct {
+	ID     string
+	UserID int64
+	Vector []float32
+}
+
+type graphKey struct {
+	UserID int64
+	Dim    int
+}
+
+// Index holds the graphs and the sequence number of the last change applied
+// to them, so a caller can replay later changes from its store.
+type Index struct {
+	mu        sync.RWMutex
+	path      string
+	opts      Options
+	params    params
+	rng       *rand.Rand
+	graphs    map[graphKey]*graph
+	watermark int64
+	changes   int
+	logger    *slog.Logger
+}
+
+// New returns an empty index persisted to path. An empty path keeps it in
+// memory only.
+func New(path string, opts Options, logger *slog.Logger) *Index {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	opts = opts.withDefaults()
+	return &Index{
+		path: path,
+		opts: opts,
+		params: params{
+			m:              opts.M,
+			efConstruction: opts.EfConstruction,
+			levelMult:      1 / math.Log(float64(opts.M)),
+		},
+		rng:    rand.New(rand.NewPCG(1, 2)),
+		graphs: make(map[graphKey]*graph),
+		logger: logger.With("component", "vectorindex"),
+	}
+}
+
+// Open loads the index saved at path, or returns an empty one when there is
+// no file yet.
+func Open(path string, opts Options, logger *slog.Logger) (*Index, error) {
+	idx := New(path, opts, logger)
+	if path == "" {
+		return idx, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open vector index: %w", err)
+	}
+	defer file.Close()
+	var snap snapshot
+	if err := gob.NewDecoder(file).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("decode vector index: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("vector index version %d is not supported", snap.Version)
+	}
+	for _, g := range snap.Graphs {
+		g.Graph.reindex()
+		idx.graphs[graphKey{UserID: g.UserID, Dim: g.Graph.Dim}] = g.Graph
+	}
+	idx.watermark = snap.Watermark
+	return idx, nil
+}
+
+// Watermark returns the sequence number of the last change applied.
+func (i *Index) Watermark() int64 {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.watermark
+}
+
+// Apply adds entries and advances the watermark to seq. Changes at or below
+// the current watermark were already applied and are ignored.
+func (i *Index) Apply(entries []Entry, seq int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if seq <= i.watermark {
+		return
+	}
+	for _, e := range entries {
+		if len(e.Vector) == 0 {
+			continue
+		}
+		key := graphKey{UserID: e.UserID, Dim: len(e.Vector)}
+		g, ok := i.graphs[key]
+		if !ok {
+			g = newGraph(key.Dim)
+			i.graphs[key] = g
+		}
+		g.insert(i.params, i.rng, e.ID, e.Vector)
+	}
+	i.watermark = seq
+	i.changes += len(entries)
+	i.maybeSave()
+}
+
+// Remove drops ids from the user's graphs, rebuilding a graph once a quarter
+// of its nodes are removed.
+func (i *Index) Remove(userID int64, ids ...string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for key, g := range i.graphs {
+		if key.UserID != userID {
+			continue
+		}
+		for _, id := range ids {
+			if g.remove(id) {
+				i.changes++
+			}
+		}
+		i.compact(key, g)
+	}
+	i.maybeSave()
+}
+
+// Retain drops every ID for which keep returns false.
+func (i *Index) Retain(keep func(id string) bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for key, g := range i.graphs {
+		for _, n := range g.Nodes {
+			if !n.Removed && !keep(n.ID) && g.remove(n.ID) {
+				i.changes++
+			}
+		}
+		i.compact(key, g)
+	}
+	i.maybeSave()
+}
+
+// Reset empties the index so it is rebuilt from the first change.
+func (i *Index) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.graphs = make(map[graphKey]*graph)
+	i.watermark = 0
+	i.changes = 0
+}
+
+// Search returns up to k IDs of the user's vectors most similar to query,
+// best first. It reports false when the user's graph holds fewer than
+// MinVectors vectors and an exact scan should be used instead.
+func (i *Index) Search(userID int64, query []float32, k int) ([]string, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	g, ok := i.graphs[graphKey{UserID: userID, Dim: len(query)}]
+	if !ok || g.live() < max(i.opts.MinVectors, 1) {
+		return nil, false
+	}
+	return g.search(query, k, i.opts.EfSearch), true
+}
+
+// Save writes the index to its path, replacing the previous file atomically.
+func (i *Index) Save() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.save()
+}
+
+// maybeSave saves once enough changes pile up. A failed save only costs
+// replaying those changes after a restart, so it is logged, not returned.
+func (i *Index) maybeSave() {
+	if i.changes < i.opts.SaveEvery {
+		return
+	}
+	if err := i.save(); err != nil {
+		i.logger.Warn("save vector index failed", "path", i.path, "error", err)
+	}
+}
+
+func (i *Index) save() error {
+	if i.path == "" {
+		i.changes = 0
+		return nil
+	}
+	snap := snapshot{Version: snapshotVersion, Watermark: i.watermark}
+	for key, g := range i.graphs {
+		snap.Graphs = append(snap.Graphs, userGraph{UserID: key.UserID, Graph: g})
+	}
+	dir := filepath.Dir(i.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create vector index directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".vectorindex-*")
+	if err != nil {
+		return fmt.Errorf("create temp vector index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		return fmt.Errorf("encode vector index: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync vector index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close vector index: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), i.path); err != nil {
+		return fmt.Errorf("move vector index into place: %w", err)
+	}
+	i.changes = 0
+	return nil
+}
+
+// compact rebuilds g from its live nodes once a quarter of them are removed,
+// since removed nodes still cost search time.
+func (i *Index) compact(key graphKey, g *graph) {
+	if g.Removed == 0 || g.Removed*4 < len(g.Nodes) {
+		return
+	}
+	if g.live() == 0 {
+		delete(i.graphs, key)
+		return
+	}
+	rebuilt := newGraph(g.Dim)
+	for _, n := range g.Nodes {
+		if !n.Removed {
+			rebuilt.insert(i.params, i.rng, n.ID, n.Vector)
+		}
+	}
+	i.graphs[key] = rebuilt
+}
+
+type snapshot struct {
+	Version   int
+	Watermark int64
+	Graphs    []userGraph
+}
+
+type userGraph struct {
+	UserID int64
+	Graph  *graph
+}
@@ -0,0 +1,157 @@
+package vectorindex
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// randomEntries draws n vectors around a few topic centroids, which is closer
+// to how document embeddings spread than uniform noise.
+func randomEntries(rng *rand.Rand, userID int64, n, dim int) []Entry {
+	centroids := make([][]float32, 20)
+	for i := range centroids {
+		centroids[i] = make([]float32, dim)
+		for j := range centroids[i] {
+			centroids[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	entries := make([]Entry, n)
+	for i := range entries {
+		centroid := centroids[rng.IntN(len(centroids))]
+		vec := make([]float32, dim)
+		for j := range vec {
+			vec[j] = centroid[j] + float32(0.7*rng.NormFloat64())
+		}
+		entries[i] = Entry{ID: fmt.Sprintf("chunk-%d-%d", userID, i), UserID: userID, Vector: vec}
+	}
+	return entries
+}
+
+// bruteForce returns the k IDs most similar to query by exhaustive scan.
+func bruteForce(entries []Entry, query []float32, k int) []string {
+	q := normalize(query)
+	type scored struct {
+		id  string
+		sim float32
+	}
+	all := make([]scored, len(entries))
+	for i, e := range entries {
+		all[i] = scored{id: e.ID, sim: dot(q, normalize(e.Vector))}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].sim > all[j].sim })
+	out := make([]string, 0, k)
+	for _, s := range all[:min(k, len(all))] {
+		out = append(out, s.id)
+	}
+	return out
+}
+
+func recall(got, want []string) float64 {
+	wanted := make(map[string]bool, len(want))
+	for _, id := range want {
+		wanted[id] = true
+	}
+	hits := 0
+	for _, id := range got {
+		if wanted[id] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(want))
+}
+
+func TestIndexRecallAgainstBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewPCG(7, 7))
+	all := randomEntries(rng, 1, 2050, 32)
+	entries, queries := all[:2000], all[2000:]
+	idx := New("", Options{}, nil)
+	idx.Apply(entries, 1)
+
+	var total float64
+	for _, q := range queries {
+		got, ok := idx.Search(1, q.Vector, 10)
+		require.True(t, ok)
+		total += recall(got, bruteForce(entries, q.Vector, 10))
+	}
+	require.GreaterOrEqual(t, total/float64(len(queries)), 0.9)
+}
+
+func TestIndexSeparatesUsersAndSkipsRemoved(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	mine := randomEntries(rng, 1, 50, 8)
+	theirs := randomEntries(rng, 2, 50, 8)
+	idx := New("", Options{MinVectors: 10}, nil)
+	idx.Apply(append(mine, theirs...), 1)
+
+	got, ok := idx.Search(1, theirs[0].Vector, 50)
+	require.True(t, ok)
+	require.Len(t, got, 50)
+	require.Equal(t, recall(got, bruteForce(mine, theirs[0].Vector, 50)), 1.0)
+
+	_, ok = idx.Search(1, []float32{1, 2, 3}, 5)
+	require.False(t, ok, "no graph for this embedding size")
+
+	idx.Remove(1, mine[0].ID)
+	got, _ = idx.Search(1, mine[0].Vector, 5)
+	require.NotContains(t, got, mine[0].ID)
+
+	idx.Retain(func(id string) bool { return id == mine[1].ID })
+	_, ok = idx.Search(1, mine[1].Vector, 5)
+	require.False(t, ok, "a single vector is below MinVectors")
+}
+
+func TestIndexSavesAndReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chunks.hnsw")
+	rng := rand.New(rand.NewPCG(3, 3))
+	entries := randomEntries(rng, 1, 300, 16)
+	idx := New(path, Options{}, nil)
+	idx.Apply(entries, 42)
+	want, ok := idx.Search(1, entries[5].Vector, 10)
+	require.True(t, ok)
+	require.NoError(t, idx.Save())
+
+	reopened, err := Open(path, Options{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), reopened.Watermark())
+	got, ok := reopened.Search(1, entries[5].Vector, 10)
+	require.True(t, ok)
+	require.Equal(t, want, got)
+
+	// Replayed changes are ignored.
+	reopened.Apply(randomEntries(rng, 1, 5, 16), 40)
+	require.Equal(t, int64(42), reopened.Watermark())
+}
+
+// BenchmarkSearch compares the index with the exhaustive scan it replaces
+// and reports recall@10 against that scan.
+func BenchmarkSearch(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		rng := rand.New(rand.NewPCG(5, 5))
+		all := randomEntries(rng, 1, n+100, 256)
+		entries, queries := all[:n], all[n:]
+		idx := New("", Options{}, nil)
+		idx.Apply(entries, 1)
+
+		b.Run(fmt.Sprintf("brute/n=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bruteForce(entries, queries[i%len(queries)].Vector, 10)
+			}
+		})
+		b.Run(fmt.Sprintf("hnsw/n=%d", n), func(b *testing.B) {
+			var total float64
+			for i := 0; i < b.N; i++ {
+				q := queries[i%len(queries)].Vector
+				got, _ := idx.Search(1, q, 10)
+				b.StopTimer()
+				total += recall(got, bruteForce(entries, q, 10))
+				b.StartTimer()
+			}
+			b.ReportMetric(total/float64(b.N), "recall@10")
+		})
+	}
+}
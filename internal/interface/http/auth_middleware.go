@@ -38,3 +38,24 @@ func authMiddleware(svc auth.Service) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// adminMiddleware only lets through users whose email is listed in emails.
+// It must run after authMiddleware.
+func adminMiddleware(emails []string) gin.HandlerFunc {
+	allowed := make(map[string]struct{}, len(emails))
+	for _, email := range emails {
+		allowed[strings.ToLower(strings.TrimSpace(email))] = struct{}{}
+	}
+	return func(c *gin.Context) {
+		claims, ok := getClaims(c)
+		if !ok {
+			abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+			return
+		}
+		if _, ok := allowed[strings.ToLower(claims.Email)]; !ok {
+			abortWithError(c, NewHTTPError(http.StatusForbidden, "forbidden", "admin access required", nil))
+			return
+		}
+		c.Next()
+	}
+}
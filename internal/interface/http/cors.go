@@ -12,8 +12,9 @@ func corsMiddleware(allowed []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		headers := c.Writer.Header()
 		headers.Set("Access-Control-Allow-Origin", resolveOrigin(c.GetHeader("Origin"), allowed))
-		headers.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		headers.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		headers.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		headers.Set("Access-Control-Expose-Headers", "ETag")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
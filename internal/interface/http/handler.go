@@ -12,6 +12,7 @@ import (
 	"github.com/yanqian/ai-helloworld/internal/domain/summarizer"
 	uploadask "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
 	"github.com/yanqian/ai-helloworld/internal/domain/uvadvisor"
+	"github.com/yanqian/ai-helloworld/internal/infra/embeddingcache"
 	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
 )
 
@@ -22,17 +23,19 @@ type Handler struct {
 	faqSvc        faq.Service
 	authSvc       auth.Service
 	uploadSvc     *uploadask.Service
+	embedCache    *embeddingcache.Cache
 	logger        *slog.Logger
 }
 
 // NewHandler constructs the root HTTP handler.
-func NewHandler(summarySvc summarizer.Service, advisorSvc uvadvisor.Service, faqSvc faq.Service, authSvc auth.Service, uploadSvc *uploadask.Service, logger *slog.Logger) *Handler {
+func NewHandler(summarySvc summarizer.Service, advisorSvc uvadvisor.Service, faqSvc faq.Service, authSvc auth.Service, uploadSvc *uploadask.Service, embedCache *embeddingcache.Cache, logger *slog.Logger) *Handler {
 	return &Handler{
 		summarizerSvc: summarySvc,
 		advisorSvc:    advisorSvc,
 		faqSvc:        faqSvc,
 		authSvc:       authSvc,
 		uploadSvc:     uploadSvc,
+		embedCache:    embedCache,
 		logger:        logger.With("component", "http.handler"),
 	}
 }
@@ -349,3 +352,13 @@ func errMessage(err error) string {
 	}
 	return err.Error()
 }
+
+// EmbeddingCacheStats reports embedding cache hits and misses since startup.
+func (h *Handler) EmbeddingCacheStats(c *gin.Context) {
+	stats := h.embedCache.Stats()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": h.embedCache != nil,
+		"hits":    stats.Hits,
+		"misses":  stats.Misses,
+	})
+}
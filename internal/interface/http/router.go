@@ -15,6 +15,8 @@ func NewRouter(cfg *config.Config, handler *Handler) *http.Server {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
+	// Multipart files beyond this spill to temp files instead of memory.
+	router.MaxMultipartMemory = 8 << 20
 	router.Use(
 		gin.Recovery(),
 		errorHandlingMiddleware(handler.logger),
@@ -34,6 +36,9 @@ func NewRouter(cfg *config.Config, handler *Handler) *http.Server {
 			authRoutes.GET("/google/callback", handler.GoogleCallback)
 		}
 
+		// Presigned direct uploads authenticate with the URL signature, not a token.
+		api.PUT("/upload-ask/direct-uploads/*key", handler.ReceiveDirectUpload)
+
 		protected := api.Group("/")
 		protected.Use(authMiddleware(handler.authSvc))
 		{
@@ -47,12 +52,45 @@ func NewRouter(cfg *config.Config, handler *Handler) *http.Server {
 			uploadAsk := protected.Group("/upload-ask")
 			{
 				uploadAsk.POST("/documents", handler.UploadDocument)
+				uploadAsk.POST("/documents/from-url", handler.IngestDocumentFromURL)
+				uploadAsk.POST("/uploads", handler.CreateUploadIntent)
+				uploadAsk.POST("/uploads/:id/confirm", handler.ConfirmUploadIntent)
 				uploadAsk.GET("/documents", handler.ListDocuments)
 				uploadAsk.GET("/documents/:id", handler.GetDocument)
+				uploadAsk.GET("/documents/:id/events", handler.DocumentEvents)
+				uploadAsk.PATCH("/documents/:id", handler.UpdateDocumentLabels)
+				uploadAsk.DELETE("/documents/:id", handler.DeleteDocument)
+				uploadAsk.POST("/documents/reindex", handler.ReindexDocuments)
+				uploadAsk.POST("/documents/:id/reindex", handler.ReindexDocument)
+				uploadAsk.GET("/documents/:id/shares", handler.ListDocumentShares)
+				uploadAsk.PUT("/documents/:id/shares/:userId", handler.ShareDocument)
+				uploadAsk.DELETE("/documents/:id/shares/:userId", handler.UnshareDocument)
+				uploadAsk.GET("/usage", handler.Usage)
+				uploadAsk.GET("/tags", handler.ListTags)
+				uploadAsk.POST("/collections", handler.CreateCollection)
+				uploadAsk.GET("/collections", handler.ListCollections)
+				uploadAsk.GET("/collections/:id", handler.GetCollection)
+				uploadAsk.PATCH("/collections/:id", handler.UpdateCollection)
+				uploadAsk.DELETE("/collections/:id", handler.DeleteCollection)
+				uploadAsk.GET("/collections/:id/documents", handler.ListCollectionDocuments)
+				uploadAsk.POST("/collections/:id/documents", handler.AddCollectionDocuments)
+				uploadAsk.DELETE("/collections/:id/documents/:docId", handler.RemoveCollectionDocument)
+				uploadAsk.GET("/collections/:id/shares", handler.ListCollectionShares)
+				uploadAsk.PUT("/collections/:id/shares/:userId", handler.ShareCollection)
+				uploadAsk.DELETE("/collections/:id/shares/:userId", handler.UnshareCollection)
+				uploadAsk.GET("/shared", handler.ListShared)
 				uploadAsk.POST("/qa/query", handler.AskQuestion)
+				uploadAsk.POST("/qa/query/stream", handler.AskQuestionStream)
 				uploadAsk.GET("/qa/sessions", handler.ListSessions)
 				uploadAsk.GET("/qa/sessions/:id/logs", handler.ListSessionLogs)
 			}
+			admin := protected.Group("/admin")
+			admin.Use(adminMiddleware(cfg.Auth.AdminEmails))
+			{
+				admin.GET("/upload-ask/jobs", handler.ListUploadJobs)
+				admin.POST("/upload-ask/jobs/:id/requeue", handler.RequeueUploadJob)
+				admin.GET("/embedding-cache", handler.EmbeddingCacheStats)
+			}
 		}
 	}
 
@@ -5,11 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -25,8 +27,12 @@ import (
 	uploadask "github.com/yanqian/ai-helloworld/internal/domain/uploadask"
 	"github.com/yanqian/ai-helloworld/internal/domain/uvadvisor"
 	"github.com/yanqian/ai-helloworld/internal/infra/config"
+	"github.com/yanqian/ai-helloworld/internal/infra/embeddingcache"
+	sqliteinfra "github.com/yanqian/ai-helloworld/internal/infra/sqlite"
 	uploadchunker "github.com/yanqian/ai-helloworld/internal/infra/uploadask/chunker"
 	uploadembedder "github.com/yanqian/ai-helloworld/internal/infra/uploadask/embedder"
+	uploadextractor "github.com/yanqian/ai-helloworld/internal/infra/uploadask/extractor"
+	uploadfetcher "github.com/yanqian/ai-helloworld/internal/infra/uploadask/fetcher"
 	uploadllm "github.com/yanqian/ai-helloworld/internal/infra/uploadask/llm"
 	uploadmemory "github.com/yanqian/ai-helloworld/internal/infra/uploadask/memory"
 	uploadqueue "github.com/yanqian/ai-helloworld/internal/infra/uploadask/queue"
@@ -139,7 +145,7 @@ func TestRouter_CORSPreflight(t *testing.T) {
 
 	require.Equal(t, http.StatusNoContent, recorder.Code)
 	require.Equal(t, "*", recorder.Header().Get("Access-Control-Allow-Origin"))
-	require.Equal(t, "GET, POST, OPTIONS", recorder.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "GET, POST, PUT, PATCH, DELETE, OPTIONS", recorder.Header().Get("Access-Control-Allow-Methods"))
 	require.Equal(t, "Content-Type, Authorization", recorder.Header().Get("Access-Control-Allow-Headers"))
 }
 
@@ -236,9 +242,19 @@ func TestRouter_ProtectedContractSmokeRejectsMissingAuth(t *testing.T) {
 		{name: "upload document", method: http.MethodPost, path: "/api/v1/upload-ask/documents"},
 		{name: "upload document list", method: http.MethodGet, path: "/api/v1/upload-ask/documents"},
 		{name: "upload document get", method: http.MethodGet, path: "/api/v1/upload-ask/documents/" + documentID},
+		{name: "upload document labels", method: http.MethodPatch, path: "/api/v1/upload-ask/documents/" + documentID, body: `{"tags":["hr"]}`},
+		{name: "upload document delete", method: http.MethodDelete, path: "/api/v1/upload-ask/documents/" + documentID},
+		{name: "upload document reindex", method: http.MethodPost, path: "/api/v1/upload-ask/documents/" + documentID + "/reindex"},
+		{name: "upload documents reindex", method: http.MethodPost, path: "/api/v1/upload-ask/documents/reindex"},
+		{name: "upload document events", method: http.MethodGet, path: "/api/v1/upload-ask/documents/" + documentID + "/events"},
+		{name: "upload tags", method: http.MethodGet, path: "/api/v1/upload-ask/tags"},
 		{name: "upload qa query", method: http.MethodPost, path: "/api/v1/upload-ask/qa/query", body: `{"query":"hello"}`},
+		{name: "upload qa query stream", method: http.MethodPost, path: "/api/v1/upload-ask/qa/query/stream", body: `{"query":"hello"}`},
 		{name: "upload qa sessions", method: http.MethodGet, path: "/api/v1/upload-ask/qa/sessions"},
 		{name: "upload qa session logs", method: http.MethodGet, path: "/api/v1/upload-ask/qa/sessions/" + sessionID + "/logs"},
+		{name: "admin upload jobs", method: http.MethodGet, path: "/api/v1/admin/upload-ask/jobs"},
+		{name: "admin upload job requeue", method: http.MethodPost, path: "/api/v1/admin/upload-ask/jobs/" + documentID + "/requeue"},
+		{name: "admin embedding cache", method: http.MethodGet, path: "/api/v1/admin/embedding-cache"},
 	}
 
 	for _, tc := range cases {
@@ -407,6 +423,618 @@ func TestRouter_UploadAskLocalContractSmoke(t *testing.T) {
 	require.Equal(t, uploadBody.Document.ID, logsBody.Logs[0].Sources[0].DocumentID)
 }
 
+func TestRouter_UploadAskStreamQuery(t *testing.T) {
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, uploadstorage.NewMemoryStorage())
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc)
+
+	upload := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, "stream.txt", "Stream", "Streaming answers arrive as server sent events.")
+	require.Equal(t, http.StatusAccepted, upload.Code)
+	var uploadBody struct {
+		Document uploadask.Document `json:"document"`
+	}
+	require.NoError(t, json.Unmarshal(upload.Body.Bytes(), &uploadBody))
+	docPath := "/api/v1/upload-ask/documents/" + uploadBody.Document.ID.String()
+	require.Eventually(t, func() bool {
+		got := performJSONRequest(http.MethodGet, docPath, "", server)
+		var doc uploadask.Document
+		return got.Code == http.StatusOK && json.Unmarshal(got.Body.Bytes(), &doc) == nil && doc.Status == uploadask.DocumentStatusProcessed
+	}, time.Second, 10*time.Millisecond)
+
+	resp := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/qa/query/stream", `{"query":"How do answers arrive?"}`, server)
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.Equal(t, "text/event-stream", resp.Header().Get("Content-Type"))
+
+	var (
+		names  []string
+		answer strings.Builder
+		done   uploadask.AskStreamDone
+	)
+	for _, frame := range strings.Split(strings.TrimSpace(resp.Body.String()), "\n\n") {
+		lines := strings.SplitN(frame, "\n", 2)
+		require.Len(t, lines, 2)
+		name := strings.TrimPrefix(lines[0], "event: ")
+		data := []byte(strings.TrimPrefix(lines[1], "data: "))
+		names = append(names, name)
+		switch name {
+		case uploadask.AskStreamEventSources:
+			var sources uploadask.AskStreamSources
+			require.NoError(t, json.Unmarshal(data, &sources))
+			require.NotEmpty(t, sources.Sources)
+		case uploadask.AskStreamEventDelta:
+			var delta uploadask.AskStreamDelta
+			require.NoError(t, json.Unmarshal(data, &delta))
+			answer.WriteString(delta.Delta)
+		case uploadask.AskStreamEventDone:
+			require.NoError(t, json.Unmarshal(data, &done))
+		}
+	}
+	require.Equal(t, uploadask.AskStreamEventSources, names[0])
+	require.Equal(t, uploadask.AskStreamEventDone, names[len(names)-1])
+	require.Contains(t, answer.String(), "How do answers arrive?")
+	require.NotEqual(t, uuid.Nil, done.SessionID)
+
+	logs := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/qa/sessions/"+done.SessionID.String()+"/logs", "", server)
+	require.Equal(t, http.StatusOK, logs.Code)
+	require.Contains(t, logs.Body.String(), "How do answers arrive?")
+
+	invalid := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/qa/query/stream", `{"query":""}`, server)
+	require.Equal(t, http.StatusBadRequest, invalid.Code)
+}
+
+func TestRouter_UploadAskDeleteDocument(t *testing.T) {
+	storage := uploadstorage.NewMemoryStorage()
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, storage)
+	authSvc := &stubAuth{
+		validateFn: func(ctx context.Context, token string) (auth.Claims, error) {
+			switch token {
+			case defaultAuthToken:
+				return auth.Claims{UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}, nil
+			case "other-user":
+				return auth.Claims{UserID: 2, ExpiresAt: time.Now().Add(time.Hour)}, nil
+			}
+			return auth.Claims{}, apperrors.Wrap("invalid_token", "invalid token", nil)
+		},
+	}
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, authSvc, uploadSvc)
+
+	upload := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, "mistake.txt", "Mistake", "Accidental upload about quarterly payroll figures.")
+	require.Equal(t, http.StatusAccepted, upload.Code)
+	var uploadBody struct {
+		Document uploadask.Document `json:"document"`
+	}
+	require.NoError(t, json.Unmarshal(upload.Body.Bytes(), &uploadBody))
+	docID := uploadBody.Document.ID
+	docPath := "/api/v1/upload-ask/documents/" + docID.String()
+	require.Eventually(t, func() bool {
+		got := performJSONRequest(http.MethodGet, docPath, "", server)
+		var doc uploadask.Document
+		return got.Code == http.StatusOK && json.Unmarshal(got.Body.Bytes(), &doc) == nil && doc.Status == uploadask.DocumentStatusProcessed
+	}, time.Second, 10*time.Millisecond)
+	storageKey := "uploads/1/" + docID.String() + "/mistake.txt"
+	_, err := storage.Get(context.Background(), storageKey)
+	require.NoError(t, err)
+
+	other := performJSONRequest(http.MethodDelete, docPath, "", server, withAuthToken("other-user"))
+	require.Equal(t, http.StatusNotFound, other.Code)
+
+	deleted := performJSONRequest(http.MethodDelete, docPath, "", server)
+	require.Equal(t, http.StatusNoContent, deleted.Code)
+	require.Empty(t, deleted.Body.String())
+
+	_, err = storage.Get(context.Background(), storageKey)
+	require.Error(t, err)
+	require.Equal(t, http.StatusNotFound, performJSONRequest(http.MethodGet, docPath, "", server).Code)
+	require.Equal(t, http.StatusNotFound, performJSONRequest(http.MethodDelete, docPath, "", server).Code)
+
+	ask := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/qa/query", `{"query":"quarterly payroll figures"}`, server)
+	require.Equal(t, http.StatusOK, ask.Code)
+	var askBody uploadask.AskResponse
+	require.NoError(t, json.Unmarshal(ask.Body.Bytes(), &askBody))
+	require.Empty(t, askBody.Sources)
+
+	invalid := performJSONRequest(http.MethodDelete, "/api/v1/upload-ask/documents/not-a-uuid", "", server)
+	require.Equal(t, http.StatusBadRequest, invalid.Code)
+}
+
+func TestRouter_UploadAskDuplicateUpload(t *testing.T) {
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, uploadstorage.NewMemoryStorage())
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc)
+
+	first := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, "notes.txt", "Notes", "Identical content uploaded twice.")
+	require.Equal(t, http.StatusAccepted, first.Code)
+	second := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, "copy.txt", "Copy", "Identical content uploaded twice.")
+	require.Equal(t, http.StatusOK, second.Code)
+
+	var firstBody, secondBody uploadask.UploadResponse
+	require.NoError(t, json.Unmarshal(first.Body.Bytes(), &firstBody))
+	require.NoError(t, json.Unmarshal(second.Body.Bytes(), &secondBody))
+	require.False(t, firstBody.Duplicate)
+	require.True(t, secondBody.Duplicate)
+	require.Equal(t, firstBody.Document.ID, secondBody.Document.ID)
+	require.Equal(t, "Notes", secondBody.Document.Title)
+}
+
+func TestRouter_UploadAskRejectsOversizeFile(t *testing.T) {
+	storage := uploadstorage.NewMemoryStorage()
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, storage)
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc)
+
+	// Just over the file limit is caught while streaming into storage; far
+	// over it, the request body cap stops reading the form.
+	for _, size := range []int{1024*1024 + 1, 4 * 1024 * 1024} {
+		resp := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, "big.txt", "Big", strings.Repeat("x", size))
+		require.Equal(t, http.StatusBadRequest, resp.Code, size)
+		require.Contains(t, resp.Body.String(), "file exceeds maximum allowed size")
+	}
+	docs, err := uploadSvc.ListDocuments(context.Background(), 1, uploadask.DocumentFilter{})
+	require.NoError(t, err)
+	require.Empty(t, docs)
+}
+
+func TestRouter_UploadAskDirectUploadFlow(t *testing.T) {
+	storage, err := uploadstorage.NewLocalStorage(t.TempDir(), "/api/v1/upload-ask/direct-uploads", []byte("secret"))
+	require.NoError(t, err)
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, storage)
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc)
+	content := "Direct uploads skip the API server."
+
+	created := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/uploads", fmt.Sprintf(`{"filename":"direct.txt","title":"Direct","mimeType":"text/plain","sizeBytes":%d}`, len(content)), server)
+	require.Equal(t, http.StatusCreated, created.Code)
+	var intentBody uploadask.UploadIntentResponse
+	require.NoError(t, json.Unmarshal(created.Body.Bytes(), &intentBody))
+	require.Equal(t, http.MethodPut, intentBody.Upload.Method)
+	require.NotContains(t, created.Body.String(), "storageKey")
+
+	tampered := performJSONRequest(http.MethodPut, strings.Replace(intentBody.Upload.URL, "signature=", "signature=0", 1), "", server, withoutAuth())
+	require.Equal(t, http.StatusForbidden, tampered.Code)
+
+	req := httptest.NewRequest(http.MethodPut, intentBody.Upload.URL, strings.NewReader(content))
+	for name, value := range intentBody.Upload.Headers {
+		req.Header.Set(name, value)
+	}
+	put := httptest.NewRecorder()
+	server.Handler.ServeHTTP(put, req)
+	require.Equal(t, http.StatusOK, put.Code)
+	etag := put.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	confirmPath := "/api/v1/upload-ask/uploads/" + intentBody.Intent.ID.String() + "/confirm"
+	confirmed := performJSONRequest(http.MethodPost, confirmPath, fmt.Sprintf(`{"etag":%q}`, etag), server)
+	require.Equal(t, http.StatusAccepted, confirmed.Code)
+	var uploadBody uploadask.UploadResponse
+	require.NoError(t, json.Unmarshal(confirmed.Body.Bytes(), &uploadBody))
+	require.Equal(t, intentBody.Intent.ID, uploadBody.Document.ID)
+	require.Equal(t, "Direct", uploadBody.Document.Title)
+
+	require.Eventually(t, func() bool {
+		doc, err := uploadSvc.GetDocument(context.Background(), 1, uploadBody.Document.ID)
+		return err == nil && doc.Status == uploadask.DocumentStatusProcessed
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRouter_UploadAskUsage(t *testing.T) {
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, uploadstorage.NewMemoryStorage())
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc)
+
+	upload := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, "usage.txt", "Usage", "Usage counts stored bytes.")
+	require.Equal(t, http.StatusAccepted, upload.Code)
+
+	var report uploadask.UsageReport
+	require.Eventually(t, func() bool {
+		resp := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/usage", "", server)
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &report))
+		return report.Used.Chunks > 0
+	}, time.Second, 10*time.Millisecond, "chunks count once the document is processed")
+	require.Equal(t, 1, report.Used.Documents)
+	require.Equal(t, int64(len("Usage counts stored bytes.")), report.Used.Bytes)
+	require.Zero(t, report.Limits)
+
+	resp := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/usage", "", server, withoutAuth())
+	require.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestRouter_UploadAskDocumentLabels(t *testing.T) {
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, uploadstorage.NewMemoryStorage())
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc)
+
+	var ids []string
+	for _, name := range []string{"policy", "notes"} {
+		upload := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, name+".txt", name, "Leave allowance rules for the "+name+" document.")
+		require.Equal(t, http.StatusAccepted, upload.Code)
+		var uploadBody struct {
+			Document uploadask.Document `json:"document"`
+		}
+		require.NoError(t, json.Unmarshal(upload.Body.Bytes(), &uploadBody))
+		ids = append(ids, uploadBody.Document.ID.String())
+		require.Eventually(t, func() bool {
+			got := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/documents/"+uploadBody.Document.ID.String(), "", server)
+			var doc uploadask.Document
+			return got.Code == http.StatusOK && json.Unmarshal(got.Body.Bytes(), &doc) == nil && doc.Status == uploadask.DocumentStatusProcessed
+		}, time.Second, 10*time.Millisecond)
+	}
+
+	patched := performJSONRequest(http.MethodPatch, "/api/v1/upload-ask/documents/"+ids[0], `{"tags":["HR-Policy","2026"],"metadata":{"owner":"people-ops"}}`, server)
+	require.Equal(t, http.StatusOK, patched.Code, patched.Body.String())
+	var doc uploadask.Document
+	require.NoError(t, json.Unmarshal(patched.Body.Bytes(), &doc))
+	require.Equal(t, []string{"2026", "hr-policy"}, doc.Tags)
+	require.Equal(t, map[string]string{"owner": "people-ops"}, doc.Metadata)
+	require.NotEmpty(t, doc.MimeType)
+
+	list := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/documents?tag=hr-policy&meta[owner]=people-ops", "", server)
+	require.Equal(t, http.StatusOK, list.Code)
+	var listBody struct {
+		Items []uploadask.Document `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(list.Body.Bytes(), &listBody))
+	require.Len(t, listBody.Items, 1)
+	require.Equal(t, ids[0], listBody.Items[0].ID.String())
+
+	tags := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/tags", "", server)
+	require.Equal(t, http.StatusOK, tags.Code)
+	require.JSONEq(t, `{"items":[{"tag":"2026","documents":1},{"tag":"hr-policy","documents":1}]}`, tags.Body.String())
+
+	ask := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/qa/query", `{"query":"leave allowance rules","tags":["hr-policy"]}`, server)
+	require.Equal(t, http.StatusOK, ask.Code)
+	var askBody uploadask.AskResponse
+	require.NoError(t, json.Unmarshal(ask.Body.Bytes(), &askBody))
+	require.NotEmpty(t, askBody.Sources)
+	for _, source := range askBody.Sources {
+		require.Equal(t, ids[0], source.DocumentID.String())
+	}
+
+	cleared := performJSONRequest(http.MethodPatch, "/api/v1/upload-ask/documents/"+ids[0], `{"metadata":{"owner":null}}`, server)
+	require.Equal(t, http.StatusOK, cleared.Code)
+	var clearedDoc uploadask.Document
+	require.NoError(t, json.Unmarshal(cleared.Body.Bytes(), &clearedDoc))
+	require.Equal(t, []string{"2026", "hr-policy"}, clearedDoc.Tags)
+	require.Empty(t, clearedDoc.Metadata)
+
+	invalid := performJSONRequest(http.MethodPatch, "/api/v1/upload-ask/documents/"+ids[0], `{"tags":["no spaces allowed"]}`, server)
+	require.Equal(t, http.StatusBadRequest, invalid.Code)
+	missing := performJSONRequest(http.MethodPatch, "/api/v1/upload-ask/documents/"+uuid.NewString(), `{"tags":["hr"]}`, server)
+	require.Equal(t, http.StatusNotFound, missing.Code)
+	badTime := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/documents?createdAfter=yesterday", "", server)
+	require.Equal(t, http.StatusBadRequest, badTime.Code)
+}
+
+func TestRouter_UploadAskCollections(t *testing.T) {
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, uploadstorage.NewMemoryStorage())
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc)
+
+	var ids []string
+	for _, name := range []string{"handbook", "notes"} {
+		upload := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, name+".txt", name, "Leave allowance rules for the "+name+" document.")
+		require.Equal(t, http.StatusAccepted, upload.Code)
+		var uploadBody struct {
+			Document uploadask.Document `json:"document"`
+		}
+		require.NoError(t, json.Unmarshal(upload.Body.Bytes(), &uploadBody))
+		ids = append(ids, uploadBody.Document.ID.String())
+		require.Eventually(t, func() bool {
+			got := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/documents/"+uploadBody.Document.ID.String(), "", server)
+			var doc uploadask.Document
+			return got.Code == http.StatusOK && json.Unmarshal(got.Body.Bytes(), &doc) == nil && doc.Status == uploadask.DocumentStatusProcessed
+		}, time.Second, 10*time.Millisecond)
+	}
+
+	created := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/collections", `{"name":"HR","description":"people policies"}`, server)
+	require.Equal(t, http.StatusCreated, created.Code, created.Body.String())
+	var collection uploadask.Collection
+	require.NoError(t, json.Unmarshal(created.Body.Bytes(), &collection))
+	require.Equal(t, "HR", collection.Name)
+	collectionPath := "/api/v1/upload-ask/collections/" + collection.ID.String()
+
+	added := performJSONRequest(http.MethodPost, collectionPath+"/documents", `{"documentIds":["`+ids[0]+`"]}`, server)
+	require.Equal(t, http.StatusOK, added.Code, added.Body.String())
+	var addedCollection uploadask.Collection
+	require.NoError(t, json.Unmarshal(added.Body.Bytes(), &addedCollection))
+	require.Equal(t, 1, addedCollection.DocumentCount)
+
+	members := performJSONRequest(http.MethodGet, collectionPath+"/documents", "", server)
+	require.Equal(t, http.StatusOK, members.Code)
+	var membersBody struct {
+		Items []uploadask.Document `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(members.Body.Bytes(), &membersBody))
+	require.Len(t, membersBody.Items, 1)
+	require.Equal(t, ids[0], membersBody.Items[0].ID.String())
+
+	ask := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/qa/query", `{"query":"leave allowance rules","collectionId":"`+collection.ID.String()+`"}`, server)
+	require.Equal(t, http.StatusOK, ask.Code, ask.Body.String())
+	var askBody uploadask.AskResponse
+	require.NoError(t, json.Unmarshal(ask.Body.Bytes(), &askBody))
+	require.NotEmpty(t, askBody.Sources)
+	for _, source := range askBody.Sources {
+		require.Equal(t, ids[0], source.DocumentID.String())
+	}
+
+	renamed := performJSONRequest(http.MethodPatch, collectionPath, `{"name":"People"}`, server)
+	require.Equal(t, http.StatusOK, renamed.Code)
+	var renamedCollection uploadask.Collection
+	require.NoError(t, json.Unmarshal(renamed.Body.Bytes(), &renamedCollection))
+	require.Equal(t, "People", renamedCollection.Name)
+	require.Equal(t, "people policies", renamedCollection.Description)
+
+	list := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/collections", "", server)
+	require.Equal(t, http.StatusOK, list.Code)
+	var listBody struct {
+		Items []uploadask.Collection `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(list.Body.Bytes(), &listBody))
+	require.Len(t, listBody.Items, 1)
+
+	removed := performJSONRequest(http.MethodDelete, collectionPath+"/documents/"+ids[0], "", server)
+	require.Equal(t, http.StatusNoContent, removed.Code)
+	unknownDoc := performJSONRequest(http.MethodPost, collectionPath+"/documents", `{"documentIds":["`+uuid.NewString()+`"]}`, server)
+	require.Equal(t, http.StatusNotFound, unknownDoc.Code)
+	badName := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/collections", `{"name":""}`, server)
+	require.Equal(t, http.StatusBadRequest, badName.Code)
+	badID := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/qa/query", `{"query":"hi","collectionId":"nope"}`, server)
+	require.Equal(t, http.StatusBadRequest, badID.Code)
+
+	deleted := performJSONRequest(http.MethodDelete, collectionPath, "", server)
+	require.Equal(t, http.StatusNoContent, deleted.Code)
+	missing := performJSONRequest(http.MethodGet, collectionPath, "", server)
+	require.Equal(t, http.StatusNotFound, missing.Code)
+	doc := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/documents/"+ids[0], "", server)
+	require.Equal(t, http.StatusOK, doc.Code)
+}
+
+func TestRouter_UploadAskSharing(t *testing.T) {
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, uploadstorage.NewMemoryStorage())
+	authSvc := &stubAuth{
+		validateFn: func(ctx context.Context, token string) (auth.Claims, error) {
+			switch token {
+			case defaultAuthToken:
+				return auth.Claims{UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}, nil
+			case "other-user":
+				return auth.Claims{UserID: 2, ExpiresAt: time.Now().Add(time.Hour)}, nil
+			}
+			return auth.Claims{}, apperrors.Wrap("invalid_token", "invalid token", nil)
+		},
+	}
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, authSvc, uploadSvc)
+
+	upload := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, "shared.txt", "Shared", "Expense claims are approved by the team lead.")
+	require.Equal(t, http.StatusAccepted, upload.Code)
+	var uploadBody struct {
+		Document uploadask.Document `json:"document"`
+	}
+	require.NoError(t, json.Unmarshal(upload.Body.Bytes(), &uploadBody))
+	docPath := "/api/v1/upload-ask/documents/" + uploadBody.Document.ID.String()
+	require.Eventually(t, func() bool {
+		got := performJSONRequest(http.MethodGet, docPath, "", server)
+		var doc uploadask.Document
+		return got.Code == http.StatusOK && json.Unmarshal(got.Body.Bytes(), &doc) == nil && doc.Status == uploadask.DocumentStatusProcessed
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, http.StatusNotFound, performJSONRequest(http.MethodGet, docPath, "", server, withAuthToken("other-user")).Code)
+	shared := performJSONRequest(http.MethodPut, docPath+"/shares/2", `{"role":"viewer"}`, server)
+	require.Equal(t, http.StatusOK, shared.Code, shared.Body.String())
+	var share uploadask.Share
+	require.NoError(t, json.Unmarshal(shared.Body.Bytes(), &share))
+	require.Equal(t, uploadask.ShareRoleViewer, share.Role)
+	require.Equal(t, int64(2), share.UserID)
+
+	list := performJSONRequest(http.MethodGet, docPath+"/shares", "", server)
+	require.Equal(t, http.StatusOK, list.Code)
+	var listBody struct {
+		Items []uploadask.Share `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(list.Body.Bytes(), &listBody))
+	require.Len(t, listBody.Items, 1)
+
+	require.Equal(t, http.StatusOK, performJSONRequest(http.MethodGet, docPath, "", server, withAuthToken("other-user")).Code)
+	mine := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/shared", "", server, withAuthToken("other-user"))
+	require.Equal(t, http.StatusOK, mine.Code)
+	var sharedBody uploadask.SharedResources
+	require.NoError(t, json.Unmarshal(mine.Body.Bytes(), &sharedBody))
+	require.Len(t, sharedBody.Documents, 1)
+	require.Equal(t, uploadask.ShareRoleViewer, sharedBody.Documents[0].Role)
+	ask := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/qa/query", `{"query":"expense claims approval"}`, server, withAuthToken("other-user"))
+	require.Equal(t, http.StatusOK, ask.Code, ask.Body.String())
+	var askBody uploadask.AskResponse
+	require.NoError(t, json.Unmarshal(ask.Body.Bytes(), &askBody))
+	require.NotEmpty(t, askBody.Sources)
+
+	edit := performJSONRequest(http.MethodPatch, docPath, `{"tags":["finance"]}`, server, withAuthToken("other-user"))
+	require.Equal(t, http.StatusForbidden, edit.Code)
+	require.Equal(t, http.StatusForbidden, performJSONRequest(http.MethodDelete, docPath, "", server, withAuthToken("other-user")).Code)
+	require.Equal(t, http.StatusForbidden, performJSONRequest(http.MethodGet, docPath+"/shares", "", server, withAuthToken("other-user")).Code)
+	require.Equal(t, http.StatusBadRequest, performJSONRequest(http.MethodPut, docPath+"/shares/2", `{"role":"owner"}`, server).Code)
+	require.Equal(t, http.StatusBadRequest, performJSONRequest(http.MethodPut, docPath+"/shares/1", `{"role":"viewer"}`, server).Code)
+	require.Equal(t, http.StatusBadRequest, performJSONRequest(http.MethodPut, docPath+"/shares/me", `{"role":"viewer"}`, server).Code)
+
+	revoked := performJSONRequest(http.MethodDelete, docPath+"/shares/2", "", server)
+	require.Equal(t, http.StatusNoContent, revoked.Code)
+	require.Equal(t, http.StatusNotFound, performJSONRequest(http.MethodDelete, docPath+"/shares/2", "", server).Code)
+	require.Equal(t, http.StatusNotFound, performJSONRequest(http.MethodGet, docPath, "", server, withAuthToken("other-user")).Code)
+}
+
+func TestRouter_UploadAskReindexDocuments(t *testing.T) {
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, uploadstorage.NewMemoryStorage())
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc)
+
+	upload := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, "reindex.txt", "Reindex", "Chunks are rebuilt when the embedder changes.")
+	require.Equal(t, http.StatusAccepted, upload.Code)
+	var uploadBody struct {
+		Document uploadask.Document `json:"document"`
+	}
+	require.NoError(t, json.Unmarshal(upload.Body.Bytes(), &uploadBody))
+	docPath := "/api/v1/upload-ask/documents/" + uploadBody.Document.ID.String()
+	processed := func() bool {
+		got := performJSONRequest(http.MethodGet, docPath, "", server)
+		var doc uploadask.Document
+		return got.Code == http.StatusOK && json.Unmarshal(got.Body.Bytes(), &doc) == nil && doc.Status == uploadask.DocumentStatusProcessed
+	}
+	require.Eventually(t, processed, time.Second, 10*time.Millisecond)
+
+	single := performJSONRequest(http.MethodPost, docPath+"/reindex", "", server)
+	require.Equal(t, http.StatusAccepted, single.Code)
+	require.JSONEq(t, `{"queued":1}`, single.Body.String())
+	require.Eventually(t, processed, time.Second, 10*time.Millisecond)
+
+	stale := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/documents/reindex", "", server)
+	require.Equal(t, http.StatusAccepted, stale.Code)
+	require.JSONEq(t, `{"queued":0}`, stale.Body.String())
+
+	all := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/documents/reindex?scope=all", "", server)
+	require.Equal(t, http.StatusAccepted, all.Code)
+	require.JSONEq(t, `{"queued":1}`, all.Body.String())
+
+	invalid := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/documents/reindex?scope=some", "", server)
+	require.Equal(t, http.StatusBadRequest, invalid.Code)
+	missing := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/documents/"+uuid.NewString()+"/reindex", "", server)
+	require.Equal(t, http.StatusNotFound, missing.Code)
+}
+
+func TestRouter_AdminUploadJobs(t *testing.T) {
+	db, err := sqliteinfra.Open(context.Background(), filepath.Join(t.TempDir(), "jobs.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	// No handler is set, so no worker runs and jobs stay queued.
+	queue := uploadqueue.NewSQLiteQueue(db, uploadqueue.SQLiteQueueConfig{}, newTestLogger())
+	uploadSvc := newLocalUploadAskServiceForTestWithQueueAndStorage(queue, uploadstorage.NewMemoryStorage())
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc, func(cfg *config.Config) {
+		cfg.Auth.AdminEmails = []string{"Tester@example.com"}
+	})
+
+	upload := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, "jobs.txt", "Jobs", "Jobs wait in the durable queue.")
+	require.Equal(t, http.StatusAccepted, upload.Code)
+
+	list := performJSONRequest(http.MethodGet, "/api/v1/admin/upload-ask/jobs?state=queued", "", server)
+	require.Equal(t, http.StatusOK, list.Code)
+	var body struct {
+		Items []uploadask.Job `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(list.Body.Bytes(), &body))
+	require.Len(t, body.Items, 1)
+	require.Equal(t, "process_document", body.Items[0].Name)
+	require.Equal(t, uploadask.JobStateQueued, body.Items[0].State)
+
+	requeue := performJSONRequest(http.MethodPost, "/api/v1/admin/upload-ask/jobs/"+body.Items[0].ID.String()+"/requeue", "", server)
+	require.Equal(t, http.StatusAccepted, requeue.Code)
+
+	missing := performJSONRequest(http.MethodPost, "/api/v1/admin/upload-ask/jobs/"+uuid.NewString()+"/requeue", "", server)
+	require.Equal(t, http.StatusNotFound, missing.Code)
+	invalidState := performJSONRequest(http.MethodGet, "/api/v1/admin/upload-ask/jobs?state=done", "", server)
+	require.Equal(t, http.StatusBadRequest, invalidState.Code)
+	invalidID := performJSONRequest(http.MethodPost, "/api/v1/admin/upload-ask/jobs/nope/requeue", "", server)
+	require.Equal(t, http.StatusBadRequest, invalidID.Code)
+}
+
+func TestRouter_AdminUploadJobsRejectsNonAdmins(t *testing.T) {
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, newLocalUploadAskServiceForTest(), func(cfg *config.Config) {
+		cfg.Auth.AdminEmails = []string{"admin@example.com"}
+	})
+
+	recorder := performJSONRequest(http.MethodGet, "/api/v1/admin/upload-ask/jobs", "", server)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+	errBody := assertStructuredError(t, recorder.Body.Bytes())
+	require.Equal(t, "forbidden", errBody["error"]["code"])
+}
+
+func TestRouter_AdminEmbeddingCacheStats(t *testing.T) {
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, newLocalUploadAskServiceForTest(), func(cfg *config.Config) {
+		cfg.Auth.AdminEmails = []string{"tester@example.com"}
+	})
+
+	recorder := performJSONRequest(http.MethodGet, "/api/v1/admin/embedding-cache", "", server)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.JSONEq(t, `{"enabled":true,"hits":0,"misses":0}`, recorder.Body.String())
+}
+
+func TestRouter_UploadAskDocumentEvents(t *testing.T) {
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, uploadstorage.NewMemoryStorage())
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc)
+
+	upload := performMultipartUpload(t, "/api/v1/upload-ask/documents", server, "progress.txt", "Progress", "Progress is reported while the document is embedded.")
+	require.Equal(t, http.StatusAccepted, upload.Code)
+	var uploadBody struct {
+		Document uploadask.Document `json:"document"`
+	}
+	require.NoError(t, json.Unmarshal(upload.Body.Bytes(), &uploadBody))
+	docPath := "/api/v1/upload-ask/documents/" + uploadBody.Document.ID.String()
+	require.Eventually(t, func() bool {
+		got := performJSONRequest(http.MethodGet, docPath, "", server)
+		var doc uploadask.Document
+		return got.Code == http.StatusOK && json.Unmarshal(got.Body.Bytes(), &doc) == nil && doc.Status == uploadask.DocumentStatusProcessed
+	}, time.Second, 10*time.Millisecond)
+
+	events := performJSONRequest(http.MethodGet, docPath+"/events", "", server)
+	require.Equal(t, http.StatusOK, events.Code)
+	require.Equal(t, "text/event-stream", events.Header().Get("Content-Type"))
+	body := events.Body.String()
+	require.True(t, strings.HasPrefix(body, "event: progress\ndata: "))
+	var doc uploadask.Document
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(body, "event: progress\ndata: "))), &doc))
+	require.Equal(t, uploadask.DocumentStatusProcessed, doc.Status)
+	require.NotNil(t, doc.Progress)
+	require.Equal(t, uploadask.ProcessingStagePersist, doc.Progress.Stage)
+	require.Equal(t, doc.Progress.ChunksTotal, doc.Progress.ChunksDone)
+	require.Len(t, doc.Progress.Stages, 4)
+	for _, stage := range doc.Progress.Stages {
+		require.NotNil(t, stage.FinishedAt)
+	}
+
+	missing := performJSONRequest(http.MethodGet, "/api/v1/upload-ask/documents/"+uuid.NewString()+"/events", "", server)
+	require.Equal(t, http.StatusNotFound, missing.Code)
+}
+
+func TestRouter_UploadAskIngestFromURL(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/handbook":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html><body><h1>Handbook</h1><p>Remote onboarding notes.</p></body></html>"))
+		case "/large":
+			_, _ = w.Write(bytes.Repeat([]byte("x"), 2*1024*1024))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer remote.Close()
+	uploadSvc := newQueuedLocalUploadAskServiceForTest(t, uploadstorage.NewMemoryStorage())
+	server := newRouterUnderTest(t, &stubSummarizer{}, nil, nil, nil, uploadSvc)
+
+	resp := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/documents/from-url", `{"url":"`+remote.URL+`/handbook#intro"}`, server)
+	require.Equal(t, http.StatusAccepted, resp.Code)
+	var body struct {
+		Document uploadask.Document `json:"document"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	require.Equal(t, uploadask.DocumentSourceURL, body.Document.Source)
+	require.NotNil(t, body.Document.SourceURL)
+	require.Equal(t, remote.URL+"/handbook", *body.Document.SourceURL)
+	require.Equal(t, remote.URL+"/handbook", body.Document.Title)
+
+	docPath := "/api/v1/upload-ask/documents/" + body.Document.ID.String()
+	require.Eventually(t, func() bool {
+		got := performJSONRequest(http.MethodGet, docPath, "", server)
+		var doc uploadask.Document
+		return got.Code == http.StatusOK && json.Unmarshal(got.Body.Bytes(), &doc) == nil && doc.Status == uploadask.DocumentStatusProcessed
+	}, time.Second, 10*time.Millisecond)
+
+	ask := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/qa/query", `{"query":"onboarding","documentIds":["`+body.Document.ID.String()+`"],"topK":1}`, server)
+	require.Equal(t, http.StatusOK, ask.Code)
+	var askBody uploadask.AskResponse
+	require.NoError(t, json.Unmarshal(ask.Body.Bytes(), &askBody))
+	require.Len(t, askBody.Sources, 1)
+	require.Contains(t, askBody.Sources[0].Preview, "# Handbook")
+
+	missing := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/documents/from-url", `{"url":"`+remote.URL+`/missing"}`, server)
+	require.Equal(t, http.StatusBadGateway, missing.Code)
+	require.Contains(t, missing.Body.String(), "url_fetch_failed")
+
+	large := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/documents/from-url", `{"url":"`+remote.URL+`/large"}`, server)
+	require.Equal(t, http.StatusBadRequest, large.Code)
+
+	invalid := performJSONRequest(http.MethodPost, "/api/v1/upload-ask/documents/from-url", `{"url":"file:///etc/passwd"}`, server)
+	require.Equal(t, http.StatusBadRequest, invalid.Code)
+}
+
 func TestRouter_Profile(t *testing.T) {
 	authSvc := &stubAuth{
 		validateFn: func(ctx context.Context, token string) (auth.Claims, error) {
@@ -665,7 +1293,7 @@ func newRouterUnderTest(t *testing.T, summarySvc summarizer.Service, advisorSvc
 			},
 		}
 	}
-	handler := NewHandler(summarySvc, advisorSvc, faqSvc, authSvc, uploadSvc, newTestLogger())
+	handler := NewHandler(summarySvc, advisorSvc, faqSvc, authSvc, uploadSvc, embeddingcache.New(nil, 16, nil), newTestLogger())
 	cfg := &config.Config{
 		HTTP: config.HTTPConfig{
 			Address:      ":0",
@@ -698,25 +1326,31 @@ func newQueuedLocalUploadAskServiceForTest(t *testing.T, storage uploadask.Objec
 	t.Helper()
 	queue := uploadqueue.NewImmediateQueue(nil)
 	svc := newLocalUploadAskServiceForTestWithQueueAndStorage(queue, storage)
-	queue.SetHandler(func(ctx context.Context, name string, payload map[string]any) {
-		if name != "process_document" {
-			return
+	queue.SetHandler(func(ctx context.Context, name string, payload map[string]any) error {
+		if name != "process_document" && name != uploadask.ReindexJobName {
+			return nil
 		}
 		rawDocID, ok := payload["document_id"].(string)
 		if !ok {
-			return
+			return nil
 		}
 		docID, err := uuid.Parse(rawDocID)
 		if err != nil {
-			return
+			return nil
 		}
 		userID, ok := payload["user_id"].(int64)
 		if !ok {
-			return
+			return nil
+		}
+		process := svc.ProcessDocument
+		if name == uploadask.ReindexJobName {
+			process = svc.ReindexDocument
 		}
-		if err := svc.ProcessDocument(ctx, docID, userID); err != nil {
-			t.Errorf("process document from queue: %v", err)
+		if err := process(ctx, docID, userID); err != nil {
+			t.Errorf("%s from queue: %v", name, err)
+			return err
 		}
+		return nil
 	})
 	return svc
 }
@@ -743,6 +1377,9 @@ func newLocalUploadAskServiceForTestWithQueueAndStorage(queue uploadask.JobQueue
 		},
 		docs,
 		files,
+		uploadrepo.NewMemoryUploadIntentRepository(),
+		uploadrepo.NewMemoryCollectionRepository(),
+		uploadrepo.NewMemoryShareRepository(),
 		chunks,
 		sessions,
 		logs,
@@ -751,7 +1388,10 @@ func newLocalUploadAskServiceForTestWithQueueAndStorage(queue uploadask.JobQueue
 		storage,
 		uploadembedder.NewDeterministicEmbedder(32),
 		uploadllm.EchoLLM{},
+		nil,
 		uploadchunker.NewSimpleChunker(120, 0),
+		uploadextractor.NewRegistry(0),
+		uploadfetcher.NewHTTPFetcher(time.Second, true),
 		queue,
 		newTestLogger(),
 	)
@@ -776,10 +1416,13 @@ func newBlockingGetStorage() *blockingGetStorage {
 	}
 }
 
-func (s *blockingGetStorage) Put(_ context.Context, key string, data []byte, mimeType string) (uploadask.StoredObject, error) {
+func (s *blockingGetStorage) Put(_ context.Context, key string, body io.Reader, mimeType string) (uploadask.StoredObject, error) {
+	copied, err := io.ReadAll(body)
+	if err != nil {
+		return uploadask.StoredObject{}, err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	copied := append([]byte(nil), data...)
 	obj := storedObject{data: copied, mimeType: mimeType, etag: "memory-etag"}
 	s.objects[key] = obj
 	return uploadask.StoredObject{Key: key, Size: int64(len(copied)), MimeType: mimeType, ETag: obj.etag}, nil
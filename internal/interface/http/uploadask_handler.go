@@ -1,9 +1,12 @@
 package http
 
 import (
-	"io"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -12,7 +15,12 @@ import (
 	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
 )
 
-// UploadDocument handles multipart upload and enqueues processing.
+// multipartOverheadBytes allows for the form fields and part headers around
+// the file when capping the request body.
+const multipartOverheadBytes = 1 << 20
+
+// UploadDocument handles multipart upload and enqueues processing. The file
+// is streamed into storage rather than read into memory.
 func (h *Handler) UploadDocument(c *gin.Context) {
 	if h.uploadSvc == nil {
 		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
@@ -23,48 +31,700 @@ func (h *Handler) UploadDocument(c *gin.Context) {
 		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
 		return
 	}
-	fileHeader, err := c.FormFile("file")
+	if limit := h.uploadSvc.MaxFileBytes(); limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+multipartOverheadBytes)
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "file exceeds maximum allowed size", err))
+			return
+		}
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "file is required", err))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "failed to read upload", err))
+		return
+	}
+	defer file.Close()
+	req := uploadask.UploadRequest{
+		Filename: fileHeader.Filename,
+		Title:    c.PostForm("title"),
+		MimeType: fileHeader.Header.Get("Content-Type"),
+		Content:  file,
+	}
+	resp, err := h.uploadSvc.Upload(c.Request.Context(), claims.UserID, req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "upload_failed"
+		switch {
+		case apperrors.IsCode(err, "invalid_input"):
+			status = http.StatusBadRequest
+			code = "invalid_request"
+		case apperrors.IsCode(err, "unauthorized"):
+			status = http.StatusUnauthorized
+			code = "unauthorized"
+		case apperrors.IsCode(err, "quota_exceeded"):
+			status = http.StatusForbidden
+			code = "quota_exceeded"
+		}
+		abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+		return
+	}
+	c.JSON(uploadStatus(resp), resp)
+}
+
+type urlIngestPayload struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// IngestDocumentFromURL fetches a remote document and queues it for processing.
+func (h *Handler) IngestDocumentFromURL(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	var req urlIngestPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", errMessage(err), err))
+		return
+	}
+	resp, err := h.uploadSvc.IngestURL(c.Request.Context(), claims.UserID, uploadask.URLIngestRequest{
+		URL:   req.URL,
+		Title: req.Title,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "upload_failed"
+		switch {
+		case apperrors.IsCode(err, "invalid_input"):
+			status = http.StatusBadRequest
+			code = "invalid_request"
+		case apperrors.IsCode(err, "unauthorized"):
+			status = http.StatusUnauthorized
+			code = "unauthorized"
+		case apperrors.IsCode(err, "quota_exceeded"):
+			status = http.StatusForbidden
+			code = "quota_exceeded"
+		case apperrors.IsCode(err, "url_fetch_failed"):
+			status = http.StatusBadGateway
+			code = "url_fetch_failed"
+		case apperrors.IsCode(err, "unavailable"):
+			status = http.StatusServiceUnavailable
+			code = "upload_disabled"
+		}
+		abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+		return
+	}
+	c.JSON(uploadStatus(resp), resp)
+}
+
+// uploadStatus is 200 when an upload matched an existing document and 202 when
+// a new one was queued.
+func uploadStatus(resp uploadask.UploadResponse) int {
+	if resp.Duplicate {
+		return http.StatusOK
+	}
+	return http.StatusAccepted
+}
+
+// CreateUploadIntent presigns a direct upload to storage.
+func (h *Handler) CreateUploadIntent(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	var req uploadask.UploadIntentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", errMessage(err), err))
+		return
+	}
+	resp, err := h.uploadSvc.CreateUploadIntent(c.Request.Context(), claims.UserID, req)
+	if err != nil {
+		abortWithDirectUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+type confirmUploadPayload struct {
+	ETag string `json:"etag"`
+}
+
+// ConfirmUploadIntent verifies a direct upload and queues the document.
+func (h *Handler) ConfirmUploadIntent(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	intentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid upload id", err))
+		return
+	}
+	var req confirmUploadPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", errMessage(err), err))
+		return
+	}
+	resp, err := h.uploadSvc.ConfirmUploadIntent(c.Request.Context(), claims.UserID, intentID, req.ETag)
+	if err != nil {
+		abortWithDirectUploadError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// ReceiveDirectUpload accepts the PUT of a presigned URL when storage signs
+// URLs pointing back at this server. The signature in the query string
+// authorizes the request in place of a token.
+func (h *Handler) ReceiveDirectUpload(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	obj, err := h.uploadSvc.ReceiveDirectUpload(c.Request.Context(), key, c.Request.URL.Query(), c.Request.Body, c.ContentType())
+	if err != nil {
+		abortWithDirectUploadError(c, err)
+		return
+	}
+	c.Header("ETag", `"`+obj.ETag+`"`)
+	c.Status(http.StatusOK)
+}
+
+func abortWithDirectUploadError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	code := "upload_failed"
+	switch {
+	case apperrors.IsCode(err, "invalid_input"):
+		status = http.StatusBadRequest
+		code = "invalid_request"
+	case apperrors.IsCode(err, "unauthorized"):
+		status = http.StatusUnauthorized
+		code = "unauthorized"
+	case apperrors.IsCode(err, "forbidden"):
+		status = http.StatusForbidden
+		code = "forbidden"
+	case apperrors.IsCode(err, "quota_exceeded"):
+		status = http.StatusForbidden
+		code = "quota_exceeded"
+	case apperrors.IsCode(err, "not_found"):
+		status = http.StatusNotFound
+		code = "not_found"
+	case apperrors.IsCode(err, "conflict"):
+		status = http.StatusConflict
+		code = "conflict"
+	case apperrors.IsCode(err, "direct_upload_unavailable"):
+		status = http.StatusNotImplemented
+		code = "direct_upload_unavailable"
+	}
+	abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+}
+
+// Usage reports the user's storage consumption against their quota.
+func (h *Handler) Usage(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	report, err := h.uploadSvc.Usage(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusInternalServerError, "fetch_failed", errMessage(err), err))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ListDocuments returns the user's uploads.
+func (h *Handler) ListDocuments(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	filter, ok := bindDocumentFilter(c)
+	if !ok {
+		return
+	}
+	docs, err := h.uploadSvc.ListDocuments(c.Request.Context(), claims.UserID, filter)
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "fetch_failed"
+		if apperrors.IsCode(err, "invalid_input") {
+			status = http.StatusBadRequest
+			code = "invalid_request"
+		}
+		abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": docs})
+}
+
+type documentLabelsPayload struct {
+	Tags     *[]string          `json:"tags"`
+	Metadata map[string]*string `json:"metadata"`
+}
+
+// UpdateDocumentLabels changes a document's tags and metadata. Tags replace
+// the current set; metadata keys are merged, and a null value removes one.
+func (h *Handler) UpdateDocumentLabels(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid document id", err))
+		return
+	}
+	var req documentLabelsPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", errMessage(err), err))
+		return
+	}
+	doc, err := h.uploadSvc.UpdateDocumentLabels(c.Request.Context(), claims.UserID, id, uploadask.DocumentLabelsUpdate{
+		Tags:     req.Tags,
+		Metadata: req.Metadata,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "update_failed"
+		switch {
+		case apperrors.IsCode(err, "invalid_input"):
+			status = http.StatusBadRequest
+			code = "invalid_request"
+		case apperrors.IsCode(err, "not_found"):
+			status = http.StatusNotFound
+			code = "not_found"
+		case apperrors.IsCode(err, "forbidden"):
+			status = http.StatusForbidden
+			code = "forbidden"
+		}
+		abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+// ListTags returns the tags on the user's documents with their counts.
+func (h *Handler) ListTags(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	tags, err := h.uploadSvc.ListTags(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusInternalServerError, "fetch_failed", errMessage(err), err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": tags})
+}
+
+type collectionPayload struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// CreateCollection creates an empty collection.
+func (h *Handler) CreateCollection(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	var req collectionPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", errMessage(err), err))
+		return
+	}
+	var name, description string
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if req.Description != nil {
+		description = *req.Description
+	}
+	collection, err := h.uploadSvc.CreateCollection(c.Request.Context(), claims.UserID, name, description)
+	if err != nil {
+		abortWithCollectionError(c, "create_failed", err)
+		return
+	}
+	c.JSON(http.StatusCreated, collection)
+}
+
+// ListCollections returns the user's collections.
+func (h *Handler) ListCollections(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	collections, err := h.uploadSvc.ListCollections(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithCollectionError(c, "fetch_failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": collections})
+}
+
+// GetCollection returns one collection.
+func (h *Handler) GetCollection(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid collection id", err))
+		return
+	}
+	collection, err := h.uploadSvc.GetCollection(c.Request.Context(), claims.UserID, id)
+	if err != nil {
+		abortWithCollectionError(c, "fetch_failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+// UpdateCollection renames a collection or changes its description.
+func (h *Handler) UpdateCollection(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid collection id", err))
+		return
+	}
+	var req collectionPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", errMessage(err), err))
+		return
+	}
+	collection, err := h.uploadSvc.UpdateCollection(c.Request.Context(), claims.UserID, id, uploadask.CollectionUpdate{
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if err != nil {
+		abortWithCollectionError(c, "update_failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+// DeleteCollection removes a collection but keeps its documents.
+func (h *Handler) DeleteCollection(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid collection id", err))
+		return
+	}
+	if err := h.uploadSvc.DeleteCollection(c.Request.Context(), claims.UserID, id); err != nil {
+		abortWithCollectionError(c, "delete_failed", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListCollectionDocuments lists a collection's documents, taking the same
+// filters as ListDocuments.
+func (h *Handler) ListCollectionDocuments(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid collection id", err))
+		return
+	}
+	filter, ok := bindDocumentFilter(c)
+	if !ok {
+		return
+	}
+	docs, err := h.uploadSvc.ListCollectionDocuments(c.Request.Context(), claims.UserID, id, filter)
+	if err != nil {
+		abortWithCollectionError(c, "fetch_failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": docs})
+}
+
+type collectionDocumentsPayload struct {
+	DocumentIDs []string `json:"documentIds"`
+}
+
+// AddCollectionDocuments adds documents to a collection and returns it.
+func (h *Handler) AddCollectionDocuments(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid collection id", err))
+		return
+	}
+	var req collectionDocumentsPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", errMessage(err), err))
+		return
+	}
+	docIDs := make([]uuid.UUID, 0, len(req.DocumentIDs))
+	for _, raw := range req.DocumentIDs {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid documentIds entry", err))
+			return
+		}
+		docIDs = append(docIDs, parsed)
+	}
+	collection, err := h.uploadSvc.AddCollectionDocuments(c.Request.Context(), claims.UserID, id, docIDs)
+	if err != nil {
+		abortWithCollectionError(c, "update_failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+// RemoveCollectionDocument takes a document out of a collection without
+// deleting it.
+func (h *Handler) RemoveCollectionDocument(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid collection id", err))
+		return
+	}
+	docID, err := uuid.Parse(c.Param("docId"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid document id", err))
+		return
+	}
+	if err := h.uploadSvc.RemoveCollectionDocuments(c.Request.Context(), claims.UserID, id, []uuid.UUID{docID}); err != nil {
+		abortWithCollectionError(c, "update_failed", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func abortWithCollectionError(c *gin.Context, code string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case apperrors.IsCode(err, "invalid_input"):
+		status = http.StatusBadRequest
+		code = "invalid_request"
+	case apperrors.IsCode(err, "unauthorized"):
+		status = http.StatusUnauthorized
+		code = "unauthorized"
+	case apperrors.IsCode(err, "not_found"):
+		status = http.StatusNotFound
+		code = "not_found"
+	case apperrors.IsCode(err, "forbidden"):
+		status = http.StatusForbidden
+		code = "forbidden"
+	}
+	abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+}
+
+type sharePayload struct {
+	Role uploadask.ShareRole `json:"role"`
+}
+
+// ListDocumentShares returns who one of the user's documents is shared with.
+func (h *Handler) ListDocumentShares(c *gin.Context) {
+	h.listShares(c, uploadask.ShareResourceDocument)
+}
+
+// ShareDocument grants another user a role on one of the user's documents.
+func (h *Handler) ShareDocument(c *gin.Context) {
+	h.share(c, uploadask.ShareResourceDocument)
+}
+
+// UnshareDocument revokes another user's access to a document.
+func (h *Handler) UnshareDocument(c *gin.Context) {
+	h.unshare(c, uploadask.ShareResourceDocument)
+}
+
+// ListCollectionShares returns who one of the user's collections is shared
+// with.
+func (h *Handler) ListCollectionShares(c *gin.Context) {
+	h.listShares(c, uploadask.ShareResourceCollection)
+}
+
+// ShareCollection grants another user a role on one of the user's
+// collections.
+func (h *Handler) ShareCollection(c *gin.Context) {
+	h.share(c, uploadask.ShareResourceCollection)
+}
+
+// UnshareCollection revokes another user's access to a collection.
+func (h *Handler) UnshareCollection(c *gin.Context) {
+	h.unshare(c, uploadask.ShareResourceCollection)
+}
+
+// ListShared returns the documents and collections other users shared with
+// the user.
+func (h *Handler) ListShared(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	shared, err := h.uploadSvc.ListShared(c.Request.Context(), claims.UserID)
+	if err != nil {
+		abortWithCollectionError(c, "fetch_failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, shared)
+}
+
+func (h *Handler) listShares(c *gin.Context, resourceType uploadask.ShareResourceType) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "file is required", err))
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid "+string(resourceType)+" id", err))
 		return
 	}
-	file, err := fileHeader.Open()
+	shares, err := h.uploadSvc.ListShares(c.Request.Context(), claims.UserID, resourceType, id)
 	if err != nil {
-		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "failed to read upload", err))
+		abortWithCollectionError(c, "fetch_failed", err)
 		return
 	}
-	defer file.Close()
-	data, err := io.ReadAll(file)
+	c.JSON(http.StatusOK, gin.H{"items": shares})
+}
+
+func (h *Handler) share(c *gin.Context, resourceType uploadask.ShareResourceType) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		abortWithError(c, NewHTTPError(http.StatusInternalServerError, "upload_failed", "failed to read file", err))
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid "+string(resourceType)+" id", err))
 		return
 	}
-	req := uploadask.UploadRequest{
-		Filename: fileHeader.Filename,
-		Title:    c.PostForm("title"),
-		MimeType: fileHeader.Header.Get("Content-Type"),
-		Content:  data,
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid user id", err))
+		return
 	}
-	resp, err := h.uploadSvc.Upload(c.Request.Context(), claims.UserID, req)
+	var req sharePayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", errMessage(err), err))
+		return
+	}
+	share, err := h.uploadSvc.Share(c.Request.Context(), claims.UserID, resourceType, id, userID, req.Role)
 	if err != nil {
-		status := http.StatusInternalServerError
-		code := "upload_failed"
-		switch {
-		case apperrors.IsCode(err, "invalid_input"):
-			status = http.StatusBadRequest
-			code = "invalid_request"
-		case apperrors.IsCode(err, "unauthorized"):
-			status = http.StatusUnauthorized
-			code = "unauthorized"
-		}
-		abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+		abortWithCollectionError(c, "share_failed", err)
 		return
 	}
-	c.JSON(http.StatusAccepted, resp)
+	c.JSON(http.StatusOK, share)
 }
 
-// ListDocuments returns the user's uploads.
-func (h *Handler) ListDocuments(c *gin.Context) {
+func (h *Handler) unshare(c *gin.Context, resourceType uploadask.ShareResourceType) {
 	if h.uploadSvc == nil {
 		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
 		return
@@ -74,14 +734,21 @@ func (h *Handler) ListDocuments(c *gin.Context) {
 		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
 		return
 	}
-	statuses := parseStatuses(c.Query("status"))
-	filter := uploadask.DocumentFilter{Statuses: statuses}
-	docs, err := h.uploadSvc.ListDocuments(c.Request.Context(), claims.UserID, filter)
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		abortWithError(c, NewHTTPError(http.StatusInternalServerError, "fetch_failed", errMessage(err), err))
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid "+string(resourceType)+" id", err))
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"items": docs})
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid user id", err))
+		return
+	}
+	if err := h.uploadSvc.Unshare(c.Request.Context(), claims.UserID, resourceType, id, userID); err != nil {
+		abortWithCollectionError(c, "unshare_failed", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
 }
 
 // GetDocument returns a single document's metadata.
@@ -114,14 +781,232 @@ func (h *Handler) GetDocument(c *gin.Context) {
 	c.JSON(http.StatusOK, doc)
 }
 
+// DocumentEvents streams the document over SSE as its processing progresses.
+// Each change is sent as a "progress" event carrying the full Document; the
+// stream ends once the document is processed or failed.
+func (h *Handler) DocumentEvents(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid document id", err))
+		return
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusInternalServerError, "stream_unsupported", "streaming not supported", nil))
+		return
+	}
+	updates, err := h.uploadSvc.WatchDocument(c.Request.Context(), claims.UserID, id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		code := "fetch_failed"
+		if apperrors.IsCode(err, "not_found") {
+			status = http.StatusNotFound
+			code = "not_found"
+		}
+		abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+
+	for doc := range updates {
+		payload, err := json.Marshal(doc)
+		if err != nil {
+			h.logger.Error("marshal document progress failed", "document_id", doc.ID, "error", err)
+			continue
+		}
+		c.Writer.Write([]byte("event: progress\ndata: "))
+		c.Writer.Write(payload)
+		c.Writer.Write([]byte("\n\n"))
+		flusher.Flush()
+	}
+}
+
+// DeleteDocument removes a document, its stored file and its chunks.
+func (h *Handler) DeleteDocument(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid document id", err))
+		return
+	}
+	if err := h.uploadSvc.DeleteDocument(c.Request.Context(), claims.UserID, id); err != nil {
+		status := http.StatusInternalServerError
+		code := "delete_failed"
+		switch {
+		case apperrors.IsCode(err, "not_found"):
+			status = http.StatusNotFound
+			code = "not_found"
+		case apperrors.IsCode(err, "forbidden"):
+			status = http.StatusForbidden
+			code = "forbidden"
+		}
+		abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ReindexDocument queues one document to be re-chunked and re-embedded.
+func (h *Handler) ReindexDocument(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid document id", err))
+		return
+	}
+	if err := h.uploadSvc.QueueReindex(c.Request.Context(), claims.UserID, id); err != nil {
+		abortWithReindexError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"queued": 1})
+}
+
+// ReindexDocuments queues the user's stale documents, or all of them with
+// scope=all, for reindexing.
+func (h *Handler) ReindexDocuments(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	var staleOnly bool
+	switch strings.ToLower(strings.TrimSpace(c.DefaultQuery("scope", "stale"))) {
+	case "stale":
+		staleOnly = true
+	case "all":
+	default:
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "scope must be stale or all", nil))
+		return
+	}
+	queued, err := h.uploadSvc.QueueReindexAll(c.Request.Context(), claims.UserID, staleOnly)
+	if err != nil {
+		abortWithReindexError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"queued": queued})
+}
+
+func abortWithReindexError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	code := "reindex_failed"
+	switch {
+	case apperrors.IsCode(err, "not_found"):
+		status = http.StatusNotFound
+		code = "not_found"
+	case apperrors.IsCode(err, "forbidden"):
+		status = http.StatusForbidden
+		code = "forbidden"
+	case apperrors.IsCode(err, "unauthorized"):
+		status = http.StatusUnauthorized
+		code = "unauthorized"
+	case apperrors.IsCode(err, "unavailable"):
+		status = http.StatusServiceUnavailable
+		code = "reindex_unavailable"
+	}
+	abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+}
+
+// ListUploadJobs lists the processing queue's jobs, optionally filtered by
+// state.
+func (h *Handler) ListUploadJobs(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	state := uploadask.JobState(strings.ToLower(strings.TrimSpace(c.Query("state"))))
+	jobs, err := h.uploadSvc.ListJobs(c.Request.Context(), state)
+	if err != nil {
+		abortWithJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": jobs})
+}
+
+// RequeueUploadJob makes a queued or dead job run again right away.
+func (h *Handler) RequeueUploadJob(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid job id", err))
+		return
+	}
+	if err := h.uploadSvc.RequeueJob(c.Request.Context(), id); err != nil {
+		abortWithJobError(c, err)
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+func abortWithJobError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	code := "job_failed"
+	switch {
+	case apperrors.IsCode(err, "invalid_input"):
+		status = http.StatusBadRequest
+		code = "invalid_request"
+	case apperrors.IsCode(err, "not_found"):
+		status = http.StatusNotFound
+		code = "not_found"
+	case apperrors.IsCode(err, "conflict"):
+		status = http.StatusConflict
+		code = "job_running"
+	case apperrors.IsCode(err, "unavailable"):
+		status = http.StatusServiceUnavailable
+		code = "jobs_unavailable"
+	}
+	abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
+}
+
 type askPayload struct {
-	Query            string   `json:"query"`
-	SessionID        *string  `json:"sessionId"`
-	DocumentIDs      []string `json:"documentIds"`
-	TopK             int      `json:"topK"`
-	TopKMems         *int     `json:"topKMems"`
-	MaxHistoryTokens *int     `json:"maxHistoryTokens"`
-	IncludeHistory   *bool    `json:"includeHistory"`
+	Query            string            `json:"query"`
+	SessionID        *string           `json:"sessionId"`
+	CollectionID     *string           `json:"collectionId"`
+	DocumentIDs      []string          `json:"documentIds"`
+	TopK             int               `json:"topK"`
+	TopKMems         *int              `json:"topKMems"`
+	MaxHistoryTokens *int              `json:"maxHistoryTokens"`
+	IncludeHistory   *bool             `json:"includeHistory"`
+	RetrievalMode    string            `json:"retrievalMode"`
+	Tags             []string          `json:"tags"`
+	Metadata         map[string]string `json:"metadata"`
+	MimeTypes        []string          `json:"mimeTypes"`
+	CreatedAfter     *time.Time        `json:"createdAfter"`
+	CreatedBefore    *time.Time        `json:"createdBefore"`
 }
 
 // AskQuestion performs retrieval augmented question answering.
@@ -135,20 +1020,91 @@ func (h *Handler) AskQuestion(c *gin.Context) {
 		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
 		return
 	}
+	req, ok := bindAskRequest(c)
+	if !ok {
+		return
+	}
+	resp, err := h.uploadSvc.Ask(c.Request.Context(), claims.UserID, req)
+	if err != nil {
+		abortWithAskError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// AskQuestionStream answers like AskQuestion but streams Server-Sent Events:
+// "sources" with the retrieved chunks, "delta" for each piece of the answer
+// and "done" with the session id and latency.
+func (h *Handler) AskQuestionStream(c *gin.Context) {
+	if h.uploadSvc == nil {
+		abortWithError(c, NewHTTPError(http.StatusServiceUnavailable, "upload_disabled", "upload service unavailable", nil))
+		return
+	}
+	claims, ok := getClaims(c)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusUnauthorized, "unauthorized", "missing token", nil))
+		return
+	}
+	req, ok := bindAskRequest(c)
+	if !ok {
+		return
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		abortWithError(c, NewHTTPError(http.StatusInternalServerError, "stream_unsupported", "streaming not supported", nil))
+		return
+	}
+	stream, err := h.uploadSvc.AskStream(c.Request.Context(), claims.UserID, req)
+	if err != nil {
+		abortWithAskError(c, err)
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+
+	// Drain the stream even after a write failure so the service can finish
+	// recording the turn.
+	for event := range stream {
+		payload, err := json.Marshal(event.Data)
+		if err != nil {
+			h.logger.Error("marshal ask stream event failed", "event", event.Event, "error", err)
+			continue
+		}
+		c.Writer.Write([]byte("event: " + event.Event + "\ndata: "))
+		c.Writer.Write(payload)
+		c.Writer.Write([]byte("\n\n"))
+		flusher.Flush()
+	}
+}
+
+// bindAskRequest parses the Ask JSON body, writing a 400 response and
+// returning false when it is invalid.
+func bindAskRequest(c *gin.Context) (uploadask.AskRequest, bool) {
 	var req askPayload
 	if err := c.ShouldBindJSON(&req); err != nil {
 		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", errMessage(err), err))
-		return
+		return uploadask.AskRequest{}, false
 	}
 	var sessionID *uuid.UUID
 	if req.SessionID != nil {
 		parsed, err := uuid.Parse(*req.SessionID)
 		if err != nil {
 			abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid sessionId", err))
-			return
+			return uploadask.AskRequest{}, false
 		}
 		sessionID = &parsed
 	}
+	var collectionID *uuid.UUID
+	if req.CollectionID != nil {
+		parsed, err := uuid.Parse(*req.CollectionID)
+		if err != nil {
+			abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid collectionId", err))
+			return uploadask.AskRequest{}, false
+		}
+		collectionID = &parsed
+	}
 	docIDs := make([]uuid.UUID, 0, len(req.DocumentIDs))
 	for _, raw := range req.DocumentIDs {
 		if raw == "" {
@@ -157,37 +1113,43 @@ func (h *Handler) AskQuestion(c *gin.Context) {
 		parsed, err := uuid.Parse(raw)
 		if err != nil {
 			abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "invalid documentIds entry", err))
-			return
+			return uploadask.AskRequest{}, false
 		}
 		docIDs = append(docIDs, parsed)
 	}
-	resp, err := h.uploadSvc.Ask(c.Request.Context(), claims.UserID, uploadask.AskRequest{
+	return uploadask.AskRequest{
 		Query:            req.Query,
 		SessionID:        sessionID,
+		CollectionID:     collectionID,
 		DocumentIDs:      docIDs,
 		TopK:             req.TopK,
 		TopKMems:         req.TopKMems,
 		MaxHistoryTokens: req.MaxHistoryTokens,
 		IncludeHistory:   req.IncludeHistory,
-	})
-	if err != nil {
-		status := http.StatusInternalServerError
-		code := "query_failed"
-		switch {
-		case apperrors.IsCode(err, "invalid_input"):
-			status = http.StatusBadRequest
-			code = "invalid_request"
-		case apperrors.IsCode(err, "unauthorized"):
-			status = http.StatusUnauthorized
-			code = "unauthorized"
-		case apperrors.IsCode(err, "not_found"):
-			status = http.StatusNotFound
-			code = "not_found"
-		}
-		abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
-		return
+		RetrievalMode:    uploadask.RetrievalMode(req.RetrievalMode),
+		Tags:             req.Tags,
+		Metadata:         req.Metadata,
+		MimeTypes:        req.MimeTypes,
+		CreatedAfter:     req.CreatedAfter,
+		CreatedBefore:    req.CreatedBefore,
+	}, true
+}
+
+func abortWithAskError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	code := "query_failed"
+	switch {
+	case apperrors.IsCode(err, "invalid_input"):
+		status = http.StatusBadRequest
+		code = "invalid_request"
+	case apperrors.IsCode(err, "unauthorized"):
+		status = http.StatusUnauthorized
+		code = "unauthorized"
+	case apperrors.IsCode(err, "not_found"):
+		status = http.StatusNotFound
+		code = "not_found"
 	}
-	c.JSON(http.StatusOK, resp)
+	abortWithError(c, NewHTTPError(status, code, errMessage(err), err))
 }
 
 // ListSessions returns QA sessions for the current user.
@@ -239,6 +1201,51 @@ func (h *Handler) ListSessionLogs(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"logs": logs})
 }
 
+// bindDocumentFilter reads the document list filters from the query string,
+// writing a 400 response and returning false when they are invalid.
+func bindDocumentFilter(c *gin.Context) (uploadask.DocumentFilter, bool) {
+	filter := uploadask.DocumentFilter{
+		Statuses:  parseStatuses(c.Query("status")),
+		Tags:      parseListQuery(c.QueryArray("tag")),
+		MimeTypes: parseListQuery(c.QueryArray("mimeType")),
+		Metadata:  c.QueryMap("meta"),
+	}
+	var err error
+	if filter.CreatedAfter, err = parseTimeQuery(c.Query("createdAfter")); err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "createdAfter must be an RFC 3339 time", err))
+		return uploadask.DocumentFilter{}, false
+	}
+	if filter.CreatedBefore, err = parseTimeQuery(c.Query("createdBefore")); err != nil {
+		abortWithError(c, NewHTTPError(http.StatusBadRequest, "invalid_request", "createdBefore must be an RFC 3339 time", err))
+		return uploadask.DocumentFilter{}, false
+	}
+	return filter, true
+}
+
+// parseListQuery flattens repeated and comma-separated query values.
+func parseListQuery(values []string) []string {
+	var out []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+func parseTimeQuery(raw string) (*time.Time, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
 func parseStatuses(raw string) []uploadask.DocumentStatus {
 	if strings.TrimSpace(raw) == "" {
 		return nil
@@ -2,8 +2,14 @@ package unit
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -11,8 +17,13 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/yanqian/ai-helloworld/internal/domain/uploadask"
+	uploadchunker "github.com/yanqian/ai-helloworld/internal/infra/uploadask/chunker"
+	uploadextractor "github.com/yanqian/ai-helloworld/internal/infra/uploadask/extractor"
 	uploadmemory "github.com/yanqian/ai-helloworld/internal/infra/uploadask/memory"
+	uploadqueue "github.com/yanqian/ai-helloworld/internal/infra/uploadask/queue"
 	uploadrepo "github.com/yanqian/ai-helloworld/internal/infra/uploadask/repo"
+	uploadstorage "github.com/yanqian/ai-helloworld/internal/infra/uploadask/storage"
+	apperrors "github.com/yanqian/ai-helloworld/pkg/errors"
 )
 
 func TestAskSkipsMemoryWhenDisabled(t *testing.T) {
@@ -67,7 +78,7 @@ func TestAskTrimsHistoryTokens(t *testing.T) {
 	cfg.Memory.Enabled = true
 	cfg.Memory.MaxHistoryTokens = 100
 	llm := &stubLLM{response: "ok"}
-	svc := uploadask.NewService(cfg, uploadrepo.NewMemoryDocumentRepository(), uploadrepo.NewMemoryFileRepository(), chunkRepo, sessions, uploadrepo.NewMemoryQueryLogRepository(), msgLog, memStore, nil, &stubEmbedder{}, llm, nil, nil, uploadaskTestLogger())
+	svc := uploadask.NewService(cfg, uploadrepo.NewMemoryDocumentRepository(), uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunkRepo, sessions, uploadrepo.NewMemoryQueryLogRepository(), msgLog, memStore, nil, &stubEmbedder{}, llm, nil, nil, nil, nil, nil, uploadaskTestLogger())
 
 	maxTokens := 6
 	resp, err := svc.Ask(context.Background(), 7, uploadask.AskRequest{
@@ -81,6 +92,866 @@ func TestAskTrimsHistoryTokens(t *testing.T) {
 	require.Equal(t, "assistant", llm.lastMessages[len(llm.lastMessages)-2].Role)
 }
 
+func TestAskHybridRetrievalFusesKeywordMatches(t *testing.T) {
+	docID := uuid.New()
+	chunkRepo := &stubChunkRepo{
+		results: []uploadask.RetrievedChunk{
+			{Chunk: uploadask.DocumentChunk{DocumentID: docID, ChunkIndex: 0, Content: "general notes"}},
+			{Chunk: uploadask.DocumentChunk{DocumentID: docID, ChunkIndex: 1, Content: "SKU-88213 ships in May"}},
+		},
+		lexical: []uploadask.RetrievedChunk{
+			{Chunk: uploadask.DocumentChunk{DocumentID: docID, ChunkIndex: 1, Content: "SKU-88213 ships in May"}},
+		},
+	}
+	svc := newUploadService(baseUploadConfig(), chunkRepo, &stubMemoryStore{}, uploadmemory.NewMemoryMessageLog(), &stubEmbedder{}, &stubLLM{response: "May"})
+
+	resp, err := svc.Ask(context.Background(), 3, uploadask.AskRequest{Query: "When does SKU-88213 ship?", RetrievalMode: uploadask.RetrievalModeHybrid})
+	require.NoError(t, err)
+	require.Equal(t, "When does SKU-88213 ship?", chunkRepo.lastQuery)
+	require.Len(t, resp.Sources, 2)
+	require.Equal(t, 1, resp.Sources[0].ChunkIndex)
+
+	chunkRepo.lastQuery = ""
+	resp, err = svc.Ask(context.Background(), 3, uploadask.AskRequest{Query: "When does SKU-88213 ship?", RetrievalMode: uploadask.RetrievalModeVector})
+	require.NoError(t, err)
+	require.Empty(t, chunkRepo.lastQuery)
+	require.Equal(t, 0, resp.Sources[0].ChunkIndex)
+
+	_, err = svc.Ask(context.Background(), 3, uploadask.AskRequest{Query: "When?", RetrievalMode: "fuzzy"})
+	require.True(t, apperrors.IsCode(err, "invalid_input"))
+}
+
+type stubReranker struct {
+	scores []float64
+	err    error
+}
+
+func (s stubReranker) Rerank(ctx context.Context, query string, candidates []uploadask.RetrievedChunk) ([]float64, error) {
+	return s.scores, s.err
+}
+
+func TestAskRerankReordersAndReportsBothScores(t *testing.T) {
+	docID := uuid.New()
+	chunkRepo := &stubChunkRepo{results: []uploadask.RetrievedChunk{
+		{Chunk: uploadask.DocumentChunk{DocumentID: docID, ChunkIndex: 0}, Score: 0.9},
+		{Chunk: uploadask.DocumentChunk{DocumentID: docID, ChunkIndex: 1}, Score: 0.8},
+		{Chunk: uploadask.DocumentChunk{DocumentID: docID, ChunkIndex: 2}, Score: 0.7},
+	}}
+	cfg := baseUploadConfig()
+	cfg.RerankCandidates = 3
+	newService := func(reranker uploadask.Reranker) *uploadask.Service {
+		return uploadask.NewService(cfg, uploadrepo.NewMemoryDocumentRepository(), uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunkRepo, uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), &stubMemoryStore{}, nil, &stubEmbedder{}, &stubLLM{}, reranker, nil, nil, nil, nil, uploadaskTestLogger())
+	}
+
+	resp, err := newService(stubReranker{scores: []float64{0.1, 0.2, 0.95}}).Ask(context.Background(), 5, uploadask.AskRequest{Query: "q", TopK: 2, RetrievalMode: uploadask.RetrievalModeVector})
+	require.NoError(t, err)
+	require.Len(t, resp.Sources, 2)
+	require.Equal(t, 2, resp.Sources[0].ChunkIndex)
+	require.Equal(t, 0.7, resp.Sources[0].Score)
+	require.NotNil(t, resp.Sources[0].RerankScore)
+	require.Equal(t, 0.95, *resp.Sources[0].RerankScore)
+
+	resp, err = newService(stubReranker{err: errors.New("model down")}).Ask(context.Background(), 5, uploadask.AskRequest{Query: "q", TopK: 2, RetrievalMode: uploadask.RetrievalModeVector})
+	require.NoError(t, err)
+	require.Equal(t, 0, resp.Sources[0].ChunkIndex)
+	require.Nil(t, resp.Sources[0].RerankScore)
+}
+
+func TestAskStreamEmitsSourcesDeltasThenDone(t *testing.T) {
+	docID := uuid.New()
+	chunkRepo := &stubChunkRepo{results: []uploadask.RetrievedChunk{{Chunk: uploadask.DocumentChunk{DocumentID: docID, Content: "context"}, Score: 0.5}}}
+	msgLog := uploadmemory.NewMemoryMessageLog()
+	llm := &stubLLM{deltas: []string{"Hello", " world"}}
+	svc := newUploadService(baseUploadConfig(), chunkRepo, &stubMemoryStore{}, msgLog, &stubEmbedder{}, llm)
+
+	stream, err := svc.AskStream(context.Background(), 9, uploadask.AskRequest{Query: "Greeting?", RetrievalMode: uploadask.RetrievalModeVector})
+	require.NoError(t, err)
+	var events []uploadask.AskStreamEvent
+	for event := range stream {
+		events = append(events, event)
+	}
+
+	require.Len(t, events, 4)
+	require.Equal(t, uploadask.AskStreamEventSources, events[0].Event)
+	require.Equal(t, docID, events[0].Data.(uploadask.AskStreamSources).Sources[0].DocumentID)
+	require.Equal(t, uploadask.AskStreamDelta{Delta: "Hello"}, events[1].Data)
+	require.Equal(t, uploadask.AskStreamDelta{Delta: " world"}, events[2].Data)
+	require.Equal(t, uploadask.AskStreamEventDone, events[3].Event)
+	done := events[3].Data.(uploadask.AskStreamDone)
+	require.NotZero(t, done.SessionID)
+
+	msgs, err := msgLog.ListRecent(context.Background(), 9, done.SessionID, 1000, 10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, "Hello world", msgs[1].Content)
+}
+
+func TestAskStreamFallsBackWhenLLMFails(t *testing.T) {
+	llm := &stubLLM{streamErr: errors.New("upstream closed")}
+	svc := newUploadService(baseUploadConfig(), &stubChunkRepo{}, &stubMemoryStore{}, uploadmemory.NewMemoryMessageLog(), &stubEmbedder{}, llm)
+
+	stream, err := svc.AskStream(context.Background(), 9, uploadask.AskRequest{Query: "Anything?"})
+	require.NoError(t, err)
+	var deltas []string
+	for event := range stream {
+		if delta, ok := event.Data.(uploadask.AskStreamDelta); ok {
+			deltas = append(deltas, delta.Delta)
+		}
+	}
+	require.Equal(t, []string{"No relevant context available to answer this question."}, deltas)
+
+	_, err = svc.AskStream(context.Background(), 9, uploadask.AskRequest{Query: " "})
+	require.True(t, apperrors.IsCode(err, "invalid_input"))
+}
+
+func TestProcessDocumentFailsUnsupportedFileType(t *testing.T) {
+	ctx := context.Background()
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	svc := uploadask.NewService(baseUploadConfig(), docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), uploadrepo.NewMemoryChunkRepository(docs), uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), &stubEmbedder{}, &stubLLM{}, nil, nil, uploadextractor.NewRegistry(0), nil, nil, uploadaskTestLogger())
+
+	upload, err := svc.Upload(ctx, 7, uploadask.UploadRequest{
+		Filename: "photo.png",
+		Content:  strings.NewReader("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+	})
+	require.NoError(t, err)
+
+	err = svc.ProcessDocument(ctx, upload.Document.ID, 7)
+	require.True(t, apperrors.IsCode(err, "unsupported_file_type"))
+	doc, found, err := docs.Get(ctx, upload.Document.ID, 7)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, uploadask.DocumentStatusFailed, doc.Status)
+	require.NotNil(t, doc.FailureReason)
+	require.Equal(t, "unsupported file type: image/png", *doc.FailureReason)
+}
+
+func TestReindexRebuildsStaleChunksWithCurrentModel(t *testing.T) {
+	ctx := context.Background()
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	files := uploadrepo.NewMemoryFileRepository()
+	chunks := uploadrepo.NewMemoryChunkRepository(docs)
+	storage := uploadstorage.NewMemoryStorage()
+	queue := &recordingQueue{}
+	newService := func(model, version string) *uploadask.Service {
+		cfg := baseUploadConfig()
+		cfg.EmbeddingModel = model
+		cfg.IndexVersion = version
+		return uploadask.NewService(cfg, docs, files, uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunks, uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), storage, &stubEmbedder{}, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(50, 0), nil, nil, queue, uploadaskTestLogger())
+	}
+
+	old := newService("model-a", "v1")
+	upload, err := old.Upload(ctx, 7, uploadask.UploadRequest{Filename: "notes.txt", Content: strings.NewReader("Reindexing keeps chunks fresh.")})
+	require.NoError(t, err)
+	docID := upload.Document.ID
+	require.NoError(t, old.ProcessDocument(ctx, docID, 7))
+	queued, err := old.QueueReindexAll(ctx, 7, true)
+	require.NoError(t, err)
+	require.Zero(t, queued)
+
+	current := newService("model-b", "v2")
+	queued, err = current.QueueReindexAll(ctx, 7, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, queued)
+	require.Equal(t, uploadask.ReindexJobName, queue.jobs[len(queue.jobs)-1])
+
+	require.NoError(t, current.ReindexDocument(ctx, docID, 7))
+	stale, err := chunks.ListStaleDocuments(ctx, 7, "model-b", "v2")
+	require.NoError(t, err)
+	require.Empty(t, stale)
+	results, err := chunks.SearchSimilar(ctx, 7, []float32{1, 0, 0}, uploadask.DocumentFilter{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "model-b", results[0].Chunk.EmbeddingModel)
+	require.Equal(t, "v2", results[0].Chunk.IndexVersion)
+	doc, _, err := docs.Get(ctx, docID, 7)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.DocumentStatusProcessed, doc.Status)
+
+	err = current.QueueReindex(ctx, 8, docID)
+	require.True(t, apperrors.IsCode(err, "not_found"))
+}
+
+func TestProcessDocumentReportsProgressPerEmbedBatch(t *testing.T) {
+	ctx := context.Background()
+	docs := &recordingDocRepo{MemoryDocumentRepository: uploadrepo.NewMemoryDocumentRepository()}
+	cfg := baseUploadConfig()
+	cfg.EmbedBatchSize = 2
+	svc := uploadask.NewService(cfg, docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), uploadrepo.NewMemoryChunkRepository(docs), uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), &stubEmbedder{}, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(4, 0), nil, nil, nil, uploadaskTestLogger())
+
+	upload, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "long.txt", Content: strings.NewReader("one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty")})
+	require.NoError(t, err)
+	require.NoError(t, svc.ProcessDocument(ctx, upload.Document.ID, 7))
+
+	doc, err := svc.GetDocument(ctx, 7, upload.Document.ID)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.DocumentStatusProcessed, doc.Status)
+	require.NotNil(t, doc.Progress)
+	require.Equal(t, uploadask.ProcessingStagePersist, doc.Progress.Stage)
+	total := doc.Progress.ChunksTotal
+	require.Greater(t, total, 2)
+	require.Equal(t, total, doc.Progress.ChunksDone)
+
+	expected := []int{0}
+	for done := 2; done < total; done += 2 {
+		expected = append(expected, done)
+	}
+	expected = append(expected, total)
+	var embedDone []int
+	for _, p := range docs.updates {
+		if p.Stage == uploadask.ProcessingStageEmbed && p.ChunksTotal > 0 {
+			embedDone = append(embedDone, p.ChunksDone)
+		}
+	}
+	require.Equal(t, expected, embedDone)
+
+	var stages []uploadask.ProcessingStage
+	for _, timing := range doc.Progress.Stages {
+		stages = append(stages, timing.Stage)
+		require.NotNil(t, timing.FinishedAt)
+		require.False(t, timing.FinishedAt.Before(timing.StartedAt))
+	}
+	require.Equal(t, []uploadask.ProcessingStage{
+		uploadask.ProcessingStageExtract,
+		uploadask.ProcessingStageChunk,
+		uploadask.ProcessingStageEmbed,
+		uploadask.ProcessingStagePersist,
+	}, stages)
+}
+
+func TestRecoverStuckDocumentsRequeuesThenFails(t *testing.T) {
+	ctx := context.Background()
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	queue := &recordingQueue{}
+	cfg := baseUploadConfig()
+	cfg.RecoveryStaleAfter = time.Minute
+	cfg.RecoveryMaxAttempts = 1
+	svc := uploadask.NewService(cfg, docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), uploadrepo.NewMemoryChunkRepository(docs), uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), &stubEmbedder{}, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(4, 0), nil, nil, queue, uploadaskTestLogger())
+
+	old := time.Now().Add(-time.Hour)
+	stuck := uploadask.Document{ID: uuid.New(), UserID: 7, Title: "stuck", Source: uploadask.DocumentSourceUpload, Status: uploadask.DocumentStatusProcessing, CreatedAt: old, UpdatedAt: old}
+	fresh := uploadask.Document{ID: uuid.New(), UserID: 7, Title: "fresh", Source: uploadask.DocumentSourceUpload, Status: uploadask.DocumentStatusPending, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	done := uploadask.Document{ID: uuid.New(), UserID: 8, Title: "done", Source: uploadask.DocumentSourceUpload, Status: uploadask.DocumentStatusProcessed, CreatedAt: old, UpdatedAt: old}
+	for _, doc := range []uploadask.Document{stuck, fresh, done} {
+		require.NoError(t, docs.Create(ctx, doc))
+	}
+
+	result, err := svc.RecoverStuckDocuments(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.RecoveryResult{Requeued: 1}, result)
+	require.Equal(t, []string{uploadask.ProcessJobName}, queue.jobs)
+
+	// The requeued job never finished; once it is stale again the document
+	// has used its only attempt and is failed.
+	stuckAgain, _, err := docs.Get(ctx, stuck.ID, 7)
+	require.NoError(t, err)
+	stuckAgain.UpdatedAt = old
+	require.NoError(t, docs.Create(ctx, stuckAgain))
+
+	result, err = svc.RecoverStuckDocuments(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.RecoveryResult{Failed: 1}, result)
+	require.Len(t, queue.jobs, 1)
+	failed, _, err := docs.Get(ctx, stuck.ID, 7)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.DocumentStatusFailed, failed.Status)
+	require.NotNil(t, failed.FailureReason)
+}
+
+func TestRecoverStuckDocumentsSkipsDocumentsWithLiveJobs(t *testing.T) {
+	ctx := context.Background()
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	old := time.Now().Add(-time.Hour)
+	waiting := uploadask.Document{ID: uuid.New(), UserID: 7, Title: "waiting", Source: uploadask.DocumentSourceUpload, Status: uploadask.DocumentStatusPending, CreatedAt: old, UpdatedAt: old}
+	orphaned := uploadask.Document{ID: uuid.New(), UserID: 7, Title: "orphaned", Source: uploadask.DocumentSourceUpload, Status: uploadask.DocumentStatusProcessing, CreatedAt: old, UpdatedAt: old}
+	for _, doc := range []uploadask.Document{waiting, orphaned} {
+		require.NoError(t, docs.Create(ctx, doc))
+	}
+	queue := &inspectableQueue{live: []uploadask.Job{{
+		ID:      uuid.New(),
+		Name:    uploadask.ProcessJobName,
+		Payload: map[string]any{"document_id": waiting.ID.String(), "user_id": float64(7)},
+		State:   uploadask.JobStateQueued,
+	}}}
+	cfg := baseUploadConfig()
+	cfg.RecoveryStaleAfter = time.Minute
+	cfg.RecoveryMaxAttempts = 1
+	svc := uploadask.NewService(cfg, docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), uploadrepo.NewMemoryChunkRepository(docs), uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), &stubEmbedder{}, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(4, 0), nil, nil, queue, uploadaskTestLogger())
+
+	result, err := svc.RecoverStuckDocuments(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.RecoveryResult{Requeued: 1}, result, "only the orphaned document is requeued")
+	require.Len(t, queue.jobs, 1)
+
+	// The waiting document is past its recovery budget if sweeps counted it.
+	_, err = svc.RecoverStuckDocuments(ctx)
+	require.NoError(t, err)
+	stillWaiting, _, err := docs.Get(ctx, waiting.ID, 7)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.DocumentStatusPending, stillWaiting.Status, "a queued job must not be failed by recovery")
+}
+
+func TestUploadReusesDocumentWithSameContent(t *testing.T) {
+	ctx := context.Background()
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	queue := &recordingQueue{}
+	svc := uploadask.NewService(baseUploadConfig(), docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), uploadrepo.NewMemoryChunkRepository(docs), uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), &stubEmbedder{}, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(50, 0), nil, nil, queue, uploadaskTestLogger())
+	content := "Same bytes, different filename."
+
+	first, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "a.txt", Content: strings.NewReader(content)})
+	require.NoError(t, err)
+	require.False(t, first.Duplicate)
+
+	again, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "b.txt", Content: strings.NewReader(content)})
+	require.NoError(t, err)
+	require.True(t, again.Duplicate)
+	require.Equal(t, first.Document.ID, again.Document.ID)
+	require.Len(t, queue.jobs, 1)
+
+	// Another user's identical file is not shared.
+	other, err := svc.Upload(ctx, 8, uploadask.UploadRequest{Filename: "a.txt", Content: strings.NewReader(content)})
+	require.NoError(t, err)
+	require.False(t, other.Duplicate)
+	require.NotEqual(t, first.Document.ID, other.Document.ID)
+
+	// A failed document is processed afresh instead of being reused.
+	reason := "boom"
+	require.NoError(t, docs.UpdateStatus(ctx, first.Document.ID, uploadask.DocumentStatusFailed, &reason))
+	retry, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "a.txt", Content: strings.NewReader(content)})
+	require.NoError(t, err)
+	require.False(t, retry.Duplicate)
+	require.NotEqual(t, first.Document.ID, retry.Document.ID)
+	require.Len(t, queue.jobs, 3)
+}
+
+func TestProcessDocumentRetriesRateLimitedEmbeddingBatch(t *testing.T) {
+	ctx := context.Background()
+	cfg := baseUploadConfig()
+	cfg.EmbedBatchSize = 2
+	cfg.EmbedConcurrency = 2
+	cfg.EmbedBaseBackoff = time.Millisecond
+	embedder := &flakyEmbedder{fail: func(call int) error {
+		if call == 1 {
+			return &uploadask.RateLimitError{RetryAfter: 5 * time.Millisecond, Err: errors.New("429")}
+		}
+		return nil
+	}}
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	chunks := uploadrepo.NewMemoryChunkRepository(docs)
+	svc := uploadask.NewService(cfg, docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunks, uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), embedder, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(4, 0), nil, nil, nil, uploadaskTestLogger())
+
+	upload, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "long.txt", Content: strings.NewReader("one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen")})
+	require.NoError(t, err)
+	require.NoError(t, svc.ProcessDocument(ctx, upload.Document.ID, 7))
+
+	doc, err := svc.GetDocument(ctx, 7, upload.Document.ID)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.DocumentStatusProcessed, doc.Status)
+	stored, err := chunks.ListByDocument(ctx, upload.Document.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, doc.Progress.ChunksTotal)
+	require.Equal(t, len(stored), embedder.embedded(), "only the rate limited batch is sent twice")
+	require.Equal(t, (len(stored)+1)/2+1, embedder.calls)
+}
+
+func TestProcessDocumentStartsOverWhenStoredChunksAreStale(t *testing.T) {
+	ctx := context.Background()
+	cfg := baseUploadConfig()
+	cfg.EmbedBatchSize = 2
+	embedder := &flakyEmbedder{fail: func(int) error { return nil }}
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	chunks := uploadrepo.NewMemoryChunkRepository(docs)
+	// Without a handler the upload's own job is dropped, leaving the
+	// document pending until the job below runs.
+	queue := uploadqueue.NewImmediateQueue(nil)
+	svc := uploadask.NewService(cfg, docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunks, uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), embedder, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(4, 0), nil, nil, queue, uploadaskTestLogger())
+
+	upload, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "long.txt", Content: strings.NewReader("one two three four five six seven eight nine ten eleven twelve")})
+	require.NoError(t, err)
+	// A previous run stored chunk 0 from a different version of the text.
+	require.NoError(t, chunks.InsertBatch(ctx, []uploadask.DocumentChunk{{ID: uuid.New(), DocumentID: upload.Document.ID, ChunkIndex: 0, Content: "outdated text", Embedding: []float32{1, 0, 0}}}))
+
+	queue.SetHandler(func(ctx context.Context, name string, payload map[string]any) error {
+		return svc.ProcessDocument(ctx, upload.Document.ID, 7)
+	})
+	require.NoError(t, queue.Enqueue(ctx, uploadask.ProcessJobName, map[string]any{"document_id": upload.Document.ID.String()}))
+	queue.Close()
+
+	doc, err := svc.GetDocument(ctx, 7, upload.Document.ID)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.DocumentStatusProcessed, doc.Status, "the job re-embeds instead of leaving the document processing")
+	stored, err := chunks.ListByDocument(ctx, upload.Document.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, stored)
+	for i, chunk := range stored {
+		require.Equal(t, i, chunk.ChunkIndex)
+		require.NotEqual(t, "outdated text", chunk.Content)
+	}
+}
+
+func TestProcessDocumentResumesFromStoredBatches(t *testing.T) {
+	ctx := context.Background()
+	cfg := baseUploadConfig()
+	cfg.EmbedBatchSize = 2
+	cfg.EmbedConcurrency = 1
+	cfg.EmbedMaxAttempts = 2
+	cfg.EmbedBaseBackoff = time.Millisecond
+	broken := true
+	embedder := &flakyEmbedder{fail: func(call int) error {
+		if broken && call > 2 {
+			return errors.New("provider unavailable")
+		}
+		return nil
+	}}
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	chunks := uploadrepo.NewMemoryChunkRepository(docs)
+	svc := uploadask.NewService(cfg, docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunks, uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), embedder, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(4, 0), nil, nil, nil, uploadaskTestLogger())
+
+	upload, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "long.txt", Content: strings.NewReader("one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty")})
+	require.NoError(t, err)
+	err = svc.ProcessDocument(ctx, upload.Document.ID, 7)
+	require.True(t, apperrors.IsCode(err, "embedding_error"))
+	doc, err := svc.GetDocument(ctx, 7, upload.Document.ID)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.DocumentStatusFailed, doc.Status)
+	require.Equal(t, 4, doc.Progress.ChunksDone)
+	total := doc.Progress.ChunksTotal
+	stored, err := chunks.ListByDocument(ctx, upload.Document.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, 4)
+	require.Equal(t, 4, embedder.calls, "two batches stored, the third tried twice")
+
+	broken = false
+	embedder.texts = nil
+	require.NoError(t, svc.ProcessDocument(ctx, upload.Document.ID, 7))
+	doc, err = svc.GetDocument(ctx, 7, upload.Document.ID)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.DocumentStatusProcessed, doc.Status)
+	require.Equal(t, total-4, embedder.embedded(), "stored batches are not embedded again")
+	stored, err = chunks.ListByDocument(ctx, upload.Document.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, total)
+	for i, chunk := range stored {
+		require.Equal(t, i, chunk.ChunkIndex)
+	}
+}
+
+func TestUploadRejectsOversizeStreamWithoutStoringIt(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	storage, err := uploadstorage.NewLocalStorage(root, "", nil)
+	require.NoError(t, err)
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	cfg := baseUploadConfig()
+	cfg.MaxFileBytes = 16
+	svc := uploadask.NewService(cfg, docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), uploadrepo.NewMemoryChunkRepository(docs), uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), storage, &stubEmbedder{}, &stubLLM{}, nil, nil, nil, nil, nil, uploadaskTestLogger())
+
+	_, err = svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "big.txt", Content: strings.NewReader(strings.Repeat("x", 64))})
+	require.True(t, apperrors.IsCode(err, "invalid_input"))
+	require.ErrorContains(t, err, "file exceeds maximum allowed size")
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	require.Empty(t, entries, "the partial blob is removed")
+	listed, err := svc.ListDocuments(ctx, 7, uploadask.DocumentFilter{})
+	require.NoError(t, err)
+	require.Empty(t, listed)
+
+	_, err = svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "fits.txt", Content: strings.NewReader(strings.Repeat("x", 16))})
+	require.NoError(t, err)
+}
+
+func TestDirectUploadIsConfirmedAgainstStoredObject(t *testing.T) {
+	ctx := context.Background()
+	storage, err := uploadstorage.NewLocalStorage(t.TempDir(), "/direct", []byte("secret"))
+	require.NoError(t, err)
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	queue := &recordingQueue{}
+	cfg := baseUploadConfig()
+	cfg.MaxFileBytes = 16
+	svc := uploadask.NewService(cfg, docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), uploadrepo.NewMemoryChunkRepository(docs), uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), storage, &stubEmbedder{}, &stubLLM{}, nil, nil, nil, nil, queue, uploadaskTestLogger())
+
+	_, err = svc.CreateUploadIntent(ctx, 7, uploadask.UploadIntentRequest{Filename: "big.txt", SizeBytes: 17})
+	require.True(t, apperrors.IsCode(err, "invalid_input"))
+	created, err := svc.CreateUploadIntent(ctx, 7, uploadask.UploadIntentRequest{Filename: "notes.md", SizeBytes: 5})
+	require.NoError(t, err)
+	require.Equal(t, "text/markdown", created.Intent.MimeType)
+	signed, err := url.Parse(created.Upload.URL)
+	require.NoError(t, err)
+	key := strings.TrimPrefix(signed.Path, "/direct/")
+
+	_, err = svc.ConfirmUploadIntent(ctx, 7, created.Intent.ID, "etag")
+	require.True(t, apperrors.IsCode(err, "invalid_input"), "nothing was uploaded yet")
+	_, err = svc.ReceiveDirectUpload(ctx, key, url.Values{}, strings.NewReader("hello"), "text/plain")
+	require.True(t, apperrors.IsCode(err, "forbidden"))
+	_, err = svc.ReceiveDirectUpload(ctx, key, signed.Query(), strings.NewReader("hello!"), "text/plain")
+	require.True(t, apperrors.IsCode(err, "invalid_input"), "the body may not exceed the intent's size")
+	_, err = svc.ReceiveDirectUpload(ctx, "uploads/7/other/notes.md", signed.Query(), strings.NewReader("hello"), "text/plain")
+	require.True(t, apperrors.IsCode(err, "forbidden"), "keys without an intent are rejected")
+
+	obj, err := svc.ReceiveDirectUpload(ctx, key, signed.Query(), strings.NewReader("hell"), "text/plain")
+	require.NoError(t, err)
+	_, err = svc.ConfirmUploadIntent(ctx, 7, created.Intent.ID, obj.ETag)
+	require.ErrorContains(t, err, "uploaded file is 4 bytes, expected 5")
+
+	obj, err = svc.ReceiveDirectUpload(ctx, key, signed.Query(), strings.NewReader("hello"), "text/plain")
+	require.NoError(t, err)
+	_, err = svc.ConfirmUploadIntent(ctx, 7, created.Intent.ID, `"0123"`)
+	require.ErrorContains(t, err, "etag does not match")
+	_, err = svc.ConfirmUploadIntent(ctx, 8, created.Intent.ID, obj.ETag)
+	require.True(t, apperrors.IsCode(err, "not_found"))
+
+	confirmed, err := svc.ConfirmUploadIntent(ctx, 7, created.Intent.ID, `"`+obj.ETag+`"`)
+	require.NoError(t, err)
+	require.Equal(t, created.Intent.ID, confirmed.Document.ID)
+	require.Equal(t, uploadask.DocumentStatusPending, confirmed.Document.Status)
+	require.Equal(t, []string{uploadask.ProcessJobName}, queue.jobs)
+
+	again, err := svc.ConfirmUploadIntent(ctx, 7, created.Intent.ID, obj.ETag)
+	require.NoError(t, err)
+	require.Equal(t, confirmed.Document.ID, again.Document.ID)
+	require.Len(t, queue.jobs, 1, "confirming twice enqueues once")
+
+	_, err = svc.ReceiveDirectUpload(ctx, key, signed.Query(), strings.NewReader("swap!"), "text/plain")
+	require.True(t, apperrors.IsCode(err, "forbidden"), "a confirmed upload cannot be replaced: %v", err)
+}
+
+func TestDirectUploadUnavailableWithoutPresigningStorage(t *testing.T) {
+	svc := newUploadService(baseUploadConfig(), &stubChunkRepo{}, &stubMemoryStore{}, uploadmemory.NewMemoryMessageLog(), &stubEmbedder{}, &stubLLM{})
+	_, err := svc.CreateUploadIntent(context.Background(), 7, uploadask.UploadIntentRequest{Filename: "notes.txt", SizeBytes: 5})
+	require.True(t, apperrors.IsCode(err, "direct_upload_unavailable"))
+}
+
+func TestUploadEnforcesPerUserQuotas(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	storage, err := uploadstorage.NewLocalStorage(root, "", nil)
+	require.NoError(t, err)
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	cfg := baseUploadConfig()
+	cfg.MaxFileBytes = 64
+	cfg.Quota = uploadask.QuotaConfig{MaxBytes: 20, MaxDocuments: 2}
+	svc := uploadask.NewService(cfg, docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), uploadrepo.NewMemoryChunkRepository(docs), uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), storage, &stubEmbedder{}, &stubLLM{}, nil, nil, nil, nil, nil, uploadaskTestLogger())
+
+	_, err = svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "a.txt", Content: strings.NewReader(strings.Repeat("a", 12))})
+	require.NoError(t, err)
+	_, err = svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "b.txt", Content: strings.NewReader(strings.Repeat("b", 9))})
+	require.True(t, apperrors.IsCode(err, "quota_exceeded"), "12 + 9 bytes is over the 20 byte quota")
+	_, err = svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "b.txt", Content: strings.NewReader(strings.Repeat("b", 8))})
+	require.NoError(t, err)
+
+	report, err := svc.Usage(ctx, 7)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.StorageUsage{Documents: 2, Bytes: 20}, report.Used)
+	require.Equal(t, uploadask.StorageUsage{Documents: 2, Bytes: 20}, report.Limits)
+
+	_, err = svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "c.txt", Content: strings.NewReader("c")})
+	require.True(t, apperrors.IsCode(err, "quota_exceeded"))
+	require.ErrorContains(t, err, "document limit of 2 reached")
+	_, err = svc.Upload(ctx, 8, uploadask.UploadRequest{Filename: "c.txt", Content: strings.NewReader("c")})
+	require.NoError(t, err, "quotas are per user")
+
+	listed, err := svc.ListDocuments(ctx, 7, uploadask.DocumentFilter{})
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	require.NoError(t, svc.DeleteDocument(ctx, 7, listed[0].ID))
+	_, err = svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "c.txt", Content: strings.NewReader("c")})
+	require.NoError(t, err, "deleting a document frees its share")
+}
+
+func TestAskScopesRetrievalByDocumentLabels(t *testing.T) {
+	ctx := context.Background()
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	chunks := uploadrepo.NewMemoryChunkRepository(docs)
+	created := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	policy := uuid.New()
+	notes := uuid.New()
+	for i, doc := range []uploadask.Document{
+		{ID: policy, UserID: 5, Title: "policy", MimeType: "application/pdf", CreatedAt: created},
+		{ID: notes, UserID: 5, Title: "notes", MimeType: "text/plain", CreatedAt: created.AddDate(0, 1, 0)},
+	} {
+		doc.Source = uploadask.DocumentSourceUpload
+		doc.Status = uploadask.DocumentStatusProcessed
+		require.NoError(t, docs.Create(ctx, doc))
+		require.NoError(t, chunks.InsertBatch(ctx, []uploadask.DocumentChunk{{ID: uuid.New(), DocumentID: doc.ID, ChunkIndex: i, Content: doc.Title, Embedding: []float32{1, 0, 0}}}))
+	}
+	svc := uploadask.NewService(baseUploadConfig(), docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunks, uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), nil, &stubEmbedder{}, &stubLLM{}, nil, nil, nil, nil, nil, uploadaskTestLogger())
+
+	tags := []string{" HR-Policy", "2026", "hr-policy"}
+	year := "2026"
+	doc, err := svc.UpdateDocumentLabels(ctx, 5, policy, uploadask.DocumentLabelsUpdate{Tags: &tags, Metadata: map[string]*string{"year": &year, "team": &year}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"2026", "hr-policy"}, doc.Tags)
+	doc, err = svc.UpdateDocumentLabels(ctx, 5, policy, uploadask.DocumentLabelsUpdate{Metadata: map[string]*string{"team": nil}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"2026", "hr-policy"}, doc.Tags, "tags are kept when not sent")
+	require.Equal(t, map[string]string{"year": "2026"}, doc.Metadata)
+	otherTags := []string{"2026"}
+	_, err = svc.UpdateDocumentLabels(ctx, 5, notes, uploadask.DocumentLabelsUpdate{Tags: &otherTags})
+	require.NoError(t, err)
+
+	sourceDocs := func(req uploadask.AskRequest) []uuid.UUID {
+		t.Helper()
+		req.Query = "what is the policy?"
+		resp, err := svc.Ask(ctx, 5, req)
+		require.NoError(t, err)
+		var ids []uuid.UUID
+		for _, source := range resp.Sources {
+			ids = append(ids, source.DocumentID)
+		}
+		return ids
+	}
+	require.ElementsMatch(t, []uuid.UUID{policy, notes}, sourceDocs(uploadask.AskRequest{Tags: []string{"2026"}}))
+	require.Equal(t, []uuid.UUID{policy}, sourceDocs(uploadask.AskRequest{Tags: []string{"2026", "HR-POLICY"}}))
+	require.Equal(t, []uuid.UUID{policy}, sourceDocs(uploadask.AskRequest{Metadata: map[string]string{"year": "2026"}}))
+	require.Equal(t, []uuid.UUID{notes}, sourceDocs(uploadask.AskRequest{MimeTypes: []string{"text/plain"}}))
+	after := created.AddDate(0, 0, 15)
+	require.Equal(t, []uuid.UUID{notes}, sourceDocs(uploadask.AskRequest{CreatedAfter: &after}))
+	require.Equal(t, []uuid.UUID{policy}, sourceDocs(uploadask.AskRequest{CreatedBefore: &after}))
+
+	counts, err := svc.ListTags(ctx, 5)
+	require.NoError(t, err)
+	require.Equal(t, []uploadask.TagCount{{Tag: "2026", Documents: 2}, {Tag: "hr-policy", Documents: 1}}, counts)
+
+	bad := []string{"has space"}
+	_, err = svc.UpdateDocumentLabels(ctx, 5, policy, uploadask.DocumentLabelsUpdate{Tags: &bad})
+	require.True(t, apperrors.IsCode(err, "invalid_input"))
+	_, err = svc.UpdateDocumentLabels(ctx, 6, policy, uploadask.DocumentLabelsUpdate{Tags: &otherTags})
+	require.True(t, apperrors.IsCode(err, "not_found"))
+}
+
+func TestCollectionBoundSessionRetrievesOnlyFromCollection(t *testing.T) {
+	ctx := context.Background()
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	chunks := uploadrepo.NewMemoryChunkRepository(docs)
+	handbook := uuid.New()
+	policy := uuid.New()
+	notes := uuid.New()
+	for i, id := range []uuid.UUID{handbook, policy, notes} {
+		require.NoError(t, docs.Create(ctx, uploadask.Document{ID: id, UserID: 5, Title: id.String(), Source: uploadask.DocumentSourceUpload, Status: uploadask.DocumentStatusProcessed}))
+		require.NoError(t, chunks.InsertBatch(ctx, []uploadask.DocumentChunk{{ID: uuid.New(), DocumentID: id, ChunkIndex: i, Content: "leave rules", Embedding: []float32{1, 0, 0}}}))
+	}
+	require.NoError(t, docs.Create(ctx, uploadask.Document{ID: uuid.New(), UserID: 6, Title: "foreign", Source: uploadask.DocumentSourceUpload, Status: uploadask.DocumentStatusProcessed}))
+	svc := uploadask.NewService(baseUploadConfig(), docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunks, uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), nil, &stubEmbedder{}, &stubLLM{}, nil, nil, nil, nil, nil, uploadaskTestLogger())
+
+	_, err := svc.CreateCollection(ctx, 5, "  ", "")
+	require.True(t, apperrors.IsCode(err, "invalid_input"))
+	hr, err := svc.CreateCollection(ctx, 5, " HR ", "people policies")
+	require.NoError(t, err)
+	require.Equal(t, "HR", hr.Name)
+	hr, err = svc.AddCollectionDocuments(ctx, 5, hr.ID, []uuid.UUID{handbook, policy, handbook})
+	require.NoError(t, err)
+	require.Equal(t, 2, hr.DocumentCount)
+	_, err = svc.AddCollectionDocuments(ctx, 5, hr.ID, []uuid.UUID{uuid.New()})
+	require.True(t, apperrors.IsCode(err, "not_found"), "documents must exist and belong to the user")
+	_, err = svc.GetCollection(ctx, 6, hr.ID)
+	require.True(t, apperrors.IsCode(err, "not_found"))
+
+	sourceDocs := func(resp uploadask.AskResponse) []uuid.UUID {
+		var ids []uuid.UUID
+		for _, source := range resp.Sources {
+			ids = append(ids, source.DocumentID)
+		}
+		return ids
+	}
+	resp, err := svc.Ask(ctx, 5, uploadask.AskRequest{Query: "how much leave?", CollectionID: &hr.ID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uuid.UUID{handbook, policy}, sourceDocs(resp))
+	sessions, err := svc.ListSessions(ctx, 5)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, hr.ID, *sessions[0].CollectionID)
+
+	// Later turns stay in the collection without repeating it.
+	resp, err = svc.Ask(ctx, 5, uploadask.AskRequest{Query: "and sick leave?", SessionID: &resp.SessionID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []uuid.UUID{handbook, policy}, sourceDocs(resp))
+	resp, err = svc.Ask(ctx, 5, uploadask.AskRequest{Query: "and notes?", SessionID: &resp.SessionID, DocumentIDs: []uuid.UUID{policy, notes}})
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{policy}, sourceDocs(resp), "documentIds only narrow the collection")
+
+	other, err := svc.CreateCollection(ctx, 5, "Other", "")
+	require.NoError(t, err)
+	_, err = svc.Ask(ctx, 5, uploadask.AskRequest{Query: "switch?", SessionID: &resp.SessionID, CollectionID: &other.ID})
+	require.True(t, apperrors.IsCode(err, "invalid_input"))
+	empty, err := svc.Ask(ctx, 5, uploadask.AskRequest{Query: "anything?", CollectionID: &other.ID})
+	require.NoError(t, err)
+	require.Empty(t, empty.Sources, "an empty collection retrieves nothing")
+
+	require.NoError(t, svc.RemoveCollectionDocuments(ctx, 5, hr.ID, []uuid.UUID{policy}))
+	require.NoError(t, svc.DeleteDocument(ctx, 5, handbook))
+	hr, err = svc.GetCollection(ctx, 5, hr.ID)
+	require.NoError(t, err)
+	require.Zero(t, hr.DocumentCount)
+	listed, err := svc.ListCollectionDocuments(ctx, 5, hr.ID, uploadask.DocumentFilter{})
+	require.NoError(t, err)
+	require.Empty(t, listed)
+
+	require.NoError(t, svc.DeleteCollection(ctx, 5, hr.ID))
+	_, err = svc.Ask(ctx, 5, uploadask.AskRequest{Query: "still there?", CollectionID: &hr.ID})
+	require.True(t, apperrors.IsCode(err, "not_found"))
+	_, err = svc.GetDocument(ctx, 5, policy)
+	require.NoError(t, err, "deleting a collection keeps its documents")
+}
+
+func TestProcessDocumentFailsOverChunkQuota(t *testing.T) {
+	ctx := context.Background()
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	chunks := uploadrepo.NewMemoryChunkRepository(docs)
+	cfg := baseUploadConfig()
+	cfg.EmbedBatchSize = 1
+	cfg.Quota = uploadask.QuotaConfig{MaxChunks: 3}
+	svc := uploadask.NewService(cfg, docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunks, uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), &stubEmbedder{}, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(4, 0), nil, nil, nil, uploadaskTestLogger())
+
+	small, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "small.txt", Content: strings.NewReader("one two three four five six")})
+	require.NoError(t, err)
+	require.NoError(t, svc.ProcessDocument(ctx, small.Document.ID, 7))
+
+	large, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "large.txt", Content: strings.NewReader(strings.Repeat("word ", 40))})
+	require.NoError(t, err)
+	err = svc.ProcessDocument(ctx, large.Document.ID, 7)
+	require.True(t, apperrors.IsCode(err, "quota_exceeded"))
+	doc, err := svc.GetDocument(ctx, 7, large.Document.ID)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.DocumentStatusFailed, doc.Status)
+	require.NotNil(t, doc.FailureReason)
+	require.Contains(t, *doc.FailureReason, "chunk limit of 3")
+	stored, err := chunks.CountByDocument(ctx, large.Document.ID)
+	require.NoError(t, err)
+	require.Zero(t, stored, "chunks embedded before the limit was hit are dropped")
+
+	report, err := svc.Usage(ctx, 7)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Used.Chunks)
+	require.NoError(t, svc.ReindexDocument(ctx, small.Document.ID, 7), "a document's own chunks do not count against its reindex")
+}
+
+func TestProcessDocumentEmbedsLargeDocumentInWindows(t *testing.T) {
+	ctx := context.Background()
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	chunks := uploadrepo.NewMemoryChunkRepository(docs)
+	embedder := &flakyEmbedder{fail: func(int) error { return nil }}
+	svc := uploadask.NewService(baseUploadConfig(), docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunks, uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), embedder, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(8, 0), uploadextractor.NewRegistry(0), nil, nil, uploadaskTestLogger())
+
+	var text strings.Builder
+	for i := 0; i < 400; i++ {
+		fmt.Fprintf(&text, "Paragraph %d covers one more topic of the handbook.\n\n", i)
+	}
+	upload, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "handbook.txt", Content: strings.NewReader(text.String())})
+	require.NoError(t, err)
+	require.NoError(t, svc.ProcessDocument(ctx, upload.Document.ID, 7))
+
+	doc, err := svc.GetDocument(ctx, 7, upload.Document.ID)
+	require.NoError(t, err)
+	require.Equal(t, uploadask.DocumentStatusProcessed, doc.Status)
+	require.Greater(t, doc.Progress.ChunksTotal, 256, "the document spans several windows")
+	require.Equal(t, doc.Progress.ChunksTotal, doc.Progress.ChunksDone)
+	stored, err := chunks.ListByDocument(ctx, upload.Document.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, doc.Progress.ChunksTotal)
+	require.Equal(t, len(stored), embedder.embedded())
+	for i, chunk := range stored {
+		require.Equal(t, i, chunk.ChunkIndex)
+	}
+}
+
+func TestProcessDocumentKeepsPageNumbers(t *testing.T) {
+	ctx := context.Background()
+	docs := uploadrepo.NewMemoryDocumentRepository()
+	chunks := uploadrepo.NewMemoryChunkRepository(docs)
+	extractor := pagedExtractor{"intro", "scope", "results"}
+	svc := uploadask.NewService(baseUploadConfig(), docs, uploadrepo.NewMemoryFileRepository(), uploadrepo.NewMemoryUploadIntentRepository(), uploadrepo.NewMemoryCollectionRepository(), uploadrepo.NewMemoryShareRepository(), chunks, uploadrepo.NewMemoryQASessionRepository(), uploadrepo.NewMemoryQueryLogRepository(), uploadmemory.NewMemoryMessageLog(), uploadmemory.NewMemoryStore(), uploadstorage.NewMemoryStorage(), &stubEmbedder{}, &stubLLM{}, nil, uploadchunker.NewSimpleChunker(50, 0), extractor, nil, nil, uploadaskTestLogger())
+
+	upload, err := svc.Upload(ctx, 7, uploadask.UploadRequest{Filename: "report.pdf", Content: strings.NewReader("%PDF-1.4")})
+	require.NoError(t, err)
+	require.NoError(t, svc.ProcessDocument(ctx, upload.Document.ID, 7))
+
+	stored, err := chunks.ListByDocument(ctx, upload.Document.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	for i, chunk := range stored {
+		require.Equal(t, i, chunk.ChunkIndex)
+		require.Equal(t, i+1, chunk.PageNumber)
+		require.Equal(t, extractor[i], chunk.Content)
+	}
+}
+
+// pagedExtractor returns one page per entry, numbered from 1.
+type pagedExtractor []string
+
+func (e pagedExtractor) Extract(context.Context, string, []byte) ([]uploadask.ExtractedPage, error) {
+	pages := make([]uploadask.ExtractedPage, len(e))
+	for i, text := range e {
+		pages[i] = uploadask.ExtractedPage{Number: i + 1, Text: text}
+	}
+	return pages, nil
+}
+
+// flakyEmbedder embeds like stubEmbedder but fails the calls fail rejects.
+// Calls are numbered from 1.
+type flakyEmbedder struct {
+	mu    sync.Mutex
+	fail  func(call int) error
+	calls int
+	texts []string
+}
+
+func (e *flakyEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.calls++
+	call := e.calls
+	e.mu.Unlock()
+	if err := e.fail(call); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.texts = append(e.texts, texts...)
+	e.mu.Unlock()
+	return stubEmbedder{}.Embed(ctx, texts)
+}
+
+func (e *flakyEmbedder) embedded() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.texts)
+}
+
+type recordingDocRepo struct {
+	*uploadrepo.MemoryDocumentRepository
+	updates []uploadask.DocumentProgress
+}
+
+func (r *recordingDocRepo) UpdateProgress(ctx context.Context, docID uuid.UUID, progress uploadask.DocumentProgress) error {
+	r.updates = append(r.updates, progress)
+	return r.MemoryDocumentRepository.UpdateProgress(ctx, docID, progress)
+}
+
+type recordingQueue struct {
+	jobs []string
+}
+
+func (q *recordingQueue) Enqueue(ctx context.Context, name string, payload any) error {
+	q.jobs = append(q.jobs, name)
+	return nil
+}
+
+// inspectableQueue records enqueued jobs and reports a fixed set of live
+// jobs, like a persistent queue.
+type inspectableQueue struct {
+	recordingQueue
+	live []uploadask.Job
+}
+
+func (q *inspectableQueue) ListJobs(ctx context.Context, state uploadask.JobState, limit int) ([]uploadask.Job, error) {
+	return q.live, nil
+}
+
+func (q *inspectableQueue) RequeueJob(ctx context.Context, id uuid.UUID) (bool, error) {
+	return false, nil
+}
+
+func (q *inspectableQueue) LiveJobs(ctx context.Context, name string) ([]uploadask.Job, error) {
+	return q.live, nil
+}
+
 func baseUploadConfig() uploadask.Config {
 	return uploadask.Config{
 		VectorDim:       3,
@@ -100,7 +971,9 @@ func baseUploadConfig() uploadask.Config {
 
 type stubChunkRepo struct {
 	results       []uploadask.RetrievedChunk
+	lexical       []uploadask.RetrievedChunk
 	lastEmbedding []float32
+	lastQuery     string
 }
 
 func (s *stubChunkRepo) InsertBatch(ctx context.Context, chunks []uploadask.DocumentChunk) error {
@@ -110,6 +983,27 @@ func (s *stubChunkRepo) SearchSimilar(ctx context.Context, userID int64, embeddi
 	s.lastEmbedding = append([]float32(nil), embedding...)
 	return s.results, nil
 }
+func (s *stubChunkRepo) SearchLexical(ctx context.Context, userID int64, query string, filter uploadask.DocumentFilter) ([]uploadask.RetrievedChunk, error) {
+	s.lastQuery = query
+	return s.lexical, nil
+}
+func (s *stubChunkRepo) ListByDocument(ctx context.Context, docID uuid.UUID) ([]uploadask.DocumentChunk, error) {
+	return nil, nil
+}
+func (s *stubChunkRepo) DeleteByDocument(ctx context.Context, docID uuid.UUID) error {
+	return nil
+}
+func (s *stubChunkRepo) ListStaleDocuments(ctx context.Context, userID int64, embeddingModel, indexVersion string) ([]uuid.UUID, error) {
+	return nil, nil
+}
+
+func (s *stubChunkRepo) CountByUser(ctx context.Context, userID int64) (int, error) {
+	return 0, nil
+}
+
+func (s *stubChunkRepo) CountByDocument(ctx context.Context, docID uuid.UUID) (int, error) {
+	return 0, nil
+}
 
 type stubMemoryStore struct {
 	records       []uploadask.RetrievedMemory
@@ -150,6 +1044,8 @@ func (stubEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, err
 
 type stubLLM struct {
 	response     string
+	deltas       []string
+	streamErr    error
 	lastMessages []uploadask.LLMMessage
 }
 
@@ -161,11 +1057,27 @@ func (s *stubLLM) Chat(ctx context.Context, messages []uploadask.LLMMessage) (st
 	return "stub-answer", nil
 }
 
+func (s *stubLLM) ChatStream(ctx context.Context, messages []uploadask.LLMMessage) (<-chan uploadask.LLMStreamChunk, error) {
+	s.lastMessages = messages
+	out := make(chan uploadask.LLMStreamChunk, len(s.deltas)+1)
+	for _, delta := range s.deltas {
+		out <- uploadask.LLMStreamChunk{Delta: delta}
+	}
+	if s.streamErr != nil {
+		out <- uploadask.LLMStreamChunk{Err: s.streamErr}
+	}
+	close(out)
+	return out, nil
+}
+
 func newUploadService(cfg uploadask.Config, chunkRepo uploadask.ChunkRepository, memStore uploadask.MemoryStore, msgLog uploadask.MessageLog, embedder uploadask.Embedder, llm uploadask.LLM) *uploadask.Service {
 	return uploadask.NewService(
 		cfg,
 		uploadrepo.NewMemoryDocumentRepository(),
 		uploadrepo.NewMemoryFileRepository(),
+		uploadrepo.NewMemoryUploadIntentRepository(),
+		uploadrepo.NewMemoryCollectionRepository(),
+		uploadrepo.NewMemoryShareRepository(),
 		chunkRepo,
 		uploadrepo.NewMemoryQASessionRepository(),
 		uploadrepo.NewMemoryQueryLogRepository(),
@@ -176,6 +1088,9 @@ func newUploadService(cfg uploadask.Config, chunkRepo uploadask.ChunkRepository,
 		llm,
 		nil,
 		nil,
+		nil,
+		nil,
+		nil,
 		uploadaskTestLogger(),
 	)
 }